@@ -0,0 +1,86 @@
+package mediadevices
+
+import (
+	"sync"
+
+	"github.com/pion/webrtc/v2"
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+// BufferTrack is a LocalTrack which stores the written samples in memory.
+// It's useful to test the media pipeline without PeerConnection.
+type BufferTrack struct {
+	codec *webrtc.RTPCodec
+	id    string
+
+	mu      sync.Mutex
+	samples []media.Sample
+}
+
+var _ LocalTrack = &BufferTrack{}
+
+// NewBufferTrack creates new BufferTrack. It can be used from TrackGenerator:
+//
+//	WithTrackGenerator(func(_ uint8, _ uint32, id, _ string, codec *webrtc.RTPCodec) (LocalTrack, error) {
+//		return NewBufferTrack(codec, id), nil
+//	})
+func NewBufferTrack(codec *webrtc.RTPCodec, id string) *BufferTrack {
+	return &BufferTrack{
+		codec: codec,
+		id:    id,
+	}
+}
+
+// WriteSample stores a copy of s.
+func (t *BufferTrack) WriteSample(s media.Sample) error {
+	// Sample data is only valid during the call
+	s.Data = append([]byte(nil), s.Data...)
+
+	t.mu.Lock()
+	t.samples = append(t.samples, s)
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *BufferTrack) Codec() *webrtc.RTPCodec {
+	return t.codec
+}
+
+func (t *BufferTrack) ID() string {
+	return t.id
+}
+
+func (t *BufferTrack) Kind() webrtc.RTPCodecType {
+	return t.codec.Type
+}
+
+// Len returns the number of the written samples.
+func (t *BufferTrack) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.samples)
+}
+
+// Samples returns the written samples in order.
+func (t *BufferTrack) Samples() []media.Sample {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]media.Sample(nil), t.samples...)
+}
+
+// Data returns the data of the i-th written sample.
+func (t *BufferTrack) Data(i int) []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.samples[i].Data
+}
+
+// Reset discards the written samples.
+func (t *BufferTrack) Reset() {
+	t.mu.Lock()
+	t.samples = nil
+	t.mu.Unlock()
+}
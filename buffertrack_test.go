@@ -0,0 +1,97 @@
+package mediadevices
+
+import (
+	"bytes"
+	"image"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec/raw"
+	"github.com/pion/webrtc/v2"
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+func TestBufferTrack(t *testing.T) {
+	codec := &webrtc.RTPCodec{Name: raw.Name, Type: webrtc.RTPCodecTypeVideo}
+	track := NewBufferTrack(codec, "id")
+	if track.Codec() != codec || track.ID() != "id" || track.Kind() != webrtc.RTPCodecTypeVideo {
+		t.Fatal("Track properties are not reflected")
+	}
+
+	data := []byte{0}
+	for i := 0; i < 5; i++ {
+		data[0] = byte(i)
+		// The data buffer is reused by the caller
+		if err := track.WriteSample(media.Sample{Data: data, Samples: uint32(i * 10)}); err != nil {
+			t.Fatalf("Failed to write sample: %v", err)
+		}
+	}
+
+	if n := track.Len(); n != 5 {
+		t.Fatalf("Expected 5 samples, got %d", n)
+	}
+	for i, s := range track.Samples() {
+		if !bytes.Equal(s.Data, []byte{byte(i)}) || s.Samples != uint32(i*10) {
+			t.Errorf("Unexpected sample %d: %v", i, s)
+		}
+		if !bytes.Equal(track.Data(i), []byte{byte(i)}) {
+			t.Errorf("Unexpected data %d: %v", i, track.Data(i))
+		}
+	}
+
+	track.Reset()
+	if n := track.Len(); n != 0 {
+		t.Errorf("Expected no samples after Reset, got %d", n)
+	}
+}
+
+func TestBufferTrackGetUserMedia(t *testing.T) {
+	img := &image.YCbCr{
+		Y:              []uint8{0, 1, 2, 3, 4, 5, 6, 7},
+		YStride:        4,
+		Cb:             []uint8{8, 9},
+		Cr:             []uint8{10, 11},
+		CStride:        2,
+		SubsampleRatio: image.YCbCrSubsampleRatio420,
+		Rect:           image.Rect(0, 0, 4, 2),
+	}
+	id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		return img, nil
+	}}, "TestBufferTrackGetUserMedia")
+
+	var track *BufferTrack
+	md := newMediaDevicesMock([]string{raw.Name}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		track = NewBufferTrack(codec, id)
+		return track
+	})
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = raw.Name
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+
+	timeout := time.After(time.Second)
+	for track.Len() < 3 {
+		select {
+		case <-timeout:
+			t.Fatal("Timeout")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	for _, tr := range s.GetTracks() {
+		tr.Stop()
+	}
+
+	expected := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+	for i := 0; i < 3; i++ {
+		if !bytes.Equal(expected, track.Data(i)) {
+			t.Errorf("Expected sample %d to be %v, got %v", i, expected, track.Data(i))
+		}
+	}
+}
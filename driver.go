@@ -4,7 +4,9 @@ import (
 	"github.com/pion/mediadevices/pkg/driver"
 )
 
-// RegisterDriverAdapter allows user space level of driver registration
+// RegisterDriverAdapter allows user space level of driver registration.
+// info.Priority decides the device to be selected among the devices fitting
+// the constraints equally.
 func RegisterDriverAdapter(a driver.Adapter, info driver.Info) error {
 	return driver.GetManager().Register(a, info)
 }
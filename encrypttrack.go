@@ -0,0 +1,46 @@
+package mediadevices
+
+import (
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+// FrameEncryptor encrypts the encoded frames before they are written to the
+// LocalTrack, e.g. to implement end-to-end encryption like SFrame. The receiver
+// is responsible for decrypting the frames.
+type FrameEncryptor interface {
+	// Encrypt returns the encrypted frame. frame is only valid during the call,
+	// so the returned frame must not share the memory with it unless frame is
+	// modified in place.
+	Encrypt(frame []byte) ([]byte, error)
+}
+
+// encryptTrack is a LocalTrack which encrypts the samples before writing them
+// to the inner track.
+type encryptTrack struct {
+	LocalTrack
+	encryptor FrameEncryptor
+}
+
+func newEncryptTrack(t LocalTrack, encryptor FrameEncryptor) LocalTrack {
+	if encryptor == nil {
+		return t
+	}
+	return &encryptTrack{
+		LocalTrack: t,
+		encryptor:  encryptor,
+	}
+}
+
+func (t *encryptTrack) WriteSample(s media.Sample) error {
+	data, err := t.encryptor.Encrypt(s.Data)
+	if err != nil {
+		return err
+	}
+	s.Data = data
+	return t.LocalTrack.WriteSample(s)
+}
+
+// Unwrap returns the inner track.
+func (t *encryptTrack) Unwrap() LocalTrack {
+	return t.LocalTrack
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"time"
 
 	"github.com/pion/mediadevices"
 	_ "github.com/pion/mediadevices/pkg/codec/openh264" // This is required to register h264 video encoder
@@ -18,6 +19,7 @@ import (
 const (
 	videoCodecName = webrtc.VP8
 	mtu            = 1000
+	maxBurst       = 100 * time.Millisecond
 )
 
 func main() {
@@ -36,7 +38,8 @@ func main() {
 			func(_ uint8, _ uint32, id, _ string, codec *webrtc.RTPCodec) (
 				mediadevices.LocalTrack, error,
 			) {
-				return newTrack(codec, id, os.Args[1]), nil
+				// Pace the output to avoid bursts since UDP has no congestion control
+				return mediadevices.NewPacedTrack(newTrack(codec, id, os.Args[1]), maxBurst), nil
 			},
 		),
 	)
@@ -37,79 +37,44 @@ func main() {
 	offer := webrtc.SessionDescription{}
 	signal.Decode(signal.MustReadStdin(), &offer)
 
-	// Create a new RTCPeerConnection
-	mediaEngine := webrtc.MediaEngine{}
-	if err := mediaEngine.PopulateFromSDP(offer); err != nil {
-		panic(err)
-	}
-	api := webrtc.NewAPI(webrtc.WithMediaEngine(mediaEngine))
-	peerConnection, err := api.NewPeerConnection(config)
+	// Create a new RTCPeerConnection answering the offer with the stream
+	session, err := mediadevices.NewSession(offer, mediadevices.SessionConfig{
+		Configuration: config,
+		Constraints: mediadevices.MediaStreamConstraints{
+			Audio: func(c *mediadevices.MediaTrackConstraints) {
+				c.CodecName = webrtc.Opus
+				c.Enabled = true
+				c.BitRate = 32000 // 32kbps
+			},
+			Video: func(c *mediadevices.MediaTrackConstraints) {
+				c.CodecName = videoCodecName
+				c.FrameFormat = frame.FormatYUY2
+				c.Enabled = true
+				c.Width = 640
+				c.Height = 480
+				c.BitRate = 100000 // 100kbps
+			},
+		},
+	})
 	if err != nil {
 		panic(err)
 	}
 
 	// Set the handler for ICE connection state
 	// This will notify you when the peer has connected/disconnected
-	peerConnection.OnICEConnectionStateChange(func(connectionState webrtc.ICEConnectionState) {
+	session.PeerConnection().OnICEConnectionStateChange(func(connectionState webrtc.ICEConnectionState) {
 		fmt.Printf("Connection State has changed %s \n", connectionState.String())
 	})
 
-	md := mediadevices.NewMediaDevices(peerConnection)
-
-	s, err := md.GetUserMedia(mediadevices.MediaStreamConstraints{
-		Audio: func(c *mediadevices.MediaTrackConstraints) {
-			c.CodecName = webrtc.Opus
-			c.Enabled = true
-			c.BitRate = 32000 // 32kbps
-		},
-		Video: func(c *mediadevices.MediaTrackConstraints) {
-			c.CodecName = videoCodecName
-			c.FrameFormat = frame.FormatYUY2
-			c.Enabled = true
-			c.Width = 640
-			c.Height = 480
-			c.BitRate = 100000 // 100kbps
-		},
-	})
-	if err != nil {
-		panic(err)
-	}
-
-	for _, tracker := range s.GetTracks() {
+	for _, tracker := range session.Stream().GetTracks() {
 		t := tracker.Track()
 		tracker.OnEnded(func(err error) {
 			fmt.Printf("Track (ID: %s, Label: %s) ended with error: %v\n",
 				t.ID(), t.Label(), err)
 		})
-		_, err = peerConnection.AddTransceiverFromTrack(t,
-			webrtc.RtpTransceiverInit{
-				Direction: webrtc.RTPTransceiverDirectionSendonly,
-			},
-		)
-		if err != nil {
-			panic(err)
-		}
-	}
-
-	// Set the remote SessionDescription
-	err = peerConnection.SetRemoteDescription(offer)
-	if err != nil {
-		panic(err)
-	}
-
-	// Create an answer
-	answer, err := peerConnection.CreateAnswer(nil)
-	if err != nil {
-		panic(err)
-	}
-
-	// Sets the LocalDescription, and starts our UDP listeners
-	err = peerConnection.SetLocalDescription(answer)
-	if err != nil {
-		panic(err)
 	}
 
 	// Output the answer in base64 so we can paste it in browser
-	fmt.Println(signal.Encode(answer))
+	fmt.Println(signal.Encode(session.Answer()))
 	select {}
 }
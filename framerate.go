@@ -0,0 +1,72 @@
+package mediadevices
+
+import (
+	"sync"
+	"time"
+)
+
+// frameRateWindow is the duration to measure the frame rate.
+const frameRateWindow = time.Second
+
+// frameRateMeter measures the rate of the frames and their bits in the last
+// frameRateWindow.
+type frameRateMeter struct {
+	mu    sync.Mutex
+	start time.Time
+	times []time.Time
+	sizes []int
+	// bytes is the sum of sizes
+	bytes int
+}
+
+// tick records a frame of size bytes.
+func (m *frameRateMeter) tick(size int) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.start.IsZero() {
+		m.start = now
+	}
+	m.prune(now)
+	m.times = append(m.times, now)
+	m.sizes = append(m.sizes, size)
+	m.bytes += size
+}
+
+// rate returns the frames per second. The rate is measured from the first
+// frame until frameRateWindow passes.
+func (m *frameRateMeter) rate() float64 {
+	return m.measure(func() int { return len(m.times) })
+}
+
+// bitRate returns the bits per second of the frames measured like rate.
+func (m *frameRateMeter) bitRate() float64 {
+	return m.measure(func() int { return 8 * m.bytes })
+}
+
+func (m *frameRateMeter) measure(count func() int) float64 {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune(now)
+	elapsed := now.Sub(m.start)
+	if m.start.IsZero() || elapsed <= 0 {
+		return 0
+	}
+	if elapsed > frameRateWindow {
+		elapsed = frameRateWindow
+	}
+	return float64(count()) / elapsed.Seconds()
+}
+
+func (m *frameRateMeter) prune(now time.Time) {
+	var i int
+	for i < len(m.times) && now.Sub(m.times[i]) > frameRateWindow {
+		m.bytes -= m.sizes[i]
+		i++
+	}
+	m.times = m.times[:copy(m.times, m.times[i:])]
+	m.sizes = m.sizes[:copy(m.sizes, m.sizes[i:])]
+}
@@ -9,6 +9,8 @@ require (
 	github.com/jfreymuth/pulse v0.0.0-20200118113426-7cf5f487291e
 	github.com/pion/rtp v1.2.0
 	github.com/pion/webrtc/v2 v2.1.19-0.20200106051345-726a16faa60d
+	github.com/prometheus/client_golang v1.7.1
+	github.com/prometheus/client_model v0.2.0
 	github.com/satori/go.uuid v1.2.0
 	golang.org/x/image v0.0.0-20191009234506-e7c1f5e7dbb8
 	gopkg.in/hraban/opus.v2 v2.0.0-20191117073431-57179dff69a6
@@ -0,0 +1,21 @@
+package mediadevices
+
+// Logger is a leveled logger receiving the events of MediaDevices and the tracks,
+// e.g. opening the devices, selecting the properties, building the encoders and
+// ending the tracks. Its methods are compatible with logging.LeveledLogger of
+// github.com/pion/logging, so the loggers created by pion's LoggerFactory can be
+// used as is. The methods may be called concurrently.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// nopLogger is the default Logger discarding all events.
+type nopLogger struct{}
+
+func (nopLogger) Debugf(string, ...interface{}) {}
+func (nopLogger) Infof(string, ...interface{})  {}
+func (nopLogger) Warnf(string, ...interface{})  {}
+func (nopLogger) Errorf(string, ...interface{}) {}
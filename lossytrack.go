@@ -0,0 +1,67 @@
+package mediadevices
+
+import (
+	"math/rand"
+
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+// LossConfig represents the network impairments simulated by NewLossyTrack.
+type LossConfig struct {
+	// DropRate is the probability [0-1] to drop each sample.
+	DropRate float64
+	// ReorderRate is the probability [0-1] to hold each sample and write it
+	// after the next one.
+	ReorderRate float64
+	// Seed of the random generator. The same seed reproduces the same pattern.
+	Seed int64
+}
+
+// lossyTrack is a LocalTrack which randomly drops and reorders the samples
+// written to the inner track.
+type lossyTrack struct {
+	LocalTrack
+	config LossConfig
+	random *rand.Rand
+	// held is the sample delayed to be written after the next one
+	held *media.Sample
+}
+
+// NewLossyTrack wraps t to simulate the packet loss and reordering of the
+// network, e.g. to test the resilience of the receiver. The dropped samples
+// are not reported as errors. The sample held for reordering is written after
+// the next sample, so the last sample is lost if it's held.
+func NewLossyTrack(t LocalTrack, config LossConfig) LocalTrack {
+	return &lossyTrack{
+		LocalTrack: t,
+		config:     config,
+		random:     rand.New(rand.NewSource(config.Seed)),
+	}
+}
+
+func (t *lossyTrack) WriteSample(s media.Sample) error {
+	if t.random.Float64() < t.config.DropRate {
+		return nil
+	}
+
+	if t.held == nil && t.random.Float64() < t.config.ReorderRate {
+		// Sample data is only valid during the call
+		s.Data = append([]byte(nil), s.Data...)
+		t.held = &s
+		return nil
+	}
+
+	if err := t.LocalTrack.WriteSample(s); err != nil {
+		return err
+	}
+	if held := t.held; held != nil {
+		t.held = nil
+		return t.LocalTrack.WriteSample(*held)
+	}
+	return nil
+}
+
+// Unwrap returns the inner track.
+func (t *lossyTrack) Unwrap() LocalTrack {
+	return t.LocalTrack
+}
@@ -0,0 +1,68 @@
+package mediadevices
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v2"
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+func TestLossyTrack(t *testing.T) {
+	const n = 10000
+	codec := &webrtc.RTPCodec{Type: webrtc.RTPCodecTypeVideo}
+
+	t.Run("Drop", func(t *testing.T) {
+		for _, rate := range []float64{0, 0.1, 0.5} {
+			inner := NewBufferTrack(codec, "test")
+			track := NewLossyTrack(inner, LossConfig{DropRate: rate, Seed: 1})
+			for i := 0; i < n; i++ {
+				if err := track.WriteSample(media.Sample{Data: []byte{0}, Samples: 1}); err != nil {
+					t.Fatalf("Failed to write sample: %v", err)
+				}
+			}
+			dropped := float64(n-inner.Len()) / n
+			if dropped < rate-0.02 || rate+0.02 < dropped {
+				t.Errorf("Expected drop rate of %v, got %v", rate, dropped)
+			}
+		}
+	})
+	t.Run("Reorder", func(t *testing.T) {
+		inner := NewBufferTrack(codec, "test")
+		track := NewLossyTrack(inner, LossConfig{ReorderRate: 0.2, Seed: 1})
+		for i := 0; i < n; i++ {
+			data := []byte{byte(i), byte(i >> 8)}
+			if err := track.WriteSample(media.Sample{Data: data, Samples: 1}); err != nil {
+				t.Fatalf("Failed to write sample: %v", err)
+			}
+			// Data must be copied by the track
+			data[0], data[1] = 0xFF, 0xFF
+		}
+
+		seen := make(map[int]bool)
+		var reordered int
+		prev := -1
+		for _, s := range inner.Samples() {
+			i := int(s.Data[0]) | int(s.Data[1])<<8
+			if seen[i] {
+				t.Fatalf("Sample %d is written twice", i)
+			}
+			seen[i] = true
+			if i < prev {
+				reordered++
+				if prev-i != 1 {
+					t.Errorf("Expected the sample to be delayed by 1, got %d", prev-i)
+				}
+			}
+			prev = i
+		}
+		// The last sample may be held
+		if len(seen) < n-1 {
+			t.Errorf("Expected all samples to be written, got %d", len(seen))
+		}
+		rate := float64(reordered) / n
+		// A sample is held with 20% probability only if no sample is held
+		if rate < 0.1 || 0.2 < rate {
+			t.Errorf("Expected reorder rate around 0.17, got %v", rate)
+		}
+	})
+}
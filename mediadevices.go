@@ -3,8 +3,12 @@ package mediadevices
 import (
 	"fmt"
 	"math"
+	"sort"
+	"time"
 
+	"github.com/pion/mediadevices/pkg/codec"
 	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/frame"
 	"github.com/pion/mediadevices/pkg/prop"
 	"github.com/pion/webrtc/v2"
 )
@@ -21,13 +25,26 @@ type MediaDevices interface {
 // NewMediaDevices creates MediaDevices interface that provides access to connected media input devices
 // like cameras and microphones, as well as screen sharing.
 // In essence, it lets you obtain access to any hardware source of media data.
+//
+// The codecs registered to pc are used. pc may be nil to use the tracks without PeerConnection,
+// e.g. for recording or sending RTP packets by WithTrackGenerator. In that case, the default codecs
+// of webrtc.MediaEngine are used.
 func NewMediaDevices(pc *webrtc.PeerConnection, opts ...MediaDevicesOption) MediaDevices {
+	getCodecs := func(kind webrtc.RTPCodecType) []*webrtc.RTPCodec {
+		return pc.GetRegisteredRTPCodecs(kind)
+	}
+	if pc == nil {
+		var m webrtc.MediaEngine
+		m.RegisterDefaultCodecs()
+		getCodecs = m.GetCodecsByKind
+	}
+
 	codecs := make(map[webrtc.RTPCodecType][]*webrtc.RTPCodec)
 	for _, kind := range []webrtc.RTPCodecType{
 		webrtc.RTPCodecTypeAudio,
 		webrtc.RTPCodecTypeVideo,
 	} {
-		codecs[kind] = pc.GetRegisteredRTPCodecs(kind)
+		codecs[kind] = getCodecs(kind)
 	}
 	return NewMediaDevicesFromCodecs(codecs, opts...)
 }
@@ -38,18 +55,23 @@ func NewMediaDevices(pc *webrtc.PeerConnection, opts ...MediaDevicesOption) Medi
 // In essence, it lets you obtain access to any hardware source of media data.
 func NewMediaDevicesFromCodecs(codecs map[webrtc.RTPCodecType][]*webrtc.RTPCodec, opts ...MediaDevicesOption) MediaDevices {
 	mdo := MediaDevicesOptions{
-		codecs:         codecs,
-		trackGenerator: defaultTrackGenerator,
+		codecs:           codecs,
+		trackGenerator:   defaultTrackGenerator,
+		samplerGenerator: defaultSamplerGenerator,
+		logger:           nopLogger{},
 	}
 	for _, o := range opts {
 		o(&mdo)
 	}
+	mdo.codecs = overrideCodecs(mdo.codecs, mdo.codecOverrides)
 	return &mediaDevices{
 		MediaDevicesOptions: mdo,
 	}
 }
 
 // TrackGenerator is a function to create new track.
+// The default generator creates *webrtc.Track to be added to PeerConnection.
+// Custom generators may return any LocalTrack, which doesn't require PeerConnection.
 type TrackGenerator func(payloadType uint8, ssrc uint32, id, label string, codec *webrtc.RTPCodec) (LocalTrack, error)
 
 var defaultTrackGenerator = TrackGenerator(func(pt uint8, ssrc uint32, id, label string, codec *webrtc.RTPCodec) (LocalTrack, error) {
@@ -62,8 +84,18 @@ type mediaDevices struct {
 
 // MediaDevicesOptions stores parameters used by MediaDevices.
 type MediaDevicesOptions struct {
-	codecs         map[webrtc.RTPCodecType][]*webrtc.RTPCodec
-	trackGenerator TrackGenerator
+	codecs           map[webrtc.RTPCodecType][]*webrtc.RTPCodec
+	trackGenerator   TrackGenerator
+	samplerGenerator SamplerGenerator
+	codecOverrides   map[string]codecOverride
+	logger           Logger
+}
+
+// codecOverride stores the codec parameters to be overridden.
+// nil payloadType and zero clockRate mean not overridden.
+type codecOverride struct {
+	payloadType *uint8
+	clockRate   uint32
 }
 
 // MediaDevicesOption is a type of MediaDevices functional option.
@@ -76,6 +108,78 @@ func WithTrackGenerator(gen TrackGenerator) MediaDevicesOption {
 	}
 }
 
+// WithSamplerGenerator specifies a SamplerGenerator to customize the timing and
+// the packetization of the video samples. The audio samples are written by the
+// frame durations reported by the encoders.
+func WithSamplerGenerator(gen SamplerGenerator) MediaDevicesOption {
+	return func(o *MediaDevicesOptions) {
+		o.samplerGenerator = gen
+	}
+}
+
+// WithLogger specifies a Logger to receive the events of MediaDevices and the tracks.
+// The events are discarded by default.
+func WithLogger(logger Logger) MediaDevicesOption {
+	return func(o *MediaDevicesOptions) {
+		if logger == nil {
+			logger = nopLogger{}
+		}
+		o.logger = logger
+	}
+}
+
+// WithCodecPayloadType overrides the payload type of the codec named codecName,
+// so that it matches to the payload type negotiated by SDP.
+func WithCodecPayloadType(codecName string, payloadType uint8) MediaDevicesOption {
+	return func(o *MediaDevicesOptions) {
+		override := o.codecOverride(codecName)
+		override.payloadType = &payloadType
+		o.codecOverrides[codecName] = override
+	}
+}
+
+// WithCodecClockRate overrides the clock rate of the codec named codecName.
+func WithCodecClockRate(codecName string, clockRate uint32) MediaDevicesOption {
+	return func(o *MediaDevicesOptions) {
+		override := o.codecOverride(codecName)
+		override.clockRate = clockRate
+		o.codecOverrides[codecName] = override
+	}
+}
+
+func (o *MediaDevicesOptions) codecOverride(codecName string) codecOverride {
+	if o.codecOverrides == nil {
+		o.codecOverrides = make(map[string]codecOverride)
+	}
+	return o.codecOverrides[codecName]
+}
+
+// overrideCodecs returns copy of codecs with overridden parameters.
+// Given codecs are not modified since they may be shared with the PeerConnection.
+func overrideCodecs(codecs map[webrtc.RTPCodecType][]*webrtc.RTPCodec, overrides map[string]codecOverride) map[webrtc.RTPCodecType][]*webrtc.RTPCodec {
+	if len(overrides) == 0 {
+		return codecs
+	}
+
+	overridden := make(map[webrtc.RTPCodecType][]*webrtc.RTPCodec, len(codecs))
+	for kind, cs := range codecs {
+		for _, c := range cs {
+			if override, ok := overrides[c.Name]; ok {
+				copied := *c
+				if override.payloadType != nil {
+					copied.PayloadType = *override.payloadType
+				}
+				if override.clockRate != 0 {
+					copied.ClockRate = override.clockRate
+				}
+				c = &copied
+			}
+			overridden[kind] = append(overridden[kind], c)
+		}
+	}
+	return overridden
+}
+
 // GetDisplayMedia prompts the user to select and grant permission to capture the contents
 // of a display or portion thereof (such as a window) as a MediaStream.
 // Reference: https://developer.mozilla.org/en-US/docs/Web/API/MediaDevices/getDisplayMedia
@@ -106,6 +210,10 @@ func (m *mediaDevices) GetDisplayMedia(constraints MediaStreamConstraints) (Medi
 
 // GetUserMedia prompts the user for permission to use a media input which produces a MediaStream
 // with tracks containing the requested types of media.
+// If CodecName of the constraints is empty, the codec is selected by SelectCodec from the codecs
+// of MediaDevices, e.g. the codecs of the remote offer populated to the PeerConnection.
+// If a track fails, the other track is stopped, and the error reports the failures of
+// both video and audio.
 // Reference: https://developer.mozilla.org/en-US/docs/Web/API/MediaDevices/getUserMedia
 func (m *mediaDevices) GetUserMedia(constraints MediaStreamConstraints) (MediaStream, error) {
 	// TODO: It should return media stream based on constraints
@@ -120,41 +228,111 @@ func (m *mediaDevices) GetUserMedia(constraints MediaStreamConstraints) (MediaSt
 		constraints.Audio(&audioConstraints)
 	}
 
+	var videoErr, audioErr error
 	if videoConstraints.Enabled {
 		tracker, err := m.selectVideo(videoConstraints)
 		if err != nil {
-			return nil, err
+			videoErr = err
+		} else {
+			trackers = append(trackers, tracker)
 		}
-
-		trackers = append(trackers, tracker)
 	}
 
 	if audioConstraints.Enabled {
 		tracker, err := m.selectAudio(audioConstraints)
 		if err != nil {
-			return nil, err
+			audioErr = err
+		} else {
+			trackers = append(trackers, tracker)
 		}
+	}
 
-		trackers = append(trackers, tracker)
+	if videoErr != nil || audioErr != nil {
+		// Release the tracks which have been already started
+		for _, t := range trackers {
+			t.Stop()
+		}
+		switch {
+		case audioErr == nil:
+			return nil, videoErr
+		case videoErr == nil:
+			return nil, audioErr
+		}
+		return nil, fmt.Errorf("video: %v; audio: %v", videoErr, audioErr)
 	}
 
 	s, err := NewMediaStream(trackers...)
 	if err != nil {
+		for _, t := range trackers {
+			t.Stop()
+		}
 		return nil, err
 	}
 
 	return s, nil
 }
 
-func queryDriverProperties(filter driver.FilterFn) map[driver.Driver][]prop.Media {
+// openDriver opens d. If timeout is larger than 0 and d isn't opened within timeout,
+// it returns driver.DeviceOpenTimeoutError. In that case, d is closed after the
+// pending Open returns.
+func openDriver(d driver.Driver, timeout time.Duration) error {
+	if timeout <= 0 {
+		return d.Open()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- d.Open()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		go func() {
+			if err := <-done; err == nil {
+				d.Close()
+			}
+		}()
+		return &driver.DeviceOpenTimeoutError{Timeout: timeout}
+	}
+}
+
+// openDriverWithRetry opens d by openDriver with the timeout and the retries
+// of constraints. The interval between the retries is doubled after each failure.
+func openDriverWithRetry(logger Logger, d driver.Driver, constraints MediaTrackConstraints) error {
+	interval := constraints.OpenRetryInterval
+	for i := 0; ; i++ {
+		err := openDriver(d, constraints.OpenTimeout)
+		if err == nil || i >= constraints.OpenRetryCount {
+			return err
+		}
+		if _, ok := err.(*driver.DeviceOpenTimeoutError); ok {
+			// The pending Open may still hold the device
+			return err
+		}
+		logger.Warnf("failed to open device %q, retrying in %v: %v", d.Info().Label, interval, err)
+		time.Sleep(interval)
+		interval *= 2
+	}
+}
+
+// queryDriverProperties returns the properties of the drivers matching filter.
+// The error is set if any of the drivers timed out while opening.
+func queryDriverProperties(logger Logger, filter driver.FilterFn, constraints MediaTrackConstraints) (map[driver.Driver][]prop.Media, error) {
 	var needToClose []driver.Driver
+	var timeoutErr error
 	drivers := driver.GetManager().Query(filter)
 	m := make(map[driver.Driver][]prop.Media)
 
 	for _, d := range drivers {
 		if d.Status() == driver.StateClosed {
-			err := d.Open()
+			err := openDriverWithRetry(logger, d, constraints)
 			if err != nil {
+				logger.Warnf("failed to open device %q to query the properties: %v", d.Info().Label, err)
+				if _, ok := err.(*driver.DeviceOpenTimeoutError); ok && timeoutErr == nil {
+					timeoutErr = err
+				}
 				// Skip this driver if we failed to open because we can't get the properties
 				continue
 			}
@@ -169,23 +347,118 @@ func queryDriverProperties(filter driver.FilterFn) map[driver.Driver][]prop.Medi
 		d.Close()
 	}
 
-	return m
+	return m, timeoutErr
+}
+
+// filterLabel narrows down filter to the drivers whose label contains label.
+// The drivers exactly matching label are preferred if exist.
+func filterLabel(filter driver.FilterFn, label string) driver.FilterFn {
+	if label == "" {
+		return filter
+	}
+
+	exactFilter := driver.FilterAnd(filter, driver.FilterLabel(label))
+	if len(driver.GetManager().Query(exactFilter)) > 0 {
+		return exactFilter
+	}
+	return driver.FilterAnd(filter, driver.FilterLabelContains(label))
+}
+
+// frameFormatCost returns relative cost to convert frames of the format f
+// to the one accepted by the encoder.
+func frameFormatCost(codecName string, f frame.Format) int {
+	formats := codec.VideoInputFormats(codecName)
+	for i, accepted := range formats {
+		if f == accepted {
+			return i
+		}
+	}
+
+	cost := len(formats)
+	switch f {
+	case frame.FormatI420, frame.FormatI422, frame.FormatI444, frame.FormatNV21, frame.FormatYUY2, frame.FormatP010:
+		// Only the memory layout is converted
+		return cost + 1
+	case frame.FormatRGBA, frame.FormatBGRA:
+		// Color space is converted
+		return cost + 2
+	case frame.FormatMJPEG:
+		// Frames are decompressed
+		return cost + 3
+	default:
+		return cost + 4
+	}
+}
+
+// indexFrameFormat returns the index of f in formats, or -1 if not found.
+func indexFrameFormat(formats []frame.Format, f frame.Format) int {
+	for i, format := range formats {
+		if f == format {
+			return i
+		}
+	}
+	return -1
 }
 
 // select implements SelectSettings algorithm.
 // Reference: https://w3c.github.io/mediacapture-main/#dfn-selectsettings
-func selectBestDriver(filter driver.FilterFn, constraints MediaTrackConstraints) (driver.Driver, MediaTrackConstraints, error) {
+func selectBestDriver(logger Logger, filter driver.FilterFn, constraints MediaTrackConstraints) (driver.Driver, MediaTrackConstraints, error) {
 	var bestDriver driver.Driver
 	var bestProp prop.Media
 	minFitnessDist := math.Inf(1)
+	maxPriority := driver.Priority(math.Inf(-1))
+	minFormatCost := math.MaxInt32
+
+	driverProperties, timeoutErr := queryDriverProperties(logger, filter, constraints)
+	// All the matching drivers are scored. They are sorted so that the ties are
+	// resolved in the same way regardless of the order of the registration.
+	drivers := make([]driver.Driver, 0, len(driverProperties))
+	for d := range driverProperties {
+		drivers = append(drivers, d)
+	}
+	sort.Slice(drivers, func(i, j int) bool {
+		if li, lj := drivers[i].Info().Label, drivers[j].Info().Label; li != lj {
+			return li < lj
+		}
+		return drivers[i].ID() < drivers[j].ID()
+	})
 
-	driverProperties := queryDriverProperties(filter)
-	for d, props := range driverProperties {
-		priority := float64(d.Info().Priority)
-		for _, p := range props {
-			fitnessDist := constraints.Media.FitnessDistance(p) - priority
-			if fitnessDist < minFitnessDist {
+	for _, d := range drivers {
+		priority := d.Info().Priority
+		for _, p := range driverProperties[d] {
+			if !constraints.Media.SatisfiesExact(p, constraints.Exact) {
+				logger.Debugf("device %q with %+v violates the exact constraints", d.Info().Label, p)
+				continue
+			}
+			formatRank := indexFrameFormat(constraints.FrameFormats, p.FrameFormat)
+			if len(constraints.FrameFormats) > 0 && formatRank < 0 {
+				logger.Debugf("device %q with %+v doesn't match the frame formats %v", d.Info().Label, p, constraints.FrameFormats)
+				continue
+			}
+			fitnessDist := constraints.Media.FitnessDistance(p)
+			logger.Debugf("device %q with %+v has fitness distance %f", d.Info().Label, p, fitnessDist)
+			// The priority only breaks the ties of the fitness distance, so that the
+			// better fitting device is selected regardless of the priority.
+			// If the frame format is not specified or any native format is accepted, prefer
+			// the format requiring less conversion among the properties with the same
+			// fitness distance and priority. If the frame formats are listed, prefer the
+			// earlier one.
+			var formatCost int
+			anyFormat := constraints.FrameFormat == "" || constraints.FrameFormat == frame.FormatAny
+			switch {
+			case len(constraints.FrameFormats) > 0:
+				formatCost = formatRank
+			case anyFormat && p.FrameFormat != "":
+				formatCost = frameFormatCost(constraints.CodecName, p.FrameFormat)
+			}
+			better := fitnessDist < minFitnessDist
+			if fitnessDist == minFitnessDist {
+				better = priority > maxPriority || (priority == maxPriority && formatCost < minFormatCost)
+			}
+			if better {
 				minFitnessDist = fitnessDist
+				maxPriority = priority
+				minFormatCost = formatCost
 				bestDriver = d
 				bestProp = p
 			}
@@ -193,29 +466,76 @@ func selectBestDriver(filter driver.FilterFn, constraints MediaTrackConstraints)
 	}
 
 	if bestDriver == nil {
+		logger.Warnf("no device matches the constraints among %d devices", len(driverProperties))
+		if timeoutErr != nil {
+			// The device may be the one requested but stuck
+			return nil, MediaTrackConstraints{}, timeoutErr
+		}
 		return nil, MediaTrackConstraints{}, errNotFound
 	}
 
 	// Reset Codec because bestProp only contains either audio.Prop or video.Prop
 	bestProp.Codec = constraints.Codec
-	bestConstraint := MediaTrackConstraints{
-		Media:          bestProp,
-		Enabled:        true,
-		AudioTransform: constraints.AudioTransform,
-		VideoTransform: constraints.VideoTransform,
-	}
+	bestConstraint := constraints
+	bestConstraint.Media = bestProp
+	bestConstraint.Enabled = true
+	logger.Infof("selected device %q with %+v", bestDriver.Info().Label, bestProp)
 	return bestDriver, bestConstraint, nil
 }
 
+// SelectCodec returns the name of the first codec of kind in codecs which has
+// an available encoder registered. codecs are in order of preference, e.g. the
+// codecs populated to MediaEngine from the remote offer by PopulateFromSDP.
+func SelectCodec(kind webrtc.RTPCodecType, codecs []*webrtc.RTPCodec) (string, error) {
+	available := make(map[string]bool)
+	for _, info := range codec.Registered() {
+		if info.Kind == codec.Kind(kind.String()) && info.Available {
+			available[info.Name] = true
+		}
+	}
+
+	for _, c := range codecs {
+		if c.Type == kind && available[c.Name] {
+			return c.Name, nil
+		}
+	}
+	return "", fmt.Errorf("no %s encoder available for the codecs", kind)
+}
+
+// selectCodec sets the codec selected by SelectCodec if the codec isn't specified.
+func (m *mediaDevices) selectCodec(kind webrtc.RTPCodecType, constraints *MediaTrackConstraints) error {
+	if constraints.CodecName != "" {
+		return nil
+	}
+
+	name, err := SelectCodec(kind, m.codecs[kind])
+	if err != nil {
+		return err
+	}
+	m.logger.Infof("selected %s codec %s", kind, name)
+	constraints.CodecName = name
+	return nil
+}
+
 func (m *mediaDevices) selectAudio(constraints MediaTrackConstraints) (Tracker, error) {
+	if err := m.selectCodec(webrtc.RTPCodecTypeAudio, &constraints); err != nil {
+		return nil, err
+	}
+
+	// Validate constraints before opening the devices to avoid unnecessary device access
+	if err := validateAudioConstraints(constraints); err != nil {
+		return nil, err
+	}
+
 	typeFilter := driver.FilterAudioRecorder()
 	filter := typeFilter
 	if constraints.DeviceID != "" {
 		idFilter := driver.FilterID(constraints.DeviceID)
 		filter = driver.FilterAnd(typeFilter, idFilter)
 	}
+	filter = filterLabel(filter, constraints.DeviceLabel)
 
-	d, c, err := selectBestDriver(filter, constraints)
+	d, c, err := selectBestDriver(m.logger, filter, constraints)
 	if err != nil {
 		return nil, err
 	}
@@ -223,6 +543,26 @@ func (m *mediaDevices) selectAudio(constraints MediaTrackConstraints) (Tracker,
 	return newAudioTrack(&m.MediaDevicesOptions, d, c)
 }
 func (m *mediaDevices) selectVideo(constraints MediaTrackConstraints) (Tracker, error) {
+	if err := m.selectCodec(webrtc.RTPCodecTypeVideo, &constraints); err != nil {
+		return nil, err
+	}
+
+	// Validate constraints before opening the devices to avoid unnecessary device access
+	if err := validateVideoConstraints(constraints); err != nil {
+		return nil, err
+	}
+
+	d, c, err := selectBestDriver(m.logger, videoFilter(constraints), constraints)
+	if err != nil {
+		return nil, err
+	}
+
+	return newVideoTrack(&m.MediaDevicesOptions, d, c)
+}
+
+// videoFilter returns the filter of the video devices other than the screens
+// matching the device ID and the label of constraints.
+func videoFilter(constraints MediaTrackConstraints) driver.FilterFn {
 	typeFilter := driver.FilterVideoRecorder()
 	notScreenFilter := driver.FilterNot(driver.FilterDeviceType(driver.Screen))
 	filter := driver.FilterAnd(typeFilter, notScreenFilter)
@@ -230,16 +570,19 @@ func (m *mediaDevices) selectVideo(constraints MediaTrackConstraints) (Tracker,
 		idFilter := driver.FilterID(constraints.DeviceID)
 		filter = driver.FilterAnd(typeFilter, notScreenFilter, idFilter)
 	}
+	return filterLabel(filter, constraints.DeviceLabel)
+}
 
-	d, c, err := selectBestDriver(filter, constraints)
-	if err != nil {
+func (m *mediaDevices) selectScreen(constraints MediaTrackConstraints) (Tracker, error) {
+	if err := m.selectCodec(webrtc.RTPCodecTypeVideo, &constraints); err != nil {
 		return nil, err
 	}
 
-	return newVideoTrack(&m.MediaDevicesOptions, d, c)
-}
+	// Validate constraints before opening the devices to avoid unnecessary device access
+	if err := validateVideoConstraints(constraints); err != nil {
+		return nil, err
+	}
 
-func (m *mediaDevices) selectScreen(constraints MediaTrackConstraints) (Tracker, error) {
 	typeFilter := driver.FilterVideoRecorder()
 	screenFilter := driver.FilterDeviceType(driver.Screen)
 	filter := driver.FilterAnd(typeFilter, screenFilter)
@@ -247,8 +590,9 @@ func (m *mediaDevices) selectScreen(constraints MediaTrackConstraints) (Tracker,
 		idFilter := driver.FilterID(constraints.DeviceID)
 		filter = driver.FilterAnd(typeFilter, screenFilter, idFilter)
 	}
+	filter = filterLabel(filter, constraints.DeviceLabel)
 
-	d, c, err := selectBestDriver(filter, constraints)
+	d, c, err := selectBestDriver(m.logger, filter, constraints)
 	if err != nil {
 		return nil, err
 	}
@@ -256,6 +600,78 @@ func (m *mediaDevices) selectScreen(constraints MediaTrackConstraints) (Tracker,
 	return newVideoTrack(&m.MediaDevicesOptions, d, c)
 }
 
+func validateVideoConstraints(constraints MediaTrackConstraints) error {
+	switch {
+	case constraints.WriteRetryCount < 0:
+		return fmt.Errorf("invalid write retry count %d", constraints.WriteRetryCount)
+	case constraints.OpenTimeout < 0:
+		return fmt.Errorf("invalid open timeout %v", constraints.OpenTimeout)
+	case constraints.OpenRetryCount < 0:
+		return fmt.Errorf("invalid open retry count %d", constraints.OpenRetryCount)
+	case constraints.OpenRetryInterval < 0:
+		return fmt.Errorf("invalid open retry interval %v", constraints.OpenRetryInterval)
+	case constraints.FrameBufferSize < 0:
+		return fmt.Errorf("invalid frame buffer size %d", constraints.FrameBufferSize)
+	case constraints.MaxBufferBytes < 0:
+		return fmt.Errorf("invalid max buffer bytes %d", constraints.MaxBufferBytes)
+	case len(constraints.FrameFormats) > 0 && constraints.FrameFormat != "":
+		return fmt.Errorf("frame format %s can't be used with frame formats", constraints.FrameFormat)
+	case !validFacingMode(constraints.FacingMode):
+		return fmt.Errorf("invalid facing mode %q", constraints.FacingMode)
+	case constraints.SceneChangeThreshold < 0 || constraints.SceneChangeThreshold > 1:
+		return fmt.Errorf("scene change threshold %f is out of range [0.0-1.0]", constraints.SceneChangeThreshold)
+	case constraints.OnThumbnail != nil && constraints.ThumbnailInterval <= 0:
+		return fmt.Errorf("invalid thumbnail interval %v", constraints.ThumbnailInterval)
+	case constraints.OnThumbnail != nil && constraints.ThumbnailWidth <= 0 && constraints.ThumbnailHeight <= 0:
+		return fmt.Errorf("invalid thumbnail size %dx%d", constraints.ThumbnailWidth, constraints.ThumbnailHeight)
+	}
+	for _, f := range constraints.FrameFormats {
+		// The frames are decoded to be passed to the encoder
+		if _, err := frame.NewDecoder(f); err != nil {
+			return fmt.Errorf("invalid frame format in frame formats: %v", err)
+		}
+	}
+	return codec.ValidateVideoEncoder(constraints.Media)
+}
+
+// validFacingMode returns true if m is one of the facing modes or empty.
+func validFacingMode(m prop.FacingMode) bool {
+	switch m {
+	case "", prop.FacingModeUser, prop.FacingModeEnvironment, prop.FacingModeLeft, prop.FacingModeRight:
+		return true
+	}
+	return false
+}
+
+func validateAudioConstraints(constraints MediaTrackConstraints) error {
+	if constraints.ChannelMap != nil {
+		if n := len(constraints.ChannelMap); n == 0 || n > 2 {
+			return fmt.Errorf("channel map must have 1 or 2 channels, got %d", n)
+		}
+		for _, ch := range constraints.ChannelMap {
+			if ch < 0 {
+				return fmt.Errorf("invalid channel %d in channel map", ch)
+			}
+		}
+	}
+
+	switch {
+	case constraints.WriteRetryCount < 0:
+		return fmt.Errorf("invalid write retry count %d", constraints.WriteRetryCount)
+	case constraints.OpenTimeout < 0:
+		return fmt.Errorf("invalid open timeout %v", constraints.OpenTimeout)
+	case constraints.OpenRetryCount < 0:
+		return fmt.Errorf("invalid open retry count %d", constraints.OpenRetryCount)
+	case constraints.OpenRetryInterval < 0:
+		return fmt.Errorf("invalid open retry interval %v", constraints.OpenRetryInterval)
+	case constraints.AudioBufferDuration < 0:
+		return fmt.Errorf("invalid audio buffer duration %v", constraints.AudioBufferDuration)
+	case constraints.AudioConcealDelay < 0:
+		return fmt.Errorf("invalid audio conceal delay %v", constraints.AudioConcealDelay)
+	}
+	return codec.ValidateAudioEncoder(constraints.Media)
+}
+
 func (m *mediaDevices) EnumerateDevices() []MediaDeviceInfo {
 	drivers := driver.GetManager().Query(
 		driver.FilterFn(func(driver.Driver) bool { return true }))
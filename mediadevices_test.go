@@ -0,0 +1,764 @@
+package mediadevices
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/codec/raw"
+	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/webrtc/v2"
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+func TestSelectByDeviceLabel(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	read := func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		return img, nil
+	}
+
+	// Make the labels unique to run the test multiple times
+	prefix := fmt.Sprintf("TestSelectByDeviceLabel%d ", time.Now().UnixNano())
+	ids := make(map[string]string)
+	for _, label := range []string{"HD Webcam", "HD Webcam Pro", "Capture Card"} {
+		if err := RegisterDriverAdapter(
+			&videoAdapterMock{read: read},
+			driver.Info{Label: prefix + label, DeviceType: driver.Camera},
+		); err != nil {
+			t.Fatalf("Failed to register adapter: %v", err)
+		}
+		for _, d := range driver.GetManager().Query(driver.FilterLabel(prefix + label)) {
+			ids[label] = d.ID()
+		}
+	}
+
+	var selectedID string
+	md := newMediaDevicesMock([]string{raw.Name}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		selectedID = id
+		return &localTrackMock{codec: codec, id: id, samples: make(chan media.Sample)}
+	})
+
+	cases := map[string]struct {
+		label    string
+		expected string
+	}{
+		"Substring": {
+			label:    prefix + "Capture",
+			expected: "Capture Card",
+		},
+		"PreferExact": {
+			label:    prefix + "HD Webcam",
+			expected: "HD Webcam",
+		},
+		"SubstringOfLonger": {
+			label:    prefix + "HD Webcam P",
+			expected: "HD Webcam Pro",
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			s, err := md.GetUserMedia(MediaStreamConstraints{
+				Video: func(tc *MediaTrackConstraints) {
+					tc.Enabled = true
+					tc.DeviceLabel = c.label
+					tc.CodecName = raw.Name
+				},
+			})
+			if err != nil {
+				t.Fatalf("Failed to get user media: %v", err)
+			}
+			for _, tr := range s.GetTracks() {
+				tr.Stop()
+			}
+
+			if selectedID != ids[c.expected] {
+				t.Errorf("Expected %s (%s) to be selected, got %s", c.expected, ids[c.expected], selectedID)
+			}
+		})
+	}
+
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := md.GetUserMedia(MediaStreamConstraints{
+			Video: func(tc *MediaTrackConstraints) {
+				tc.Enabled = true
+				tc.DeviceLabel = prefix + "Microphone"
+				tc.CodecName = raw.Name
+			},
+		})
+		if err != errNotFound {
+			t.Errorf("Expected %v, got %v", errNotFound, err)
+		}
+	})
+}
+
+func TestCodecOverride(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		return img, nil
+	}}, "TestCodecOverride")
+
+	original := &webrtc.RTPCodec{Name: raw.Name, Type: webrtc.RTPCodecTypeVideo, PayloadType: 96}
+	original.ClockRate = 90000
+
+	var generatedPayloadType uint8
+	md := NewMediaDevicesFromCodecs(
+		map[webrtc.RTPCodecType][]*webrtc.RTPCodec{
+			webrtc.RTPCodecTypeVideo: {original},
+		},
+		WithTrackGenerator(func(pt uint8, _ uint32, id, _ string, codec *webrtc.RTPCodec) (LocalTrack, error) {
+			generatedPayloadType = pt
+			return &localTrackMock{codec: codec, id: id, samples: make(chan media.Sample)}, nil
+		}),
+		WithCodecPayloadType(raw.Name, 120),
+		WithCodecClockRate(raw.Name, 48000),
+	)
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = raw.Name
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	tr := s.GetVideoTracks()[0]
+	defer tr.Stop()
+
+	if c := tr.Codec(); c.Name != raw.Name || c.PayloadType != 120 {
+		t.Errorf("Expected %s codec with payload type 120, got %s codec with %d", raw.Name, c.Name, c.PayloadType)
+	}
+	if generatedPayloadType != 120 {
+		t.Errorf("Expected track to be generated with payload type 120, got %d", generatedPayloadType)
+	}
+	c := tr.LocalTrack().Codec()
+	if c.PayloadType != 120 {
+		t.Errorf("Expected payload type 120, got %d", c.PayloadType)
+	}
+	if c.ClockRate != 48000 {
+		t.Errorf("Expected clock rate 48000, got %d", c.ClockRate)
+	}
+	if original.PayloadType != 96 || original.ClockRate != 90000 {
+		t.Error("Given codec must not be modified")
+	}
+}
+
+func TestPreflightValidation(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	a := &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		return img, nil
+	}}
+	id := registerMock(t, a, "TestPreflightValidation")
+
+	const unregisteredCodec = "TestPreflightValidationUnregistered"
+	md := newMediaDevicesMock([]string{raw.Name, unregisteredCodec}, nil)
+
+	cases := map[string]func(c *MediaTrackConstraints){
+		"UnregisteredEncoder": func(c *MediaTrackConstraints) {
+			c.CodecName = unregisteredCodec
+		},
+		"InvalidQuality": func(c *MediaTrackConstraints) {
+			c.CodecName = raw.Name
+			c.Quality = 10
+		},
+		"InvalidThreads": func(c *MediaTrackConstraints) {
+			c.CodecName = raw.Name
+			c.Threads = -1
+		},
+		"InvalidLatencyMode": func(c *MediaTrackConstraints) {
+			c.CodecName = raw.Name
+			c.LatencyMode = prop.LatencyModeQuality + 1
+		},
+		"InvalidBitRateMode": func(c *MediaTrackConstraints) {
+			c.CodecName = raw.Name
+			c.BitRateMode = prop.BitRateModeVBR + 1
+		},
+		"BitRateRangeWithCBR": func(c *MediaTrackConstraints) {
+			c.CodecName = raw.Name
+			c.BitRateMode = prop.BitRateModeCBR
+			c.MaxBitRate = 1000000
+		},
+		"BitRateOutOfRange": func(c *MediaTrackConstraints) {
+			c.CodecName = raw.Name
+			c.BitRateMode = prop.BitRateModeVBR
+			c.BitRate = 2000000
+			c.MinBitRate, c.MaxBitRate = 500000, 1000000
+		},
+		"InvalidOpenRetryCount": func(c *MediaTrackConstraints) {
+			c.CodecName = raw.Name
+			c.OpenRetryCount = -1
+		},
+		"UnknownControl": func(c *MediaTrackConstraints) {
+			c.CodecName = raw.Name
+			c.Controls = map[string]int{"UNKNOWN": 1}
+		},
+		"FrameFormatsWithFrameFormat": func(c *MediaTrackConstraints) {
+			c.CodecName = raw.Name
+			c.FrameFormat = frame.FormatI420
+			c.FrameFormats = []frame.Format{frame.FormatI420}
+		},
+		"UnsupportedFrameFormats": func(c *MediaTrackConstraints) {
+			c.CodecName = raw.Name
+			c.FrameFormats = []frame.Format{frame.FormatI420, "UNKNOWN"}
+		},
+		"InvalidMaxBufferBytes": func(c *MediaTrackConstraints) {
+			c.CodecName = raw.Name
+			c.FrameBufferSize = 4
+			c.MaxBufferBytes = -1
+		},
+		"InvalidFacingMode": func(c *MediaTrackConstraints) {
+			c.CodecName = raw.Name
+			c.FacingMode = "front"
+		},
+		"InvalidFrameBufferSize": func(c *MediaTrackConstraints) {
+			c.CodecName = raw.Name
+			c.FrameBufferSize = -1
+		},
+		"InvalidThumbnailInterval": func(c *MediaTrackConstraints) {
+			c.CodecName = raw.Name
+			c.OnThumbnail = func([]byte) {}
+			c.ThumbnailWidth = 160
+		},
+	}
+	for name, opt := range cases {
+		opt := opt
+		t.Run(name, func(t *testing.T) {
+			opened := atomic.LoadInt32(&a.opened)
+			_, err := md.GetUserMedia(MediaStreamConstraints{
+				Video: func(c *MediaTrackConstraints) {
+					c.Enabled = true
+					c.DeviceID = id
+					opt(c)
+				},
+			})
+			if err == nil {
+				t.Fatal("Expected error")
+			}
+			if n := atomic.LoadInt32(&a.opened) - opened; n != 0 {
+				t.Errorf("Device must not be opened, opened %d times", n)
+			}
+		})
+	}
+}
+
+func TestFrameFormatAutoSelection(t *testing.T) {
+	const codecName = "TestFrameFormatAutoSelection"
+	codec.Register(codecName, codec.VideoEncoderBuilder(raw.NewVideoEncoder))
+	codec.RegisterVideoInputFormats(codecName, frame.FormatRGBA)
+
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	md := newMediaDevicesMock([]string{raw.Name, codecName}, nil)
+
+	cases := map[string]struct {
+		codecName    string
+		formats      []frame.Format
+		frameFormat  frame.Format
+		frameFormats []frame.Format
+		expected     frame.Format
+	}{
+		"SharedFormat": {
+			codecName: codecName,
+			formats:   []frame.Format{frame.FormatMJPEG, frame.FormatYUY2, frame.FormatRGBA, frame.FormatI420},
+			expected:  frame.FormatRGBA,
+		},
+		"LeastConversion": {
+			codecName: codecName,
+			formats:   []frame.Format{frame.FormatMJPEG, frame.FormatYUY2, frame.FormatBGRA},
+			expected:  frame.FormatYUY2,
+		},
+		"EncoderPreference": {
+			codecName: raw.Name,
+			formats:   []frame.Format{frame.FormatRGBA, frame.FormatI444, frame.FormatI420},
+			expected:  frame.FormatI420,
+		},
+		"Specified": {
+			codecName:   codecName,
+			formats:     []frame.Format{frame.FormatMJPEG, frame.FormatRGBA},
+			frameFormat: frame.FormatMJPEG,
+			expected:    frame.FormatMJPEG,
+		},
+		"FrameFormatsFirst": {
+			codecName:    codecName,
+			formats:      []frame.Format{frame.FormatRGBA, frame.FormatYUY2, frame.FormatI420},
+			frameFormats: []frame.Format{frame.FormatI420, frame.FormatYUY2},
+			expected:     frame.FormatI420,
+		},
+		"FrameFormatsFallback": {
+			// The first preferred format is unavailable
+			codecName:    codecName,
+			formats:      []frame.Format{frame.FormatRGBA, frame.FormatMJPEG, frame.FormatYUY2},
+			frameFormats: []frame.Format{frame.FormatI420, frame.FormatYUY2, frame.FormatMJPEG},
+			expected:     frame.FormatYUY2,
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			a := &videoAdapterMock{read: func() (image.Image, error) {
+				time.Sleep(time.Millisecond)
+				return img, nil
+			}}
+			for _, f := range c.formats {
+				a.props = append(a.props, prop.Media{Video: prop.Video{Width: 4, Height: 2, FrameFormat: f}})
+			}
+			id := registerMock(t, a, "TestFrameFormatAutoSelection"+name)
+
+			s, err := md.GetUserMedia(MediaStreamConstraints{
+				Video: func(tc *MediaTrackConstraints) {
+					tc.Enabled = true
+					tc.DeviceID = id
+					tc.CodecName = c.codecName
+					tc.FrameFormat = c.frameFormat
+					tc.FrameFormats = c.frameFormats
+				},
+			})
+			if err != nil {
+				t.Fatalf("Failed to get user media: %v", err)
+			}
+			for _, tr := range s.GetTracks() {
+				tr.Stop()
+			}
+
+			if a.recordedProp.FrameFormat != c.expected {
+				t.Errorf("Expected %s to be selected, got %s", c.expected, a.recordedProp.FrameFormat)
+			}
+		})
+	}
+
+	t.Run("FrameFormatsUnavailable", func(t *testing.T) {
+		a := &videoAdapterMock{props: []prop.Media{
+			{Video: prop.Video{Width: 4, Height: 2, FrameFormat: frame.FormatRGBA}},
+		}}
+		id := registerMock(t, a, "TestFrameFormatAutoSelectionUnavailable")
+
+		_, err := md.GetUserMedia(MediaStreamConstraints{
+			Video: func(tc *MediaTrackConstraints) {
+				tc.Enabled = true
+				tc.DeviceID = id
+				tc.CodecName = codecName
+				tc.FrameFormats = []frame.Format{frame.FormatI420, frame.FormatYUY2}
+			},
+		})
+		if err != errNotFound {
+			t.Errorf("Expected %v, got %v", errNotFound, err)
+		}
+	})
+}
+
+func TestWithoutPeerConnection(t *testing.T) {
+	t.Run("DefaultCodecs", func(t *testing.T) {
+		md := NewMediaDevices(nil).(*mediaDevices)
+		for kind, name := range map[webrtc.RTPCodecType]string{
+			webrtc.RTPCodecTypeVideo: webrtc.VP8,
+			webrtc.RTPCodecTypeAudio: webrtc.Opus,
+		} {
+			var found bool
+			for _, c := range md.codecs[kind] {
+				if c.Name == name {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Expected %s codec to be available", name)
+			}
+		}
+	})
+	t.Run("CustomTrackGenerator", func(t *testing.T) {
+		img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+		id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+			time.Sleep(time.Millisecond)
+			return img, nil
+		}}, "TestWithoutPeerConnection")
+
+		var bt *BufferTrack
+		md := newMediaDevicesMock([]string{raw.Name}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+			bt = NewBufferTrack(codec, id)
+			return bt
+		})
+		s, err := md.GetUserMedia(MediaStreamConstraints{
+			Video: func(c *MediaTrackConstraints) {
+				c.Enabled = true
+				c.DeviceID = id
+				c.CodecName = raw.Name
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to get user media: %v", err)
+		}
+		tr := s.GetVideoTracks()[0]
+		time.Sleep(50 * time.Millisecond)
+		tr.Stop()
+
+		if wt := tr.Track(); wt != nil {
+			t.Errorf("Expected nil *webrtc.Track, got %v", wt)
+		}
+		if tr.LocalTrack() != bt {
+			t.Error("Expected LocalTrack to be the generated track")
+		}
+		if bt.Len() == 0 {
+			t.Error("Expected samples to be written to the generated track")
+		}
+	})
+}
+
+type capturingLogger struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *capturingLogger) log(level, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, level+": "+fmt.Sprintf(format, args...))
+}
+
+func (l *capturingLogger) Debugf(format string, args ...interface{}) { l.log("debug", format, args...) }
+func (l *capturingLogger) Infof(format string, args ...interface{})  { l.log("info", format, args...) }
+func (l *capturingLogger) Warnf(format string, args ...interface{})  { l.log("warn", format, args...) }
+func (l *capturingLogger) Errorf(format string, args ...interface{}) { l.log("error", format, args...) }
+
+// has returns true if an event of level starts with prefix.
+func (l *capturingLogger) has(level, prefix string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, e := range l.events {
+		if strings.HasPrefix(e, level+": "+prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLogger(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	var cnt int32
+	id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		if atomic.AddInt32(&cnt, 1) > 5 {
+			return nil, &driver.DeviceLostError{Err: errors.New("unplugged")}
+		}
+		return img, nil
+	}}, "TestLogger")
+
+	logger := &capturingLogger{}
+	md := newMediaDevicesMock([]string{raw.Name}, nil, WithLogger(logger))
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = raw.Name
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+
+	ended := make(chan struct{})
+	tr := s.GetVideoTracks()[0]
+	tr.OnEnded(func(error) { close(ended) })
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("Timeout")
+	}
+
+	for _, e := range []struct{ level, prefix string }{
+		{"info", "selected device"},
+		{"info", "opened device"},
+		{"info", "built " + raw.Name + " encoder"},
+		{"warn", "video track of device"},
+	} {
+		if !logger.has(e.level, e.prefix) {
+			t.Errorf("Expected %s event %q, got %v", e.level, e.prefix, logger.events)
+		}
+	}
+}
+
+func TestCodecAutoSelection(t *testing.T) {
+	// Only VP8 encoder is registered in this test
+	codec.Register(webrtc.VP8, codec.VideoEncoderBuilder(raw.NewVideoEncoder))
+
+	// Codecs of the remote offer supporting VP9 and VP8
+	offerCodecs := []*webrtc.RTPCodec{
+		{Name: webrtc.VP9, Type: webrtc.RTPCodecTypeVideo},
+		{Name: webrtc.VP8, Type: webrtc.RTPCodecTypeVideo},
+	}
+
+	name, err := SelectCodec(webrtc.RTPCodecTypeVideo, offerCodecs)
+	if err != nil {
+		t.Fatalf("Failed to select codec: %v", err)
+	}
+	if name != webrtc.VP8 {
+		t.Errorf("Expected %s to be selected, got %s", webrtc.VP8, name)
+	}
+
+	if _, err := SelectCodec(webrtc.RTPCodecTypeVideo, offerCodecs[:1]); err == nil {
+		t.Error("Expected error without common codec")
+	}
+	if _, err := SelectCodec(webrtc.RTPCodecTypeAudio, offerCodecs); err == nil {
+		t.Error("Expected error without audio codec")
+	}
+
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		return img, nil
+	}}, "TestCodecAutoSelection")
+
+	md := NewMediaDevicesFromCodecs(
+		map[webrtc.RTPCodecType][]*webrtc.RTPCodec{
+			webrtc.RTPCodecTypeVideo: offerCodecs,
+		},
+		WithTrackGenerator(func(_ uint8, _ uint32, id, _ string, codec *webrtc.RTPCodec) (LocalTrack, error) {
+			return NewBufferTrack(codec, id), nil
+		}),
+	)
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	for _, tr := range s.GetTracks() {
+		defer tr.Stop()
+		if name := tr.Codec().Name; name != webrtc.VP8 {
+			t.Errorf("Expected %s to be selected, got %s", webrtc.VP8, name)
+		}
+	}
+}
+
+func TestExactConstraints(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	a := &videoAdapterMock{
+		read: func() (image.Image, error) {
+			time.Sleep(time.Millisecond)
+			return img, nil
+		},
+		props: []prop.Media{
+			{Video: prop.Video{Width: 1280, Height: 720, FrameFormat: frame.FormatI420}},
+			{Video: prop.Video{Width: 640, Height: 480, FrameFormat: frame.FormatI420}},
+		},
+	}
+	id := registerMock(t, a, "TestExactConstraints")
+
+	md := newMediaDevicesMock([]string{raw.Name}, nil)
+	getUserMedia := func(width, height int, exact prop.Exact) error {
+		s, err := md.GetUserMedia(MediaStreamConstraints{
+			Video: func(c *MediaTrackConstraints) {
+				c.Enabled = true
+				c.DeviceID = id
+				c.CodecName = raw.Name
+				c.FrameFormat = frame.FormatI420
+				c.Width, c.Height = width, height
+				c.Exact = exact
+			},
+		})
+		if err != nil {
+			return err
+		}
+		for _, tr := range s.GetTracks() {
+			tr.Stop()
+		}
+		return nil
+	}
+
+	t.Run("IdealNearMiss", func(t *testing.T) {
+		if err := getUserMedia(1288, 720, 0); err != nil {
+			t.Fatalf("Failed to get user media: %v", err)
+		}
+		if a.recordedProp.Width != 1280 {
+			t.Errorf("Expected the closest width 1280 to be selected, got %d", a.recordedProp.Width)
+		}
+	})
+	t.Run("ExactNearMiss", func(t *testing.T) {
+		if err := getUserMedia(1288, 720, prop.ExactWidth); err != errNotFound {
+			t.Errorf("Expected %v, got %v", errNotFound, err)
+		}
+	})
+	t.Run("ExactOverCloser", func(t *testing.T) {
+		// 1280x720 is closer to the constraints but violates the exact height
+		if err := getUserMedia(1280, 480, prop.ExactHeight); err != nil {
+			t.Fatalf("Failed to get user media: %v", err)
+		}
+		if a.recordedProp.Width != 640 || a.recordedProp.Height != 480 {
+			t.Errorf("Expected 640x480 to be selected, got %dx%d", a.recordedProp.Width, a.recordedProp.Height)
+		}
+	})
+}
+
+func TestSelectBestDriverPriority(t *testing.T) {
+	props := []prop.Media{
+		{Video: prop.Video{Width: 640, Height: 480, FrameFormat: frame.FormatI420}},
+	}
+	label := fmt.Sprintf("TestSelectBestDriverPriority%d", time.Now().UnixNano())
+	for _, info := range []driver.Info{
+		{Label: label + "Generic", DeviceType: driver.Camera, Priority: driver.PriorityNormal},
+		{Label: label + "Hardware", DeviceType: driver.Camera, Priority: driver.PriorityHigh},
+		{Label: label + "Unrecommended", DeviceType: driver.Camera, Priority: driver.PriorityLow},
+	} {
+		if err := RegisterDriverAdapter(&videoAdapterMock{props: props}, info); err != nil {
+			t.Fatalf("Failed to register adapter: %v", err)
+		}
+	}
+
+	var constraints MediaTrackConstraints
+	constraints.Width, constraints.Height = 640, 480
+	// Drivers are queried in random order
+	for i := 0; i < 10; i++ {
+		d, _, err := selectBestDriver(nopLogger{}, driver.FilterLabelContains(label), constraints)
+		if err != nil {
+			t.Fatalf("Failed to select driver: %v", err)
+		}
+		if l := d.Info().Label; l != label+"Hardware" {
+			t.Fatalf("Expected the driver with the higher priority to be selected, got %s", l)
+		}
+	}
+}
+
+func TestSelectBestDriverPriorityFitness(t *testing.T) {
+	label := fmt.Sprintf("TestSelectBestDriverPriorityFitness%d", time.Now().UnixNano())
+	// The high priority device fits slightly worse, by less than the difference of the priorities
+	for _, c := range []struct {
+		name     string
+		width    int
+		priority driver.Priority
+	}{
+		{"Exact", 640, driver.PriorityLow},
+		{"Close", 600, driver.PriorityHigh},
+	} {
+		props := []prop.Media{
+			{Video: prop.Video{Width: c.width, Height: 480, FrameFormat: frame.FormatI420}},
+		}
+		info := driver.Info{Label: label + c.name, DeviceType: driver.Camera, Priority: c.priority}
+		if err := RegisterDriverAdapter(&videoAdapterMock{props: props}, info); err != nil {
+			t.Fatalf("Failed to register adapter: %v", err)
+		}
+	}
+
+	var constraints MediaTrackConstraints
+	constraints.Width, constraints.Height = 640, 480
+	d, _, err := selectBestDriver(nopLogger{}, driver.FilterLabelContains(label), constraints)
+	if err != nil {
+		t.Fatalf("Failed to select driver: %v", err)
+	}
+	if l := d.Info().Label; l != label+"Exact" {
+		t.Errorf("Expected the better fitting driver to be selected regardless of the priority, got %s", l)
+	}
+}
+
+func TestSelectFacingMode(t *testing.T) {
+	label := fmt.Sprintf("TestSelectFacingMode%d", time.Now().UnixNano())
+	for name, facingMode := range map[string]prop.FacingMode{
+		"Front":   prop.FacingModeUser,
+		"Back":    prop.FacingModeEnvironment,
+		"Unknown": "",
+	} {
+		props := []prop.Media{
+			{Video: prop.Video{Width: 640, Height: 480, FrameFormat: frame.FormatI420, FacingMode: facingMode}},
+		}
+		if err := RegisterDriverAdapter(&videoAdapterMock{props: props}, driver.Info{Label: label + name, DeviceType: driver.Camera}); err != nil {
+			t.Fatalf("Failed to register adapter: %v", err)
+		}
+	}
+
+	for facingMode, expected := range map[prop.FacingMode]string{
+		prop.FacingModeUser:        "Front",
+		prop.FacingModeEnvironment: "Back",
+	} {
+		facingMode, expected := facingMode, expected
+		t.Run(expected, func(t *testing.T) {
+			var constraints MediaTrackConstraints
+			constraints.Width, constraints.Height = 640, 480
+			constraints.FacingMode = facingMode
+			d, c, err := selectBestDriver(nopLogger{}, driver.FilterLabelContains(label), constraints)
+			if err != nil {
+				t.Fatalf("Failed to select driver: %v", err)
+			}
+			if l := d.Info().Label; l != label+expected {
+				t.Errorf("Expected the camera facing %s to be selected, got %s", facingMode, l)
+			}
+			if c.FacingMode != facingMode {
+				t.Errorf("Expected the settings to report %s, got %s", facingMode, c.FacingMode)
+			}
+		})
+	}
+	t.Run("ExactUnavailable", func(t *testing.T) {
+		var constraints MediaTrackConstraints
+		constraints.FacingMode = prop.FacingModeLeft
+		constraints.Exact = prop.ExactFacingMode
+		if _, _, err := selectBestDriver(nopLogger{}, driver.FilterLabelContains(label), constraints); err != errNotFound {
+			t.Errorf("Expected %v, got %v", errNotFound, err)
+		}
+	})
+}
+
+func TestSelectClosestDevice(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	label := fmt.Sprintf("TestSelectClosestDevice%d", time.Now().UnixNano())
+	adapters := map[string]*videoAdapterMock{}
+	for name, size := range map[string][2]int{
+		"QVGA":   {320, 240},
+		"VGA":    {640, 480},
+		"FullHD": {1920, 1080},
+	} {
+		a := &videoAdapterMock{
+			read: func() (image.Image, error) {
+				time.Sleep(time.Millisecond)
+				return img, nil
+			},
+			props: []prop.Media{
+				{Video: prop.Video{Width: size[0], Height: size[1], FrameFormat: frame.FormatI420}},
+			},
+		}
+		adapters[name] = a
+		if err := RegisterDriverAdapter(a, driver.Info{Label: label + name, DeviceType: driver.Camera}); err != nil {
+			t.Fatalf("Failed to register adapter: %v", err)
+		}
+	}
+
+	md := newMediaDevicesMock([]string{raw.Name}, nil)
+
+	for expected, size := range map[string][2]int{
+		"QVGA":   {160, 120},
+		"VGA":    {800, 600},
+		"FullHD": {1280, 720},
+	} {
+		expected, size := expected, size
+		t.Run(expected, func(t *testing.T) {
+			s, err := md.GetUserMedia(MediaStreamConstraints{
+				Video: func(c *MediaTrackConstraints) {
+					c.Enabled = true
+					c.DeviceLabel = label
+					c.CodecName = raw.Name
+					c.Width, c.Height = size[0], size[1]
+				},
+			})
+			if err != nil {
+				t.Fatalf("Failed to get user media: %v", err)
+			}
+			defer s.GetVideoTracks()[0].Stop()
+
+			for name, a := range adapters {
+				opened := atomic.LoadInt32(&a.opened) > atomic.LoadInt32(&a.closed)
+				if opened != (name == expected) {
+					t.Errorf("Expected %s to be selected for %dx%d, but %s is opened: %v", expected, size[0], size[1], name, opened)
+				}
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@ type MediaStream interface {
 	// AddTrack implements https://w3c.github.io/mediacapture-main/#dom-mediastream-addtrack
 	AddTrack(t Tracker)
 	// RemoveTrack implements https://w3c.github.io/mediacapture-main/#dom-mediastream-removetrack
+	// Unlike the browser API, the removed track is stopped to release the device.
 	RemoveTrack(t Tracker)
 }
 
@@ -84,7 +85,12 @@ func (m *mediaStream) AddTrack(t Tracker) {
 
 func (m *mediaStream) RemoveTrack(t Tracker) {
 	m.l.Lock()
-	defer m.l.Unlock()
+	id := t.LocalTrack().ID()
+	tracker, ok := m.trackers[id]
+	delete(m.trackers, id)
+	m.l.Unlock()
 
-	delete(m.trackers, t.LocalTrack().ID())
+	if ok {
+		tracker.Stop()
+	}
 }
@@ -0,0 +1,113 @@
+package mediadevices
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/webrtc/v2"
+)
+
+type trackerMock struct {
+	t       LocalTrack
+	stopped int32
+}
+
+func (t *trackerMock) Track() *webrtc.Track                   { wt, _ := WebRTCTrack(t.t); return wt }
+func (t *trackerMock) LocalTrack() LocalTrack                 { return t.t }
+func (t *trackerMock) Codec() *webrtc.RTPCodec                { return t.t.Codec() }
+func (t *trackerMock) Kind() string                           { return t.t.Kind().String() }
+func (t *trackerMock) ReadyState() MediaStreamTrackState      { return TrackStateLive }
+func (t *trackerMock) Settings() prop.Media                   { return prop.Media{} }
+func (t *trackerMock) HeaderExtensions() []RTPHeaderExtension { return nil }
+func (t *trackerMock) Stop()                                  { atomic.AddInt32(&t.stopped, 1) }
+func (t *trackerMock) Restart() error                         { return nil }
+func (t *trackerMock) SetEnabled(bool)                        {}
+func (t *trackerMock) Enabled() bool                          { return true }
+func (t *trackerMock) OnEnded(func(error))                    {}
+
+func newTrackerMock(id string, kind webrtc.RTPCodecType) *trackerMock {
+	return &trackerMock{
+		t: &localTrackMock{codec: &webrtc.RTPCodec{Type: kind}, id: id},
+	}
+}
+
+func TestMediaStreamAddRemoveTrack(t *testing.T) {
+	camera := newTrackerMock("camera", webrtc.RTPCodecTypeVideo)
+	mic := newTrackerMock("mic", webrtc.RTPCodecTypeAudio)
+	screen := newTrackerMock("screen", webrtc.RTPCodecTypeVideo)
+
+	s, err := NewMediaStream(camera, mic)
+	if err != nil {
+		t.Fatalf("Failed to create media stream: %v", err)
+	}
+	assertTracks := func(expected ...*trackerMock) {
+		t.Helper()
+		tracks := s.GetTracks()
+		if len(tracks) != len(expected) {
+			t.Fatalf("Expected %d tracks, got %d", len(expected), len(tracks))
+		}
+		for _, e := range expected {
+			var found bool
+			for _, tr := range tracks {
+				if tr == Tracker(e) {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Track %s is not found", e.t.ID())
+			}
+		}
+	}
+	assertTracks(camera, mic)
+
+	s.AddTrack(screen)
+	assertTracks(camera, mic, screen)
+	if n := len(s.GetVideoTracks()); n != 2 {
+		t.Errorf("Expected 2 video tracks, got %d", n)
+	}
+
+	// Adding the same track again must be ignored
+	s.AddTrack(screen)
+	assertTracks(camera, mic, screen)
+
+	s.RemoveTrack(camera)
+	assertTracks(mic, screen)
+	if n := atomic.LoadInt32(&camera.stopped); n != 1 {
+		t.Errorf("Expected removed track to be stopped once, stopped %d times", n)
+	}
+
+	// Removing the track which is not in the stream must not stop it again
+	s.RemoveTrack(camera)
+	if n := atomic.LoadInt32(&camera.stopped); n != 1 {
+		t.Errorf("Expected removed track to be stopped once, stopped %d times", n)
+	}
+	if n := atomic.LoadInt32(&mic.stopped) + atomic.LoadInt32(&screen.stopped); n != 0 {
+		t.Errorf("Tracks in the stream must not be stopped, stopped %d times", n)
+	}
+}
+
+func TestMediaStreamConcurrentAccess(t *testing.T) {
+	s, err := NewMediaStream()
+	if err != nil {
+		t.Fatalf("Failed to create media stream: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tr := newTrackerMock(string(rune('a'+i)), webrtc.RTPCodecTypeVideo)
+			s.AddTrack(tr)
+			s.GetTracks()
+			s.RemoveTrack(tr)
+		}(i)
+	}
+	wg.Wait()
+
+	if n := len(s.GetTracks()); n != 0 {
+		t.Errorf("Expected no tracks, got %d", n)
+	}
+}
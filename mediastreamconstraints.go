@@ -1,6 +1,10 @@
 package mediadevices
 
 import (
+	"image"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/frame"
 	"github.com/pion/mediadevices/pkg/io/audio"
 	"github.com/pion/mediadevices/pkg/io/video"
 	"github.com/pion/mediadevices/pkg/prop"
@@ -14,13 +18,103 @@ type MediaStreamConstraints struct {
 // MediaTrackConstraints represents https://w3c.github.io/mediacapture-main/#dom-mediatrackconstraints
 type MediaTrackConstraints struct {
 	prop.Media
+	// Exact is the set of the properties of Media which must be satisfied
+	// exactly, e.g. prop.ExactWidth|prop.ExactHeight to get the resolution
+	// as is. The devices violating them are rejected even if they are the
+	// closest to the constraints. The other properties are ideal values.
+	Exact   prop.Exact
 	Enabled bool
+	// FrameFormats are the acceptable frame formats of the video device in order
+	// of preference, used in place of FrameFormat, e.g. {frame.FormatI420,
+	// frame.FormatMJPEG} to fall back to MJPEG if the camera lacks I420. The
+	// properties of the devices in the other formats are rejected. The closest
+	// properties to the constraints are selected, and the earlier format is
+	// selected among the equally close ones.
+	FrameFormats []frame.Format
+	// DeviceLabel selects the device whose label contains DeviceLabel.
+	// If there is a device exactly matching DeviceLabel, it's preferred.
+	DeviceLabel string
+	// OpenTimeout is the maximum duration to wait for the device to be opened.
+	// If the device doesn't respond in time, the acquisition fails with
+	// driver.DeviceOpenTimeoutError. If it's 0, there is no timeout.
+	OpenTimeout time.Duration
+	// OpenRetryCount is the number of the retries to open the device, e.g. while
+	// it's still being released by another process. The interval between the
+	// retries starts from OpenRetryInterval and is doubled after each failure.
+	// Devices which timed out by OpenTimeout are not retried since they may be stuck.
+	OpenRetryCount    int
+	OpenRetryInterval time.Duration
 	// VideoTransform will be used to transform the video that's coming from the driver.
 	// So, basically it'll look like following: driver -> VideoTransform -> codec
+	// The transformed reader can return ErrEnded to end the track.
 	VideoTransform video.TransformFunc
 	// AudioTransform will be used to transform the audio that's coming from the driver.
 	// So, basically it'll look like following: driver -> AudioTransform -> code
+	// The transformed reader can return ErrEnded to end the track.
 	AudioTransform audio.TransformFunc
+	// ChannelMap selects the channels of the audio device by the indices, e.g. {2, 4}
+	// to use the third and the fifth channels as stereo, and {0} to use the first
+	// channel as mono. The channels are selected before AudioTransform.
+	// If it's nil, the first 2 channels are used.
+	ChannelMap []int
+	// AudioBufferDuration is the duration of the audio samples buffered between AudioTransform
+	// and the codec. If it's 0, the samples are passed to the codec synchronously.
+	// When the buffer is full, the oldest samples are dropped, so the buffer adds up to
+	// AudioBufferDuration of the latency.
+	AudioBufferDuration time.Duration
+	// AudioConcealDelay enables the concealment of the audio gaps if it's larger than 0.
+	// When the device stalls longer than AudioConcealDelay beyond the duration of the
+	// captured samples, the missing samples are substituted to keep the timing of the
+	// stream. The number of the concealed samples is reported by AudioTracker.Stats.
+	AudioConcealDelay time.Duration
+	// FrameBufferSize is the number of the video frames buffered between VideoTransform and the codec.
+	// If it's 0, the frames are passed to the codec synchronously, so the slow codec blocks the driver.
+	// Each buffered frame may add one frame interval to the latency when the codec is slower than
+	// the capture, so small buffer is preferred for real-time communication and large buffer is
+	// preferred to avoid frame drops on temporary codec slowdown.
+	FrameBufferSize int
+	// FrameDropPolicy decides which frame to drop when the frame buffer is full.
+	// It's effective only if FrameBufferSize is larger than 0.
+	FrameDropPolicy video.DropPolicy
+	// MaxBufferBytes caps the bytes of the frames stored in the frame buffer, e.g.
+	// to prevent OOM of the constrained devices when the codec can't keep up with
+	// the high resolution frames. The frame buffer is full when storing the frame
+	// exceeds it, and the frames are dropped by FrameDropPolicy. The frames larger
+	// than it are always dropped. The drops are reported by VideoTracker.Stats.
+	// 0 means no limit. It's effective only if FrameBufferSize is larger than 0.
+	MaxBufferBytes int
+	// SceneChangeThreshold enables keyframe insertion on scene changes if it's larger than 0.
+	// A keyframe is inserted when the difference between the consecutive frames is larger than
+	// or equal to the threshold in range of 0.0 to 1.0. The codec must implement
+	// codec.KeyFrameController unless the source provides encoded frames; the encoded
+	// frames relayed by the codec, e.g. passthrough, are not checked for scene changes.
+	SceneChangeThreshold float64
+	// OnFrame is called with each captured frame after VideoTransform is applied.
+	// It's called in a separate goroutine on best-effort basis; frames are dropped
+	// while OnFrame is busy, so a slow OnFrame doesn't block the encoding.
+	OnFrame func(image.Image)
+	// OnThumbnail is called with a JPEG thumbnail of the captured frame every
+	// ThumbnailInterval, e.g. to show the participants in a roster. The frames are
+	// downscaled to ThumbnailWidth and ThumbnailHeight; a non-positive one keeps
+	// the aspect ratio. Like OnFrame, it doesn't block the encoding.
+	OnThumbnail       func(jpeg []byte)
+	ThumbnailWidth    int
+	ThumbnailHeight   int
+	ThumbnailInterval time.Duration
+	// OnAudioLevel is called with the RMS level of the captured audio every 100ms.
+	// The level is in range of 0.0 to 1.0. It's called in a separate goroutine,
+	// so it doesn't add latency to the audio.
+	OnAudioLevel func(rms float64)
+	// IsTemporaryWriteError classifies the errors returned by WriteSample of the LocalTrack.
+	// Temporary errors don't end the track. If nil, IsTemporaryError is used.
+	IsTemporaryWriteError func(error) bool
+	// WriteRetryCount is the number of the retries of WriteSample on temporary errors.
+	// If it still fails, the sample is dropped and the capture continues.
+	// Other errors end the track and are passed to the OnEnded handler.
+	WriteRetryCount int
+	// FrameEncryptor encrypts each encoded frame before it's written to the LocalTrack.
+	// The frames are written as is if nil. An error from FrameEncryptor ends the track.
+	FrameEncryptor FrameEncryptor
 }
 
 type MediaOption func(*MediaTrackConstraints)
@@ -0,0 +1,59 @@
+package mediadevices
+
+import (
+	"time"
+
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+// pacedTrack is a LocalTrack which paces WriteSample calls of the inner track.
+type pacedTrack struct {
+	LocalTrack
+	clockRate float64
+	maxBurst  time.Duration
+	next      time.Time
+}
+
+// NewPacedTrack wraps t to pace the samples according to the codec clock rate.
+// WriteSample blocks until the time when the sample should be sent, calculated
+// from the Samples of the previous samples, so that the samples written in burst
+// are sent at the constant rate. This is useful for UDP sinks without congestion
+// control. If the samples are written later than the schedule, up to maxBurst
+// duration of the samples are sent without waiting to catch up.
+func NewPacedTrack(t LocalTrack, maxBurst time.Duration) LocalTrack {
+	return &pacedTrack{
+		LocalTrack: t,
+		clockRate:  float64(t.Codec().ClockRate),
+		maxBurst:   maxBurst,
+	}
+}
+
+func (t *pacedTrack) WriteSample(s media.Sample) error {
+	if t.clockRate == 0 {
+		// Unable to pace without the clock rate
+		return t.LocalTrack.WriteSample(s)
+	}
+
+	now := time.Now()
+	switch {
+	case t.next.IsZero():
+		t.next = now
+	case t.next.After(now):
+		time.Sleep(t.next.Sub(now))
+	case now.Sub(t.next) > t.maxBurst:
+		// Drop the schedule too far in the past to limit the burst
+		t.next = now.Add(-t.maxBurst)
+	}
+
+	if err := t.LocalTrack.WriteSample(s); err != nil {
+		return err
+	}
+
+	t.next = t.next.Add(time.Duration(float64(s.Samples) / t.clockRate * float64(time.Second)))
+	return nil
+}
+
+// Unwrap returns the inner track.
+func (t *pacedTrack) Unwrap() LocalTrack {
+	return t.LocalTrack
+}
@@ -0,0 +1,56 @@
+package mediadevices
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v2"
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+type timestampTrackMock struct {
+	localTrackMock
+	written []time.Time
+}
+
+func (t *timestampTrackMock) WriteSample(s media.Sample) error {
+	t.written = append(t.written, time.Now())
+	return nil
+}
+
+func TestPacedTrack(t *testing.T) {
+	codec := &webrtc.RTPCodec{Type: webrtc.RTPCodecTypeAudio}
+	codec.ClockRate = 48000
+	inner := &timestampTrackMock{localTrackMock: localTrackMock{codec: codec}}
+	track := NewPacedTrack(inner, 40*time.Millisecond)
+
+	// Write 20ms samples in burst
+	for i := 0; i < 10; i++ {
+		if err := track.WriteSample(media.Sample{Data: []byte{0}, Samples: 960}); err != nil {
+			t.Fatalf("Failed to write sample: %v", err)
+		}
+	}
+	for i := 1; i < len(inner.written); i++ {
+		interval := inner.written[i].Sub(inner.written[i-1])
+		if interval < 15*time.Millisecond || 30*time.Millisecond < interval {
+			t.Errorf("Expected interval of 20ms, got %v", interval)
+		}
+	}
+
+	// Stall longer than the max burst and write in burst again
+	time.Sleep(100 * time.Millisecond)
+	inner.written = nil
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := track.WriteSample(media.Sample{Data: []byte{0}, Samples: 960}); err != nil {
+			t.Fatalf("Failed to write sample: %v", err)
+		}
+	}
+	// 40ms of samples are sent immediately and the rest are paced.
+	for i, expected := range []time.Duration{0, 0, 0, 20 * time.Millisecond, 40 * time.Millisecond} {
+		delay := inner.written[i].Sub(start)
+		if delay < expected-5*time.Millisecond || expected+10*time.Millisecond < delay {
+			t.Errorf("Expected sample %d to be sent after %v, got %v", i, expected, delay)
+		}
+	}
+}
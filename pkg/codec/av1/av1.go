@@ -0,0 +1,300 @@
+// +build aom
+
+package av1
+
+// #cgo pkg-config: aom
+// #include <stdlib.h>
+// #include <aom/aom_encoder.h>
+// #include <aom/aom_image.h>
+// #include <aom/aomcx.h>
+//
+// // C function pointers
+// aom_codec_iface_t *ifaceAV1() {
+//   return aom_codec_av1_cx();
+// }
+//
+// // C union helpers
+// void *pktBuf(aom_codec_cx_pkt_t *pkt) {
+//   return pkt->data.frame.buf;
+// }
+// int pktSz(aom_codec_cx_pkt_t *pkt) {
+//   return pkt->data.frame.sz;
+// }
+//
+// // Alloc helpers
+// aom_codec_ctx_t *newCtx() {
+//   return malloc(sizeof(aom_codec_ctx_t));
+// }
+// aom_image_t *newImage() {
+//   return malloc(sizeof(aom_image_t));
+// }
+//
+// // Variadic function wrapper
+// aom_codec_err_t setCPUUsed(aom_codec_ctx_t *codec, int v) {
+//   return aom_codec_control(codec, AOME_SET_CPUUSED, v);
+// }
+//
+// // Wrap encode function to keep Go memory safe
+// aom_codec_err_t encode_wrapper(
+//     aom_codec_ctx_t* codec, aom_image_t* raw,
+//     long t, unsigned long dt, long flags,
+//     unsigned char *y_ptr, unsigned char *cb_ptr, unsigned char *cr_ptr) {
+//   raw->planes[0] = y_ptr;
+//   raw->planes[1] = cb_ptr;
+//   raw->planes[2] = cr_ptr;
+//   aom_codec_err_t ret = aom_codec_encode(codec, raw, t, dt, flags);
+//   raw->planes[0] = raw->planes[1] = raw->planes[2] = 0;
+//   return ret;
+// }
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/frame"
+	mio "github.com/pion/mediadevices/pkg/io"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+// Name is the name of AV1 codec used to register the encoder.
+const Name = "AV1"
+
+// maxCPUUsed is the fastest cpu-used value supported by libaom.
+// prop.Codec.Quality [0-9] is mapped to cpu-used [maxCPUUsed-0].
+const maxCPUUsed = 8
+
+type encoder struct {
+	codec      *C.aom_codec_ctx_t
+	raw        *C.aom_image_t
+	cfg        *C.aom_codec_enc_cfg_t
+	r          video.RawReader
+	ratio      image.YCbCrSubsampleRatio
+	frameIndex int
+	buff       []byte
+	frame      []byte
+	// timer calculates the presentation time and the duration of the frames
+	timer *codec.FrameTimer
+
+	forceKeyFrame int32 // accessed atomically
+}
+
+func init() {
+	codec.Register(Name, codec.VideoEncoderBuilder(NewEncoder))
+	codec.RegisterVideoInputFormats(Name, frame.FormatI420, frame.FormatI422, frame.FormatI444)
+}
+
+// chromaFormat returns the image format, the AV1 profile and the subsample
+// ratio encoding the frames of format f. Formats other than FormatI422 and
+// FormatI444 are converted to I420 which is supported by the main profile.
+func chromaFormat(f frame.Format) (C.aom_img_fmt_t, C.uint, image.YCbCrSubsampleRatio) {
+	switch f {
+	case frame.FormatI422:
+		// Professional profile
+		return C.AOM_IMG_FMT_I422, 2, image.YCbCrSubsampleRatio422
+	case frame.FormatI444:
+		// High profile
+		return C.AOM_IMG_FMT_I444, 1, image.YCbCrSubsampleRatio444
+	default:
+		return C.AOM_IMG_FMT_I420, 0, image.YCbCrSubsampleRatio420
+	}
+}
+
+// rangePct returns the difference between the target bitrate and the bound
+// in percentage of the target.
+func rangePct(target, bound int) int {
+	d := bound - target
+	if d < 0 {
+		d = -d
+	}
+	return d * 100 / target
+}
+
+// NewEncoder creates new AV1 encoder
+func NewEncoder(r video.Reader, p prop.Media) (io.ReadCloser, error) {
+	if p.BitRate == 0 {
+		p.BitRate = 100000
+	}
+
+	if p.KeyFrameInterval == 0 {
+		p.KeyFrameInterval = 60
+	}
+
+	if p.Quality < 0 || p.Quality > 9 {
+		return nil, errors.New("av1: quality must be in [0-9]")
+	}
+
+	if p.Threads < 0 {
+		return nil, errors.New("av1: threads must not be negative")
+	}
+
+	codecIface := C.ifaceAV1()
+	cfg := &C.aom_codec_enc_cfg_t{}
+	if ec := C.aom_codec_enc_config_default(codecIface, cfg, 0); ec != 0 {
+		return nil, fmt.Errorf("aom_codec_enc_config_default failed (%d)", ec)
+	}
+	cfg.g_w = C.uint(p.Width)
+	cfg.g_h = C.uint(p.Height)
+	cfg.g_timebase.num = 1
+	cfg.g_timebase.den = 1000
+	cfg.rc_target_bitrate = C.uint(p.BitRate) / 1000
+	cfg.kf_max_dist = C.uint(p.KeyFrameInterval)
+	// 0 keeps the single thread of the default configuration
+	cfg.g_threads = C.uint(p.Threads)
+
+	cfg.rc_resize_mode = 0
+	cfg.rc_end_usage = C.AOM_CBR
+	if p.BitRateMode == prop.BitRateModeVBR {
+		cfg.rc_end_usage = C.AOM_VBR
+		// The range is relative to the target bitrate in libaom
+		if p.MinBitRate > 0 {
+			cfg.rc_undershoot_pct = C.uint(rangePct(p.BitRate, p.MinBitRate))
+		}
+		if p.MaxBitRate > 0 {
+			cfg.rc_overshoot_pct = C.uint(rangePct(p.BitRate, p.MaxBitRate))
+		}
+	}
+	cfg.g_lag_in_frames = 0
+	cfg.g_pass = C.AOM_RC_ONE_PASS
+
+	imgFmt, profile, ratio := chromaFormat(p.FrameFormat)
+	cfg.g_profile = profile
+
+	raw := &C.aom_image_t{}
+	if C.aom_img_alloc(raw, imgFmt, cfg.g_w, cfg.g_h, 1) == nil {
+		return nil, errors.New("aom_img_alloc failed")
+	}
+	rawNoBuffer := C.newImage()
+	*rawNoBuffer = *raw // Copy only parameters
+	C.aom_img_free(raw) // Pointers will be overwritten by the raw buffer
+
+	timer := codec.NewFrameTimer(p.FrameRate)
+	codec := C.newCtx()
+	if ec := C.aom_codec_enc_init_ver(
+		codec, codecIface, cfg, 0, C.AOM_ENCODER_ABI_VERSION,
+	); ec != 0 {
+		C.free(unsafe.Pointer(rawNoBuffer))
+		C.free(unsafe.Pointer(codec))
+		return nil, fmt.Errorf("aom_codec_enc_init failed (%d)", ec)
+	}
+
+	cpuUsed := maxCPUUsed - p.Quality*maxCPUUsed/9
+	if ec := C.setCPUUsed(codec, C.int(cpuUsed)); ec != C.AOM_CODEC_OK {
+		C.aom_codec_destroy(codec)
+		C.free(unsafe.Pointer(rawNoBuffer))
+		C.free(unsafe.Pointer(codec))
+		return nil, fmt.Errorf("aom_codec_control(AOME_SET_CPUUSED) failed (%d)", ec)
+	}
+
+	// 4:2:2 and 4:4:4 frames are encoded without subsampling the chroma
+	if ratio == image.YCbCrSubsampleRatio420 {
+		r = video.ToI420(r)
+	}
+
+	return &encoder{
+		r:     video.ToRaw(r),
+		ratio: ratio,
+		codec: codec,
+		raw:   rawNoBuffer,
+		cfg:   cfg,
+		frame: make([]byte, 1024),
+		timer: timer,
+	}, nil
+}
+
+func (e *encoder) Read(p []byte) (int, error) {
+	if e.buff != nil {
+		n, err := mio.Copy(p, e.buff)
+		if err == nil {
+			e.buff = nil
+		}
+		return n, err
+	}
+
+	f, err := e.r.ReadRaw()
+	if err != nil {
+		return 0, err
+	}
+	img, err := f.Image()
+	if err != nil {
+		return 0, err
+	}
+	yuvImg, ok := img.(*image.YCbCr)
+	if !ok || yuvImg.SubsampleRatio != e.ratio {
+		return 0, fmt.Errorf("av1: frame must be YCbCr %s, got %T", e.ratio, img)
+	}
+	bounds := yuvImg.Bounds()
+	height := C.int(bounds.Dy())
+	width := C.int(bounds.Dx())
+
+	e.raw.stride[0] = C.int(yuvImg.YStride)
+	e.raw.stride[1] = C.int(yuvImg.CStride)
+	e.raw.stride[2] = C.int(yuvImg.CStride)
+
+	if e.cfg.g_w != C.uint(width) || e.cfg.g_h != C.uint(height) {
+		e.cfg.g_w, e.cfg.g_h = C.uint(width), C.uint(height)
+		if ec := C.aom_codec_enc_config_set(e.codec, e.cfg); ec != C.AOM_CODEC_OK {
+			return 0, fmt.Errorf("aom_codec_enc_config_set failed (%d)", ec)
+		}
+		e.raw.w, e.raw.h = C.uint(width), C.uint(height)
+		e.raw.r_w, e.raw.r_h = C.uint(width), C.uint(height)
+		e.raw.d_w, e.raw.d_h = C.uint(width), C.uint(height)
+	}
+
+	// Use the actual frame timing, so that the rate control keeps the target bitrate
+	// even if the frames are captured or dropped irregularly. The time base is 1ms.
+	pts, duration := e.timer.Next(f.Timestamp)
+
+	var flags int
+	if atomic.CompareAndSwapInt32(&e.forceKeyFrame, 1, 0) {
+		flags |= C.AOM_EFLAG_FORCE_KF
+	}
+	if ec := C.encode_wrapper(
+		e.codec, e.raw,
+		C.long(pts.Milliseconds()), C.ulong(duration.Milliseconds()), C.long(flags),
+		(*C.uchar)(&yuvImg.Y[0]), (*C.uchar)(&yuvImg.Cb[0]), (*C.uchar)(&yuvImg.Cr[0]),
+	); ec != C.AOM_CODEC_OK {
+		return 0, fmt.Errorf("aom_codec_encode failed (%d)", ec)
+	}
+
+	e.frameIndex++
+
+	e.frame = e.frame[:0]
+	var iter C.aom_codec_iter_t
+	for {
+		pkt := C.aom_codec_get_cx_data(e.codec, &iter)
+		if pkt == nil {
+			break
+		}
+		if pkt.kind == C.AOM_CODEC_CX_FRAME_PKT {
+			encoded := C.GoBytes(unsafe.Pointer(C.pktBuf(pkt)), C.pktSz(pkt))
+			e.frame = append(e.frame, encoded...)
+		}
+	}
+	n, err := mio.Copy(p, e.frame)
+	if err != nil {
+		e.buff = e.frame
+	}
+	return n, err
+}
+
+func (e *encoder) ForceKeyFrame() error {
+	atomic.StoreInt32(&e.forceKeyFrame, 1)
+	return nil
+}
+
+func (e *encoder) Close() error {
+	C.free(unsafe.Pointer(e.raw))
+	defer C.free(unsafe.Pointer(e.codec))
+
+	if C.aom_codec_destroy(e.codec) != 0 {
+		return errors.New("aom_codec_destroy failed")
+	}
+	return nil
+}
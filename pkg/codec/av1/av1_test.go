@@ -0,0 +1,108 @@
+// +build aom
+
+package av1
+
+import (
+	"fmt"
+	"image"
+	"testing"
+
+	mio "github.com/pion/mediadevices/pkg/io"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+const obuSequenceHeader = 1
+
+// obuTypes parses AV1 low overhead bitstream and returns the list of OBU types.
+func obuTypes(b []byte) []int {
+	var types []int
+	for len(b) > 0 {
+		header := b[0]
+		types = append(types, int(header>>3)&0x0F)
+		b = b[1:]
+		if header&0x04 != 0 {
+			// Skip extension header
+			b = b[1:]
+		}
+		if header&0x02 == 0 {
+			// No size field, the OBU extends to the end of the data
+			break
+		}
+		var size, shift uint
+		for len(b) > 0 {
+			v := b[0]
+			b = b[1:]
+			size |= uint(v&0x7F) << shift
+			shift += 7
+			if v&0x80 == 0 {
+				break
+			}
+		}
+		if int(size) > len(b) {
+			break
+		}
+		b = b[size:]
+	}
+	return types
+}
+
+func TestEncode(t *testing.T) {
+	for _, threads := range []int{0, 1, 4} {
+		threads := threads
+		t.Run(fmt.Sprintf("Threads%d", threads), func(t *testing.T) {
+			testEncode(t, threads)
+		})
+	}
+}
+
+func testEncode(t *testing.T, threads int) {
+	const width, height = 64, 64
+	img := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+	for i := range img.Y {
+		img.Y[i] = uint8(i)
+	}
+
+	e, err := NewEncoder(video.ReaderFunc(func() (image.Image, error) {
+		return img, nil
+	}), prop.Media{
+		Video: prop.Video{
+			Width:  width,
+			Height: height,
+		},
+		Codec: prop.Codec{
+			Threads: threads,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	defer e.Close()
+
+	buff := make([]byte, 1024)
+	var n int
+	for {
+		n, err = e.Read(buff)
+		if e, ok := err.(*mio.InsufficientBufferError); ok {
+			buff = make([]byte, 2*e.RequiredSize)
+			continue
+		}
+		break
+	}
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	if n == 0 {
+		t.Fatal("Encoded frame is empty")
+	}
+
+	var found bool
+	for _, typ := range obuTypes(buff[:n]) {
+		if typ == obuSequenceHeader {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Sequence header is not found in the first frame")
+	}
+}
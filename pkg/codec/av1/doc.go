@@ -0,0 +1,8 @@
+// Package av1 implements AV1 encoder.
+// This package requires libaom headers and libraries to be built,
+// and has to be enabled by the aom build tag:
+//
+//	go build -tags aom
+//
+// Without the tag, importing this package doesn't register any encoder.
+package av1
@@ -10,3 +10,80 @@ import (
 
 type VideoEncoderBuilder func(r video.Reader, p prop.Media) (io.ReadCloser, error)
 type AudioEncoderBuilder func(r audio.Reader, p prop.Media) (io.ReadCloser, error)
+
+// KeyFrameController is implemented by the video encoders which can generate
+// a keyframe on demand.
+type KeyFrameController interface {
+	// ForceKeyFrame requests the encoder to encode the next frame as a keyframe.
+	// It's safe to call ForceKeyFrame from a goroutine other than the reader's one.
+	ForceKeyFrame() error
+}
+
+// ResolutionController is implemented by the video encoders which can change
+// the resolution without being rebuilt.
+type ResolutionController interface {
+	// SetResolution prepares the encoder for the frames of the new resolution.
+	// The encoder reconfigures itself when it receives the first frame of the
+	// new resolution. The track forces a keyframe at the frame unless the
+	// encoder reports by ReconfigurationReporter that it's not needed.
+	SetResolution(width, height int) error
+}
+
+// BitRateController is implemented by the video encoders which can change
+// the target bitrate without being rebuilt.
+type BitRateController interface {
+	// SetBitRate sets the target bitrate in bps. It's applied from the next
+	// frame. It's safe to call SetBitRate from a goroutine other than the
+	// reader's one.
+	SetBitRate(bitRate int) error
+}
+
+// Reconfiguration is a change of the encoder settings applied without
+// rebuilding the encoder.
+type Reconfiguration int
+
+const (
+	// ReconfigureBitRate is the change by BitRateController.
+	ReconfigureBitRate Reconfiguration = iota
+	// ReconfigureResolution is the change by ResolutionController.
+	ReconfigureResolution
+)
+
+// ReconfigurationReporter is implemented by the video encoders which can report
+// whether a reconfiguration needs a keyframe, e.g. VP9 can scale the reference
+// frames to the new resolution while VP8 can't. If the encoder doesn't
+// implement it, the track forces a keyframe at the change of the resolution
+// but not at the change of the bitrate.
+type ReconfigurationReporter interface {
+	// NeedsKeyFrame returns true if the frames encoded after r can't be decoded
+	// without a keyframe.
+	NeedsKeyFrame(r Reconfiguration) bool
+}
+
+// ParameterSetReader is implemented by the H.264 encoders which can report the
+// parameter sets, e.g. to signal them out-of-band by sprop-parameter-sets of SDP.
+type ParameterSetReader interface {
+	// ParameterSets returns the NAL units of the current SPS and PPS without
+	// the start codes. It returns nil until the first keyframe is encoded.
+	// It's safe to call ParameterSets from a goroutine other than the reader's one.
+	ParameterSets() [][]byte
+}
+
+// Flusher is implemented by the encoders buffering frames, e.g. for lookahead
+// or B-frames. The encoder is flushed when the track is stopped so that the
+// trailing frames are not lost.
+type Flusher interface {
+	// Flush makes the encoder stop reading the source and encode the buffered
+	// frames. The following Reads return the frames and then io.EOF.
+	// Flush is called from the reader's goroutine.
+	Flush() error
+}
+
+// FrameSampleCounter is implemented by the audio encoders which can report the
+// duration of the encoded frames. It's required to generate correct timestamps
+// if the encoder outputs frames of variable duration.
+type FrameSampleCounter interface {
+	// LastFrameSamples returns the number of the samples per channel represented
+	// by the last frame returned by Read.
+	LastFrameSamples() int
+}
@@ -23,24 +23,40 @@ Encoder *enc_new(const EncoderOptions opts) {
   }
 
   // TODO: Remove hardcoded values
-  params.iUsageType = CAMERA_VIDEO_REAL_TIME;
+  params.iUsageType = (EUsageType)opts.usage_type;
   params.iPicWidth = opts.width;
   params.iPicHeight = opts.height;
   params.iTargetBitrate = opts.target_bitrate;
-  params.iMaxBitrate = opts.target_bitrate;
-  params.iRCMode = RC_BITRATE_MODE;
+  params.iMaxBitrate = opts.max_bitrate;
+  params.iRCMode = (RC_MODES)opts.rc_mode;
   params.fMaxFrameRate = opts.max_fps;
-  params.bEnableFrameSkip = true;
+  // The bitrate can't be bounded without skipping the frames
+  params.bEnableFrameSkip = opts.rc_mode == RC_BITRATE_MODE ||
+                            opts.max_bitrate != UNSPECIFIED_BIT_RATE;
   params.uiMaxNalSize = 0;
-  params.uiIntraPeriod = 30;
-  // set to 0, so that it'll automatically use multi threads when needed
-  params.iMultipleThreadIdc = 0;
+  params.uiIntraPeriod = opts.intra_period;
+  // 0 means that it'll automatically use multi threads when needed
+  params.iMultipleThreadIdc = opts.threads;
   // The base spatial layer 0 is the only one we use.
   params.sSpatialLayers[0].iVideoWidth = params.iPicWidth;
   params.sSpatialLayers[0].iVideoHeight = params.iPicHeight;
   params.sSpatialLayers[0].fFrameRate = params.fMaxFrameRate;
   params.sSpatialLayers[0].iSpatialBitrate = params.iTargetBitrate;
-  params.sSpatialLayers[0].iMaxSpatialBitrate = params.iTargetBitrate;
+  params.sSpatialLayers[0].iMaxSpatialBitrate = params.iMaxBitrate;
+  // 0 means automatic selection by the encoder
+  params.sSpatialLayers[0].uiProfileIdc = (EProfileIdc)opts.profile;
+  params.sSpatialLayers[0].uiLevelIdc = (ELevelIdc)opts.level;
+  if (opts.profile == PRO_MAIN || opts.profile == PRO_HIGH) {
+    // CABAC is not allowed in baseline profile
+    params.iEntropyCodingModeFlag = 1;
+  }
+  if (opts.complexity >= 0) {
+    params.iComplexityMode = (ECOMPLEXITY_MODE)opts.complexity;
+  }
+  if (opts.constant_ps_id) {
+    // The parameter sets sent out-of-band must be valid for all keyframes
+    params.eSpsPpsIdStrategy = CONSTANT_ID;
+  }
   // Single NAL unit mode
   params.sSpatialLayers[0].sSliceArgument.uiSliceNum = 1;
   params.sSpatialLayers[0].sSliceArgument.uiSliceMode = SM_SIZELIMITED_SLICE;
@@ -89,6 +105,8 @@ Slice enc_encode(Encoder *e, Frame f) {
   pic.pData[0] = (unsigned char *)f.y;
   pic.pData[1] = (unsigned char *)f.u;
   pic.pData[2] = (unsigned char *)f.v;
+  // The rate control uses the timestamps to calculate the frame rate
+  pic.uiTimeStamp = f.timestamp;
 
   rv = e->engine->EncodeFrame(&pic, &info);
   if (rv != 0) {
@@ -118,4 +136,26 @@ Slice enc_encode(Encoder *e, Frame f) {
 
   Slice s = {.data = e->buff, .data_len = size};
   return s;
-}
\ No newline at end of file
+}
+
+void enc_force_key_frame(Encoder *e) { e->engine->ForceIntraFrame(true); }
+
+int enc_set_bitrate(Encoder *e, int bitrate) {
+  SBitrateInfo info;
+  info.iLayer = SPATIAL_LAYER_ALL;
+  if (e->params.iRCMode == RC_BITRATE_MODE) {
+    // The maximum bitrate follows the target in the bitrate mode
+    info.iBitrate = bitrate;
+    int rv = e->engine->SetOption(ENCODER_OPTION_MAX_BITRATE, &info);
+    if (rv != 0) {
+      return rv;
+    }
+    e->params.iMaxBitrate = bitrate;
+  } else if (e->params.iMaxBitrate != UNSPECIFIED_BIT_RATE &&
+             e->params.iMaxBitrate < bitrate) {
+    // The target is clamped to the cap of the quality mode
+    bitrate = e->params.iMaxBitrate;
+  }
+  info.iBitrate = bitrate;
+  return e->engine->SetOption(ENCODER_OPTION_BITRATE, &info);
+}
@@ -14,12 +14,23 @@ typedef struct Frame {
   void *y, *u, *v;
   int height;
   int width;
+  // presentation time in milliseconds
+  long long timestamp;
 } Frame;
 
 typedef struct EncoderOptions {
   int width, height;
   int target_bitrate;
+  int max_bitrate;
+  int rc_mode;
   float max_fps;
+  int intra_period;
+  int threads;
+  int profile;
+  int level;
+  int usage_type;
+  int constant_ps_id;
+  int complexity;
 } EncoderOptions;
 
 typedef struct Encoder {
@@ -32,6 +43,8 @@ typedef struct Encoder {
 Encoder *enc_new(const EncoderOptions params);
 void enc_free(Encoder *e);
 Slice enc_encode(Encoder *e, Frame f);
+void enc_force_key_frame(Encoder *e);
+int enc_set_bitrate(Encoder *e, int bitrate);
 #ifdef __cplusplus
 }
 #endif
\ No newline at end of file
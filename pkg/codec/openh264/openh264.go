@@ -10,12 +10,16 @@ package openh264
 import "C"
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
-	"image"
 	"io"
+	"sync"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/frame"
 	mio "github.com/pion/mediadevices/pkg/io"
 	"github.com/pion/mediadevices/pkg/io/video"
 	"github.com/pion/mediadevices/pkg/prop"
@@ -25,26 +29,123 @@ import (
 
 type encoder struct {
 	engine *C.Encoder
-	r      video.Reader
+	r      video.RawReader
 	buff   []byte
+	// timer calculates the presentation time of the frames
+	timer *codec.FrameTimer
+
+	forceKeyFrame int32 // accessed atomically
+	bitRate       int64 // accessed atomically, 0 if not changed
+
+	omitRepeatedParameterSets bool
+	// parameterSets are the last SPS and PPS with the start codes
+	mu            sync.Mutex
+	parameterSets [][]byte
 }
 
+// Profile is H.264 profile_idc.
+type Profile int
+
+// List of the supported profiles
+const (
+	ProfileAuto     Profile = C.PRO_UNKNOWN
+	ProfileBaseline Profile = C.PRO_BASELINE
+	ProfileMain     Profile = C.PRO_MAIN
+	ProfileHigh     Profile = C.PRO_HIGH
+)
+
+// Level is H.264 level_idc. (e.g. 31 for level 3.1)
+// 0 means automatic selection by the encoder.
+type Level int
+
+// UsageType is a hint for the encoder to optimize the encoding for the content.
+type UsageType int
+
+// List of the usage types
+const (
+	UsageCamera UsageType = C.CAMERA_VIDEO_REAL_TIME
+	UsageScreen UsageType = C.SCREEN_CONTENT_REAL_TIME
+)
+
+// Params stores H.264 specific encoding parameters.
+// Zero value uses the default parameters of the encoder.
+type Params struct {
+	Profile   Profile
+	Level     Level
+	UsageType UsageType
+	// BFrames is the number of the consecutive B-frames in GOP.
+	// openh264 only encodes I and P frames, so it must be 0, which is also
+	// required by most WebRTC implementations. The GOP length is set by
+	// prop.Codec.KeyFrameInterval.
+	BFrames int
+	// OmitRepeatedParameterSets stops repeating SPS and PPS in-band with each
+	// keyframe. They are written only when they change, e.g. with the first
+	// keyframe, and the receiver must get them out-of-band by
+	// codec.ParameterSetReader. Some decoders fail to decode keyframes without them.
+	OmitRepeatedParameterSets bool
+}
+
+var errBFramesUnsupported = errors.New("openh264: B-frames are not supported")
+
 var _ codec.VideoEncoderBuilder = codec.VideoEncoderBuilder(NewEncoder)
 
 func init() {
 	codec.Register(webrtc.H264, codec.VideoEncoderBuilder(NewEncoder))
+	codec.RegisterVideoInputFormats(webrtc.H264, frame.FormatI420)
+}
+
+// NewEncoderBuilder returns the encoder builder with given H.264 parameters.
+// To use it by MediaDevices, register it in place of the default one:
+//
+//	codec.Register(webrtc.H264, openh264.NewEncoderBuilder(params))
+func NewEncoderBuilder(params Params) codec.VideoEncoderBuilder {
+	return func(r video.Reader, p prop.Media) (io.ReadCloser, error) {
+		return newEncoder(r, p, params)
+	}
 }
 
+// NewEncoder creates new H.264 encoder with the default parameters.
 func NewEncoder(r video.Reader, p prop.Media) (io.ReadCloser, error) {
+	return newEncoder(r, p, Params{})
+}
+
+func newEncoder(r video.Reader, p prop.Media, params Params) (io.ReadCloser, error) {
 	if p.BitRate == 0 {
 		p.BitRate = 100000
 	}
 
+	if p.KeyFrameInterval == 0 {
+		p.KeyFrameInterval = 30
+	}
+
+	if p.Threads < 0 {
+		return nil, fmt.Errorf("openh264: threads must not be negative")
+	}
+
+	if params.BFrames != 0 {
+		return nil, errBFramesUnsupported
+	}
+
+	rcMode, maxBitRate := C.RC_BITRATE_MODE, p.BitRate
+	if p.BitRateMode == prop.BitRateModeVBR {
+		// openh264 doesn't have the lower bound of the bitrate, so MinBitRate is ignored
+		rcMode, maxBitRate = C.RC_QUALITY_MODE, p.MaxBitRate
+	}
+
 	cEncoder, err := C.enc_new(C.EncoderOptions{
 		width:          C.int(p.Width),
 		height:         C.int(p.Height),
 		target_bitrate: C.int(p.BitRate),
+		max_bitrate:    C.int(maxBitRate),
+		rc_mode:        C.int(rcMode),
 		max_fps:        C.float(p.FrameRate),
+		intra_period:   C.int(p.KeyFrameInterval),
+		threads:        C.int(p.Threads),
+		profile:        C.int(params.Profile),
+		level:          C.int(params.Level),
+		usage_type:     C.int(params.UsageType),
+		constant_ps_id: C.int(boolToInt(params.OmitRepeatedParameterSets)),
+		complexity:     C.int(complexity(p.LatencyMode)),
 	})
 	if err != nil {
 		// TODO: better error message
@@ -52,11 +153,36 @@ func NewEncoder(r video.Reader, p prop.Media) (io.ReadCloser, error) {
 	}
 
 	return &encoder{
-		engine: cEncoder,
-		r:      video.ToI420(r),
+		engine:                    cEncoder,
+		r:                         video.ToRaw(video.ToI420(r)),
+		timer:                     codec.NewFrameTimer(p.FrameRate),
+		omitRepeatedParameterSets: params.OmitRepeatedParameterSets,
 	}, nil
 }
 
+// complexity returns the complexity mode of the encoder for the latency mode,
+// or -1 to use the default. openh264 doesn't have the lookahead, so the latency
+// mode only changes the encoding speed.
+func complexity(mode prop.LatencyMode) int {
+	switch mode {
+	case prop.LatencyModeRealtime:
+		return C.LOW_COMPLEXITY
+	case prop.LatencyModeBalanced:
+		return C.MEDIUM_COMPLEXITY
+	case prop.LatencyModeQuality:
+		return C.HIGH_COMPLEXITY
+	default:
+		return -1
+	}
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 func (e *encoder) Read(p []byte) (n int, err error) {
 	if e.buff != nil {
 		n, err = mio.Copy(p, e.buff)
@@ -67,26 +193,38 @@ func (e *encoder) Read(p []byte) (n int, err error) {
 		return n, err
 	}
 
-	img, err := e.r.Read()
+	f, err := e.r.ReadRaw()
 	if err != nil {
 		return 0, err
 	}
 
-	yuvImg := img.(*image.YCbCr)
-	bounds := yuvImg.Bounds()
+	if atomic.CompareAndSwapInt32(&e.forceKeyFrame, 1, 0) {
+		C.enc_force_key_frame(e.engine)
+	}
+
+	if bitRate := atomic.SwapInt64(&e.bitRate, 0); bitRate > 0 {
+		if rv := C.enc_set_bitrate(e.engine, C.int(bitRate)); rv != 0 {
+			return 0, fmt.Errorf("openh264: failed to set bitrate (%d)", rv)
+		}
+	}
+
+	// Use the actual frame timing, so that the rate control keeps the target bitrate
+	// even if the frames are captured or dropped irregularly.
+	pts, _ := e.timer.Next(f.Timestamp)
 	s, err := C.enc_encode(e.engine, C.Frame{
-		y:      unsafe.Pointer(&yuvImg.Y[0]),
-		u:      unsafe.Pointer(&yuvImg.Cb[0]),
-		v:      unsafe.Pointer(&yuvImg.Cr[0]),
-		height: C.int(bounds.Max.Y - bounds.Min.Y),
-		width:  C.int(bounds.Max.X - bounds.Min.X),
+		y:         unsafe.Pointer(&f.Planes[0][0]),
+		u:         unsafe.Pointer(&f.Planes[1][0]),
+		v:         unsafe.Pointer(&f.Planes[2][0]),
+		height:    C.int(f.Height),
+		width:     C.int(f.Width),
+		timestamp: C.longlong(pts.Milliseconds()),
 	})
 	if err != nil {
 		// TODO: better error message
 		return 0, fmt.Errorf("failed in encoding")
 	}
 
-	encoded := C.GoBytes(unsafe.Pointer(s.data), s.data_len)
+	encoded := e.filterParameterSets(C.GoBytes(unsafe.Pointer(s.data), s.data_len))
 	n, err = mio.Copy(p, encoded)
 	if err != nil {
 		e.buff = encoded
@@ -95,6 +233,98 @@ func (e *encoder) Read(p []byte) (n int, err error) {
 	return n, err
 }
 
+const (
+	nalTypeSPS = 7
+	nalTypePPS = 8
+)
+
+// splitNALUnits splits H.264 Annex-B byte stream into NAL units with the start codes.
+func splitNALUnits(b []byte) [][]byte {
+	var nals [][]byte
+	start := -1
+	for i := 0; i+2 < len(b); i++ {
+		if b[i] != 0 || b[i+1] != 0 || b[i+2] != 1 {
+			continue
+		}
+		// 4 bytes start code
+		begin := i
+		if i > 0 && b[i-1] == 0 {
+			begin = i - 1
+		}
+		if start >= 0 {
+			nals = append(nals, b[start:begin])
+		}
+		start = begin
+		i += 2
+	}
+	if start >= 0 {
+		nals = append(nals, b[start:])
+	}
+	return nals
+}
+
+// filterParameterSets records SPS and PPS of the encoded frame, and drops the
+// unchanged ones if they are not repeated in-band.
+func (e *encoder) filterParameterSets(encoded []byte) []byte {
+	var sets, others [][]byte
+	for _, nal := range splitNALUnits(encoded) {
+		// The header is missing after the trailing start code of a truncated stream
+		if h := bytes.IndexByte(nal, 1) + 1; h < len(nal) {
+			switch nal[h] & 0x1F {
+			case nalTypeSPS, nalTypePPS:
+				sets = append(sets, nal)
+				continue
+			}
+		}
+		others = append(others, nal)
+	}
+	if len(sets) == 0 {
+		return encoded
+	}
+
+	e.mu.Lock()
+	changed := len(sets) != len(e.parameterSets)
+	for i := 0; !changed && i < len(sets); i++ {
+		changed = !bytes.Equal(sets[i], e.parameterSets[i])
+	}
+	if changed {
+		e.parameterSets = sets
+	}
+	e.mu.Unlock()
+
+	if changed || !e.omitRepeatedParameterSets {
+		return encoded
+	}
+	return bytes.Join(others, nil)
+}
+
+// ParameterSets implements codec.ParameterSetReader.
+func (e *encoder) ParameterSets() [][]byte {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var sets [][]byte
+	for _, nal := range e.parameterSets {
+		sets = append(sets, append([]byte(nil), nal[bytes.IndexByte(nal, 1)+1:]...))
+	}
+	return sets
+}
+
+func (e *encoder) ForceKeyFrame() error {
+	atomic.StoreInt32(&e.forceKeyFrame, 1)
+	return nil
+}
+
+// SetBitRate implements codec.BitRateController. The bitrate is clamped to
+// MaxBitRate in BitRateModeVBR.
+func (e *encoder) SetBitRate(bitRate int) error {
+	if bitRate <= 0 {
+		return fmt.Errorf("openh264: invalid bitrate %d", bitRate)
+	}
+	atomic.StoreInt64(&e.bitRate, int64(bitRate))
+	return nil
+}
+
 func (e *encoder) Close() error {
 	C.enc_free(e.engine)
 	return nil
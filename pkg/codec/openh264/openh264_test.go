@@ -0,0 +1,531 @@
+package openh264
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math/rand"
+	"testing"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	mio "github.com/pion/mediadevices/pkg/io"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+const (
+	nalTypeSlice = 1
+	nalTypeIDR   = 5
+)
+
+// nalUnits splits H.264 Annex-B byte stream into NAL units.
+func nalUnits(b []byte) [][]byte {
+	var nals [][]byte
+	start := -1
+	for i := 0; i+2 < len(b); i++ {
+		if b[i] == 0 && b[i+1] == 0 && b[i+2] == 1 {
+			if start >= 0 {
+				nals = append(nals, b[start:i])
+			}
+			start = i + 3
+			i += 2
+		}
+	}
+	if start >= 0 {
+		nals = append(nals, b[start:])
+	}
+	return nals
+}
+
+// sliceType parses the beginning of the slice header and returns slice_type.
+// The values 0 and 5 are P, 1 and 6 are B, and 2 and 7 are I.
+func sliceType(nal []byte) int {
+	var pos int
+	bit := func() int {
+		v := int(nal[1+pos/8]>>(7-uint(pos%8))) & 1
+		pos++
+		return v
+	}
+	ue := func() int {
+		var zeros uint
+		for bit() == 0 {
+			zeros++
+		}
+		v := 1
+		for i := uint(0); i < zeros; i++ {
+			v = v<<1 | bit()
+		}
+		return v - 1
+	}
+	ue() // first_mb_in_slice
+	return ue()
+}
+
+// findSPS parses H.264 Annex-B byte stream and returns the first SPS NAL unit.
+func findSPS(b []byte) []byte {
+	for i := 0; i+3 < len(b); i++ {
+		if b[i] == 0 && b[i+1] == 0 && b[i+2] == 1 {
+			if b[i+3]&0x1F == nalTypeSPS {
+				return b[i+3:]
+			}
+			i += 2
+		}
+	}
+	return nil
+}
+
+func TestProfileLevel(t *testing.T) {
+	const width, height = 64, 64
+	img := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+	for i := range img.Y {
+		img.Y[i] = uint8(i)
+	}
+
+	cases := []Params{
+		{Profile: ProfileBaseline, Level: 31},
+		{Profile: ProfileMain, Level: 40},
+		{Profile: ProfileHigh, Level: 41},
+		{Profile: ProfileBaseline, Level: 31, UsageType: UsageScreen},
+	}
+	for _, params := range cases {
+		params := params
+		t.Run(fmt.Sprintf("%d_%d_%d", params.Profile, params.Level, params.UsageType), func(t *testing.T) {
+			e, err := NewEncoderBuilder(params)(video.ReaderFunc(func() (image.Image, error) {
+				return img, nil
+			}), prop.Media{
+				Video: prop.Video{
+					Width:     width,
+					Height:    height,
+					FrameRate: 30,
+				},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create encoder: %v", err)
+			}
+			defer e.Close()
+
+			buff := make([]byte, 1024)
+			n, err := e.Read(buff)
+			for err != nil {
+				bufErr, ok := err.(*mio.InsufficientBufferError)
+				if !ok {
+					t.Fatalf("Failed to encode: %v", err)
+				}
+				buff = make([]byte, 2*bufErr.RequiredSize)
+				n, err = e.Read(buff)
+			}
+
+			sps := findSPS(buff[:n])
+			if len(sps) < 4 {
+				t.Fatal("SPS is not found in the first frame")
+			}
+			if profile := Profile(sps[1]); profile != params.Profile {
+				t.Errorf("Expected profile_idc %d, got %d", params.Profile, profile)
+			}
+			if level := Level(sps[3]); level != params.Level {
+				t.Errorf("Expected level_idc %d, got %d", params.Level, level)
+			}
+		})
+	}
+}
+
+func TestGOP(t *testing.T) {
+	const width, height = 64, 64
+	img := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+	p := prop.Media{
+		Video: prop.Video{
+			Width:     width,
+			Height:    height,
+			FrameRate: 30,
+		},
+		Codec: prop.Codec{
+			KeyFrameInterval: 5,
+		},
+	}
+
+	t.Run("BFramesDisabled", func(t *testing.T) {
+		var cnt int
+		e, err := NewEncoder(video.ReaderFunc(func() (image.Image, error) {
+			cnt++
+			for i := range img.Y {
+				img.Y[i] = uint8(i + cnt)
+			}
+			return img, nil
+		}), p)
+		if err != nil {
+			t.Fatalf("Failed to create encoder: %v", err)
+		}
+		defer e.Close()
+
+		var idr int
+		buff := make([]byte, 1024)
+		for i := 0; i < 12; i++ {
+			n, err := e.Read(buff)
+			for err != nil {
+				bufErr, ok := err.(*mio.InsufficientBufferError)
+				if !ok {
+					t.Fatalf("Failed to encode: %v", err)
+				}
+				buff = make([]byte, 2*bufErr.RequiredSize)
+				n, err = e.Read(buff)
+			}
+
+			for _, nal := range nalUnits(buff[:n]) {
+				switch nal[0] & 0x1F {
+				case nalTypeIDR:
+					idr++
+				case nalTypeSlice:
+					if typ := sliceType(nal); typ%5 != 0 {
+						t.Errorf("Expected P slice in frame %d, got slice_type %d", i, typ)
+					}
+				}
+			}
+		}
+		if idr != 3 {
+			t.Errorf("Expected 3 IDR frames in 12 frames with keyframe interval 5, got %d", idr)
+		}
+	})
+	t.Run("BFramesEnabled", func(t *testing.T) {
+		_, err := NewEncoderBuilder(Params{BFrames: 2})(video.ReaderFunc(func() (image.Image, error) {
+			return img, nil
+		}), p)
+		if err != errBFramesUnsupported {
+			t.Errorf("Expected error %v, got %v", errBFramesUnsupported, err)
+		}
+	})
+}
+
+func TestSetBitRate(t *testing.T) {
+	const width, height = 320, 240
+	img := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+	rnd := rand.New(rand.NewSource(1))
+	e, err := NewEncoder(video.ReaderFunc(func() (image.Image, error) {
+		rnd.Read(img.Y)
+		return img, nil
+	}), prop.Media{
+		Video: prop.Video{
+			Width:     width,
+			Height:    height,
+			FrameRate: 30,
+		},
+		Codec: prop.Codec{
+			BitRate: 2000000,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	defer e.Close()
+
+	buff := make([]byte, 1024)
+	// encode returns the average frame size of the last half of frames
+	encode := func(frames int) int {
+		var total int
+		for i := 0; i < frames; i++ {
+			n, err := e.Read(buff)
+			for err != nil {
+				bufErr, ok := err.(*mio.InsufficientBufferError)
+				if !ok {
+					t.Fatalf("Failed to encode: %v", err)
+				}
+				buff = make([]byte, 2*bufErr.RequiredSize)
+				n, err = e.Read(buff)
+			}
+			if i >= frames/2 {
+				total += n
+			}
+		}
+		return total / (frames - frames/2)
+	}
+
+	high := encode(60)
+	if err := e.(codec.BitRateController).SetBitRate(200000); err != nil {
+		t.Fatalf("Failed to set bitrate: %v", err)
+	}
+	low := encode(60)
+	if low*2 > high {
+		t.Errorf("Expected the frames to be smaller after lowering the bitrate, got %d bytes and then %d bytes", high, low)
+	}
+}
+
+func TestSetBitRateVBR(t *testing.T) {
+	const width, height = 320, 240
+	const maxBitRate = 300000
+
+	// bitRate returns the bitrate after setting the target bitrate
+	bitRate := func(t *testing.T, target int) int {
+		img := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+		rnd := rand.New(rand.NewSource(1))
+		e, err := NewEncoder(video.ReaderFunc(func() (image.Image, error) {
+			rnd.Read(img.Y)
+			return img, nil
+		}), prop.Media{
+			Video: prop.Video{
+				Width:     width,
+				Height:    height,
+				FrameRate: 30,
+			},
+			Codec: prop.Codec{
+				BitRate:     200000,
+				BitRateMode: prop.BitRateModeVBR,
+				MaxBitRate:  maxBitRate,
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create encoder: %v", err)
+		}
+		defer e.Close()
+
+		if err := e.(codec.BitRateController).SetBitRate(target); err != nil {
+			t.Fatalf("Failed to set bitrate: %v", err)
+		}
+
+		const frames = 90
+		buff := make([]byte, 1024)
+		var total int
+		for i := 0; i < frames; i++ {
+			n, err := e.Read(buff)
+			for err != nil {
+				bufErr, ok := err.(*mio.InsufficientBufferError)
+				if !ok {
+					t.Fatalf("Failed to encode: %v", err)
+				}
+				buff = make([]byte, 2*bufErr.RequiredSize)
+				n, err = e.Read(buff)
+			}
+			if i >= frames/3 {
+				total += n
+			}
+		}
+		return total * 8 * 30 / (frames - frames/3)
+	}
+
+	// The bitrate over MaxBitRate must be clamped instead of raising the cap
+	capped := bitRate(t, maxBitRate)
+	over := bitRate(t, 4*maxBitRate)
+	if over > capped*11/10 {
+		t.Errorf("Expected the bitrate to be clamped to MaxBitRate, got %d bps while %d bps at MaxBitRate", over, capped)
+	}
+}
+
+func TestParameterSets(t *testing.T) {
+	const width, height = 64, 64
+	img := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+	p := prop.Media{
+		Video: prop.Video{
+			Width:     width,
+			Height:    height,
+			FrameRate: 30,
+		},
+		Codec: prop.Codec{
+			KeyFrameInterval: 5,
+		},
+	}
+
+	cases := map[string]struct {
+		params      Params
+		expectedSPS int
+	}{
+		"Repeated": {Params{}, 3},
+		"Omitted":  {Params{OmitRepeatedParameterSets: true}, 1},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			var cnt int
+			e, err := NewEncoderBuilder(c.params)(video.ReaderFunc(func() (image.Image, error) {
+				cnt++
+				for i := range img.Y {
+					img.Y[i] = uint8(i + cnt)
+				}
+				return img, nil
+			}), p)
+			if err != nil {
+				t.Fatalf("Failed to create encoder: %v", err)
+			}
+			defer e.Close()
+
+			if sets := e.(codec.ParameterSetReader).ParameterSets(); sets != nil {
+				t.Errorf("Expected no parameter sets before encoding, got %v", sets)
+			}
+
+			var idr, sps, pps int
+			// inband is the last SPS and PPS in the stream
+			inband := make([][]byte, 2)
+			buff := make([]byte, 1024)
+			for i := 0; i < 12; i++ {
+				n, err := e.Read(buff)
+				for err != nil {
+					bufErr, ok := err.(*mio.InsufficientBufferError)
+					if !ok {
+						t.Fatalf("Failed to encode: %v", err)
+					}
+					buff = make([]byte, 2*bufErr.RequiredSize)
+					n, err = e.Read(buff)
+				}
+
+				for _, nal := range nalUnits(buff[:n]) {
+					switch nal[0] & 0x1F {
+					case nalTypeIDR:
+						idr++
+					case nalTypeSPS:
+						sps++
+						// Trailing zero of 4 bytes start code
+						inband[0] = append([]byte(nil), bytes.TrimRight(nal, "\x00")...)
+					case nalTypePPS:
+						pps++
+						inband[1] = append([]byte(nil), bytes.TrimRight(nal, "\x00")...)
+					}
+				}
+			}
+			if idr != 3 {
+				t.Fatalf("Expected 3 IDR frames, got %d", idr)
+			}
+			if sps != c.expectedSPS || pps != c.expectedSPS {
+				t.Errorf("Expected %d SPS and PPS, got %d SPS and %d PPS", c.expectedSPS, sps, pps)
+			}
+
+			sets := e.(codec.ParameterSetReader).ParameterSets()
+			if len(sets) != 2 || sets[0][0]&0x1F != nalTypeSPS || sets[1][0]&0x1F != nalTypePPS {
+				t.Fatalf("Expected SPS and PPS, got %v", sets)
+			}
+			for i := range sets {
+				if !bytes.Equal(sets[i], inband[i]) {
+					t.Errorf("Expected parameter set %d to be %v, got %v", i, inband[i], sets[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFilterParameterSetsTruncated(t *testing.T) {
+	e := &encoder{omitRepeatedParameterSets: true}
+	sps := []byte{0, 0, 0, 1, 0x67, 0x42}
+	pps := []byte{0, 0, 0, 1, 0x68, 0xCE}
+	idr := []byte{0, 0, 0, 1, 0x65, 0x88}
+
+	// The trailing start code has no header
+	for _, encoded := range [][]byte{
+		bytes.Join([][]byte{sps, pps, idr, {0, 0, 1}}, nil),
+		{0, 0, 1},
+	} {
+		if filtered := e.filterParameterSets(encoded); !bytes.Equal(filtered, encoded) {
+			t.Errorf("Expected %v as is, got %v", encoded, filtered)
+		}
+	}
+	filtered := e.filterParameterSets(bytes.Join([][]byte{sps, pps, idr, {0, 0, 1}}, nil))
+	if expected := append(append([]byte(nil), idr...), 0, 0, 1); !bytes.Equal(filtered, expected) {
+		t.Errorf("Expected the repeated parameter sets to be dropped, got %v", filtered)
+	}
+}
+
+func TestLatencyMode(t *testing.T) {
+	const width, height = 64, 64
+	img := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+	for _, mode := range []prop.LatencyMode{prop.LatencyModeRealtime, prop.LatencyModeBalanced, prop.LatencyModeQuality} {
+		mode := mode
+		t.Run(fmt.Sprint(mode), func(t *testing.T) {
+			var cnt int
+			e, err := NewEncoder(video.ReaderFunc(func() (image.Image, error) {
+				cnt++
+				for i := range img.Y {
+					img.Y[i] = uint8(i + cnt)
+				}
+				return img, nil
+			}), prop.Media{
+				Video: prop.Video{
+					Width:     width,
+					Height:    height,
+					FrameRate: 30,
+				},
+				Codec: prop.Codec{
+					LatencyMode: mode,
+				},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create encoder: %v", err)
+			}
+			defer e.Close()
+
+			buff := make([]byte, 1024)
+			for i := 1; i <= 5; i++ {
+				n, err := e.Read(buff)
+				for err != nil {
+					bufErr, ok := err.(*mio.InsufficientBufferError)
+					if !ok {
+						t.Fatalf("Failed to encode: %v", err)
+					}
+					buff = make([]byte, 2*bufErr.RequiredSize)
+					n, err = e.Read(buff)
+				}
+				// openh264 doesn't have the lookahead
+				if n == 0 || cnt != i {
+					t.Fatalf("Expected frame %d to be encoded without delay, got %d bytes after %d frames", i, n, cnt)
+				}
+			}
+		})
+	}
+}
+
+func TestBitRateMode(t *testing.T) {
+	const width, height = 320, 240
+
+	// variance returns the variance of the frame sizes of the content
+	// alternating between the static and the noisy scenes.
+	variance := func(t *testing.T, mode prop.BitRateMode) float64 {
+		img := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+		rnd := rand.New(rand.NewSource(1))
+		var i int
+		e, err := NewEncoder(video.ReaderFunc(func() (image.Image, error) {
+			if i/15%2 == 1 {
+				rnd.Read(img.Y)
+			}
+			i++
+			return img, nil
+		}), prop.Media{
+			Video: prop.Video{
+				Width:     width,
+				Height:    height,
+				FrameRate: 30,
+			},
+			Codec: prop.Codec{
+				BitRate:     500000,
+				BitRateMode: mode,
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create encoder: %v", err)
+		}
+		defer e.Close()
+
+		const frames = 120
+		buff := make([]byte, 1024)
+		sizes := make([]float64, 0, frames)
+		var sum float64
+		for len(sizes) < frames {
+			n, err := e.Read(buff)
+			if bufErr, ok := err.(*mio.InsufficientBufferError); ok {
+				buff = make([]byte, 2*bufErr.RequiredSize)
+				continue
+			}
+			if err != nil {
+				t.Fatalf("Failed to encode: %v", err)
+			}
+			sizes = append(sizes, float64(n))
+			sum += float64(n)
+		}
+		mean := sum / frames
+		var v float64
+		for _, s := range sizes {
+			v += (s - mean) * (s - mean)
+		}
+		return v / frames
+	}
+
+	cbr := variance(t, prop.BitRateModeCBR)
+	vbr := variance(t, prop.BitRateModeVBR)
+	t.Logf("variance of the frame sizes: CBR %.0f, VBR %.0f", cbr, vbr)
+	if cbr >= vbr {
+		t.Errorf("Expected the frame sizes of CBR to vary less than VBR, got %.0f and %.0f", cbr, vbr)
+	}
+}
@@ -18,6 +18,10 @@ type encoder struct {
 	engine *opus.Encoder
 	inBuff [][2]float32
 	reader audio.Reader
+	// int16Reader is set if the reader provides int16 samples, which are
+	// encoded without the conversion to float32.
+	int16Reader audio.Int16Reader
+	inBuff16    [][2]int16
 }
 
 var latencies = []float64{5, 10, 20, 40, 60}
@@ -69,8 +73,13 @@ func NewEncoder(r audio.Reader, p prop.Media) (io.ReadCloser, error) {
 	}
 
 	inBuffSize := targetLatency * float64(p.SampleRate) / 1000
-	inBuff := make([][2]float32, int(inBuffSize))
-	e := encoder{engine, inBuff, r}
+	e := encoder{engine: engine, reader: r}
+	if ir, ok := r.(audio.Int16Reader); ok {
+		e.int16Reader = ir
+		e.inBuff16 = make([][2]int16, int(inBuffSize))
+	} else {
+		e.inBuff = make([][2]float32, int(inBuffSize))
+	}
 	return &e, nil
 }
 
@@ -84,7 +93,21 @@ func flatten(samples [][2]float32) []float32 {
 	return *(*[]float32)(unsafe.Pointer(&reflect.SliceHeader{Data: data, Len: l, Cap: l}))
 }
 
+func flattenInt16(samples [][2]int16) []int16 {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	data := uintptr(unsafe.Pointer(&samples[0]))
+	l := len(samples) * 2
+	return *(*[]int16)(unsafe.Pointer(&reflect.SliceHeader{Data: data, Len: l, Cap: l}))
+}
+
 func (e *encoder) Read(p []byte) (n int, err error) {
+	if e.int16Reader != nil {
+		return e.readInt16(p)
+	}
+
 	var curN int
 
 	// While the buffer is not full, keep reading so that we meet the latency requirement
@@ -105,6 +128,27 @@ func (e *encoder) Read(p []byte) (n int, err error) {
 	return n, nil
 }
 
+func (e *encoder) readInt16(p []byte) (int, error) {
+	var curN int
+	for curN < len(e.inBuff16) {
+		n, err := e.int16Reader.ReadInt16(e.inBuff16[curN:])
+		if err != nil {
+			return 0, err
+		}
+
+		curN += n
+	}
+
+	return e.engine.Encode(flattenInt16(e.inBuff16), p)
+}
+
+func (e *encoder) LastFrameSamples() int {
+	if e.int16Reader != nil {
+		return len(e.inBuff16)
+	}
+	return len(e.inBuff)
+}
+
 func (e *encoder) Close() error {
 	return nil
 }
@@ -0,0 +1,60 @@
+// Package passthrough implements the video codec which relays the already encoded
+// frames without re-encoding. The source must implement video.EncodedReader, and
+// each encoded frame is output as one sample.
+//
+// Since the encoded format must match to the RTP codec, the encoder is not registered
+// by default. Register it in place of the encoder of the source format:
+//
+//	codec.Register(webrtc.H264, codec.VideoEncoderBuilder(passthrough.NewVideoEncoder))
+package passthrough
+
+import (
+	"errors"
+	"io"
+
+	mio "github.com/pion/mediadevices/pkg/io"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+var errNotEncoded = errors.New("passthrough: source doesn't provide encoded frames, video transforms must not be used")
+
+type encoder struct {
+	r    video.EncodedReader
+	buff []byte
+}
+
+// NewVideoEncoder creates new passthrough video encoder.
+func NewVideoEncoder(r video.Reader, p prop.Media) (io.ReadCloser, error) {
+	er, ok := r.(video.EncodedReader)
+	if !ok {
+		return nil, errNotEncoded
+	}
+	return &encoder{r: er}, nil
+}
+
+func (e *encoder) Read(p []byte) (int, error) {
+	if e.buff != nil {
+		n, err := mio.Copy(p, e.buff)
+		if err == nil {
+			e.buff = nil
+		}
+		return n, err
+	}
+
+	frame, err := e.r.ReadEncoded()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := mio.Copy(p, frame)
+	if err != nil {
+		// The frame is only valid until the next read
+		e.buff = append([]byte(nil), frame...)
+	}
+	return n, err
+}
+
+func (e *encoder) Close() error {
+	return nil
+}
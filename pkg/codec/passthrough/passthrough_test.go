@@ -0,0 +1,67 @@
+package passthrough
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"testing"
+
+	mio "github.com/pion/mediadevices/pkg/io"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+func TestEncoder(t *testing.T) {
+	// H.264 access units in Annex-B format
+	frames := [][]byte{
+		{0x00, 0x00, 0x00, 0x01, 0x67, 0x42, 0x00, 0x1F, 0x00, 0x00, 0x00, 0x01, 0x68, 0xCE, 0x3C, 0x80,
+			0x00, 0x00, 0x00, 0x01, 0x65, 0x88, 0x84, 0x00, 0x33, 0xFF},
+		{0x00, 0x00, 0x00, 0x01, 0x41, 0x9A, 0x02, 0x04},
+		{0x00, 0x00, 0x00, 0x01, 0x41, 0x9A, 0x04, 0x08},
+	}
+
+	var i int
+	var src []byte
+	e, err := NewVideoEncoder(video.NewEncodedReader(func() ([]byte, error) {
+		if i >= len(frames) {
+			return nil, io.EOF
+		}
+		i++
+		// The buffer is reused like drivers, so the encoder must copy the frame if needed
+		src = append(src[:0], frames[i-1]...)
+		return src, nil
+	}, nil), prop.Media{})
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	defer e.Close()
+
+	// Small buffer to test the frame spanning multiple reads
+	buff := make([]byte, 16)
+	for _, expected := range frames {
+		n, err := e.Read(buff)
+		for err != nil {
+			bufErr, ok := err.(*mio.InsufficientBufferError)
+			if !ok {
+				t.Fatalf("Failed to read: %v", err)
+			}
+			buff = make([]byte, 2*bufErr.RequiredSize)
+			n, err = e.Read(buff)
+		}
+		if !bytes.Equal(expected, buff[:n]) {
+			t.Errorf("Expected frame %v, got %v", expected, buff[:n])
+		}
+	}
+	if _, err := e.Read(buff); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+}
+
+func TestEncoderNotEncodedSource(t *testing.T) {
+	_, err := NewVideoEncoder(video.ReaderFunc(func() (image.Image, error) {
+		return nil, io.EOF
+	}), prop.Media{})
+	if err != errNotEncoded {
+		t.Errorf("Expected error %v, got %v", errNotEncoded, err)
+	}
+}
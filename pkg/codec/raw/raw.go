@@ -0,0 +1,151 @@
+// Package raw implements pass-through codec which outputs uncompressed frames.
+// Video frames are serialized as concatenated planes (Y, Cb, Cr for YCbCr images
+// and Pix for RGBA images) and one sample contains one frame.
+// Audio samples are serialized as interleaved little-endian float32 and one sample
+// contains the samples of the given latency.
+package raw
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"math"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/frame"
+	mio "github.com/pion/mediadevices/pkg/io"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+// Name is the name of raw codec used to register the encoders.
+const Name = "raw"
+
+var errUnsupportedImageType = errors.New("raw: unsupported image type")
+
+func init() {
+	codec.Register(Name, codec.VideoEncoderBuilder(NewVideoEncoder))
+	codec.RegisterVideoInputFormats(Name, frame.FormatI420, frame.FormatI444, frame.FormatRGBA)
+	codec.Register(Name, codec.AudioEncoderBuilder(NewAudioEncoder))
+}
+
+type videoEncoder struct {
+	r     video.Reader
+	buff  []byte
+	frame []byte
+}
+
+// NewVideoEncoder creates new raw video encoder
+func NewVideoEncoder(r video.Reader, p prop.Media) (io.ReadCloser, error) {
+	return &videoEncoder{r: r}, nil
+}
+
+func (e *videoEncoder) Read(p []byte) (int, error) {
+	if e.buff != nil {
+		n, err := mio.Copy(p, e.buff)
+		if err == nil {
+			e.buff = nil
+		}
+		return n, err
+	}
+
+	img, err := e.r.Read()
+	if err != nil {
+		return 0, err
+	}
+
+	e.frame = e.frame[:0]
+	switch v := img.(type) {
+	case *image.YCbCr:
+		e.frame = append(e.frame, v.Y...)
+		e.frame = append(e.frame, v.Cb...)
+		e.frame = append(e.frame, v.Cr...)
+	case *image.RGBA:
+		e.frame = append(e.frame, v.Pix...)
+	default:
+		return 0, errUnsupportedImageType
+	}
+
+	n, err := mio.Copy(p, e.frame)
+	if err != nil {
+		e.buff = e.frame
+	}
+	return n, err
+}
+
+func (e *videoEncoder) Close() error {
+	return nil
+}
+
+type audioEncoder struct {
+	r      audio.Reader
+	inBuff [][2]float32
+	buff   []byte
+	frame  []byte
+}
+
+// NewAudioEncoder creates new raw audio encoder
+func NewAudioEncoder(r audio.Reader, p prop.Media) (io.ReadCloser, error) {
+	if p.SampleRate == 0 {
+		return nil, errors.New("raw: inProp.SampleRate is required")
+	}
+
+	if p.Latency == 0 {
+		p.Latency = 20 * time.Millisecond
+	}
+
+	inBuffSize := int(float64(p.SampleRate) * p.Latency.Seconds())
+	return &audioEncoder{
+		r:      r,
+		inBuff: make([][2]float32, inBuffSize),
+	}, nil
+}
+
+func (e *audioEncoder) Read(p []byte) (int, error) {
+	if e.buff != nil {
+		n, err := mio.Copy(p, e.buff)
+		if err == nil {
+			e.buff = nil
+		}
+		return n, err
+	}
+
+	var curN int
+
+	// While the buffer is not full, keep reading so that we meet the latency requirement
+	for curN < len(e.inBuff) {
+		n, err := e.r.Read(e.inBuff[curN:])
+		if err != nil {
+			return 0, err
+		}
+
+		curN += n
+	}
+
+	l := len(e.inBuff) * 2 * 4
+	if cap(e.frame) < l {
+		e.frame = make([]byte, l)
+	}
+	e.frame = e.frame[:l]
+	for i, s := range e.inBuff {
+		binary.LittleEndian.PutUint32(e.frame[i*8:], math.Float32bits(s[0]))
+		binary.LittleEndian.PutUint32(e.frame[i*8+4:], math.Float32bits(s[1]))
+	}
+
+	n, err := mio.Copy(p, e.frame)
+	if err != nil {
+		e.buff = e.frame
+	}
+	return n, err
+}
+
+func (e *audioEncoder) LastFrameSamples() int {
+	return len(e.inBuff)
+}
+
+func (e *audioEncoder) Close() error {
+	return nil
+}
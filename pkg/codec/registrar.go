@@ -3,17 +3,47 @@ package codec
 import (
 	"fmt"
 	"io"
+	"sort"
 
+	"github.com/pion/mediadevices/pkg/frame"
 	"github.com/pion/mediadevices/pkg/io/audio"
 	"github.com/pion/mediadevices/pkg/io/video"
 	"github.com/pion/mediadevices/pkg/prop"
 )
 
 var (
-	videoEncoders = make(map[string]VideoEncoderBuilder)
-	audioEncoders = make(map[string]AudioEncoderBuilder)
+	videoEncoders     = make(map[string]VideoEncoderBuilder)
+	audioEncoders     = make(map[string]AudioEncoderBuilder)
+	videoInputFormats = make(map[string][]frame.Format)
+	controls          = make(map[string][]string)
+	probes            = make(map[string]func() error)
+
+	hardwareVideoEncoders = make(map[string]VideoEncoderBuilder)
+	hardwareAudioEncoders = make(map[string]AudioEncoderBuilder)
+	hardwareProbes        = make(map[string]func() error)
+)
+
+// Kind is the kind of the media encoded by a codec.
+type Kind string
+
+// Kind definitions.
+const (
+	KindVideo Kind = "video"
+	KindAudio Kind = "audio"
 )
 
+// CodecInfo describes a registered encoder.
+type CodecInfo struct {
+	Name string
+	Kind Kind
+	// Available is false if the probe registered by RegisterProbe failed,
+	// e.g. the backend library can't be loaded. Err is the error of the probe.
+	Available bool
+	Err       error
+	// Hardware is true if the encoder is registered by RegisterHardware.
+	Hardware bool
+}
+
 func Register(name string, builder interface{}) {
 	switch b := builder.(type) {
 	case VideoEncoderBuilder:
@@ -23,19 +53,221 @@ func Register(name string, builder interface{}) {
 	}
 }
 
+// RegisterHardware registers a hardware accelerated encoder named name. It can be
+// registered alongside the software encoder registered by Register with the same
+// name, and is selected if prop.Codec.PreferHardware is set or the software one
+// isn't registered.
+func RegisterHardware(name string, builder interface{}) {
+	switch b := builder.(type) {
+	case VideoEncoderBuilder:
+		hardwareVideoEncoders[name] = b
+	case AudioEncoderBuilder:
+		hardwareAudioEncoders[name] = b
+	}
+}
+
+// RegisterHardwareProbe registers the function checking that the hardware
+// encoder named name is usable, e.g. the device is present. The unavailable
+// hardware encoder is not selected even if prop.Codec.PreferHardware is set.
+func RegisterHardwareProbe(name string, probe func() error) {
+	hardwareProbes[name] = probe
+}
+
+// RegisterProbe registers the function checking that the encoder named name is
+// usable, e.g. the backend library is loadable. It's called by Registered and
+// IsAvailable. The encoders without the probe are treated as available.
+func RegisterProbe(name string, probe func() error) {
+	probes[name] = probe
+}
+
+func probe(name string) error {
+	if p, ok := probes[name]; ok {
+		return p()
+	}
+	return nil
+}
+
+func hardwareProbe(name string) error {
+	if p, ok := hardwareProbes[name]; ok {
+		return p()
+	}
+	return nil
+}
+
+// Registered returns the registered encoders sorted by the kind and the name,
+// and the software encoder first. The name registered as both video and audio
+// encoders, or as both software and hardware encoders, appears multiple times.
+func Registered() []CodecInfo {
+	var infos []CodecInfo
+	add := func(name string, kind Kind, hardware bool) {
+		err := probe(name)
+		if hardware {
+			err = hardwareProbe(name)
+		}
+		infos = append(infos, CodecInfo{Name: name, Kind: kind, Available: err == nil, Err: err, Hardware: hardware})
+	}
+	for name := range videoEncoders {
+		add(name, KindVideo, false)
+	}
+	for name := range audioEncoders {
+		add(name, KindAudio, false)
+	}
+	for name := range hardwareVideoEncoders {
+		add(name, KindVideo, true)
+	}
+	for name := range hardwareAudioEncoders {
+		add(name, KindAudio, true)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Kind != infos[j].Kind {
+			return infos[i].Kind < infos[j].Kind
+		}
+		if infos[i].Name != infos[j].Name {
+			return infos[i].Name < infos[j].Name
+		}
+		return !infos[i].Hardware && infos[j].Hardware
+	})
+	return infos
+}
+
+// IsAvailable returns true if the encoder named name is registered and usable.
+// Apps can use it to hide the codecs which can't be used before acquiring
+// the devices.
+func IsAvailable(name string) bool {
+	_, isVideo := videoEncoders[name]
+	_, isAudio := audioEncoders[name]
+	if (isVideo || isAudio) && probe(name) == nil {
+		return true
+	}
+	_, isVideo = hardwareVideoEncoders[name]
+	_, isAudio = hardwareAudioEncoders[name]
+	return (isVideo || isAudio) && hardwareProbe(name) == nil
+}
+
+// RegisterVideoInputFormats registers the frame formats which the video encoder
+// named name accepts without conversion, in order of preference.
+// They are used to select the frame format of the device if it's not specified.
+func RegisterVideoInputFormats(name string, formats ...frame.Format) {
+	videoInputFormats[name] = formats
+}
+
+// VideoInputFormats returns the frame formats registered by RegisterVideoInputFormats.
+func VideoInputFormats(name string) []frame.Format {
+	return videoInputFormats[name]
+}
+
+// RegisterControls registers the names of the codec specific controls which
+// the encoder named name accepts by prop.Codec.Controls.
+func RegisterControls(name string, names ...string) {
+	controls[name] = names
+}
+
+// Controls returns the control names registered by RegisterControls.
+func Controls(name string) []string {
+	return controls[name]
+}
+
+// ValidateVideoEncoder checks that the video encoder specified by p is registered
+// and p has valid codec properties without building the encoder.
+func ValidateVideoEncoder(p prop.Media) error {
+	_, ok := videoEncoders[p.CodecName]
+	_, hardware := hardwareVideoEncoders[p.CodecName]
+	if !ok && !hardware {
+		return fmt.Errorf("codec: can't find %s video encoder", p.CodecName)
+	}
+
+	return validateCodec(p.Codec)
+}
+
+// ValidateAudioEncoder checks that the audio encoder specified by p is registered
+// and p has valid codec properties without building the encoder.
+func ValidateAudioEncoder(p prop.Media) error {
+	_, ok := audioEncoders[p.CodecName]
+	_, hardware := hardwareAudioEncoders[p.CodecName]
+	if !ok && !hardware {
+		return fmt.Errorf("codec: can't find %s audio encoder", p.CodecName)
+	}
+
+	return validateCodec(p.Codec)
+}
+
+func validateCodec(c prop.Codec) error {
+	switch {
+	case c.BitRate < 0:
+		return fmt.Errorf("codec: invalid bitrate %d", c.BitRate)
+	case c.Quality < 0 || c.Quality > 9:
+		return fmt.Errorf("codec: quality %d is out of range [0-9]", c.Quality)
+	case c.KeyFrameInterval < 0:
+		return fmt.Errorf("codec: invalid keyframe interval %d", c.KeyFrameInterval)
+	case c.Threads < 0:
+		return fmt.Errorf("codec: invalid number of threads %d", c.Threads)
+	case c.LatencyMode < prop.LatencyModeDefault || c.LatencyMode > prop.LatencyModeQuality:
+		return fmt.Errorf("codec: invalid latency mode %d", c.LatencyMode)
+	case c.BitRateMode < prop.BitRateModeDefault || c.BitRateMode > prop.BitRateModeVBR:
+		return fmt.Errorf("codec: invalid bitrate mode %d", c.BitRateMode)
+	case c.MinBitRate < 0 || c.MaxBitRate < 0:
+		return fmt.Errorf("codec: invalid bitrate range [%d-%d]", c.MinBitRate, c.MaxBitRate)
+	case (c.MinBitRate > 0 || c.MaxBitRate > 0) && c.BitRateMode != prop.BitRateModeVBR:
+		return fmt.Errorf("codec: bitrate range is only supported in VBR mode")
+	case c.MaxBitRate > 0 && (c.MinBitRate > c.MaxBitRate || c.BitRate > c.MaxBitRate):
+		return fmt.Errorf("codec: bitrate %d is out of range [%d-%d]", c.BitRate, c.MinBitRate, c.MaxBitRate)
+	case c.BitRate > 0 && c.BitRate < c.MinBitRate:
+		return fmt.Errorf("codec: bitrate %d is below the minimum %d", c.BitRate, c.MinBitRate)
+	}
+
+	for name := range c.Controls {
+		if !hasControl(c.CodecName, name) {
+			return fmt.Errorf("codec: unknown control %s of %s encoder", name, c.CodecName)
+		}
+	}
+	return nil
+}
+
+func hasControl(codecName, name string) bool {
+	for _, n := range controls[codecName] {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildVideoEncoder builds the video encoder specified by p. The hardware encoder
+// is used if p.PreferHardware is set and it's available, or the software encoder
+// isn't registered. If building the preferred hardware encoder fails, e.g. all
+// hardware sessions are in use, the software encoder is used instead.
 func BuildVideoEncoder(r video.Reader, p prop.Media) (io.ReadCloser, error) {
 	b, ok := videoEncoders[p.CodecName]
-	if !ok {
+	hw, hardware := hardwareVideoEncoders[p.CodecName]
+	switch {
+	case !ok && !hardware:
 		return nil, fmt.Errorf("codec: can't find %s video encoder", p.CodecName)
+	case !ok:
+		return hw(r, p)
+	case hardware && p.PreferHardware && hardwareProbe(p.CodecName) == nil:
+		if e, err := hw(r, p); err == nil {
+			return e, nil
+		}
 	}
 
 	return b(r, p)
 }
 
+// BuildAudioEncoder builds the audio encoder specified by p. The encoder is
+// selected in the same way as BuildVideoEncoder.
 func BuildAudioEncoder(r audio.Reader, p prop.Media) (io.ReadCloser, error) {
 	b, ok := audioEncoders[p.CodecName]
-	if !ok {
+	hw, hardware := hardwareAudioEncoders[p.CodecName]
+	switch {
+	case !ok && !hardware:
 		return nil, fmt.Errorf("codec: can't find %s audio encoder", p.CodecName)
+	case !ok:
+		return hw(r, p)
+	case hardware && p.PreferHardware && hardwareProbe(p.CodecName) == nil:
+		if e, err := hw(r, p); err == nil {
+			return e, nil
+		}
 	}
 
 	return b(r, p)
@@ -0,0 +1,151 @@
+package codec
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+func TestRegistered(t *testing.T) {
+	videoBuilder := VideoEncoderBuilder(func(r video.Reader, p prop.Media) (io.ReadCloser, error) {
+		return nil, nil
+	})
+	audioBuilder := AudioEncoderBuilder(func(r audio.Reader, p prop.Media) (io.ReadCloser, error) {
+		return nil, nil
+	})
+	errUnavailable := errors.New("library not found")
+
+	Register("TestRegisteredVideo", videoBuilder)
+	Register("TestRegisteredAudio", audioBuilder)
+	Register("TestRegisteredBoth", videoBuilder)
+	Register("TestRegisteredBoth", audioBuilder)
+	Register("TestRegisteredUnavailable", videoBuilder)
+	RegisterProbe("TestRegisteredUnavailable", func() error { return errUnavailable })
+
+	infos := make(map[CodecInfo]bool)
+	for _, info := range Registered() {
+		infos[info] = true
+	}
+	for _, expected := range []CodecInfo{
+		{Name: "TestRegisteredVideo", Kind: KindVideo, Available: true},
+		{Name: "TestRegisteredAudio", Kind: KindAudio, Available: true},
+		{Name: "TestRegisteredBoth", Kind: KindVideo, Available: true},
+		{Name: "TestRegisteredBoth", Kind: KindAudio, Available: true},
+		{Name: "TestRegisteredUnavailable", Kind: KindVideo, Err: errUnavailable},
+	} {
+		if !infos[expected] {
+			t.Errorf("Expected %+v to be registered", expected)
+		}
+	}
+
+	for name, expected := range map[string]bool{
+		"TestRegisteredVideo":       true,
+		"TestRegisteredAudio":       true,
+		"TestRegisteredBoth":        true,
+		"TestRegisteredUnavailable": false,
+		"TestRegisteredNotFound":    false,
+	} {
+		if available := IsAvailable(name); available != expected {
+			t.Errorf("%s: Expected availability %v, got %v", name, expected, available)
+		}
+	}
+}
+
+func TestValidateControls(t *testing.T) {
+	Register("TestValidateControls", VideoEncoderBuilder(func(r video.Reader, p prop.Media) (io.ReadCloser, error) {
+		return nil, nil
+	}))
+	RegisterControls("TestValidateControls", "CONTROL_A", "CONTROL_B")
+
+	p := prop.Media{Codec: prop.Codec{
+		CodecName: "TestValidateControls",
+		Controls:  map[string]int{"CONTROL_A": 1, "CONTROL_B": -1},
+	}}
+	if err := ValidateVideoEncoder(p); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	p.Controls["CONTROL_C"] = 0
+	if err := ValidateVideoEncoder(p); err == nil {
+		t.Error("Expected error on unknown control")
+	}
+}
+
+type encoderMock struct {
+	io.Reader
+	hardware bool
+}
+
+func (e *encoderMock) Close() error { return nil }
+
+func TestPreferHardware(t *testing.T) {
+	builder := func(hardware bool, err error) VideoEncoderBuilder {
+		return func(r video.Reader, p prop.Media) (io.ReadCloser, error) {
+			if err != nil {
+				return nil, err
+			}
+			return &encoderMock{hardware: hardware}, nil
+		}
+	}
+	errUnavailable := errors.New("device not found")
+	errBusy := errors.New("device busy")
+
+	Register("TestPreferHardware", builder(false, nil))
+	RegisterHardware("TestPreferHardware", builder(true, nil))
+	Register("TestPreferHardwareUnavailable", builder(false, nil))
+	RegisterHardware("TestPreferHardwareUnavailable", builder(true, nil))
+	RegisterHardwareProbe("TestPreferHardwareUnavailable", func() error { return errUnavailable })
+	Register("TestPreferHardwareBusy", builder(false, nil))
+	RegisterHardware("TestPreferHardwareBusy", builder(true, errBusy))
+	RegisterHardware("TestPreferHardwareOnly", builder(true, nil))
+
+	testCases := map[string]struct {
+		name           string
+		preferHardware bool
+		hardware       bool
+	}{
+		"Software":            {name: "TestPreferHardware", hardware: false},
+		"Hardware":            {name: "TestPreferHardware", preferHardware: true, hardware: true},
+		"FallbackUnavailable": {name: "TestPreferHardwareUnavailable", preferHardware: true, hardware: false},
+		"FallbackBuildError":  {name: "TestPreferHardwareBusy", preferHardware: true, hardware: false},
+		"HardwareOnly":        {name: "TestPreferHardwareOnly", hardware: true},
+	}
+	for name, c := range testCases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			p := prop.Media{Codec: prop.Codec{CodecName: c.name, PreferHardware: c.preferHardware}}
+			if err := ValidateVideoEncoder(p); err != nil {
+				t.Fatalf("Unexpected validation error: %v", err)
+			}
+			e, err := BuildVideoEncoder(nil, p)
+			if err != nil {
+				t.Fatalf("Failed to build encoder: %v", err)
+			}
+			if hardware := e.(*encoderMock).hardware; hardware != c.hardware {
+				t.Errorf("Expected hardware encoder %v, got %v", c.hardware, hardware)
+			}
+		})
+	}
+
+	infos := make(map[CodecInfo]bool)
+	for _, info := range Registered() {
+		infos[info] = true
+	}
+	for _, expected := range []CodecInfo{
+		{Name: "TestPreferHardware", Kind: KindVideo, Available: true},
+		{Name: "TestPreferHardware", Kind: KindVideo, Available: true, Hardware: true},
+		{Name: "TestPreferHardwareUnavailable", Kind: KindVideo, Available: false, Err: errUnavailable, Hardware: true},
+		{Name: "TestPreferHardwareOnly", Kind: KindVideo, Available: true, Hardware: true},
+	} {
+		if !infos[expected] {
+			t.Errorf("Expected %+v to be registered", expected)
+		}
+	}
+	if !IsAvailable("TestPreferHardwareOnly") {
+		t.Error("Hardware only encoder must be available")
+	}
+}
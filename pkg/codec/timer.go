@@ -0,0 +1,54 @@
+package codec
+
+import (
+	"time"
+)
+
+// minFrameDuration is the minimum duration of a frame given to the encoders,
+// which is the finest time base used by them.
+const minFrameDuration = time.Millisecond
+
+// FrameTimer calculates the presentation time and the duration of the video
+// frames from their capture timestamps, e.g. video.RawFrame.Timestamp. The
+// encoders pass them to the rate control, so that the target bitrate is kept
+// even if the frames are captured or dropped irregularly.
+type FrameTimer struct {
+	start, last time.Time
+	// duration of the first frame, which can't be calculated from the timestamps
+	frameDuration time.Duration
+	started       bool
+}
+
+// NewFrameTimer creates a FrameTimer. frameRate is the nominal frame rate used
+// for the duration of the first frame. 30fps is assumed if it's not positive.
+func NewFrameTimer(frameRate float32) *FrameTimer {
+	frameDuration := time.Second / 30
+	if frameRate > 0 {
+		frameDuration = time.Duration(float64(time.Second) / float64(frameRate))
+	}
+	return &FrameTimer{frameDuration: frameDuration}
+}
+
+// Next returns the presentation time and the duration of the frame captured at
+// timestamp. The presentation time is counted from the start of the first frame.
+// The current time is used if timestamp is zero. The presentation time is
+// monotonically increasing, so the frames not after the previous one are
+// shifted to last for minFrameDuration.
+func (t *FrameTimer) Next(timestamp time.Time) (pts, duration time.Duration) {
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	if !t.started {
+		t.started = true
+		t.start = timestamp.Add(-t.frameDuration)
+		t.last = t.start
+	}
+
+	duration = timestamp.Sub(t.last)
+	if duration < minFrameDuration {
+		duration = minFrameDuration
+		timestamp = t.last.Add(minFrameDuration)
+	}
+	t.last = timestamp
+	return timestamp.Sub(t.start), duration
+}
@@ -0,0 +1,61 @@
+package codec
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFrameTimer(t *testing.T) {
+	timer := NewFrameTimer(20)
+
+	// Frames arrive at 20fps in average, but the intervals alternate between
+	// 10ms and 90ms as if the capture is throttled.
+	timestamp := time.Now()
+	expectedPTS := 50 * time.Millisecond
+	for i := 0; i < 10; i++ {
+		interval := 10 * time.Millisecond
+		if i%2 == 1 {
+			interval = 90 * time.Millisecond
+		}
+		timestamp = timestamp.Add(interval)
+
+		pts, duration := timer.Next(timestamp)
+		expectedDuration := interval
+		if i == 0 {
+			// The first frame lasts for the nominal frame duration
+			expectedDuration = 50 * time.Millisecond
+		} else {
+			expectedPTS += interval
+		}
+		if pts != expectedPTS || duration != expectedDuration {
+			t.Errorf("Frame %d: expected pts %v and duration %v, got %v and %v",
+				i, expectedPTS, expectedDuration, pts, duration)
+		}
+	}
+}
+
+func TestFrameTimer_Monotonic(t *testing.T) {
+	timer := NewFrameTimer(0)
+
+	timestamp := time.Now()
+	if pts, duration := timer.Next(timestamp); pts != time.Second/30 || duration != time.Second/30 {
+		t.Errorf("Expected the first frame of 30fps, got pts %v and duration %v", pts, duration)
+	}
+
+	// Repeated and stale timestamps are shifted after the previous frame
+	last := time.Second / 30
+	for _, ts := range []time.Time{timestamp, timestamp.Add(-time.Second)} {
+		pts, duration := timer.Next(ts)
+		if pts != last+minFrameDuration || duration != minFrameDuration {
+			t.Errorf("Expected pts %v and duration %v, got %v and %v",
+				last+minFrameDuration, minFrameDuration, pts, duration)
+		}
+		last = pts
+	}
+
+	// Zero timestamp is replaced by the current time
+	pts, _ := timer.Next(time.Time{})
+	if pts <= last {
+		t.Errorf("Expected pts after %v, got %v", last, pts)
+	}
+}
@@ -32,6 +32,11 @@ package vpx
 //   return malloc(sizeof(vpx_image_t));
 // }
 //
+// // Variadic function wrapper
+// vpx_codec_err_t setControl(vpx_codec_ctx_t *codec, int id, int v) {
+//   return vpx_codec_control_(codec, id, v);
+// }
+//
 // // Wrap encode function to keep Go memory safe
 // vpx_codec_err_t encode_wrapper(
 //     vpx_codec_ctx_t* codec, vpx_image_t* raw,
@@ -49,12 +54,13 @@ import "C"
 import (
 	"errors"
 	"fmt"
-	"image"
 	"io"
-	"time"
+	"sort"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/frame"
 	mio "github.com/pion/mediadevices/pkg/io"
 	"github.com/pion/mediadevices/pkg/io/video"
 	"github.com/pion/mediadevices/pkg/prop"
@@ -66,30 +72,98 @@ type encoder struct {
 	codec      *C.vpx_codec_ctx_t
 	raw        *C.vpx_image_t
 	cfg        *C.vpx_codec_enc_cfg_t
-	r          video.Reader
+	r          video.RawReader
 	frameIndex int
 	buff       []byte
-	tStart     int
-	tLastFrame int
 	frame      []byte
+	// timer calculates the presentation time and the duration of the frames
+	timer *codec.FrameTimer
+	// initial resolution, which is the maximum resolution supported by the encoder
+	maxWidth, maxHeight int
+	// deadline of the encoding of a frame decided by the latency mode
+	deadline C.ulong
+	// resizeKeyFrame is true if the codec needs a keyframe at the change of
+	// the resolution. VP9 scales the reference frames instead.
+	resizeKeyFrame bool
+	// flushing is true after Flush, and the source is no longer read
+	flushing bool
+
+	forceKeyFrame int32 // accessed atomically
+	bitRate       int64 // accessed atomically, 0 if not changed
+}
+
+// qualityLagInFrames is the number of the lookahead frames of LatencyModeQuality.
+const qualityLagInFrames = 16
+
+// vp8Controls are the libvpx controls of VP8 which can be set by prop.Codec.Controls.
+var vp8Controls = map[string]C.int{
+	"VP8E_SET_CPUUSED":               C.VP8E_SET_CPUUSED,
+	"VP8E_SET_ENABLEAUTOALTREF":      C.VP8E_SET_ENABLEAUTOALTREF,
+	"VP8E_SET_NOISE_SENSITIVITY":     C.VP8E_SET_NOISE_SENSITIVITY,
+	"VP8E_SET_SHARPNESS":             C.VP8E_SET_SHARPNESS,
+	"VP8E_SET_STATIC_THRESHOLD":      C.VP8E_SET_STATIC_THRESHOLD,
+	"VP8E_SET_TOKEN_PARTITIONS":      C.VP8E_SET_TOKEN_PARTITIONS,
+	"VP8E_SET_ARNR_MAXFRAMES":        C.VP8E_SET_ARNR_MAXFRAMES,
+	"VP8E_SET_ARNR_STRENGTH":         C.VP8E_SET_ARNR_STRENGTH,
+	"VP8E_SET_CQ_LEVEL":              C.VP8E_SET_CQ_LEVEL,
+	"VP8E_SET_MAX_INTRA_BITRATE_PCT": C.VP8E_SET_MAX_INTRA_BITRATE_PCT,
+	"VP8E_SET_SCREEN_CONTENT_MODE":   C.VP8E_SET_SCREEN_CONTENT_MODE,
+}
+
+// vp9Controls are the libvpx controls of VP9 which can be set by prop.Codec.Controls.
+// The controls prefixed by VP8E are common to VP8 and VP9.
+var vp9Controls = map[string]C.int{
+	"VP8E_SET_CPUUSED":                 C.VP8E_SET_CPUUSED,
+	"VP8E_SET_ENABLEAUTOALTREF":        C.VP8E_SET_ENABLEAUTOALTREF,
+	"VP8E_SET_SHARPNESS":               C.VP8E_SET_SHARPNESS,
+	"VP8E_SET_STATIC_THRESHOLD":        C.VP8E_SET_STATIC_THRESHOLD,
+	"VP8E_SET_ARNR_MAXFRAMES":          C.VP8E_SET_ARNR_MAXFRAMES,
+	"VP8E_SET_ARNR_STRENGTH":           C.VP8E_SET_ARNR_STRENGTH,
+	"VP8E_SET_CQ_LEVEL":                C.VP8E_SET_CQ_LEVEL,
+	"VP8E_SET_MAX_INTRA_BITRATE_PCT":   C.VP8E_SET_MAX_INTRA_BITRATE_PCT,
+	"VP9E_SET_MAX_INTER_BITRATE_PCT":   C.VP9E_SET_MAX_INTER_BITRATE_PCT,
+	"VP9E_SET_GF_CBR_BOOST_PCT":        C.VP9E_SET_GF_CBR_BOOST_PCT,
+	"VP9E_SET_LOSSLESS":                C.VP9E_SET_LOSSLESS,
+	"VP9E_SET_TILE_COLUMNS":            C.VP9E_SET_TILE_COLUMNS,
+	"VP9E_SET_TILE_ROWS":               C.VP9E_SET_TILE_ROWS,
+	"VP9E_SET_FRAME_PARALLEL_DECODING": C.VP9E_SET_FRAME_PARALLEL_DECODING,
+	"VP9E_SET_AQ_MODE":                 C.VP9E_SET_AQ_MODE,
+	"VP9E_SET_FRAME_PERIODIC_BOOST":    C.VP9E_SET_FRAME_PERIODIC_BOOST,
+	"VP9E_SET_NOISE_SENSITIVITY":       C.VP9E_SET_NOISE_SENSITIVITY,
+	"VP9E_SET_TUNE_CONTENT":            C.VP9E_SET_TUNE_CONTENT,
+	"VP9E_SET_ROW_MT":                  C.VP9E_SET_ROW_MT,
 }
 
 func init() {
 	codec.Register(webrtc.VP8, codec.VideoEncoderBuilder(NewVP8Encoder))
 	codec.Register(webrtc.VP9, codec.VideoEncoderBuilder(NewVP9Encoder))
+	codec.RegisterVideoInputFormats(webrtc.VP8, frame.FormatI420)
+	codec.RegisterVideoInputFormats(webrtc.VP9, frame.FormatI420)
+	codec.RegisterControls(webrtc.VP8, controlNames(vp8Controls)...)
+	codec.RegisterControls(webrtc.VP9, controlNames(vp9Controls)...)
+}
+
+// controlNames returns the sorted names of controls.
+func controlNames(controls map[string]C.int) []string {
+	names := make([]string, 0, len(controls))
+	for name := range controls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 // NewVP8Encoder creates new VP8 encoder
 func NewVP8Encoder(r video.Reader, p prop.Media) (io.ReadCloser, error) {
-	return newEncoder(r, p, C.ifaceVP8())
+	return newEncoder(r, p, C.ifaceVP8(), vp8Controls, true)
 }
 
 // NewVP9Encoder creates new VP9 encoder
 func NewVP9Encoder(r video.Reader, p prop.Media) (io.ReadCloser, error) {
-	return newEncoder(r, p, C.ifaceVP9())
+	return newEncoder(r, p, C.ifaceVP9(), vp9Controls, false)
 }
 
-func newEncoder(r video.Reader, p prop.Media, codecIface *C.vpx_codec_iface_t) (io.ReadCloser, error) {
+func newEncoder(r video.Reader, p prop.Media, codecIface *C.vpx_codec_iface_t, controls map[string]C.int, resizeKeyFrame bool) (io.ReadCloser, error) {
 	if p.BitRate == 0 {
 		p.BitRate = 100000
 	}
@@ -98,6 +172,10 @@ func newEncoder(r video.Reader, p prop.Media, codecIface *C.vpx_codec_iface_t) (
 		p.KeyFrameInterval = 60
 	}
 
+	if p.Threads < 0 {
+		return nil, errors.New("vpx: threads must not be negative")
+	}
+
 	cfg := &C.vpx_codec_enc_cfg_t{}
 	if ec := C.vpx_codec_enc_config_default(codecIface, cfg, 0); ec != 0 {
 		return nil, fmt.Errorf("vpx_codec_enc_config_default failed (%d)", ec)
@@ -108,10 +186,38 @@ func newEncoder(r video.Reader, p prop.Media, codecIface *C.vpx_codec_iface_t) (
 	cfg.g_timebase.den = 1000
 	cfg.rc_target_bitrate = C.uint(p.BitRate) / 1000
 	cfg.kf_max_dist = C.uint(p.KeyFrameInterval)
+	// 0 keeps the single thread of the default configuration
+	cfg.g_threads = C.uint(p.Threads)
 
 	cfg.rc_resize_allowed = 0
 	cfg.g_pass = C.VPX_RC_ONE_PASS
 
+	switch p.BitRateMode {
+	case prop.BitRateModeCBR:
+		cfg.rc_end_usage = C.VPX_CBR
+	case prop.BitRateModeVBR:
+		cfg.rc_end_usage = C.VPX_VBR
+		// The range is relative to the target bitrate in libvpx
+		if p.MinBitRate > 0 {
+			cfg.rc_undershoot_pct = C.uint(rangePct(p.BitRate, p.MinBitRate))
+		}
+		if p.MaxBitRate > 0 {
+			cfg.rc_overshoot_pct = C.uint(rangePct(p.BitRate, p.MaxBitRate))
+		}
+	}
+
+	deadline := C.ulong(C.VPX_DL_REALTIME)
+	switch p.LatencyMode {
+	case prop.LatencyModeRealtime:
+		cfg.g_lag_in_frames = 0
+	case prop.LatencyModeBalanced:
+		cfg.g_lag_in_frames = 0
+		deadline = C.VPX_DL_GOOD_QUALITY
+	case prop.LatencyModeQuality:
+		cfg.g_lag_in_frames = qualityLagInFrames
+		deadline = C.VPX_DL_GOOD_QUALITY
+	}
+
 	raw := &C.vpx_image_t{}
 	if C.vpx_img_alloc(raw, C.VPX_IMG_FMT_I420, cfg.g_w, cfg.g_h, 1) == nil {
 		return nil, errors.New("vpx_img_alloc failed")
@@ -120,24 +226,63 @@ func newEncoder(r video.Reader, p prop.Media, codecIface *C.vpx_codec_iface_t) (
 	*rawNoBuffer = *raw // Copy only parameters
 	C.vpx_img_free(raw) // Pointers will be overwritten by the raw buffer
 
+	timer := codec.NewFrameTimer(p.FrameRate)
 	codec := C.newCtx()
 	if ec := C.vpx_codec_enc_init_ver(
 		codec, codecIface, cfg, 0, C.VPX_ENCODER_ABI_VERSION,
 	); ec != 0 {
 		return nil, fmt.Errorf("vpx_codec_enc_init failed (%d)", ec)
 	}
-	t0 := time.Now().Nanosecond() / 1000000
+	if err := setControls(codec, controls, p.Controls); err != nil {
+		C.vpx_codec_destroy(codec)
+		C.free(unsafe.Pointer(rawNoBuffer))
+		C.free(unsafe.Pointer(codec))
+		return nil, err
+	}
 	return &encoder{
-		r:          video.ToI420(r),
-		codec:      codec,
-		raw:        rawNoBuffer,
-		cfg:        cfg,
-		tStart:     t0,
-		tLastFrame: t0,
-		frame:      make([]byte, 1024),
+		r:         video.ToRaw(video.ToI420(r)),
+		codec:     codec,
+		raw:       rawNoBuffer,
+		cfg:       cfg,
+		frame:     make([]byte, 1024),
+		timer:     timer,
+		maxWidth:  p.Width,
+		maxHeight: p.Height,
+		deadline:  deadline,
+
+		resizeKeyFrame: resizeKeyFrame,
 	}, nil
 }
 
+// rangePct returns the difference between the target bitrate and the bound
+// in percentage of the target.
+func rangePct(target, bound int) int {
+	d := bound - target
+	if d < 0 {
+		d = -d
+	}
+	return d * 100 / target
+}
+
+// setControls applies the controls of prop.Codec.Controls in order of the names.
+func setControls(codec *C.vpx_codec_ctx_t, supported map[string]C.int, values map[string]int) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if _, ok := supported[name]; !ok {
+			return fmt.Errorf("vpx: unknown control %s", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if ec := C.setControl(codec, supported[name], C.int(values[name])); ec != C.VPX_CODEC_OK {
+			return fmt.Errorf("vpx: failed to set control %s=%d (%d)", name, values[name], ec)
+		}
+	}
+	return nil
+}
+
 func (e *encoder) Read(p []byte) (int, error) {
 	if e.buff != nil {
 		n, err := mio.Copy(p, e.buff)
@@ -147,20 +292,45 @@ func (e *encoder) Read(p []byte) (int, error) {
 		return n, err
 	}
 
-	img, err := e.r.Read()
+	if e.flushing {
+		// Encoding no image outputs the frames buffered for the lookahead one by one
+		if ec := C.vpx_codec_encode(e.codec, nil, -1, 1, 0, e.deadline); ec != C.VPX_CODEC_OK {
+			return 0, fmt.Errorf("vpx_codec_encode failed (%d)", ec)
+		}
+		e.readPackets()
+		if len(e.frame) == 0 {
+			return 0, io.EOF
+		}
+		return e.copyFrame(p)
+	}
+
+	f, err := e.r.ReadRaw()
 	if err != nil {
 		return 0, err
 	}
-	yuvImg := img.(*image.YCbCr)
-	bounds := yuvImg.Bounds()
-	height := C.int(bounds.Dy())
-	width := C.int(bounds.Dx())
+	height := C.int(f.Height)
+	width := C.int(f.Width)
+
+	e.raw.stride[0] = C.int(f.Strides[0])
+	e.raw.stride[1] = C.int(f.Strides[1])
+	e.raw.stride[2] = C.int(f.Strides[2])
 
-	e.raw.stride[0] = C.int(yuvImg.YStride)
-	e.raw.stride[1] = C.int(yuvImg.CStride)
-	e.raw.stride[2] = C.int(yuvImg.CStride)
+	// Use the actual frame timing, so that the rate control keeps the target bitrate
+	// even if the frames are captured or dropped irregularly.
+	// The time base is 1ms.
+	pts, duration := e.timer.Next(f.Timestamp)
 
-	t := time.Now().Nanosecond() / 1000000
+	var flags int
+	if atomic.CompareAndSwapInt32(&e.forceKeyFrame, 1, 0) {
+		flags |= C.VPX_EFLAG_FORCE_KF
+	}
+
+	if bitRate := atomic.SwapInt64(&e.bitRate, 0); bitRate > 0 {
+		e.cfg.rc_target_bitrate = C.uint(bitRate) / 1000
+		if ec := C.vpx_codec_enc_config_set(e.codec, e.cfg); ec != C.VPX_CODEC_OK {
+			return 0, fmt.Errorf("vpx_codec_enc_config_set failed (%d)", ec)
+		}
+	}
 
 	if e.cfg.g_w != C.uint(width) || e.cfg.g_h != C.uint(height) {
 		e.cfg.g_w, e.cfg.g_h = C.uint(width), C.uint(height)
@@ -170,20 +340,31 @@ func (e *encoder) Read(p []byte) (int, error) {
 		e.raw.w, e.raw.h = C.uint(width), C.uint(height)
 		e.raw.r_w, e.raw.r_h = C.uint(width), C.uint(height)
 		e.raw.d_w, e.raw.d_h = C.uint(width), C.uint(height)
+		if e.resizeKeyFrame {
+			// The decoder needs a keyframe to know the new resolution
+			flags |= C.VPX_EFLAG_FORCE_KF
+		}
 	}
-
-	var flags int
 	if ec := C.encode_wrapper(
 		e.codec, e.raw,
-		C.long(t-e.tStart), C.ulong(t-e.tLastFrame), C.long(flags), C.VPX_DL_REALTIME,
-		(*C.uchar)(&yuvImg.Y[0]), (*C.uchar)(&yuvImg.Cb[0]), (*C.uchar)(&yuvImg.Cr[0]),
+		C.long(pts.Milliseconds()), C.ulong(duration.Milliseconds()), C.long(flags), e.deadline,
+		(*C.uchar)(&f.Planes[0][0]), (*C.uchar)(&f.Planes[1][0]), (*C.uchar)(&f.Planes[2][0]),
 	); ec != C.VPX_CODEC_OK {
 		return 0, fmt.Errorf("vpx_codec_encode failed (%d)", ec)
 	}
 
 	e.frameIndex++
-	e.tLastFrame = t
 
+	e.readPackets()
+	if len(e.frame) == 0 && e.cfg.g_lag_in_frames > 0 {
+		// The frame is buffered for the lookahead
+		return e.Read(p)
+	}
+	return e.copyFrame(p)
+}
+
+// readPackets reads the encoded packets into e.frame.
+func (e *encoder) readPackets() {
 	e.frame = e.frame[:0]
 	var iter C.vpx_codec_iter_t
 	for {
@@ -196,6 +377,9 @@ func (e *encoder) Read(p []byte) (int, error) {
 			e.frame = append(e.frame, encoded...)
 		}
 	}
+}
+
+func (e *encoder) copyFrame(p []byte) (int, error) {
 	n, err := mio.Copy(p, e.frame)
 	if err != nil {
 		e.buff = e.frame
@@ -203,6 +387,50 @@ func (e *encoder) Read(p []byte) (int, error) {
 	return n, err
 }
 
+// Flush implements codec.Flusher. The frames buffered for the lookahead of
+// LatencyModeQuality are output by the following Reads.
+func (e *encoder) Flush() error {
+	e.flushing = true
+	return nil
+}
+
+func (e *encoder) ForceKeyFrame() error {
+	atomic.StoreInt32(&e.forceKeyFrame, 1)
+	return nil
+}
+
+// SetResolution implements codec.ResolutionController. The encoder is
+// reconfigured when the frame of the new resolution is read.
+// The resolution can't exceed the initial one since libvpx doesn't reallocate
+// the VP8 encoder buffers.
+func (e *encoder) SetResolution(width, height int) error {
+	switch {
+	case width <= 0 || height <= 0:
+		return fmt.Errorf("vpx: invalid resolution %dx%d", width, height)
+	case width > e.maxWidth || height > e.maxHeight:
+		return fmt.Errorf("vpx: resolution %dx%d exceeds the initial resolution %dx%d",
+			width, height, e.maxWidth, e.maxHeight)
+	case e.cfg.g_lag_in_frames > 1:
+		return errors.New("vpx: resolution can't be changed when lag in frames is enabled")
+	}
+	return nil
+}
+
+// SetBitRate implements codec.BitRateController.
+func (e *encoder) SetBitRate(bitRate int) error {
+	if bitRate < 1000 {
+		return fmt.Errorf("vpx: invalid bitrate %d", bitRate)
+	}
+	atomic.StoreInt64(&e.bitRate, int64(bitRate))
+	return nil
+}
+
+// NeedsKeyFrame implements codec.ReconfigurationReporter. The bitrate is changed
+// without a keyframe. VP8 needs a keyframe at the change of the resolution.
+func (e *encoder) NeedsKeyFrame(r codec.Reconfiguration) bool {
+	return r == codec.ReconfigureResolution && e.resizeKeyFrame
+}
+
 func (e *encoder) Close() error {
 	C.free(unsafe.Pointer(e.raw))
 	defer C.free(unsafe.Pointer(e.codec))
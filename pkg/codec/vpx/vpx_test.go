@@ -0,0 +1,328 @@
+package vpx
+
+import (
+	"encoding/binary"
+	"image"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	mio "github.com/pion/mediadevices/pkg/io"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+// parseVP8FrameHeader returns whether the VP8 frame is a keyframe and the
+// frame size stored in the keyframe header.
+// Reference: https://tools.ietf.org/html/rfc6386#section-9.1
+func parseVP8FrameHeader(b []byte) (key bool, width, height int) {
+	if b[0]&0x01 != 0 {
+		return false, 0, 0
+	}
+	width = int(binary.LittleEndian.Uint16(b[6:8]) & 0x3FFF)
+	height = int(binary.LittleEndian.Uint16(b[8:10]) & 0x3FFF)
+	return true, width, height
+}
+
+func TestSetResolution(t *testing.T) {
+	size := image.Pt(64, 48)
+	e, err := NewVP8Encoder(video.ReaderFunc(func() (image.Image, error) {
+		return image.NewYCbCr(image.Rectangle{Max: size}, image.YCbCrSubsampleRatio420), nil
+	}), prop.Media{
+		Video: prop.Video{
+			Width:     size.X,
+			Height:    size.Y,
+			FrameRate: 30,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	defer e.Close()
+
+	rc, ok := e.(codec.ResolutionController)
+	if !ok {
+		t.Fatal("Encoder must implement codec.ResolutionController")
+	}
+	if err := rc.SetResolution(96, 64); err == nil {
+		t.Error("Expected error on resolution larger than the initial one")
+	}
+
+	buff := make([]byte, 1024)
+	for _, s := range []image.Point{{64, 48}, {32, 24}, {64, 48}} {
+		if err := rc.SetResolution(s.X, s.Y); err != nil {
+			t.Fatalf("Failed to set resolution: %v", err)
+		}
+		size = s
+
+		for i := 0; i < 3; i++ {
+			n, err := e.Read(buff)
+			for err != nil {
+				bufErr, ok := err.(*mio.InsufficientBufferError)
+				if !ok {
+					t.Fatalf("Failed to encode: %v", err)
+				}
+				buff = make([]byte, 2*bufErr.RequiredSize)
+				n, err = e.Read(buff)
+			}
+
+			key, width, height := parseVP8FrameHeader(buff[:n])
+			switch {
+			case i == 0 && !key:
+				t.Errorf("Expected keyframe at the switch to %dx%d", s.X, s.Y)
+			case i == 0 && (width != s.X || height != s.Y):
+				t.Errorf("Expected %dx%d, got %dx%d", s.X, s.Y, width, height)
+			case i > 0 && key:
+				t.Errorf("Unexpected keyframe in frame %d of %dx%d", i, s.X, s.Y)
+			}
+		}
+	}
+}
+
+func TestIrregularFrameTiming(t *testing.T) {
+	const (
+		width, height = 160, 120
+		bitRate       = 300000
+		frames        = 300
+	)
+
+	// Frames arrive at 20fps in average, but the intervals alternate between
+	// 10ms and 90ms as if the capture is throttled.
+	timestamp := time.Now()
+	buf := make([]byte, width*height*3/2)
+	rnd := rand.New(rand.NewSource(1))
+	var cnt int
+	e, err := NewVP8Encoder(video.RawReaderFunc(func() (video.RawFrame, error) {
+		if cnt%2 == 0 {
+			timestamp = timestamp.Add(10 * time.Millisecond)
+		} else {
+			timestamp = timestamp.Add(90 * time.Millisecond)
+		}
+		cnt++
+		rnd.Read(buf)
+		f, err := video.NewI420Frame(buf, width, height)
+		f.Timestamp = timestamp
+		return f, err
+	}), prop.Media{
+		Video: prop.Video{
+			Width:     width,
+			Height:    height,
+			FrameRate: 20,
+		},
+		Codec: prop.Codec{
+			BitRate: bitRate,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	defer e.Close()
+
+	buff := make([]byte, 1024)
+	var total int
+	var tHalf time.Time
+	for i := 0; i < frames; i++ {
+		n, err := e.Read(buff)
+		for err != nil {
+			bufErr, ok := err.(*mio.InsufficientBufferError)
+			if !ok {
+				t.Fatalf("Failed to encode: %v", err)
+			}
+			buff = make([]byte, 2*bufErr.RequiredSize)
+			n, err = e.Read(buff)
+		}
+		// Measure the second half after the rate control converged
+		if i == frames/2 {
+			tHalf = timestamp
+		}
+		if i > frames/2 {
+			total += n
+		}
+	}
+
+	actual := float64(total*8) / timestamp.Sub(tHalf).Seconds()
+	if actual < bitRate*0.7 || actual > bitRate*1.3 {
+		t.Errorf("Expected average bitrate close to %d bps, got %.0f bps", bitRate, actual)
+	}
+}
+
+func TestLatencyMode(t *testing.T) {
+	const width, height = 64, 48
+	rnd := rand.New(rand.NewSource(1))
+
+	// latency returns the number of the frames read by the encoder until the first frame is output.
+	latency := func(t *testing.T, mode prop.LatencyMode) int {
+		img := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+		var cnt int
+		e, err := NewVP9Encoder(video.ReaderFunc(func() (image.Image, error) {
+			cnt++
+			rnd.Read(img.Y)
+			return img, nil
+		}), prop.Media{
+			Video: prop.Video{
+				Width:     width,
+				Height:    height,
+				FrameRate: 30,
+			},
+			Codec: prop.Codec{
+				LatencyMode: mode,
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create encoder: %v", err)
+		}
+		defer e.Close()
+
+		buff := make([]byte, 1024)
+		_, err = e.Read(buff)
+		for err != nil {
+			bufErr, ok := err.(*mio.InsufficientBufferError)
+			if !ok {
+				t.Fatalf("Failed to encode: %v", err)
+			}
+			buff = make([]byte, 2*bufErr.RequiredSize)
+			_, err = e.Read(buff)
+		}
+		return cnt
+	}
+
+	realtime := latency(t, prop.LatencyModeRealtime)
+	quality := latency(t, prop.LatencyModeQuality)
+	if realtime != 1 {
+		t.Errorf("Expected no lookahead in realtime mode, got %d frames latency", realtime)
+	}
+	if quality <= realtime {
+		t.Errorf("Expected realtime mode to have lower latency than quality mode, got %d and %d frames", realtime, quality)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	const width, height = 64, 48
+	rnd := rand.New(rand.NewSource(1))
+
+	img := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+	var cnt int
+	e, err := NewVP8Encoder(video.ReaderFunc(func() (image.Image, error) {
+		cnt++
+		rnd.Read(img.Y)
+		return img, nil
+	}), prop.Media{
+		Video: prop.Video{
+			Width:     width,
+			Height:    height,
+			FrameRate: 30,
+		},
+		Codec: prop.Codec{
+			LatencyMode: prop.LatencyModeQuality,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	defer e.Close()
+
+	read := func(buff []byte) (int, error) {
+		n, err := e.Read(buff)
+		if bufErr, ok := err.(*mio.InsufficientBufferError); ok {
+			return e.Read(make([]byte, 2*bufErr.RequiredSize))
+		}
+		return n, err
+	}
+
+	buff := make([]byte, 1024)
+	if _, err := read(buff); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	if cnt <= 1 {
+		t.Fatalf("Expected the frames to be buffered for the lookahead, got %d frames latency", cnt)
+	}
+
+	if err := e.(codec.Flusher).Flush(); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+	frames := 1
+	for {
+		_, err := read(buff)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to flush: %v", err)
+		}
+		frames++
+	}
+	if frames != cnt {
+		t.Errorf("Expected all %d frames to be output, got %d", cnt, frames)
+	}
+}
+
+func TestControls(t *testing.T) {
+	const width, height = 64, 48
+	rnd := rand.New(rand.NewSource(1))
+
+	// frameSize returns the size of the first frame encoded by VP9 with the controls.
+	frameSize := func(t *testing.T, controls map[string]int) int {
+		img := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+		rnd.Read(img.Y)
+		e, err := NewVP9Encoder(video.ReaderFunc(func() (image.Image, error) {
+			return img, nil
+		}), prop.Media{
+			Video: prop.Video{
+				Width:     width,
+				Height:    height,
+				FrameRate: 30,
+			},
+			Codec: prop.Codec{
+				Controls: controls,
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create encoder: %v", err)
+		}
+		defer e.Close()
+
+		buff := make([]byte, 1024)
+		n, err := e.Read(buff)
+		for err != nil {
+			bufErr, ok := err.(*mio.InsufficientBufferError)
+			if !ok {
+				t.Fatalf("Failed to encode: %v", err)
+			}
+			buff = make([]byte, 2*bufErr.RequiredSize)
+			n, err = e.Read(buff)
+		}
+		return n
+	}
+
+	lossy := frameSize(t, nil)
+	lossless := frameSize(t, map[string]int{"VP9E_SET_LOSSLESS": 1})
+	// Random luma can't be compressed without loss
+	if lossless < width*height {
+		t.Errorf("Expected lossless frame larger than %d bytes, got %d", width*height, lossless)
+	}
+	if lossy >= lossless {
+		t.Errorf("Expected lossy frame smaller than lossless frame, got %d and %d bytes", lossy, lossless)
+	}
+
+	for name, controls := range map[string]map[string]int{
+		"Unknown":    {"VP8E_SET_UNKNOWN": 1},
+		"VP9OnlyVP8": {"VP9E_SET_LOSSLESS": 1},
+		"OutOfRange": {"VP8E_SET_CPUUSED": 100},
+	} {
+		controls := controls
+		t.Run(name, func(t *testing.T) {
+			e, err := NewVP8Encoder(video.ReaderFunc(func() (image.Image, error) {
+				return image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420), nil
+			}), prop.Media{
+				Video: prop.Video{Width: width, Height: height},
+				Codec: prop.Codec{Controls: controls},
+			})
+			if err == nil {
+				e.Close()
+				t.Error("Expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,8 @@
+// Package x265 implements H.265 (HEVC) encoder.
+// This package requires libx265 headers and libraries to be built,
+// and has to be enabled by the x265 build tag:
+//
+//	go build -tags x265
+//
+// Without the tag, importing this package doesn't register any encoder.
+package x265
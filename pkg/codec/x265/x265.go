@@ -0,0 +1,349 @@
+// +build x265
+
+package x265
+
+// #cgo pkg-config: x265
+// #include <stdlib.h>
+// #include <stdint.h>
+// #include <x265.h>
+//
+// // Wrap encode function to keep Go memory safe
+// int encode_wrapper(
+//     x265_encoder *enc, x265_picture *pic,
+//     x265_nal **nals, uint32_t *nnal, int64_t pts,
+//     unsigned char *y_ptr, unsigned char *cb_ptr, unsigned char *cr_ptr,
+//     int y_stride, int c_stride) {
+//   pic->planes[0] = y_ptr;
+//   pic->planes[1] = cb_ptr;
+//   pic->planes[2] = cr_ptr;
+//   pic->stride[0] = y_stride;
+//   pic->stride[1] = c_stride;
+//   pic->stride[2] = c_stride;
+//   pic->pts = pts;
+//   int ret = x265_encoder_encode(enc, nals, nnal, pic, NULL);
+//   pic->planes[0] = pic->planes[1] = pic->planes[2] = 0;
+//   return ret;
+// }
+//
+// // x265_encoder_open is a macro to add ABI version suffix
+// x265_encoder *encoder_open(x265_param *param) {
+//   return x265_encoder_open(param);
+// }
+//
+// // C array helpers
+// x265_nal *nalAt(x265_nal *nals, int i) {
+//   return &nals[i];
+// }
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/frame"
+	mio "github.com/pion/mediadevices/pkg/io"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+// Name is the name of H.265 codec used to register the encoder.
+const Name = "H265"
+
+// presets are x265 presets ordered by encoding quality.
+// prop.Codec.Quality [0-9] is used as the index of this list.
+var presets = []string{
+	"ultrafast", "superfast", "veryfast", "faster", "fast",
+	"medium", "slow", "slower", "veryslow", "placebo",
+}
+
+// balancedLookahead is the number of the lookahead frames of LatencyModeBalanced.
+const balancedLookahead = 10
+
+type encoder struct {
+	engine *C.x265_encoder
+	param  *C.x265_param
+	pic    *C.x265_picture
+	r      video.RawReader
+	ratio  image.YCbCrSubsampleRatio
+	buff   []byte
+	frame  []byte
+	// timer calculates the presentation time of the frames, which is passed to
+	// x265 in the time base of the nominal frame rate
+	timer     *codec.FrameTimer
+	frameRate float32
+	pts       int64
+	// flushing is true after Flush, and the source is no longer read
+	flushing bool
+
+	forceKeyFrame int32 // accessed atomically
+}
+
+func init() {
+	codec.Register(Name, codec.VideoEncoderBuilder(NewEncoder))
+	codec.RegisterVideoInputFormats(Name, frame.FormatI420, frame.FormatI422, frame.FormatI444)
+}
+
+// chromaFormat returns the x265 color space and the subsample ratio encoding
+// the frames of format f. Formats other than FormatI422 and FormatI444 are
+// converted to I420.
+func chromaFormat(f frame.Format) (C.int, image.YCbCrSubsampleRatio) {
+	switch f {
+	case frame.FormatI422:
+		return C.X265_CSP_I422, image.YCbCrSubsampleRatio422
+	case frame.FormatI444:
+		return C.X265_CSP_I444, image.YCbCrSubsampleRatio444
+	default:
+		return C.X265_CSP_I420, image.YCbCrSubsampleRatio420
+	}
+}
+
+// NewEncoder creates new H.265 encoder
+func NewEncoder(r video.Reader, p prop.Media) (io.ReadCloser, error) {
+	if p.BitRate == 0 {
+		p.BitRate = 100000
+	}
+
+	if p.KeyFrameInterval == 0 {
+		p.KeyFrameInterval = 60
+	}
+
+	if p.FrameRate == 0 {
+		p.FrameRate = 30
+	}
+
+	if p.Quality < 0 || p.Quality >= len(presets) {
+		return nil, fmt.Errorf("x265: quality must be in [0-%d]", len(presets)-1)
+	}
+
+	if p.Threads < 0 {
+		return nil, errors.New("x265: threads must not be negative")
+	}
+
+	param := C.x265_param_alloc()
+	if param == nil {
+		return nil, errors.New("x265_param_alloc failed")
+	}
+
+	preset := C.CString(presets[p.Quality])
+	defer C.free(unsafe.Pointer(preset))
+	// zerolatency disables the lookahead and the frame threads
+	var tune *C.char
+	if p.LatencyMode != prop.LatencyModeQuality {
+		tune = C.CString("zerolatency")
+		defer C.free(unsafe.Pointer(tune))
+	}
+	if C.x265_param_default_preset(param, preset, tune) != 0 {
+		C.x265_param_free(param)
+		return nil, errors.New("x265_param_default_preset failed")
+	}
+
+	param.sourceWidth = C.int(p.Width)
+	param.sourceHeight = C.int(p.Height)
+	csp, ratio := chromaFormat(p.FrameFormat)
+	param.internalCsp = csp
+	param.fpsNum = C.uint32_t(p.FrameRate * 1000)
+	param.fpsDenom = 1000
+	param.keyframeMax = C.int(p.KeyFrameInterval)
+	param.bRepeatHeaders = 1
+	param.bAnnexB = 1
+	param.logLevel = C.X265_LOG_ERROR
+	param.rc.rateControlMode = C.X265_RC_ABR
+	param.rc.bitrate = C.int(p.BitRate / 1000)
+	if err := setRateControl(param, p.Codec); err != nil {
+		C.x265_param_free(param)
+		return nil, err
+	}
+	switch p.LatencyMode {
+	case prop.LatencyModeBalanced:
+		if err := parseParam(param, "rc-lookahead", strconv.Itoa(balancedLookahead)); err != nil {
+			C.x265_param_free(param)
+			return nil, err
+		}
+	case prop.LatencyModeQuality:
+		// B-frames are not supported by most WebRTC implementations
+		if err := parseParam(param, "bframes", "0"); err != nil {
+			C.x265_param_free(param)
+			return nil, err
+		}
+	}
+	if p.Threads > 0 {
+		// Both of the worker pool size and the number of the concurrently
+		// encoded frames are limited. x265 selects them automatically by default.
+		for _, opt := range []string{"pools", "frame-threads"} {
+			if err := parseParam(param, opt, strconv.Itoa(p.Threads)); err != nil {
+				C.x265_param_free(param)
+				return nil, err
+			}
+		}
+	}
+
+	engine := C.encoder_open(param)
+	if engine == nil {
+		C.x265_param_free(param)
+		return nil, errors.New("x265_encoder_open failed")
+	}
+
+	pic := C.x265_picture_alloc()
+	C.x265_picture_init(param, pic)
+
+	// 4:2:2 and 4:4:4 frames are encoded without subsampling the chroma
+	if ratio == image.YCbCrSubsampleRatio420 {
+		r = video.ToI420(r)
+	}
+
+	return &encoder{
+		engine:    engine,
+		param:     param,
+		pic:       pic,
+		r:         video.ToRaw(r),
+		ratio:     ratio,
+		frame:     make([]byte, 1024),
+		timer:     codec.NewFrameTimer(p.FrameRate),
+		frameRate: p.FrameRate,
+		pts:       -1,
+	}, nil
+}
+
+func (e *encoder) Read(p []byte) (int, error) {
+	if e.buff != nil {
+		n, err := mio.Copy(p, e.buff)
+		if err == nil {
+			e.buff = nil
+		}
+		return n, err
+	}
+
+	if e.flushing {
+		// Encoding no picture outputs the frames buffered for the lookahead one by one
+		var nals *C.x265_nal
+		var nnal C.uint32_t
+		ret := C.x265_encoder_encode(e.engine, &nals, &nnal, nil, nil)
+		if ret < 0 {
+			return 0, fmt.Errorf("x265_encoder_encode failed (%d)", ret)
+		}
+		if ret == 0 {
+			return 0, io.EOF
+		}
+		return e.copyNALs(p, nals, nnal)
+	}
+
+	f, err := e.r.ReadRaw()
+	if err != nil {
+		return 0, err
+	}
+	img, err := f.Image()
+	if err != nil {
+		return 0, err
+	}
+	yuvImg, ok := img.(*image.YCbCr)
+	if !ok || yuvImg.SubsampleRatio != e.ratio {
+		return 0, fmt.Errorf("x265: frame must be YCbCr %s, got %T", e.ratio, img)
+	}
+
+	e.pic.sliceType = C.X265_TYPE_AUTO
+	if atomic.CompareAndSwapInt32(&e.forceKeyFrame, 1, 0) {
+		e.pic.sliceType = C.X265_TYPE_IDR
+	}
+
+	// x265 has no per-frame duration and its rate control assumes the nominal
+	// frame rate, but the capture timing is kept in the presentation time.
+	// The presentation time must be monotonically increasing.
+	pts, _ := e.timer.Next(f.Timestamp)
+	ticks := int64(math.Round(pts.Seconds() * float64(e.frameRate)))
+	if ticks <= e.pts {
+		ticks = e.pts + 1
+	}
+	e.pts = ticks
+
+	var nals *C.x265_nal
+	var nnal C.uint32_t
+	if ret := C.encode_wrapper(
+		e.engine, e.pic, &nals, &nnal, C.int64_t(e.pts),
+		(*C.uchar)(&yuvImg.Y[0]), (*C.uchar)(&yuvImg.Cb[0]), (*C.uchar)(&yuvImg.Cr[0]),
+		C.int(yuvImg.YStride), C.int(yuvImg.CStride),
+	); ret < 0 {
+		return 0, fmt.Errorf("x265_encoder_encode failed (%d)", ret)
+	}
+	if nnal == 0 {
+		// The frame is buffered for the lookahead
+		return e.Read(p)
+	}
+	return e.copyNALs(p, nals, nnal)
+}
+
+func (e *encoder) copyNALs(p []byte, nals *C.x265_nal, nnal C.uint32_t) (int, error) {
+	e.frame = e.frame[:0]
+	for i := 0; i < int(nnal); i++ {
+		nal := C.nalAt(nals, C.int(i))
+		encoded := C.GoBytes(unsafe.Pointer(nal.payload), C.int(nal.sizeBytes))
+		e.frame = append(e.frame, encoded...)
+	}
+	n, err := mio.Copy(p, e.frame)
+	if err != nil {
+		e.buff = e.frame
+	}
+	return n, err
+}
+
+// Flush implements codec.Flusher. The frames buffered for the lookahead and
+// the B-frames are output by the following Reads.
+func (e *encoder) Flush() error {
+	e.flushing = true
+	return nil
+}
+
+func (e *encoder) ForceKeyFrame() error {
+	atomic.StoreInt32(&e.forceKeyFrame, 1)
+	return nil
+}
+
+func (e *encoder) Close() error {
+	C.x265_picture_free(e.pic)
+	C.x265_encoder_close(e.engine)
+	C.x265_param_free(e.param)
+	return nil
+}
+
+// setRateControl bounds the bitrate of ABR by the VBV buffer of a second.
+// x265 doesn't have the lower bound of the bitrate.
+func setRateControl(param *C.x265_param, c prop.Codec) error {
+	var maxRate int
+	switch c.BitRateMode {
+	case prop.BitRateModeCBR:
+		if err := parseParam(param, "strict-cbr", "1"); err != nil {
+			return err
+		}
+		maxRate = c.BitRate
+	case prop.BitRateModeVBR:
+		maxRate = c.MaxBitRate
+	}
+	if maxRate == 0 {
+		return nil
+	}
+	kbps := strconv.Itoa(maxRate / 1000)
+	for _, opt := range []string{"vbv-maxrate", "vbv-bufsize"} {
+		if err := parseParam(param, opt, kbps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseParam(param *C.x265_param, name, value string) error {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	cValue := C.CString(value)
+	defer C.free(unsafe.Pointer(cValue))
+
+	if ec := C.x265_param_parse(param, cName, cValue); ec != 0 {
+		return fmt.Errorf("x265_param_parse %s=%s failed (%d)", name, value, ec)
+	}
+	return nil
+}
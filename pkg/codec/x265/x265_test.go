@@ -0,0 +1,227 @@
+// +build x265
+
+package x265
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math"
+	"os/exec"
+	"testing"
+
+	mio "github.com/pion/mediadevices/pkg/io"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+// nalTypes parses H.265 Annex-B byte stream and returns the list of NAL unit types.
+func nalTypes(b []byte) []int {
+	var types []int
+	for i := 0; i+3 < len(b); i++ {
+		if b[i] == 0 && b[i+1] == 0 && b[i+2] == 1 {
+			types = append(types, int(b[i+3]>>1)&0x3F)
+			i += 3
+		}
+	}
+	return types
+}
+
+func TestEncode(t *testing.T) {
+	for _, threads := range []int{0, 1, 4} {
+		threads := threads
+		t.Run(fmt.Sprintf("Threads%d", threads), func(t *testing.T) {
+			testEncode(t, threads)
+		})
+	}
+}
+
+func testEncode(t *testing.T, threads int) {
+	const width, height = 64, 64
+	img := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+	for i := range img.Y {
+		img.Y[i] = uint8(i)
+	}
+
+	e, err := NewEncoder(video.ReaderFunc(func() (image.Image, error) {
+		return img, nil
+	}), prop.Media{
+		Video: prop.Video{
+			Width:     width,
+			Height:    height,
+			FrameRate: 30,
+		},
+		Codec: prop.Codec{
+			Threads: threads,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	defer e.Close()
+
+	found := make(map[int]bool)
+	buff := make([]byte, 1024)
+	for i := 0; i < 10; i++ {
+		n, err := e.Read(buff)
+		if err != nil {
+			if e, ok := err.(*mio.InsufficientBufferError); ok {
+				buff = make([]byte, 2*e.RequiredSize)
+				i--
+				continue
+			}
+			t.Fatalf("Failed to encode: %v", err)
+		}
+		for _, typ := range nalTypes(buff[:n]) {
+			found[typ] = true
+		}
+	}
+
+	// VPS, SPS, PPS and IDR slice must be in the stream to be decodable.
+	for name, typ := range map[string]int{"VPS": 32, "SPS": 33, "PPS": 34} {
+		if !found[typ] {
+			t.Errorf("%s is not found in the encoded stream", name)
+		}
+	}
+	if !found[19] && !found[20] {
+		t.Error("IDR slice is not found in the encoded stream")
+	}
+}
+
+func TestLatencyMode(t *testing.T) {
+	const width, height = 64, 64
+
+	// latency returns the number of the frames read by the encoder until the first frame is output.
+	latency := func(t *testing.T, mode prop.LatencyMode) int {
+		img := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+		var cnt int
+		e, err := NewEncoder(video.ReaderFunc(func() (image.Image, error) {
+			cnt++
+			for i := range img.Y {
+				img.Y[i] = uint8(i + cnt)
+			}
+			return img, nil
+		}), prop.Media{
+			Video: prop.Video{
+				Width:     width,
+				Height:    height,
+				FrameRate: 30,
+			},
+			Codec: prop.Codec{
+				LatencyMode: mode,
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create encoder: %v", err)
+		}
+		defer e.Close()
+
+		buff := make([]byte, 1024)
+		_, err = e.Read(buff)
+		for err != nil {
+			bufErr, ok := err.(*mio.InsufficientBufferError)
+			if !ok {
+				t.Fatalf("Failed to encode: %v", err)
+			}
+			buff = make([]byte, 2*bufErr.RequiredSize)
+			_, err = e.Read(buff)
+		}
+		return cnt
+	}
+
+	realtime := latency(t, prop.LatencyModeRealtime)
+	balanced := latency(t, prop.LatencyModeBalanced)
+	quality := latency(t, prop.LatencyModeQuality)
+	if realtime != 1 {
+		t.Errorf("Expected no lookahead in realtime mode, got %d frames latency", realtime)
+	}
+	if !(realtime < balanced && balanced <= quality) {
+		t.Errorf("Expected latency to increase from realtime to quality mode, got %d, %d and %d frames", realtime, balanced, quality)
+	}
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	// libx265 has no decoder, so the stream is decoded by the HEVC decoder of ffmpeg.
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		t.Skip("ffmpeg is not found")
+	}
+
+	const width, height = 64, 64
+	const frames = 30
+	// source returns the i-th frame, a gradient moving by a pixel per frame.
+	source := func(i int) *image.YCbCr {
+		img := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				img.Y[y*img.YStride+x] = uint8(2 * (x + y + i))
+			}
+		}
+		for j := range img.Cb {
+			img.Cb[j] = 128
+			img.Cr[j] = 128
+		}
+		return img
+	}
+
+	var cnt int
+	e, err := NewEncoder(video.ReaderFunc(func() (image.Image, error) {
+		img := source(cnt)
+		cnt++
+		return img, nil
+	}), prop.Media{
+		Video: prop.Video{
+			Width:     width,
+			Height:    height,
+			FrameRate: 30,
+		},
+		Codec: prop.Codec{
+			BitRate: 1000000,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	defer e.Close()
+
+	var stream bytes.Buffer
+	buff := make([]byte, 1024)
+	for i := 0; i < frames; i++ {
+		n, err := e.Read(buff)
+		if err != nil {
+			if e, ok := err.(*mio.InsufficientBufferError); ok {
+				buff = make([]byte, 2*e.RequiredSize)
+				i--
+				continue
+			}
+			t.Fatalf("Failed to encode: %v", err)
+		}
+		stream.Write(buff[:n])
+	}
+
+	cmd := exec.Command(ffmpeg, "-loglevel", "error", "-f", "hevc", "-i", "-", "-f", "rawvideo", "-pix_fmt", "yuv420p", "-")
+	cmd.Stdin = &stream
+	decoded, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	// The frames are decoded in the presentation order from the first frame
+	const frameSize = width * height * 3 / 2
+	if len(decoded) != frames*frameSize {
+		t.Fatalf("Expected %d decoded frames of %dx%d, got %d bytes", frames, width, height, len(decoded))
+	}
+	for i := 0; i < frames; i++ {
+		y := decoded[i*frameSize : i*frameSize+width*height]
+		src := source(i)
+		var mse float64
+		for j := range y {
+			d := float64(y[j]) - float64(src.Y[j])
+			mse += d * d
+		}
+		mse /= float64(len(y))
+		if psnr := 10 * math.Log10(255*255/mse); psnr < 30 {
+			t.Errorf("Expected PSNR of frame %d over 30dB, got %.1fdB", i, psnr)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Package audiotone provides a tone generator audio driver.
+// It's useful for deterministic tests and demos without a microphone.
+package audiotone
+
+import (
+	"context"
+	"io"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+// Waveform represents the shape of the generated signal
+type Waveform int
+
+// Waveform definitions.
+const (
+	// Sine generates a sine wave at Config.Frequency
+	Sine Waveform = iota
+	// WhiteNoise generates a uniformly distributed white noise
+	WhiteNoise
+)
+
+// Config represents the tone to be generated
+type Config struct {
+	Waveform Waveform
+	// Frequency of the sine wave in Hz.
+	Frequency float64
+	// Amplitude of the signal [0-1].
+	Amplitude float32
+}
+
+func init() {
+	Register("AudioTone", Config{Waveform: Sine, Frequency: 440, Amplitude: 0.25})
+}
+
+// Register registers a tone generator with the given label.
+func Register(label string, c Config) error {
+	return driver.GetManager().Register(
+		newTone(c), driver.Info{Label: label, DeviceType: driver.Microphone},
+	)
+}
+
+type tone struct {
+	config Config
+	closed <-chan struct{}
+	cancel func()
+}
+
+func newTone(c Config) *tone {
+	return &tone{config: c}
+}
+
+func (t *tone) Open() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.closed = ctx.Done()
+	t.cancel = cancel
+	return nil
+}
+
+func (t *tone) Close() error {
+	t.cancel()
+	return nil
+}
+
+func (t *tone) AudioRecord(p prop.Media) (audio.Reader, error) {
+	if p.SampleRate == 0 {
+		p.SampleRate = 48000
+	}
+
+	var generate func() float32
+	switch t.config.Waveform {
+	case WhiteNoise:
+		random := rand.New(rand.NewSource(0))
+		generate = func() float32 {
+			return (random.Float32()*2 - 1) * t.config.Amplitude
+		}
+	default:
+		var phase float64
+		step := 2 * math.Pi * t.config.Frequency / float64(p.SampleRate)
+		generate = func() float32 {
+			v := float32(math.Sin(phase)) * t.config.Amplitude
+			phase = math.Mod(phase+step, 2*math.Pi)
+			return v
+		}
+	}
+
+	nextReadTime := time.Now()
+
+	reader := audio.ReaderFunc(func(samples [][2]float32) (int, error) {
+		select {
+		case <-t.closed:
+			return 0, io.EOF
+		default:
+		}
+
+		time.Sleep(nextReadTime.Sub(time.Now()))
+		dur := time.Second * time.Duration(len(samples)) / time.Duration(p.SampleRate)
+		nextReadTime = nextReadTime.Add(dur)
+
+		for i := range samples {
+			v := generate()
+			samples[i][0] = v
+			if p.ChannelCount == 2 {
+				samples[i][1] = v
+			} else {
+				samples[i][1] = 0
+			}
+		}
+		return len(samples), nil
+	})
+	return reader, nil
+}
+
+func (t *tone) Properties() []prop.Media {
+	var props []prop.Media
+	for _, sampleRate := range []int{48000, 44100} {
+		for _, channels := range []int{1, 2} {
+			props = append(props, prop.Media{
+				Audio: prop.Audio{
+					SampleRate:   sampleRate,
+					Latency:      time.Millisecond * 20,
+					ChannelCount: channels,
+				},
+			})
+		}
+	}
+	return props
+}
@@ -0,0 +1,62 @@
+package audiotone
+
+import (
+	"testing"
+
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+func TestSineFrequency(t *testing.T) {
+	cases := map[string]struct {
+		frequency    float64
+		sampleRate   int
+		channelCount int
+	}{
+		"1kHz48kMono":    {1000, 48000, 1},
+		"1kHz48kStereo":  {1000, 48000, 2},
+		"440Hz44.1kMono": {440, 44100, 1},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			d := newTone(Config{Waveform: Sine, Frequency: c.frequency, Amplitude: 0.5})
+			if err := d.Open(); err != nil {
+				t.Fatal(err)
+			}
+			defer d.Close()
+
+			r, err := d.AudioRecord(prop.Media{
+				Audio: prop.Audio{SampleRate: c.sampleRate, ChannelCount: c.channelCount},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Read 100ms of the samples
+			samples := make([][2]float32, c.sampleRate/10)
+			if _, err := r.Read(samples); err != nil {
+				t.Fatal(err)
+			}
+
+			var crossings int
+			for i := 1; i < len(samples); i++ {
+				if (samples[i-1][0] < 0) != (samples[i][0] < 0) {
+					crossings++
+				}
+				if c.channelCount == 2 && samples[i][0] != samples[i][1] {
+					t.Fatalf("Expected same samples on both channels, got %v", samples[i])
+				}
+				if c.channelCount == 1 && samples[i][1] != 0 {
+					t.Fatalf("Expected silent second channel, got %v", samples[i])
+				}
+			}
+
+			// Sine wave crosses zero twice per cycle
+			expected := int(c.frequency * 2 / 10)
+			if crossings < expected-1 || expected+1 < crossings {
+				t.Errorf("Expected %d zero crossings, got %d", expected, crossings)
+			}
+		})
+	}
+}
@@ -9,7 +9,10 @@ import (
 	"image"
 	"io"
 	"io/ioutil"
+	"os"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/blackjack/webcam"
 	"github.com/pion/mediadevices/pkg/driver"
@@ -23,10 +26,14 @@ const (
 )
 
 var (
-	errReadTimeout = errors.New("read timeout")
-	errEmptyFrame  = errors.New("empty frame")
+	errReadTimeout    = errors.New("read timeout")
+	errEmptyFrame     = errors.New("empty frame")
+	errFormatMismatch = errors.New("frame format configured by another process doesn't match")
 )
 
+// framePool is shared by the cameras to reuse the buffers of the decoded frames.
+var framePool frame.Pool
+
 // Camera implementation using v4l2
 // Reference: https://linuxtv.org/downloads/v4l-dvb-apis/uapi/v4l/videodev.html#videodev
 type camera struct {
@@ -78,7 +85,7 @@ func newCamera(path string) *camera {
 func (c *camera) Open() error {
 	cam, err := webcam.Open(c.path)
 	if err != nil {
-		return err
+		return wrapBusy(err)
 	}
 
 	c.cam = cam
@@ -108,25 +115,35 @@ func (c *camera) Close() error {
 }
 
 func (c *camera) VideoRecord(p prop.Media) (video.Reader, error) {
-	decoder, err := frame.NewDecoder(p.FrameFormat)
+	decoder, err := frame.NewPooledDecoder(p.FrameFormat, &framePool)
 	if err != nil {
 		return nil, err
 	}
 
 	pf := c.reversedFormats[p.FrameFormat]
+	var shared bool
 	_, _, _, err = c.cam.SetImageFormat(pf, uint32(p.Width), uint32(p.Height))
 	if err != nil {
-		return nil, err
+		if !isBusy(err) {
+			return nil, err
+		}
+		// The format can't be changed while another process is streaming.
+		// Try to attach to the stream with the current format, which is
+		// permitted by some drivers. (e.g. v4l2loopback)
+		shared = true
 	}
 
 	if err := c.cam.StartStreaming(); err != nil {
-		return nil, err
+		return nil, wrapBusy(err)
 	}
+	frameSize, hasFrameSize := expectedFrameSize(p)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	c.cancel = cancel
 	var buf []byte
-	r := video.ReaderFunc(func() (img image.Image, err error) {
+	// captured is the time when the last frame read by readFrame became ready.
+	var captured time.Time
+	readFrame := func() ([]byte, error) {
 		// Lock to avoid accessing the buffer after StopStreaming()
 		c.mutex.Lock()
 		defer c.mutex.Unlock()
@@ -141,15 +158,25 @@ func (c *camera) VideoRecord(p prop.Media) (video.Reader, error) {
 			err := c.cam.WaitForFrame(5) // 5 seconds
 			switch err.(type) {
 			case nil:
+				captured = time.Now()
 			case *webcam.Timeout:
+				if c.isLost(err) {
+					return nil, &driver.DeviceLostError{Err: err}
+				}
 				return nil, errReadTimeout
 			default:
+				if c.isLost(err) {
+					return nil, &driver.DeviceLostError{Err: err}
+				}
 				// Camera has been stopped.
 				return nil, err
 			}
 
 			b, err := c.cam.ReadFrame()
 			if err != nil {
+				if c.isLost(err) {
+					return nil, &driver.DeviceLostError{Err: err}
+				}
 				// Camera has been stopped.
 				return nil, err
 			}
@@ -160,6 +187,10 @@ func (c *camera) VideoRecord(p prop.Media) (video.Reader, error) {
 				continue
 			}
 
+			if shared && hasFrameSize && len(b) != frameSize {
+				return nil, &driver.DeviceBusyError{Err: errFormatMismatch}
+			}
+
 			if len(b) > len(buf) {
 				// Grow the intermediate buffer
 				buf = make([]byte, len(b))
@@ -169,14 +200,87 @@ func (c *camera) VideoRecord(p prop.Media) (video.Reader, error) {
 			// from this reader will be Go safe. Otherwise, it's possible that outside of this reader
 			// that this memory is still being used even after we close it.
 			n := copy(buf, b)
-			return decoder.Decode(buf[:n], p.Width, p.Height)
+			return buf[:n], nil
 		}
 		return nil, errEmptyFrame
+	}
+
+	switch p.FrameFormat {
+	case frame.FormatMJPEG:
+		// JPEG images can be passed to the passthrough encoder without decoding.
+		return video.NewEncodedReader(readFrame, func(b []byte) (image.Image, error) {
+			return decoder.Decode(b, p.Width, p.Height)
+		}), nil
+	case frame.FormatI420:
+		// I420 frames can be passed to the encoders without decoding.
+		return video.RawReaderFunc(func() (video.RawFrame, error) {
+			b, err := readFrame()
+			if err != nil {
+				return video.RawFrame{}, err
+			}
+			f, err := video.NewI420Frame(b, p.Width, p.Height)
+			f.Timestamp = captured
+			return f, err
+		}), nil
+	}
+
+	// The decoders other than MJPEG output *image.YCbCr or *image.RGBA, which
+	// can be wrapped by RawFrame to keep the capture time.
+	r := video.RawReaderFunc(func() (video.RawFrame, error) {
+		b, err := readFrame()
+		if err != nil {
+			return video.RawFrame{}, err
+		}
+		img, err := decoder.Decode(b, p.Width, p.Height)
+		if err != nil {
+			return video.RawFrame{}, err
+		}
+		return video.NewRawFrame(img, captured)
 	})
 
 	return r, nil
 }
 
+// isLost returns true if err is caused by the device disconnection.
+func (c *camera) isLost(err error) bool {
+	if errno, ok := err.(syscall.Errno); ok {
+		switch errno {
+		case syscall.ENODEV, syscall.ENXIO:
+			return true
+		}
+	}
+	// Device file is removed when the camera is unplugged.
+	_, statErr := os.Stat(c.path)
+	return os.IsNotExist(statErr)
+}
+
+// isBusy returns true if err is caused by the device used by another process.
+func isBusy(err error) bool {
+	errno, ok := err.(syscall.Errno)
+	return ok && errno == syscall.EBUSY
+}
+
+// wrapBusy wraps err by DeviceBusyError if the device is used by another process.
+func wrapBusy(err error) error {
+	if isBusy(err) {
+		return &driver.DeviceBusyError{Err: err}
+	}
+	return err
+}
+
+// expectedFrameSize returns the size of the uncompressed frame in bytes.
+// false is returned if the size is not fixed.
+func expectedFrameSize(p prop.Media) (int, bool) {
+	switch p.FrameFormat {
+	case frame.FormatYUYV:
+		return p.Width * p.Height * 2, true
+	case frame.FormatNV21:
+		return p.Width * p.Height * 3 / 2, true
+	default:
+		return 0, false
+	}
+}
+
 func (c *camera) Properties() []prop.Media {
 	properties := make([]prop.Media, 0)
 	for format := range c.cam.GetSupportedFormats() {
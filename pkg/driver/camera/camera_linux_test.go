@@ -0,0 +1,47 @@
+package camera
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+func TestWrapBusy(t *testing.T) {
+	err := wrapBusy(syscall.EBUSY)
+	busyErr, ok := err.(*driver.DeviceBusyError)
+	if !ok {
+		t.Fatalf("Expected DeviceBusyError, got %v", err)
+	}
+	if busyErr.Err != syscall.EBUSY {
+		t.Errorf("Expected underlying error %v, got %v", syscall.EBUSY, busyErr.Err)
+	}
+
+	for _, err := range []error{syscall.ENODEV, errors.New("other")} {
+		if wrapped := wrapBusy(err); wrapped != err {
+			t.Errorf("Expected %v not to be wrapped, got %v", err, wrapped)
+		}
+	}
+}
+
+func TestExpectedFrameSize(t *testing.T) {
+	cases := map[frame.Format]struct {
+		size int
+		ok   bool
+	}{
+		frame.FormatYUYV:  {640 * 480 * 2, true},
+		frame.FormatNV21:  {640 * 480 * 3 / 2, true},
+		frame.FormatMJPEG: {0, false},
+	}
+	for format, c := range cases {
+		size, ok := expectedFrameSize(prop.Media{
+			Video: prop.Video{Width: 640, Height: 480, FrameFormat: format},
+		})
+		if size != c.size || ok != c.ok {
+			t.Errorf("%s: Expected (%d, %v), got (%d, %v)", format, c.size, c.ok, size, ok)
+		}
+	}
+}
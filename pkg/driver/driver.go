@@ -14,7 +14,11 @@ type AudioRecorder interface {
 	AudioRecord(p prop.Media) (r audio.Reader, err error)
 }
 
-// Priority represents device selection priority level
+// Priority represents device selection priority level. The priority is
+// compared only if the fitness distances of the device properties are equal,
+// so the device with the higher priority is selected among the devices fitting
+// equally, e.g. a hardware encoder device over a generic one, but never over
+// the device fitting better.
 type Priority float32
 
 const (
@@ -0,0 +1,50 @@
+package driver
+
+import (
+	"fmt"
+	"time"
+)
+
+// DeviceLostError tells the caller that the device is no longer available
+// while it's being used. (e.g. USB camera is unplugged)
+// Applications may re-enumerate the devices to switch to another device.
+type DeviceLostError struct {
+	// Err is the underlying error reported by the device
+	Err error
+}
+
+func (e *DeviceLostError) Error() string {
+	return fmt.Sprintf("device is lost: %v", e.Err)
+}
+
+// Unwrap returns the underlying error, e.g. to check syscall.ENODEV by errors.Is.
+func (e *DeviceLostError) Unwrap() error {
+	return e.Err
+}
+
+// DeviceBusyError tells the caller that the device is exclusively used by
+// another process or another driver instance and can't be opened.
+type DeviceBusyError struct {
+	// Err is the underlying error reported by the device
+	Err error
+}
+
+func (e *DeviceBusyError) Error() string {
+	return fmt.Sprintf("device is busy: %v", e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *DeviceBusyError) Unwrap() error {
+	return e.Err
+}
+
+// DeviceOpenTimeoutError tells the caller that the device didn't finish opening
+// within the timeout. The device may be stuck.
+type DeviceOpenTimeoutError struct {
+	// Timeout is the duration waited for the device
+	Timeout time.Duration
+}
+
+func (e *DeviceOpenTimeoutError) Error() string {
+	return fmt.Sprintf("device open timed out after %v", e.Timeout)
+}
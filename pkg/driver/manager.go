@@ -1,5 +1,9 @@
 package driver
 
+import (
+	"strings"
+)
+
 // FilterFn is being used to decide if a driver should be included in the
 // query result.
 type FilterFn func(Driver) bool
@@ -27,6 +31,20 @@ func FilterID(id string) FilterFn {
 	}
 }
 
+// FilterLabel returns a filter function to get registered drivers which have exactly given label
+func FilterLabel(label string) FilterFn {
+	return func(d Driver) bool {
+		return d.Info().Label == label
+	}
+}
+
+// FilterLabelContains returns a filter function to get registered drivers whose label contains substr
+func FilterLabelContains(substr string) FilterFn {
+	return func(d Driver) bool {
+		return strings.Contains(d.Info().Label, substr)
+	}
+}
+
 // FilterDeviceType returns a filter function to get registered drivers which matches t type
 func FilterDeviceType(t DeviceType) FilterFn {
 	return func(d Driver) bool {
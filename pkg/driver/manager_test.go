@@ -40,3 +40,19 @@ func TestFilterAnd(t *testing.T) {
 		t.Error("FilterAnd(filterTrue, filterTrue, filterTrue)() must be true")
 	}
 }
+
+func TestFilterLabel(t *testing.T) {
+	d := &adapterWrapper{info: Info{Label: "HD Webcam"}}
+	if FilterLabel("HD Webcam")(d) != true {
+		t.Error("FilterLabel(\"HD Webcam\")() must be true")
+	}
+	if FilterLabel("Webcam")(d) != false {
+		t.Error("FilterLabel(\"Webcam\")() must be false")
+	}
+	if FilterLabelContains("Webcam")(d) != true {
+		t.Error("FilterLabelContains(\"Webcam\")() must be true")
+	}
+	if FilterLabelContains("Microphone")(d) != false {
+		t.Error("FilterLabelContains(\"Microphone\")() must be false")
+	}
+}
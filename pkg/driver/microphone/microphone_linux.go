@@ -13,7 +13,14 @@ import (
 type microphone struct {
 	c           *pulse.Client
 	id          string
-	samplesChan chan<- []float32
+	samplesChan chan<- buffer
+}
+
+// buffer is the samples received from the device and the capture time of the
+// first sample.
+type buffer struct {
+	samples   []float32
+	timestamp time.Time
 }
 
 func init() {
@@ -84,13 +91,20 @@ func (m *microphone) AudioRecord(p prop.Media) (audio.Reader, error) {
 		pulse.RecordSource(src),
 	)
 
-	samplesChan := make(chan []float32, 1)
-	var buff []float32
+	channels := 1
+	if p.ChannelCount == 2 {
+		channels = 2
+	}
+	samplesChan := make(chan buffer, 1)
+	var buff buffer
 	var bi int
 	var more bool
 
 	handler := func(b []float32) {
-		samplesChan <- b
+		// The buffer is received when the last sample is captured, so the
+		// timestamps follow the clock of the device.
+		d := time.Duration(len(b)/channels) * time.Second / time.Duration(p.SampleRate)
+		samplesChan <- buffer{samples: b, timestamp: time.Now().Add(-d)}
 	}
 
 	stream, err := m.c.NewRecord(handler, options...)
@@ -98,28 +112,32 @@ func (m *microphone) AudioRecord(p prop.Media) (audio.Reader, error) {
 		return nil, err
 	}
 
-	reader := audio.ReaderFunc(func(samples [][2]float32) (n int, err error) {
+	reader := audio.TimestampReaderFunc(func(samples [][2]float32) (n int, timestamp time.Time, err error) {
 		for i := range samples {
 			// if we don't have anything left in buff, we'll wait until we receive
 			// more samples
-			if bi == len(buff) {
+			if bi == len(buff.samples) {
 				buff, more = <-samplesChan
 				if !more {
 					stream.Close()
-					return i, io.EOF
+					return i, timestamp, io.EOF
 				}
 				bi = 0
 			}
+			if i == 0 {
+				offset := time.Duration(bi/channels) * time.Second / time.Duration(p.SampleRate)
+				timestamp = buff.timestamp.Add(offset)
+			}
 
-			samples[i][0] = buff[bi]
+			samples[i][0] = buff.samples[bi]
 			if p.ChannelCount == 2 {
-				samples[i][1] = buff[bi+1]
+				samples[i][1] = buff.samples[bi+1]
 				bi++
 			}
 			bi++
 		}
 
-		return len(samples), nil
+		return len(samples), timestamp, nil
 	})
 
 	stream.Start()
@@ -1,8 +1,10 @@
 package screen
 
 import (
+	"context"
 	"fmt"
 	"image"
+	"io"
 	"time"
 
 	"github.com/pion/mediadevices/pkg/driver"
@@ -15,6 +17,8 @@ type screen struct {
 	num    int
 	reader *reader
 	tick   *time.Ticker
+	closed <-chan struct{}
+	cancel func()
 }
 
 func deviceID(num int) string {
@@ -48,10 +52,14 @@ func (s *screen) Open() error {
 		return err
 	}
 	s.reader = r
+	ctx, cancel := context.WithCancel(context.Background())
+	s.closed = ctx.Done()
+	s.cancel = cancel
 	return nil
 }
 
 func (s *screen) Close() error {
+	s.cancel()
 	s.reader.Close()
 	if s.tick != nil {
 		s.tick.Stop()
@@ -68,7 +76,11 @@ func (s *screen) VideoRecord(p prop.Media) (video.Reader, error) {
 	var dst image.RGBA
 
 	r := video.ReaderFunc(func() (image.Image, error) {
-		<-s.tick.C
+		select {
+		case <-s.closed:
+			return nil, io.EOF
+		case <-s.tick.C:
+		}
 		return s.reader.Read().ToRGBA(&dst), nil
 	})
 	return r, nil
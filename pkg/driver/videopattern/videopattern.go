@@ -0,0 +1,166 @@
+// Package videopattern provides a synthetic test pattern video driver.
+// It's useful to make encoder and transport tests reproducible.
+package videopattern
+
+import (
+	"context"
+	"image"
+	"image/draw"
+	"io"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+// Pattern represents the type of the generated image
+type Pattern int
+
+// Pattern definitions.
+const (
+	// PatternBars generates SMPTE like color bars
+	PatternBars Pattern = iota
+	// PatternGradient generates horizontally moving gray gradation
+	PatternGradient
+)
+
+// Config represents the video to be generated
+type Config struct {
+	Pattern Pattern
+	// Counter overlays the frame counter on the top-left of the image.
+	// The counter can be read by DecodeCounter.
+	Counter bool
+}
+
+func init() {
+	Register("VideoPattern", Config{Pattern: PatternBars, Counter: true})
+}
+
+// Register registers a pattern generator with the given label.
+func Register(label string, c Config) error {
+	return driver.GetManager().Register(
+		newPattern(c), driver.Info{Label: label, DeviceType: driver.Camera},
+	)
+}
+
+type pattern struct {
+	config Config
+	closed <-chan struct{}
+	cancel func()
+}
+
+func newPattern(c Config) *pattern {
+	return &pattern{config: c}
+}
+
+func (d *pattern) Open() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.closed = ctx.Done()
+	d.cancel = cancel
+	return nil
+}
+
+func (d *pattern) Close() error {
+	d.cancel()
+	return nil
+}
+
+func (d *pattern) VideoRecord(p prop.Media) (video.Reader, error) {
+	if p.FrameRate == 0 {
+		p.FrameRate = 30
+	}
+
+	colors := [][3]byte{
+		{235, 128, 128},
+		{210, 16, 146},
+		{170, 166, 16},
+		{145, 54, 34},
+		{107, 202, 222},
+		{82, 90, 240},
+		{41, 240, 110},
+	}
+
+	rect := image.Rect(0, 0, p.Width, p.Height)
+	yuv := image.NewYCbCr(rect, image.YCbCrSubsampleRatio420)
+	var rgba *image.RGBA
+	if p.FrameFormat == frame.FormatRGBA {
+		rgba = image.NewRGBA(rect)
+	}
+
+	var cnt uint32
+	// The ticker is owned by the reader, and stopped when the driver is closed.
+	tick := time.NewTicker(time.Duration(float32(time.Second) / p.FrameRate))
+	closed := d.closed
+	go func() {
+		<-closed
+		tick.Stop()
+	}()
+
+	// The frames are stamped with the ticks, which are the capture times of
+	// the emulated camera.
+	r := video.RawReaderFunc(func() (video.RawFrame, error) {
+		var captured time.Time
+		select {
+		case <-closed:
+			return video.RawFrame{}, io.EOF
+		case captured = <-tick.C:
+		}
+
+		for y := 0; y < p.Height; y++ {
+			for x := 0; x < p.Width; x++ {
+				yi := yuv.YOffset(x, y)
+				ci := yuv.COffset(x, y)
+				switch d.config.Pattern {
+				case PatternGradient:
+					yuv.Y[yi] = uint8((x + int(cnt)) % p.Width * 255 / p.Width)
+					yuv.Cb[ci] = 128
+					yuv.Cr[ci] = 128
+				default:
+					c := colors[x*len(colors)/p.Width]
+					yuv.Y[yi] = c[0]
+					yuv.Cb[ci] = c[1]
+					yuv.Cr[ci] = c[2]
+				}
+			}
+		}
+
+		if d.config.Counter {
+			video.DrawSequence(yuv, cnt)
+		}
+		cnt++
+
+		if rgba != nil {
+			draw.Draw(rgba, rect, yuv, image.Point{}, draw.Src)
+			return video.NewRawFrame(rgba, captured)
+		}
+		return video.NewRawFrame(yuv, captured)
+	})
+
+	return r, nil
+}
+
+// DecodeCounter reads the frame counter overlaid by the driver with Config.Counter.
+// Since the counter is drawn as large black and white blocks, it can be read
+// even after lossy encoding and decoding. The counter is drawn by
+// video.DrawSequence, so the frames can be validated by video.SequenceChecker.
+func DecodeCounter(img image.Image) uint32 {
+	return video.DecodeSequence(img)
+}
+
+func (d *pattern) Properties() []prop.Media {
+	var props []prop.Media
+	for _, size := range [][2]int{{640, 480}, {1280, 720}} {
+		for _, format := range []frame.Format{frame.FormatI420, frame.FormatRGBA} {
+			props = append(props, prop.Media{
+				Video: prop.Video{
+					Width:       size[0],
+					Height:      size[1],
+					FrameFormat: format,
+				},
+			})
+		}
+	}
+	return props
+}
@@ -0,0 +1,120 @@
+package videopattern
+
+import (
+	"image"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+func TestCounter(t *testing.T) {
+	cases := map[string]struct {
+		pattern Pattern
+		format  frame.Format
+	}{
+		"BarsI420":     {PatternBars, frame.FormatI420},
+		"GradientI420": {PatternGradient, frame.FormatI420},
+		"BarsRGBA":     {PatternBars, frame.FormatRGBA},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			d := newPattern(Config{Pattern: c.pattern, Counter: true})
+			if err := d.Open(); err != nil {
+				t.Fatal(err)
+			}
+			defer d.Close()
+
+			r, err := d.VideoRecord(prop.Media{
+				Video: prop.Video{Width: 320, Height: 240, FrameRate: 1000, FrameFormat: c.format},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 10; i++ {
+				img, err := r.Read()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, ok := img.(*image.RGBA); ok != (c.format == frame.FormatRGBA) {
+					t.Fatalf("Unexpected image type %T for %s", img, c.format)
+				}
+				if cnt := DecodeCounter(img); cnt != uint32(i) {
+					t.Errorf("Expected frame counter %d, got %d", i, cnt)
+				}
+			}
+		})
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	d := newPattern(Config{})
+	if err := d.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	r, err := d.VideoRecord(prop.Media{
+		Video: prop.Video{Width: 320, Height: 240, FrameRate: 100, FrameFormat: frame.FormatI420},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr, ok := r.(video.RawReader)
+	if !ok {
+		t.Fatal("Expected RawReader")
+	}
+
+	var last time.Time
+	for i := 0; i < 5; i++ {
+		f, err := rr.ReadRaw()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !f.Timestamp.After(last) || f.Timestamp.After(time.Now()) {
+			t.Errorf("Expected increasing capture time in the past, got %v after %v", f.Timestamp, last)
+		}
+		last = f.Timestamp
+	}
+}
+
+func TestCloseDuringRead(t *testing.T) {
+	d := newPattern(Config{})
+	if err := d.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The readers of all the VideoRecord calls end on Close
+	done := make(chan error)
+	for i := 0; i < 2; i++ {
+		r, err := d.VideoRecord(prop.Media{
+			Video: prop.Video{Width: 64, Height: 48, FrameRate: 0.1, FrameFormat: frame.FormatI420},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			_, err := r.Read()
+			done <- err
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	d.Close()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-done:
+			if err != io.EOF {
+				t.Errorf("Expected %v, got %v", io.EOF, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Read is blocked after Close")
+		}
+	}
+}
@@ -24,7 +24,6 @@ func init() {
 type dummy struct {
 	closed <-chan struct{}
 	cancel func()
-	tick   *time.Ticker
 }
 
 func newVideoTest() *dummy {
@@ -40,9 +39,6 @@ func (d *dummy) Open() error {
 
 func (d *dummy) Close() error {
 	d.cancel()
-	if d.tick != nil {
-		d.tick.Stop()
-	}
 	return nil
 }
 
@@ -99,17 +95,22 @@ func (d *dummy) VideoRecord(p prop.Media) (video.Reader, error) {
 	}
 	random := rand.New(rand.NewSource(0))
 
-	d.tick = time.NewTicker(time.Duration(float32(time.Second) / p.FrameRate))
+	// The ticker is owned by the reader, and stopped when the driver is closed.
+	tick := time.NewTicker(time.Duration(float32(time.Second) / p.FrameRate))
+	closed := d.closed
+	go func() {
+		<-closed
+		tick.Stop()
+	}()
 
-	r := video.ReaderFunc(func() (image.Image, error) {
+	r := video.RawReaderFunc(func() (video.RawFrame, error) {
+		var captured time.Time
 		select {
-		case <-d.closed:
-			return nil, io.EOF
-		default:
+		case <-closed:
+			return video.RawFrame{}, io.EOF
+		case captured = <-tick.C:
 		}
 
-		<-d.tick.C
-
 		copy(yy, yyBase)
 		copy(cb, cbBase)
 		copy(cr, crBase)
@@ -120,7 +121,7 @@ func (d *dummy) VideoRecord(p prop.Media) (video.Reader, error) {
 				yy[yi+x] = uint8(random.Int31n(2) * 255)
 			}
 		}
-		return &image.YCbCr{
+		return video.NewRawFrame(&image.YCbCr{
 			Y:              yy,
 			YStride:        p.Width,
 			Cb:             cb,
@@ -128,7 +129,7 @@ func (d *dummy) VideoRecord(p prop.Media) (video.Reader, error) {
 			CStride:        p.Width / 2,
 			SubsampleRatio: image.YCbCrSubsampleRatio422,
 			Rect:           image.Rect(0, 0, p.Width, p.Height),
-		}, nil
+		}, captured)
 	})
 
 	return r, nil
@@ -0,0 +1,73 @@
+package videotest
+
+import (
+	"image"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+func TestFrameLayout(t *testing.T) {
+	d := newVideoTest()
+	if err := d.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	r, err := d.VideoRecord(prop.Media{
+		Video: prop.Video{Width: 64, Height: 48, FrameRate: 100},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := img.(*image.YCbCr); !ok {
+		t.Fatalf("Expected *image.YCbCr, got %T", img)
+	}
+	l, err := video.FrameInfo(img)
+	if err != nil {
+		t.Fatalf("Failed to get frame info: %v", err)
+	}
+	expected := video.Layout{Format: frame.FormatI422, Strides: [3]int{64, 32, 32}}
+	if l != expected {
+		t.Errorf("Expected %+v, got %+v", expected, l)
+	}
+}
+
+func TestCloseDuringRead(t *testing.T) {
+	d := newVideoTest()
+	if err := d.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := d.VideoRecord(prop.Media{
+		Video: prop.Video{Width: 64, Height: 48, FrameRate: 0.1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := r.Read()
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	d.Close()
+
+	select {
+	case err := <-done:
+		if err != io.EOF {
+			t.Errorf("Expected %v, got %v", io.EOF, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Read is blocked after Close")
+	}
+}
@@ -0,0 +1,129 @@
+package wavfile
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
+)
+
+var (
+	errNotWAV       = errors.New("not a WAV file")
+	errNoFormat     = errors.New("fmt chunk is not found before data chunk")
+	errNoData       = errors.New("data chunk is not found")
+	errEmptySamples = errors.New("no samples in the file")
+)
+
+// wav is a decoded WAV file.
+type wav struct {
+	sampleRate int
+	channels   int
+	// samples are the interleaved samples of all channels
+	samples []float32
+}
+
+func (w *wav) frames() int {
+	return len(w.samples) / w.channels
+}
+
+// decodeWAV decodes RIFF WAVE file b.
+func decodeWAV(b []byte) (*wav, error) {
+	if len(b) < 12 || string(b[0:4]) != "RIFF" || string(b[8:12]) != "WAVE" {
+		return nil, errNotWAV
+	}
+
+	var w *wav
+	var format, bits int
+	b = b[12:]
+	for len(b) >= 8 {
+		id := string(b[0:4])
+		size := int(binary.LittleEndian.Uint32(b[4:8]))
+		b = b[8:]
+		if size > len(b) {
+			// Some writers don't update the size of the streamed data
+			size = len(b)
+		}
+		chunk := b[:size]
+		// Chunks are aligned to 2 bytes
+		if size%2 == 1 && size < len(b) {
+			size++
+		}
+		b = b[size:]
+
+		switch id {
+		case "fmt ":
+			if len(chunk) < 16 {
+				return nil, fmt.Errorf("fmt chunk is too short: %d bytes", len(chunk))
+			}
+			format = int(binary.LittleEndian.Uint16(chunk[0:2]))
+			if format == wavFormatExtensible && len(chunk) >= 26 {
+				// The first 2 bytes of SubFormat GUID is the format
+				format = int(binary.LittleEndian.Uint16(chunk[24:26]))
+			}
+			w = &wav{
+				channels:   int(binary.LittleEndian.Uint16(chunk[2:4])),
+				sampleRate: int(binary.LittleEndian.Uint32(chunk[4:8])),
+			}
+			bits = int(binary.LittleEndian.Uint16(chunk[14:16]))
+			if w.channels == 0 || w.sampleRate == 0 {
+				return nil, fmt.Errorf("invalid format: %d channels at %d Hz", w.channels, w.sampleRate)
+			}
+		case "data":
+			if w == nil {
+				return nil, errNoFormat
+			}
+			decode, err := sampleDecoder(format, bits)
+			if err != nil {
+				return nil, err
+			}
+			size := bits / 8
+			frameSize := size * w.channels
+			n := len(chunk) / frameSize * w.channels
+			if n == 0 {
+				return nil, errEmptySamples
+			}
+			w.samples = make([]float32, n)
+			for i := range w.samples {
+				w.samples[i] = decode(chunk[i*size:])
+			}
+			return w, nil
+		}
+	}
+	return nil, errNoData
+}
+
+// sampleDecoder returns the function decoding a sample to [-1, 1].
+func sampleDecoder(format, bits int) (func([]byte) float32, error) {
+	switch {
+	case format == wavFormatPCM && bits == 8:
+		// 8-bit samples are unsigned
+		return func(b []byte) float32 {
+			return float32(int(b[0])-128) / 128
+		}, nil
+	case format == wavFormatPCM && bits == 16:
+		return func(b []byte) float32 {
+			return float32(int16(binary.LittleEndian.Uint16(b))) / 32768
+		}, nil
+	case format == wavFormatPCM && bits == 24:
+		return func(b []byte) float32 {
+			v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+			return float32(v) / 8388608
+		}, nil
+	case format == wavFormatPCM && bits == 32:
+		return func(b []byte) float32 {
+			return float32(float64(int32(binary.LittleEndian.Uint32(b))) / 2147483648)
+		}, nil
+	case format == wavFormatFloat && bits == 32:
+		return func(b []byte) float32 {
+			return math.Float32frombits(binary.LittleEndian.Uint32(b))
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported sample format %d with %d bits", format, bits)
+	}
+}
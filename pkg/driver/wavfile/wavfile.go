@@ -0,0 +1,170 @@
+// Package wavfile provides an audio driver reading a WAV file.
+// It's useful for reproducible tests and for playing canned prompts.
+package wavfile
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+// Config represents the file to be played
+type Config struct {
+	// Path of the WAV file. 8, 16, 24 and 32-bit integer PCM and 32-bit float
+	// samples are supported.
+	Path string
+	// Loop plays the file from the beginning again at the end. Otherwise, the
+	// reader returns io.EOF at the end of the file.
+	Loop bool
+}
+
+// Register registers a WAV file player with the given label.
+func Register(label string, c Config) error {
+	return driver.GetManager().Register(
+		newWAVFile(c), driver.Info{Label: label, DeviceType: driver.Microphone},
+	)
+}
+
+type wavFile struct {
+	config Config
+	closed <-chan struct{}
+	cancel func()
+	// wav is decoded when the driver is opened
+	wav *wav
+}
+
+func newWAVFile(c Config) *wavFile {
+	return &wavFile{config: c}
+}
+
+func (f *wavFile) Open() error {
+	b, err := ioutil.ReadFile(f.config.Path)
+	if err != nil {
+		return err
+	}
+	w, err := decodeWAV(b)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f.closed = ctx.Done()
+	f.cancel = cancel
+	f.wav = w
+	return nil
+}
+
+func (f *wavFile) Close() error {
+	f.cancel()
+	return nil
+}
+
+// AudioRecord plays the file in real time. The samples are resampled to the
+// requested sample rate by linear interpolation. Stereo files are mixed down
+// if mono is requested, and the channels after the second one are dropped.
+func (f *wavFile) AudioRecord(p prop.Media) (audio.Reader, error) {
+	w := f.wav
+	if p.SampleRate == 0 {
+		p.SampleRate = w.sampleRate
+	}
+	if p.ChannelCount == 0 && w.channels == 1 {
+		p.ChannelCount = 1
+	}
+
+	frames := w.frames()
+	step := float64(w.sampleRate) / float64(p.SampleRate)
+	var pos float64
+
+	// frame returns the first 2 channels of i-th frame
+	frame := func(i int) (float32, float32) {
+		if i >= frames {
+			if f.config.Loop {
+				i -= frames
+			} else {
+				i = frames - 1
+			}
+		}
+		l := w.samples[i*w.channels]
+		if w.channels == 1 {
+			return l, l
+		}
+		return l, w.samples[i*w.channels+1]
+	}
+
+	nextReadTime := time.Now()
+
+	reader := audio.ReaderFunc(func(samples [][2]float32) (int, error) {
+		select {
+		case <-f.closed:
+			return 0, io.EOF
+		default:
+		}
+
+		if !f.config.Loop && pos >= float64(frames) {
+			return 0, io.EOF
+		}
+
+		time.Sleep(nextReadTime.Sub(time.Now()))
+
+		var n int
+		for ; n < len(samples); n++ {
+			if !f.config.Loop && pos >= float64(frames) {
+				break
+			}
+
+			i := int(pos)
+			frac := float32(pos - float64(i))
+			l0, r0 := frame(i)
+			l1, r1 := frame(i + 1)
+			l, r := l0+(l1-l0)*frac, r0+(r1-r0)*frac
+
+			if p.ChannelCount == 1 {
+				samples[n] = [2]float32{(l + r) / 2, 0}
+			} else {
+				samples[n] = [2]float32{l, r}
+			}
+
+			pos += step
+			if f.config.Loop && pos >= float64(frames) {
+				pos -= float64(frames)
+			}
+		}
+
+		dur := time.Second * time.Duration(n) / time.Duration(p.SampleRate)
+		nextReadTime = nextReadTime.Add(dur)
+		return n, nil
+	})
+	return reader, nil
+}
+
+func (f *wavFile) Properties() []prop.Media {
+	// The format of the file is preferred, and the others are converted
+	sampleRate, channels := f.wav.sampleRate, f.wav.channels
+	if channels > 2 {
+		channels = 2
+	}
+	props := []prop.Media{f.properties(sampleRate, channels)}
+	for _, r := range []int{48000, 44100} {
+		for _, c := range []int{1, 2} {
+			if r != sampleRate || c != channels {
+				props = append(props, f.properties(r, c))
+			}
+		}
+	}
+	return props
+}
+
+func (f *wavFile) properties(sampleRate, channels int) prop.Media {
+	return prop.Media{
+		Audio: prop.Audio{
+			SampleRate:   sampleRate,
+			Latency:      time.Millisecond * 20,
+			ChannelCount: channels,
+		},
+	}
+}
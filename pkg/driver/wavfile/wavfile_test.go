@@ -0,0 +1,206 @@
+package wavfile
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/prop"
+)
+
+// encodeWAV encodes 16-bit PCM samples as a WAV file.
+func encodeWAV(sampleRate, channels int, samples []int16) []byte {
+	data := make([]byte, 2*len(samples))
+	for i, v := range samples {
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(v))
+	}
+
+	b := []byte("RIFF\x00\x00\x00\x00WAVEfmt ")
+	b = append(b, 16, 0, 0, 0)
+	fmtChunk := make([]byte, 16)
+	binary.LittleEndian.PutUint16(fmtChunk[0:], wavFormatPCM)
+	binary.LittleEndian.PutUint16(fmtChunk[2:], uint16(channels))
+	binary.LittleEndian.PutUint32(fmtChunk[4:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(fmtChunk[8:], uint32(sampleRate*channels*2))
+	binary.LittleEndian.PutUint16(fmtChunk[12:], uint16(channels*2))
+	binary.LittleEndian.PutUint16(fmtChunk[14:], 16)
+	b = append(b, fmtChunk...)
+	b = append(b, []byte("data")...)
+	size := make([]byte, 4)
+	binary.LittleEndian.PutUint32(size, uint32(len(data)))
+	b = append(b, size...)
+	b = append(b, data...)
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b)-8))
+	return b
+}
+
+// openWAV opens the driver playing b. The returned function closes the driver.
+func openWAV(t *testing.T, c Config, b []byte) (*wavFile, func()) {
+	dir, err := ioutil.TempDir("", "TestWAVFile")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Path = filepath.Join(dir, "test.wav")
+	if err := ioutil.WriteFile(c.Path, b, 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	d := newWAVFile(c)
+	if err := d.Open(); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return d, func() {
+		d.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestWAVFile(t *testing.T) {
+	const sampleRate = 8000
+	// 100ms of 1kHz square wave
+	samples := make([]int16, sampleRate/10)
+	for i := range samples {
+		if i%8 < 4 {
+			samples[i] = 16384
+		} else {
+			samples[i] = -16384
+		}
+	}
+	b := encodeWAV(sampleRate, 1, samples)
+
+	t.Run("Native", func(t *testing.T) {
+		d, closeWAV := openWAV(t, Config{}, b)
+		defer closeWAV()
+		p := d.Properties()[0]
+		if p.SampleRate != sampleRate || p.ChannelCount != 1 {
+			t.Fatalf("Expected the format of the file to be preferred, got %+v", p.Audio)
+		}
+		r, err := d.AudioRecord(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf := make([][2]float32, 2*len(samples))
+		n, err := r.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(samples) {
+			t.Fatalf("Expected %d samples, got %d", len(samples), n)
+		}
+		if dur := time.Second * time.Duration(n) / sampleRate; dur != 100*time.Millisecond {
+			t.Errorf("Expected duration of 100ms, got %v", dur)
+		}
+		for i := 0; i < n; i++ {
+			expected := float32(samples[i]) / 32768
+			if buf[i] != [2]float32{expected, 0} {
+				t.Fatalf("Expected sample %d to be %v, got %v", i, expected, buf[i])
+			}
+		}
+		if _, err := r.Read(buf); err != io.EOF {
+			t.Errorf("Expected io.EOF at the end of the file, got %v", err)
+		}
+	})
+	t.Run("Resample", func(t *testing.T) {
+		d, closeWAV := openWAV(t, Config{}, b)
+		defer closeWAV()
+		r, err := d.AudioRecord(prop.Media{
+			Audio: prop.Audio{SampleRate: 2 * sampleRate, ChannelCount: 2},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf := make([][2]float32, 4*len(samples))
+		n, err := r.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 2*len(samples) {
+			t.Fatalf("Expected %d samples, got %d", 2*len(samples), n)
+		}
+		for _, c := range []struct {
+			i        int
+			expected float32
+		}{
+			{0, 0.5},
+			{6, 0.5},
+			// Interpolated between the edges of the square wave
+			{7, 0},
+			{8, -0.5},
+		} {
+			if buf[c.i] != [2]float32{c.expected, c.expected} {
+				t.Errorf("Expected sample %d to be %v on both channels, got %v", c.i, c.expected, buf[c.i])
+			}
+		}
+	})
+	t.Run("Loop", func(t *testing.T) {
+		d, closeWAV := openWAV(t, Config{Loop: true}, b)
+		defer closeWAV()
+		r, err := d.AudioRecord(prop.Media{
+			Audio: prop.Audio{SampleRate: sampleRate, ChannelCount: 1},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf := make([][2]float32, 2*len(samples)+1)
+		n, err := r.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(buf) {
+			t.Fatalf("Expected %d samples, got %d", len(buf), n)
+		}
+		for i := 0; i < n; i++ {
+			if buf[i] != buf[i%len(samples)] {
+				t.Fatalf("Expected sample %d to be looped, got %v", i, buf[i])
+			}
+		}
+	})
+}
+
+func TestDecodeWAV(t *testing.T) {
+	t.Run("Float", func(t *testing.T) {
+		b := encodeWAV(48000, 2, make([]int16, 4))
+		binary.LittleEndian.PutUint16(b[20:], wavFormatFloat)
+		binary.LittleEndian.PutUint16(b[34:], 32)
+		binary.LittleEndian.PutUint32(b[44:], math.Float32bits(0.25))
+		w, err := decodeWAV(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w.channels != 2 || w.sampleRate != 48000 || w.frames() != 1 || w.samples[0] != 0.25 {
+			t.Errorf("Unexpected WAV: %+v", w)
+		}
+	})
+	t.Run("24bit", func(t *testing.T) {
+		b := encodeWAV(48000, 1, make([]int16, 3))
+		binary.LittleEndian.PutUint16(b[34:], 24)
+		copy(b[44:], []byte{0x00, 0x00, 0xC0, 0xFF, 0xFF, 0x3F})
+		w, err := decodeWAV(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w.frames() != 2 || w.samples[0] != -0.5 || w.samples[1] != float32(0x3FFFFF)/8388608 {
+			t.Errorf("Unexpected samples: %v", w.samples)
+		}
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := decodeWAV([]byte("RIFF\x00\x00\x00\x00AVI ")); err != errNotWAV {
+			t.Errorf("Expected %v, got %v", errNotWAV, err)
+		}
+		b := encodeWAV(48000, 1, make([]int16, 2))
+		binary.LittleEndian.PutUint16(b[34:], 12)
+		if _, err := decodeWAV(b); err == nil {
+			t.Error("Expected an error on unsupported sample format")
+		}
+	})
+}
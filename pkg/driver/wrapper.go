@@ -1,6 +1,8 @@
 package driver
 
 import (
+	"sync"
+
 	"github.com/pion/mediadevices/pkg/io/audio"
 	"github.com/pion/mediadevices/pkg/io/video"
 	"github.com/pion/mediadevices/pkg/prop"
@@ -41,8 +43,12 @@ type adapterWrapper struct {
 	Adapter
 	VideoRecorder
 	AudioRecorder
-	id    string
-	info  Info
+	id   string
+	info Info
+
+	// state is protected by mu. mu is not held while the adapter is being
+	// operated, so that the state can be read while Open is blocked.
+	mu    sync.Mutex
 	state State
 }
 
@@ -55,19 +61,34 @@ func (w *adapterWrapper) Info() Info {
 }
 
 func (w *adapterWrapper) Status() State {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	return w.state
 }
 
+// update updates the state by State.Update without holding the lock during f.
+func (w *adapterWrapper) update(next State, f func() error) error {
+	state := w.Status()
+	if err := state.Update(next, f); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.state = state
+	w.mu.Unlock()
+	return nil
+}
+
 func (w *adapterWrapper) Open() error {
-	return w.state.Update(StateOpened, w.Adapter.Open)
+	return w.update(StateOpened, w.Adapter.Open)
 }
 
 func (w *adapterWrapper) Close() error {
-	return w.state.Update(StateClosed, w.Adapter.Close)
+	return w.update(StateClosed, w.Adapter.Close)
 }
 
 func (w *adapterWrapper) Properties() []prop.Media {
-	if w.state == StateClosed {
+	if w.Status() == StateClosed {
 		return nil
 	}
 
@@ -75,7 +96,7 @@ func (w *adapterWrapper) Properties() []prop.Media {
 }
 
 func (w *adapterWrapper) VideoRecord(p prop.Media) (r video.Reader, err error) {
-	err = w.state.Update(StateRunning, func() error {
+	err = w.update(StateRunning, func() error {
 		r, err = w.VideoRecorder.VideoRecord(p)
 		return err
 	})
@@ -83,7 +104,7 @@ func (w *adapterWrapper) VideoRecord(p prop.Media) (r video.Reader, err error) {
 }
 
 func (w *adapterWrapper) AudioRecord(p prop.Media) (r audio.Reader, err error) {
-	err = w.state.Update(StateRunning, func() error {
+	err = w.update(StateRunning, func() error {
 		r, err = w.AudioRecorder.AudioRecord(p)
 		return err
 	})
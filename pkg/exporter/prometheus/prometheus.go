@@ -0,0 +1,131 @@
+// Package prometheus exports the statistics of the tracks as Prometheus
+// metrics. It's a separate package to keep the core of mediadevices free from
+// the dependency on the Prometheus client.
+//
+// The metrics are collected from Stats of the track on every scrape and are
+// labeled by the ID of the LocalTrack and the kind of the track.
+package prometheus
+
+import (
+	"errors"
+
+	"github.com/pion/mediadevices"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Namespace is the namespace of the exported metrics.
+const Namespace = "mediadevices"
+
+var errStatsUnsupported = errors.New("prometheus: the track doesn't report the statistics")
+
+// Register registers the collector of the statistics of the track with reg.
+// The track must be a mediadevices.VideoTracker or a mediadevices.AudioTracker,
+// e.g. the tracks returned by GetUserMedia. The returned collector can be passed
+// to Unregister of reg to remove the metrics after the track is stopped.
+//
+// Video tracks export:
+//
+//	mediadevices_video_bitrate_bits_per_second
+//	mediadevices_video_frames_per_second
+//	mediadevices_video_dropped_frames_total
+//	mediadevices_video_encode_latency_seconds
+//	mediadevices_video_queued_frames
+//	mediadevices_video_queued_bytes
+//
+// Audio tracks export:
+//
+//	mediadevices_audio_concealed_samples_total
+//	mediadevices_audio_concealment_events_total
+func Register(reg prometheus.Registerer, track mediadevices.Tracker) (prometheus.Collector, error) {
+	var c prometheus.Collector
+	labels := prometheus.Labels{"track": track.LocalTrack().ID(), "kind": track.Kind()}
+	switch t := track.(type) {
+	case mediadevices.VideoTracker:
+		c = newVideoCollector(t, labels)
+	case mediadevices.AudioTracker:
+		c = newAudioCollector(t, labels)
+	default:
+		return nil, errStatsUnsupported
+	}
+
+	if err := reg.Register(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+type videoCollector struct {
+	track mediadevices.VideoTracker
+
+	bitRate       *prometheus.Desc
+	frameRate     *prometheus.Desc
+	droppedFrames *prometheus.Desc
+	encodeLatency *prometheus.Desc
+	queuedFrames  *prometheus.Desc
+	queuedBytes   *prometheus.Desc
+}
+
+func newVideoCollector(track mediadevices.VideoTracker, labels prometheus.Labels) *videoCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(Namespace, "video", name), help, nil, labels)
+	}
+	return &videoCollector{
+		track: track,
+
+		bitRate:       desc("bitrate_bits_per_second", "Bits per second of the encoded frames in the last second."),
+		frameRate:     desc("frames_per_second", "Frames per second written to the track in the last second."),
+		droppedFrames: desc("dropped_frames_total", "Frames dropped by the frame buffer."),
+		encodeLatency: desc("encode_latency_seconds", "Moving average of the time to encode a frame."),
+		queuedFrames:  desc("queued_frames", "Frames awaiting encode in the frame buffer."),
+		queuedBytes:   desc("queued_bytes", "Bytes of the frames awaiting encode in the frame buffer."),
+	}
+}
+
+func (c *videoCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.bitRate
+	ch <- c.frameRate
+	ch <- c.droppedFrames
+	ch <- c.encodeLatency
+	ch <- c.queuedFrames
+	ch <- c.queuedBytes
+}
+
+func (c *videoCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.track.Stats()
+	ch <- prometheus.MustNewConstMetric(c.bitRate, prometheus.GaugeValue, stats.BitRate)
+	ch <- prometheus.MustNewConstMetric(c.frameRate, prometheus.GaugeValue, stats.FrameRate)
+	ch <- prometheus.MustNewConstMetric(c.droppedFrames, prometheus.CounterValue, float64(stats.DroppedFrames))
+	ch <- prometheus.MustNewConstMetric(c.encodeLatency, prometheus.GaugeValue, stats.EncodeTime.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.queuedFrames, prometheus.GaugeValue, float64(stats.QueuedFrames))
+	ch <- prometheus.MustNewConstMetric(c.queuedBytes, prometheus.GaugeValue, float64(stats.QueuedBytes))
+}
+
+type audioCollector struct {
+	track mediadevices.AudioTracker
+
+	concealedSamples  *prometheus.Desc
+	concealmentEvents *prometheus.Desc
+}
+
+func newAudioCollector(track mediadevices.AudioTracker, labels prometheus.Labels) *audioCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(Namespace, "audio", name), help, nil, labels)
+	}
+	return &audioCollector{
+		track: track,
+
+		concealedSamples:  desc("concealed_samples_total", "Samples substituted for the gaps of the device."),
+		concealmentEvents: desc("concealment_events_total", "Gaps of the device concealed."),
+	}
+}
+
+func (c *audioCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.concealedSamples
+	ch <- c.concealmentEvents
+}
+
+func (c *audioCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.track.Stats()
+	ch <- prometheus.MustNewConstMetric(c.concealedSamples, prometheus.CounterValue, float64(stats.ConcealedSamples))
+	ch <- prometheus.MustNewConstMetric(c.concealmentEvents, prometheus.CounterValue, float64(stats.ConcealmentEvents))
+}
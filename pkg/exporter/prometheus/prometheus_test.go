@@ -0,0 +1,119 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/codec/raw"
+	_ "github.com/pion/mediadevices/pkg/driver/audiotest"
+	_ "github.com/pion/mediadevices/pkg/driver/videotest"
+	"github.com/pion/webrtc/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestRegister(t *testing.T) {
+	md := mediadevices.NewMediaDevicesFromCodecs(
+		map[webrtc.RTPCodecType][]*webrtc.RTPCodec{
+			webrtc.RTPCodecTypeVideo: {
+				{Name: raw.Name, Type: webrtc.RTPCodecTypeVideo},
+			},
+			webrtc.RTPCodecTypeAudio: {
+				{Name: raw.Name, Type: webrtc.RTPCodecTypeAudio},
+			},
+		},
+		mediadevices.WithTrackGenerator(func(_ uint8, _ uint32, id, _ string, codec *webrtc.RTPCodec) (mediadevices.LocalTrack, error) {
+			return mediadevices.NewBufferTrack(codec, id), nil
+		}),
+	)
+	s, err := md.GetUserMedia(mediadevices.MediaStreamConstraints{
+		Video: func(c *mediadevices.MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceLabel = "VideoTest"
+			c.CodecName = raw.Name
+			c.Width, c.Height = 64, 48
+			c.FrameRate = 30
+		},
+		Audio: func(c *mediadevices.MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceLabel = "AudioTest"
+			c.CodecName = raw.Name
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	reg := prometheus.NewRegistry()
+	for _, tr := range s.GetTracks() {
+		defer tr.Stop()
+		if _, err := Register(reg, tr); err != nil {
+			t.Fatalf("Failed to register %s track: %v", tr.Kind(), err)
+		}
+	}
+
+	gather := func() map[string]*dto.Metric {
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("Failed to gather: %v", err)
+		}
+		metrics := make(map[string]*dto.Metric)
+		for _, f := range families {
+			if len(f.GetMetric()) != 1 {
+				t.Fatalf("Expected one metric of %s, got %d", f.GetName(), len(f.GetMetric()))
+			}
+			metrics[f.GetName()] = f.GetMetric()[0]
+		}
+		return metrics
+	}
+
+	metrics := gather()
+	for _, name := range []string{
+		"mediadevices_video_bitrate_bits_per_second",
+		"mediadevices_video_frames_per_second",
+		"mediadevices_video_dropped_frames_total",
+		"mediadevices_video_encode_latency_seconds",
+		"mediadevices_video_queued_frames",
+		"mediadevices_video_queued_bytes",
+		"mediadevices_audio_concealed_samples_total",
+		"mediadevices_audio_concealment_events_total",
+	} {
+		if _, ok := metrics[name]; !ok {
+			t.Errorf("Expected %s to be registered", name)
+		}
+	}
+	labels := make(map[string]string)
+	for _, l := range metrics["mediadevices_video_frames_per_second"].GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+	if labels["kind"] != "video" || labels["track"] == "" {
+		t.Errorf("Expected the labels of the video track, got %v", labels)
+	}
+
+	// The metrics are updated after the frames flow
+	var frameRate, bitRate float64
+	for i := 0; i < 100; i++ {
+		time.Sleep(20 * time.Millisecond)
+		metrics = gather()
+		frameRate = metrics["mediadevices_video_frames_per_second"].GetGauge().GetValue()
+		bitRate = metrics["mediadevices_video_bitrate_bits_per_second"].GetGauge().GetValue()
+		if frameRate > 0 && bitRate > 0 {
+			break
+		}
+	}
+	if frameRate <= 0 {
+		t.Errorf("Expected the frame rate to be updated, got %f", frameRate)
+	}
+	// Raw I420 frames of 64x48 are 4608 bytes
+	if bitRate < frameRate*4608*8*0.9 {
+		t.Errorf("Expected the bit rate of the raw frames at %.1ffps, got %f", frameRate, bitRate)
+	}
+	if latency := metrics["mediadevices_video_encode_latency_seconds"].GetGauge().GetValue(); latency <= 0 {
+		t.Errorf("Expected the encode latency to be updated, got %f", latency)
+	}
+
+	// The collector of the same track can't be registered twice
+	if _, err := Register(reg, s.GetVideoTracks()[0]); err == nil {
+		t.Error("Expected error on the duplicated registration")
+	}
+}
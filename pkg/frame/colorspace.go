@@ -0,0 +1,90 @@
+package frame
+
+import "math"
+
+// ColorMatrix is the matrix of the conversion between YCbCr and RGB.
+type ColorMatrix string
+
+const (
+	// ColorMatrixBT601 is ITU-R BT.601 used by SD content and JPEG
+	ColorMatrixBT601 ColorMatrix = "BT.601"
+	// ColorMatrixBT709 is ITU-R BT.709 used by HD content
+	ColorMatrixBT709 ColorMatrix = "BT.709"
+)
+
+// ColorRange is the range of the YCbCr values.
+type ColorRange string
+
+const (
+	// ColorRangeFull uses 0-255 for all components as JPEG does
+	ColorRangeFull ColorRange = "full"
+	// ColorRangeLimited uses 16-235 for Y and 16-240 for Cb and Cr
+	ColorRangeLimited ColorRange = "limited"
+)
+
+// ColorSpace describes how the YCbCr values are mapped to RGB.
+// The zero value means BT.601 full range, which is the conversion of image/color.
+type ColorSpace struct {
+	Matrix ColorMatrix
+	Range  ColorRange
+}
+
+// IsDefault returns true if cs is same as the conversion of image/color.
+func (cs ColorSpace) IsDefault() bool {
+	return (cs.Matrix == "" || cs.Matrix == ColorMatrixBT601) &&
+		(cs.Range == "" || cs.Range == ColorRangeFull)
+}
+
+// coefficients returns Kr and Kb of the matrix. Unknown matrices are treated as BT.601.
+func (cs ColorSpace) coefficients() (kr, kb float64) {
+	if cs.Matrix == ColorMatrixBT709 {
+		return 0.2126, 0.0722
+	}
+	return 0.299, 0.114
+}
+
+// scales returns the offset and the scale of Y and the scale of Cb and Cr.
+func (cs ColorSpace) scales() (yOffset, yScale, cScale float64) {
+	if cs.Range == ColorRangeLimited {
+		return 16, 219, 224
+	}
+	return 0, 255, 255
+}
+
+// YCbCrToRGB converts a YCbCr triple to an RGB triple.
+func (cs ColorSpace) YCbCrToRGB(y, cb, cr uint8) (uint8, uint8, uint8) {
+	kr, kb := cs.coefficients()
+	yOffset, yScale, cScale := cs.scales()
+
+	yy := (float64(y) - yOffset) / yScale
+	pb := (float64(cb) - 128) / cScale
+	pr := (float64(cr) - 128) / cScale
+
+	r := yy + 2*(1-kr)*pr
+	b := yy + 2*(1-kb)*pb
+	g := (yy - kr*r - kb*b) / (1 - kr - kb)
+	return clip8(r * 255), clip8(g * 255), clip8(b * 255)
+}
+
+// RGBToYCbCr converts an RGB triple to a YCbCr triple.
+func (cs ColorSpace) RGBToYCbCr(r, g, b uint8) (uint8, uint8, uint8) {
+	kr, kb := cs.coefficients()
+	yOffset, yScale, cScale := cs.scales()
+
+	rr, gg, bb := float64(r)/255, float64(g)/255, float64(b)/255
+	yy := kr*rr + (1-kr-kb)*gg + kb*bb
+	pb := (bb - yy) / (2 * (1 - kb))
+	pr := (rr - yy) / (2 * (1 - kr))
+	return clip8(yOffset + yScale*yy), clip8(128 + cScale*pb), clip8(128 + cScale*pr)
+}
+
+func clip8(v float64) uint8 {
+	v = math.Round(v)
+	switch {
+	case v < 0:
+		return 0
+	case v > 255:
+		return 255
+	}
+	return uint8(v)
+}
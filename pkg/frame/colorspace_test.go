@@ -0,0 +1,70 @@
+package frame
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestColorSpace(t *testing.T) {
+	bt601 := ColorSpace{Matrix: ColorMatrixBT601, Range: ColorRangeFull}
+	bt709 := ColorSpace{Matrix: ColorMatrixBT709, Range: ColorRangeFull}
+	bt601Limited := ColorSpace{Matrix: ColorMatrixBT601, Range: ColorRangeLimited}
+	bt709Limited := ColorSpace{Matrix: ColorMatrixBT709, Range: ColorRangeLimited}
+
+	t.Run("YCbCrToRGB", func(t *testing.T) {
+		cases := map[string]struct {
+			cs    ColorSpace
+			ycbcr [3]uint8
+			rgb   [3]uint8
+		}{
+			"BT601":          {bt601, [3]uint8{100, 100, 180}, [3]uint8{173, 73, 50}},
+			"BT709":          {bt709, [3]uint8{100, 100, 180}, [3]uint8{182, 81, 48}},
+			"BT601Limited":   {bt601Limited, [3]uint8{100, 100, 180}, [3]uint8{181, 67, 41}},
+			"BT709Limited":   {bt709Limited, [3]uint8{100, 100, 180}, [3]uint8{191, 76, 39}},
+			"LimitedBlack":   {bt709Limited, [3]uint8{16, 128, 128}, [3]uint8{0, 0, 0}},
+			"LimitedWhite":   {bt709Limited, [3]uint8{235, 128, 128}, [3]uint8{255, 255, 255}},
+			"FullBlack":      {bt709, [3]uint8{16, 128, 128}, [3]uint8{16, 16, 16}},
+			"ZeroValueBT601": {ColorSpace{}, [3]uint8{100, 100, 180}, [3]uint8{173, 73, 50}},
+		}
+		for name, c := range cases {
+			c := c
+			t.Run(name, func(t *testing.T) {
+				r, g, b := c.cs.YCbCrToRGB(c.ycbcr[0], c.ycbcr[1], c.ycbcr[2])
+				if rgb := [3]uint8{r, g, b}; rgb != c.rgb {
+					t.Errorf("Expected %v, got %v", c.rgb, rgb)
+				}
+			})
+		}
+	})
+	t.Run("RoundTrip", func(t *testing.T) {
+		for _, cs := range []ColorSpace{bt601, bt709, bt601Limited, bt709Limited} {
+			for _, rgb := range [][3]uint8{{0, 0, 0}, {255, 255, 255}, {200, 30, 90}, {10, 240, 120}} {
+				y, cb, cr := cs.RGBToYCbCr(rgb[0], rgb[1], rgb[2])
+				r, g, b := cs.YCbCrToRGB(y, cb, cr)
+				for i, v := range [3]uint8{r, g, b} {
+					if diff := int(v) - int(rgb[i]); diff < -2 || diff > 2 {
+						t.Errorf("%+v: Expected %v after round trip, got %v", cs, rgb, [3]uint8{r, g, b})
+						break
+					}
+				}
+			}
+		}
+	})
+	t.Run("DefaultMatchesImageColor", func(t *testing.T) {
+		cs := ColorSpace{}
+		if !cs.IsDefault() {
+			t.Fatal("Zero value must be the default color space")
+		}
+		for _, ycbcr := range [][3]uint8{{100, 100, 180}, {16, 128, 128}, {200, 60, 30}} {
+			r0, g0, b0 := color.YCbCrToRGB(ycbcr[0], ycbcr[1], ycbcr[2])
+			r, g, b := cs.YCbCrToRGB(ycbcr[0], ycbcr[1], ycbcr[2])
+			for i, v := range [3]uint8{r, g, b} {
+				expected := [3]uint8{r0, g0, b0}[i]
+				if diff := int(v) - int(expected); diff < -1 || diff > 1 {
+					t.Errorf("Expected %v same as image/color, got %v", [3]uint8{r0, g0, b0}, [3]uint8{r, g, b})
+					break
+				}
+			}
+		}
+	})
+}
@@ -9,20 +9,34 @@ const (
 	FormatI420 Format = "I420"
 	// FormatI444 is a YUV format without sub-sampling
 	FormatI444 Format = "I444"
+	// FormatI422 is a planar YUV format with horizontal sub-sampling
+	FormatI422 Format = "I422"
 	// FormatNV21 https://www.fourcc.org/pixel-format/yuv-nv21/
 	FormatNV21 = "NV21"
 	// FormatYUY2 https://www.fourcc.org/pixel-format/yuv-yuy2/
 	FormatYUY2 = "YUY2"
+	// FormatP010 is a 10-bit version of NV12. Each sample is stored in 16 bits
+	// little endian with the value in the upper 10 bits.
+	// It's decoded to 8-bit I420 since image.YCbCr only supports 8-bit samples.
+	FormatP010 Format = "P010"
 
 	// RGB Formats
 
 	// FormatRGBA https://www.fourcc.org/pixel-format/rgb-rgba/
 	FormatRGBA Format = "RGBA"
+	// FormatBGRA is a RGBA format with reversed order of the color components
+	FormatBGRA Format = "BGRA"
 
 	// Compressed Formats
 
 	// FormatMJPEG https://www.fourcc.org/mjpg/
 	FormatMJPEG = "MJPEG"
+
+	// FormatAny is a sentinel of the constraints to use the native format of
+	// the device. Among the formats the device outputs, the one the encoder
+	// accepts without conversion is preferred, and the selected format is
+	// reported by the settings of the track.
+	FormatAny Format = "ANY"
 )
 
 // YUV aliases
@@ -2,18 +2,62 @@ package frame
 
 import (
 	"fmt"
+	"image"
 )
 
+// allocFunc allocates a buffer of the decoded frame.
+type allocFunc func(size int) []byte
+
+func makeBuffer(size int) []byte {
+	return make([]byte, size)
+}
+
 func NewDecoder(f Format) (Decoder, error) {
+	return newDecoder(f, makeBuffer)
+}
+
+// NewPooledDecoder creates a decoder allocating the decoded frames from pool.
+// The frame returned by Decode is valid until the next Decode, which puts the
+// buffers of the frame back to the pool, so the caller must copy the frame to
+// keep it. The decoder must not be used concurrently.
+func NewPooledDecoder(f Format, pool *Pool) (Decoder, error) {
+	d := &pooledDecoder{pool: pool}
+	decoder, err := newDecoder(f, d.alloc)
+	if err != nil {
+		return nil, err
+	}
+	d.decoder = decoder
+	return d, nil
+}
+
+func newDecoder(f Format, alloc allocFunc) (Decoder, error) {
 	var decoder DecoderFunc
 
 	switch f {
 	case FormatI420:
 		decoder = decodeI420
+	case FormatI422:
+		decoder = decodePlanarYUV(image.YCbCrSubsampleRatio422)
+	case FormatI444:
+		decoder = decodePlanarYUV(image.YCbCrSubsampleRatio444)
 	case FormatNV21:
-		decoder = decodeNV21
+		decoder = func(frame []byte, width, height int) (image.Image, error) {
+			return decodeNV21(frame, width, height, alloc)
+		}
 	case FormatYUY2:
-		decoder = decodeYUY2
+		decoder = func(frame []byte, width, height int) (image.Image, error) {
+			return decodeYUY2(frame, width, height, alloc)
+		}
+	case FormatP010:
+		decoder = func(frame []byte, width, height int) (image.Image, error) {
+			return decodeP010(frame, width, height, alloc)
+		}
+	case FormatRGBA:
+		decoder = decodeRGBA
+	case FormatBGRA:
+		decoder = func(frame []byte, width, height int) (image.Image, error) {
+			return decodeBGRA(frame, width, height, alloc)
+		}
 	case FormatMJPEG:
 		decoder = decodeMJPEG
 	default:
@@ -22,3 +66,25 @@ func NewDecoder(f Format) (Decoder, error) {
 
 	return decoder, nil
 }
+
+type pooledDecoder struct {
+	decoder Decoder
+	pool    *Pool
+	// used holds the buffers of the last decoded frame
+	used [][]byte
+}
+
+func (d *pooledDecoder) alloc(size int) []byte {
+	b := d.pool.Get(size)
+	d.used = append(d.used, b)
+	return b
+}
+
+func (d *pooledDecoder) Decode(frame []byte, width, height int) (image.Image, error) {
+	for i, b := range d.used {
+		d.pool.Put(b)
+		d.used[i] = nil
+	}
+	d.used = d.used[:0]
+	return d.decoder.Decode(frame, width, height)
+}
@@ -0,0 +1,43 @@
+package frame
+
+import (
+	"fmt"
+	"strings"
+)
+
+// fourCCs are the FourCC codes of the formats. The first code is returned by
+// Format.FourCC, and the others are accepted by FromFourCC as aliases.
+var fourCCs = map[Format][]string{
+	FormatI420:  {"I420", "IYUV", "YU12"},
+	FormatI444:  {"444P"},
+	FormatI422:  {"422P"},
+	FormatNV21:  {"NV21"},
+	FormatYUY2:  {"YUY2", "YUYV"},
+	FormatP010:  {"P010"},
+	FormatRGBA:  {"RGBA"},
+	FormatBGRA:  {"BGRA"},
+	FormatMJPEG: {"MJPG", "JPEG"},
+}
+
+// FromFourCC returns the Format of the FourCC code, e.g. "YUYV" or "MJPG" reported
+// by v4l2 or Media Foundation. Trailing spaces and NUL characters are ignored.
+func FromFourCC(s string) (Format, error) {
+	s = strings.TrimRight(s, " \x00")
+	for f, codes := range fourCCs {
+		for _, code := range codes {
+			if code == s {
+				return f, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("unknown FourCC %q", s)
+}
+
+// FourCC returns the FourCC code of f. It returns an empty string if f has no
+// known code.
+func (f Format) FourCC() string {
+	if codes, ok := fourCCs[f]; ok {
+		return codes[0]
+	}
+	return ""
+}
@@ -0,0 +1,46 @@
+package frame
+
+import (
+	"testing"
+)
+
+func TestFourCC(t *testing.T) {
+	cases := map[string]struct {
+		format Format
+		fourCC string
+	}{
+		"I420":     {FormatI420, "I420"},
+		"YU12":     {FormatI420, "I420"},
+		"444P":     {FormatI444, "444P"},
+		"422P":     {FormatI422, "422P"},
+		"NV21":     {FormatNV21, "NV21"},
+		"YUY2":     {FormatYUY2, "YUY2"},
+		"YUYV":     {FormatYUY2, "YUY2"},
+		"P010":     {FormatP010, "P010"},
+		"RGBA":     {FormatRGBA, "RGBA"},
+		"BGRA":     {FormatBGRA, "BGRA"},
+		"MJPG":     {FormatMJPEG, "MJPG"},
+		"JPEG":     {FormatMJPEG, "MJPG"},
+		"MJPG\x00": {FormatMJPEG, "MJPG"},
+	}
+	for code, c := range cases {
+		f, err := FromFourCC(code)
+		if err != nil {
+			t.Errorf("%q: Unexpected error: %v", code, err)
+			continue
+		}
+		if f != c.format {
+			t.Errorf("%q: Expected %s, got %s", code, c.format, f)
+		}
+		if fourCC := f.FourCC(); fourCC != c.fourCC {
+			t.Errorf("%q: Expected FourCC %q, got %q", code, c.fourCC, fourCC)
+		}
+	}
+
+	if _, err := FromFourCC("H264"); err == nil {
+		t.Error("Expected error on unknown FourCC")
+	}
+	if fourCC := Format("unknown").FourCC(); fourCC != "" {
+		t.Errorf("Expected empty FourCC of unknown format, got %q", fourCC)
+	}
+}
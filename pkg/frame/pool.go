@@ -0,0 +1,41 @@
+package frame
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// Pool is a pool of the frame buffers to reduce the allocations and the GC
+// pressure of the sustained capture. The buffers are grouped by the capacity
+// rounded up to a power of two. The zero value is ready to use.
+//
+// The buffer must not be used after it's put back to the pool. Since the
+// frames read from video.Reader are valid until the next Read, the owner of a
+// pooled frame puts its buffers back when the next frame is produced.
+type Pool struct {
+	pools [64]sync.Pool
+}
+
+// Get returns a buffer of the given size. The content of the buffer is undefined.
+func (p *Pool) Get(size int) []byte {
+	if size <= 0 {
+		return nil
+	}
+
+	class := bits.Len(uint(size - 1))
+	if b, ok := p.pools[class].Get().(*[]byte); ok {
+		return (*b)[:size]
+	}
+	return make([]byte, size, 1<<uint(class))
+}
+
+// Put puts b back to the pool. The buffers not allocated by Get are dropped.
+func (p *Pool) Put(b []byte) {
+	c := cap(b)
+	if c == 0 || c&(c-1) != 0 {
+		return
+	}
+
+	b = b[:c]
+	p.pools[bits.Len(uint(c-1))].Put(&b)
+}
@@ -0,0 +1,126 @@
+package frame
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestPool(t *testing.T) {
+	var p Pool
+
+	if b := p.Get(0); b != nil {
+		t.Errorf("Expected nil for size 0, got %v", b)
+	}
+	for _, size := range []int{1, 3, 4, 1000, 1024, 1025} {
+		b := p.Get(size)
+		if len(b) != size {
+			t.Errorf("Expected length %d, got %d", size, len(b))
+		}
+		if c := cap(b); c < size || c&(c-1) != 0 {
+			t.Errorf("Expected capacity of power of two for size %d, got %d", size, c)
+		}
+		p.Put(b)
+	}
+
+	// Buffers not allocated by the pool are ignored
+	p.Put(make([]byte, 3))
+	p.Put(nil)
+}
+
+func TestPooledDecoder(t *testing.T) {
+	const width, height = 4, 2
+	formats := map[Format][]byte{
+		FormatYUY2: {
+			0, 10, 1, 20, 2, 11, 3, 21,
+			4, 12, 5, 22, 6, 13, 7, 23,
+		},
+		FormatNV21: {
+			0, 1, 2, 3, 4, 5, 6, 7,
+			10, 20, 11, 21,
+		},
+		FormatBGRA: bytes.Repeat([]byte{1, 2, 3, 4}, width*height),
+	}
+
+	var p Pool
+	for f, src := range formats {
+		f, src := f, src
+		t.Run(string(f), func(t *testing.T) {
+			expectedDecoder, err := NewDecoder(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, err := expectedDecoder.Decode(src, width, height)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d, err := NewPooledDecoder(f, &p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The buffers are reused from the second frame
+			for i := 0; i < 3; i++ {
+				img, err := d.Decode(src, width, height)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assertSameImage(t, expected, img)
+			}
+		})
+	}
+
+	if _, err := NewPooledDecoder(Format("unknown"), &p); err == nil {
+		t.Error("Expected error on unsupported format")
+	}
+}
+
+func assertSameImage(t *testing.T, expected, actual image.Image) {
+	t.Helper()
+	if expected.Bounds() != actual.Bounds() {
+		t.Fatalf("Expected bounds %v, got %v", expected.Bounds(), actual.Bounds())
+	}
+	bounds := expected.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if e, a := expected.At(x, y), actual.At(x, y); e != a {
+				t.Fatalf("Expected %v at (%d, %d), got %v", e, x, y, a)
+			}
+		}
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	const width, height = 1280, 720
+
+	for _, f := range []Format{FormatYUY2, FormatNV21} {
+		var src []byte
+		switch f {
+		case FormatYUY2:
+			src = make([]byte, 2*width*height)
+		case FormatNV21:
+			src = make([]byte, width*height*3/2)
+		}
+
+		decoders := map[string]func() (Decoder, error){
+			"Alloc": func() (Decoder, error) { return NewDecoder(f) },
+			"Pool":  func() (Decoder, error) { return NewPooledDecoder(f, &Pool{}) },
+		}
+		for name, newDecoder := range decoders {
+			b.Run(string(f)+"/"+name, func(b *testing.B) {
+				d, err := newDecoder()
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.ReportAllocs()
+				b.SetBytes(int64(len(src)))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := d.Decode(src, width, height); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
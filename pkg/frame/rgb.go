@@ -0,0 +1,40 @@
+package frame
+
+import (
+	"fmt"
+	"image"
+)
+
+func decodeRGBA(frame []byte, width, height int) (image.Image, error) {
+	size := 4 * width * height
+	if size > len(frame) {
+		return nil, fmt.Errorf("frame length (%d) less than expected (%d)", len(frame), size)
+	}
+
+	return &image.RGBA{
+		Pix:    frame[:size],
+		Stride: 4 * width,
+		Rect:   image.Rect(0, 0, width, height),
+	}, nil
+}
+
+func decodeBGRA(frame []byte, width, height int, alloc allocFunc) (image.Image, error) {
+	size := 4 * width * height
+	if size > len(frame) {
+		return nil, fmt.Errorf("frame length (%d) less than expected (%d)", len(frame), size)
+	}
+
+	pix := alloc(size)
+	for i := 0; i < size; i += 4 {
+		pix[i+0] = frame[i+2]
+		pix[i+1] = frame[i+1]
+		pix[i+2] = frame[i+0]
+		pix[i+3] = frame[i+3]
+	}
+
+	return &image.RGBA{
+		Pix:    pix,
+		Stride: 4 * width,
+		Rect:   image.Rect(0, 0, width, height),
+	}, nil
+}
@@ -0,0 +1,49 @@
+package frame
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDecodeRGB(t *testing.T) {
+	cases := map[Format][]byte{
+		FormatRGBA: {
+			0xFF, 0x00, 0x00, 0xFF, 0x00, 0xFF, 0x00, 0xFF,
+			0x00, 0x00, 0xFF, 0xFF, 0x10, 0x20, 0x30, 0x80,
+		},
+		FormatBGRA: {
+			0x00, 0x00, 0xFF, 0xFF, 0x00, 0xFF, 0x00, 0xFF,
+			0xFF, 0x00, 0x00, 0xFF, 0x30, 0x20, 0x10, 0x80,
+		},
+	}
+	expected := []color.RGBA{
+		{0xFF, 0x00, 0x00, 0xFF}, {0x00, 0xFF, 0x00, 0xFF},
+		{0x00, 0x00, 0xFF, 0xFF}, {0x10, 0x20, 0x30, 0x80},
+	}
+
+	for format, raw := range cases {
+		format, raw := format, raw
+		t.Run(string(format), func(t *testing.T) {
+			d, err := NewDecoder(format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			img, err := d.Decode(raw, 2, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rgba := img.(*image.RGBA)
+			for i, c := range expected {
+				if got := rgba.RGBAAt(i%2, i/2); got != c {
+					t.Errorf("Expected %v at (%d, %d), got %v", c, i%2, i/2, got)
+				}
+			}
+
+			if _, err := d.Decode(raw[:15], 2, 2); err == nil {
+				t.Error("Expected an error on short frame")
+			}
+		})
+	}
+}
@@ -25,7 +25,38 @@ func decodeI420(frame []byte, width, height int) (image.Image, error) {
 	}, nil
 }
 
-func decodeNV21(frame []byte, width, height int) (image.Image, error) {
+// decodePlanarYUV returns a decoder of the planar YUV frames of the subsample ratio.
+// The planes share the frame without copying it like decodeI420.
+func decodePlanarYUV(ratio image.YCbCrSubsampleRatio) DecoderFunc {
+	return func(frame []byte, width, height int) (image.Image, error) {
+		cw, ch := width, height
+		switch ratio {
+		case image.YCbCrSubsampleRatio422:
+			cw = width / 2
+		case image.YCbCrSubsampleRatio420:
+			cw, ch = width/2, height/2
+		}
+		yi := width * height
+		cbi := yi + cw*ch
+		cri := cbi + cw*ch
+
+		if cri > len(frame) {
+			return nil, fmt.Errorf("frame length (%d) less than expected (%d)", len(frame), cri)
+		}
+
+		return &image.YCbCr{
+			Y:              frame[:yi],
+			YStride:        width,
+			Cb:             frame[yi:cbi],
+			Cr:             frame[cbi:cri],
+			CStride:        cw,
+			SubsampleRatio: ratio,
+			Rect:           image.Rect(0, 0, width, height),
+		}, nil
+	}
+}
+
+func decodeNV21(frame []byte, width, height int, alloc allocFunc) (image.Image, error) {
 	yi := width * height
 	ci := yi + width*height/2
 
@@ -33,10 +64,11 @@ func decodeNV21(frame []byte, width, height int) (image.Image, error) {
 		return nil, fmt.Errorf("frame length (%d) less than expected (%d)", len(frame), ci)
 	}
 
-	var cb, cr []byte
-	for i := yi; i < ci; i += 2 {
-		cb = append(cb, frame[i])
-		cr = append(cr, frame[i+1])
+	cb := alloc((ci - yi) / 2)
+	cr := alloc((ci - yi) / 2)
+	for i := range cb {
+		cb[i] = frame[yi+2*i]
+		cr[i] = frame[yi+2*i+1]
 	}
 
 	return &image.YCbCr{
@@ -50,7 +82,7 @@ func decodeNV21(frame []byte, width, height int) (image.Image, error) {
 	}, nil
 }
 
-func decodeYUY2(frame []byte, width, height int) (image.Image, error) {
+func decodeYUY2(frame []byte, width, height int, alloc allocFunc) (image.Image, error) {
 	yi := width * height
 	ci := yi / 2
 	fi := yi + 2*ci
@@ -59,9 +91,9 @@ func decodeYUY2(frame []byte, width, height int) (image.Image, error) {
 		return nil, fmt.Errorf("frame length (%d) less than expected (%d)", len(frame), fi)
 	}
 
-	y := make([]byte, yi)
-	cb := make([]byte, ci)
-	cr := make([]byte, ci)
+	y := alloc(yi)
+	cb := alloc(ci)
+	cr := alloc(ci)
 
 	fast := 0
 	slow := 0
@@ -84,3 +116,76 @@ func decodeYUY2(frame []byte, width, height int) (image.Image, error) {
 		Rect:           image.Rect(0, 0, width, height),
 	}, nil
 }
+
+func decodeP010(frame []byte, width, height int, alloc allocFunc) (image.Image, error) {
+	yi := 2 * width * height
+	ci := yi + width*height
+
+	if ci > len(frame) {
+		return nil, fmt.Errorf("frame length (%d) less than expected (%d)", len(frame), ci)
+	}
+
+	// Round the 10-bit samples to 8 bits
+	to8 := func(b []byte) uint8 {
+		v := (int(b[0]) | int(b[1])<<8) >> 6
+		if v = (v + 2) >> 2; v > 0xFF {
+			v = 0xFF
+		}
+		return uint8(v)
+	}
+
+	y := alloc(width * height)
+	for i := range y {
+		y[i] = to8(frame[2*i:])
+	}
+
+	cb := alloc(width * height / 4)
+	cr := alloc(width * height / 4)
+	for i := range cb {
+		cb[i] = to8(frame[yi+4*i:])
+		cr[i] = to8(frame[yi+4*i+2:])
+	}
+
+	return &image.YCbCr{
+		Y:              y,
+		YStride:        width,
+		Cb:             cb,
+		Cr:             cr,
+		CStride:        width / 2,
+		SubsampleRatio: image.YCbCrSubsampleRatio420,
+		Rect:           image.Rect(0, 0, width, height),
+	}, nil
+}
+
+// EncodeP010 converts an 8-bit I420 image to a P010 frame. The samples are
+// scaled to the full 10-bit range.
+func EncodeP010(img *image.YCbCr) ([]byte, error) {
+	if img.SubsampleRatio != image.YCbCrSubsampleRatio420 {
+		return nil, fmt.Errorf("unsupported sub-sampling ratio: %s", img.SubsampleRatio)
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	yi := 2 * width * height
+	frame := make([]byte, yi+width*height)
+
+	put := func(b []byte, v uint8) {
+		v10 := uint16(v)<<2 | uint16(v)>>6
+		b[0], b[1] = uint8(v10<<6), uint8(v10>>2)
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			put(frame[2*(y*width+x):], img.Y[img.YOffset(bounds.Min.X+x, bounds.Min.Y+y)])
+		}
+	}
+	for y := 0; y < height/2; y++ {
+		for x := 0; x < width/2; x++ {
+			i := yi + 4*(y*width/2+x)
+			ci := img.COffset(bounds.Min.X+2*x, bounds.Min.Y+2*y)
+			put(frame[i:], img.Cb[ci])
+			put(frame[i+2:], img.Cr[ci])
+		}
+	}
+	return frame, nil
+}
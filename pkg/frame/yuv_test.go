@@ -0,0 +1,113 @@
+package frame
+
+import (
+	"image"
+	"testing"
+)
+
+func TestP010(t *testing.T) {
+	const width, height = 4, 2
+
+	// 10-bit samples of Y plane followed by interleaved Cb and Cr
+	samples := []uint16{
+		0, 1, 2, 64, 510, 511, 1021, 1023,
+		100, 900, 512, 3,
+	}
+	frame := make([]byte, 2*len(samples))
+	for i, v := range samples {
+		frame[2*i], frame[2*i+1] = uint8(v<<6), uint8(v>>2)
+	}
+
+	d, err := NewDecoder(FormatP010)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := d.Decode(frame, width, height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yuv, ok := img.(*image.YCbCr)
+	if !ok || yuv.SubsampleRatio != image.YCbCrSubsampleRatio420 {
+		t.Fatalf("Expected I420 image, got %T", img)
+	}
+
+	decoded := append(append([]byte(nil), yuv.Y...), yuv.Cb[0], yuv.Cr[0], yuv.Cb[1], yuv.Cr[1])
+	for i, v := range samples {
+		// The rounding error is up to 2 and 1023 is clipped to 255
+		if diff := int(decoded[i])*4 - int(v); diff < -3 || diff > 2 {
+			t.Errorf("Expected 8-bit sample %d to be close to %d/4, got %d", i, v, decoded[i])
+		}
+	}
+
+	encoded, err := EncodeP010(yuv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(encoded) != len(frame) {
+		t.Fatalf("Expected frame length %d, got %d", len(frame), len(encoded))
+	}
+	for i, v := range samples {
+		got := int(encoded[2*i]) | int(encoded[2*i+1])<<8
+		if got&0x3F != 0 {
+			t.Errorf("Expected lower 6 bits of sample %d to be zero, got %04x", i, got)
+		}
+		if diff := got>>6 - int(v); diff < -4 || diff > 4 {
+			t.Errorf("Expected 10-bit sample %d to be close to %d, got %d", i, v, got>>6)
+		}
+	}
+
+	if _, err := d.Decode(frame[:len(frame)-1], width, height); err == nil {
+		t.Error("Expected error on short frame")
+	}
+}
+
+func TestDecodePlanarYUV(t *testing.T) {
+	const width, height = 4, 2
+
+	cases := map[Format]struct {
+		ratio      image.YCbCrSubsampleRatio
+		chromaSize int
+		cStride    int
+	}{
+		FormatI420: {image.YCbCrSubsampleRatio420, 2, 2},
+		FormatI422: {image.YCbCrSubsampleRatio422, 4, 2},
+		FormatI444: {image.YCbCrSubsampleRatio444, 8, 4},
+	}
+	for format, c := range cases {
+		format, c := format, c
+		t.Run(string(format), func(t *testing.T) {
+			frame := make([]byte, width*height+2*c.chromaSize)
+			for i := range frame {
+				frame[i] = byte(i)
+			}
+
+			d, err := NewDecoder(format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			img, err := d.Decode(frame, width, height)
+			if err != nil {
+				t.Fatal(err)
+			}
+			yuv, ok := img.(*image.YCbCr)
+			if !ok || yuv.SubsampleRatio != c.ratio {
+				t.Fatalf("Expected %s image, got %T", c.ratio, img)
+			}
+			if yuv.CStride != c.cStride {
+				t.Errorf("Expected chroma stride %d, got %d", c.cStride, yuv.CStride)
+			}
+
+			yi := width * height
+			if yuv.Cb[0] != frame[yi] || yuv.Cr[0] != frame[yi+c.chromaSize] {
+				t.Errorf("Expected planes at %d and %d, got Cb %d and Cr %d", yi, yi+c.chromaSize, yuv.Cb[0], yuv.Cr[0])
+			}
+			if len(yuv.Cb) != c.chromaSize || len(yuv.Cr) != c.chromaSize {
+				t.Errorf("Expected chroma planes of %d bytes, got %d and %d", c.chromaSize, len(yuv.Cb), len(yuv.Cr))
+			}
+
+			if _, err := d.Decode(frame[:len(frame)-1], width, height); err == nil {
+				t.Error("Expected error on short frame")
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package audio
+
+import (
+	"math"
+	"time"
+)
+
+const rmsWindow = 50 * time.Millisecond
+
+// AGCParams stores the parameters of AGC. Zero values use the defaults.
+type AGCParams struct {
+	// SampleRate is the sample rate of the audio. It's required to convert
+	// the durations to the number of the samples.
+	SampleRate int
+	// Attack is the time constant to lower the gain when the level rises.
+	// Default is 10ms.
+	Attack time.Duration
+	// Release is the time constant to raise the gain when the level falls.
+	// Longer release avoids pumping after transients. Default is 1s.
+	Release time.Duration
+	// MaxGainDB is the maximum gain in dB. Default is 30dB.
+	MaxGainDB float64
+	// SilenceDB is the level in dBFS under which the gain is held, so that
+	// the silence and the noise floor are not amplified. Default is -60dBFS.
+	SilenceDB float64
+}
+
+// AGC returns an automatic gain control transform which adjusts the gain to
+// keep the RMS level of the audio near targetDB in dBFS. 0dBFS is the level of
+// a full scale square wave, the same as Level. The output is clipped to the
+// range of -1.0 to 1.0.
+func AGC(targetDB float64, params AGCParams) TransformFunc {
+	if params.SampleRate <= 0 {
+		panic("AGC sample rate must be positive!")
+	}
+	if params.Attack == 0 {
+		params.Attack = 10 * time.Millisecond
+	}
+	if params.Release == 0 {
+		params.Release = time.Second
+	}
+	if params.MaxGainDB == 0 {
+		params.MaxGainDB = 30
+	}
+	if params.SilenceDB == 0 {
+		params.SilenceDB = -60
+	}
+
+	coeff := func(d time.Duration) float64 {
+		return 1 - math.Exp(-1/(d.Seconds()*float64(params.SampleRate)))
+	}
+	attack, release := coeff(params.Attack), coeff(params.Release)
+	target := dbToAmplitude(targetDB)
+	maxGain := dbToAmplitude(params.MaxGainDB)
+	silence := dbToAmplitude(params.SilenceDB)
+
+	// The level is measured over rmsWindow to remove the ripple of the waveform
+	detect := coeff(rmsWindow)
+
+	return func(r Reader) Reader {
+		var power float64
+		gain := 1.0
+		return ReaderFunc(func(samples [][2]float32) (int, error) {
+			n, err := r.Read(samples)
+			for i := range samples[:n] {
+				l, r := float64(samples[i][0]), float64(samples[i][1])
+				power += ((l*l+r*r)/2 - power) * detect
+
+				if rms := math.Sqrt(power); rms > silence {
+					desired := math.Min(target/rms, maxGain)
+					if desired < gain {
+						gain += (desired - gain) * attack
+					} else {
+						gain += (desired - gain) * release
+					}
+				}
+
+				samples[i][0] = clip(l * gain)
+				samples[i][1] = clip(r * gain)
+			}
+			return n, err
+		})
+	}
+}
+
+func dbToAmplitude(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+func clip(v float64) float32 {
+	return float32(math.Max(-1, math.Min(1, v)))
+}
@@ -0,0 +1,76 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAGC(t *testing.T) {
+	const (
+		sampleRate = 48000
+		targetDB   = -20
+		frequency  = 440
+	)
+
+	// rmsDB returns the RMS level of samples in dBFS
+	rmsDB := func(samples [][2]float32) float64 {
+		var sum float64
+		for _, s := range samples {
+			sum += float64(s[0])*float64(s[0]) + float64(s[1])*float64(s[1])
+		}
+		return 20 * math.Log10(math.Sqrt(sum/float64(2*len(samples))))
+	}
+
+	t.Run("Ramp", func(t *testing.T) {
+		// Amplitude ramps from 0.01 to 0.8 in 4 seconds and then stays
+		const duration = 6 * sampleRate
+		var pos int
+		src := ReaderFunc(func(samples [][2]float32) (int, error) {
+			for i := range samples {
+				amplitude := 0.01 + 0.79*math.Min(float64(pos)/(4*sampleRate), 1)
+				v := float32(amplitude * math.Sin(2*math.Pi*frequency*float64(pos)/sampleRate))
+				samples[i] = [2]float32{v, v}
+				pos++
+			}
+			return len(samples), nil
+		})
+
+		r := AGC(targetDB, AGCParams{SampleRate: sampleRate})(src)
+		buf := make([][2]float32, sampleRate/10)
+		for i := 0; i < duration/len(buf); i++ {
+			if _, err := r.Read(buf); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			// The level has to be kept during the ramp after the initial convergence
+			if i < 20 {
+				continue
+			}
+			if level := rmsDB(buf); math.Abs(level-targetDB) > 2 {
+				t.Errorf("Expected %ddBFS at %dms, got %.1fdBFS", targetDB, i*100, level)
+			}
+		}
+	})
+	t.Run("Silence", func(t *testing.T) {
+		// Noise floor at -80dBFS must not be amplified to the target
+		rnd := uint32(1)
+		src := ReaderFunc(func(samples [][2]float32) (int, error) {
+			for i := range samples {
+				rnd = rnd*1664525 + 1013904223
+				v := float32(1e-4 * (float64(rnd)/math.MaxUint32*2 - 1))
+				samples[i] = [2]float32{v, v}
+			}
+			return len(samples), nil
+		})
+
+		r := AGC(targetDB, AGCParams{SampleRate: sampleRate})(src)
+		buf := make([][2]float32, sampleRate)
+		for i := 0; i < 3; i++ {
+			if _, err := r.Read(buf); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+		if level := rmsDB(buf); level > -70 {
+			t.Errorf("Expected silence not to be amplified, got %.1fdBFS", level)
+		}
+	})
+}
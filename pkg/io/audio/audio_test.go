@@ -0,0 +1,45 @@
+package audio
+
+import (
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	for name, merge := range map[string]func(...TransformFunc) TransformFunc{
+		"Merge": Merge,
+		"Chain": Chain,
+	} {
+		merge := merge
+		t.Run(name, func(t *testing.T) {
+			var order []string
+			stage := func(name string) TransformFunc {
+				return func(r Reader) Reader {
+					return ReaderFunc(func(samples [][2]float32) (int, error) {
+						n, err := r.Read(samples)
+						order = append(order, name)
+						return n, err
+					})
+				}
+			}
+
+			src := ReaderFunc(func(samples [][2]float32) (int, error) {
+				return len(samples), nil
+			})
+			r := merge(stage("resample"), nil, stage("gain"), stage("mono"))(src)
+
+			if _, err := r.Read(make([][2]float32, 10)); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			expected := []string{"resample", "gain", "mono"}
+			if len(order) != len(expected) {
+				t.Fatalf("Expected %v, got %v", expected, order)
+			}
+			for i := range expected {
+				if order[i] != expected[i] {
+					t.Fatalf("Expected %v, got %v", expected, order)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package audio
+
+import (
+	"sync"
+)
+
+// Buffer returns audio buffering transform.
+// This transform reads samples from the upstream reader in a separate goroutine
+// and stores up to size samples, so that a slow downstream (e.g. encoder) doesn't
+// block the capture. When the buffer is full, the oldest samples are dropped to
+// keep the latency bounded. The buffer adds up to size samples of latency.
+func Buffer(size int) TransformFunc {
+	if size <= 0 {
+		panic("Buffer size must be positive!")
+	}
+
+	// Read in smaller chunks than the buffer size not to wait for the upstream
+	// too long before storing the samples.
+	chunkSize := size / 2
+	if chunkSize == 0 {
+		chunkSize = 1
+	}
+
+	return func(r Reader) Reader {
+		var mu sync.Mutex
+		cond := sync.NewCond(&mu)
+		buff := make([][2]float32, 0, size)
+		var readErr error
+
+		go func() {
+			chunk := make([][2]float32, chunkSize)
+			for {
+				n, err := r.Read(chunk)
+
+				mu.Lock()
+				if len(buff)+n > size {
+					// Drop the oldest samples
+					drop := len(buff) + n - size
+					if drop > len(buff) {
+						drop = len(buff)
+					}
+					copy(buff, buff[drop:])
+					buff = buff[:len(buff)-drop]
+				}
+				buff = append(buff, chunk[:n]...)
+				if err != nil {
+					readErr = err
+				}
+				cond.Broadcast()
+				mu.Unlock()
+
+				if err != nil {
+					return
+				}
+			}
+		}()
+
+		return ReaderFunc(func(samples [][2]float32) (int, error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			for len(buff) == 0 && readErr == nil {
+				cond.Wait()
+			}
+			if len(buff) == 0 {
+				return 0, readErr
+			}
+
+			n := copy(samples, buff)
+			copy(buff, buff[n:])
+			buff = buff[:len(buff)-n]
+			return n, nil
+		})
+	}
+}
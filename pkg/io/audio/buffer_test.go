@@ -0,0 +1,83 @@
+package audio
+
+import (
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBuffer(t *testing.T) {
+	var cnt int
+	r := Buffer(16)(ReaderFunc(func(samples [][2]float32) (int, error) {
+		if cnt == 100 {
+			return 0, io.EOF
+		}
+		samples[0] = [2]float32{float32(cnt), -float32(cnt)}
+		cnt++
+		return 1, nil
+	}))
+
+	var got []float32
+	buff := make([][2]float32, 7)
+	for {
+		n, err := r.Read(buff)
+		for _, s := range buff[:n] {
+			if s[0] != -s[1] {
+				t.Fatalf("Channels are mixed: %v", s)
+			}
+			got = append(got, s[0])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	// Samples must be in order without duplication even if some of them are dropped.
+	for i := 1; i < len(got); i++ {
+		if got[i] <= got[i-1] {
+			t.Fatalf("Samples are out of order: %v", got)
+		}
+	}
+	if len(got) == 0 || got[len(got)-1] != 99 {
+		t.Errorf("Last sample must be 99, got %v", got)
+	}
+}
+
+func TestBufferLatency(t *testing.T) {
+	// Measures average delay in samples between the capture and the consumption
+	// with the slow consumer.
+	measure := func(size int) int64 {
+		var captured int64
+		r := Buffer(size)(ReaderFunc(func(samples [][2]float32) (int, error) {
+			time.Sleep(time.Millisecond)
+			for i := range samples {
+				samples[i][0] = float32(atomic.AddInt64(&captured, 1))
+			}
+			return len(samples), nil
+		}))
+
+		var total int64
+		const nReads = 20
+		buff := make([][2]float32, 1)
+		for i := 0; i < nReads; i++ {
+			if _, err := r.Read(buff); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if i >= nReads/2 {
+				// Measure after the buffer is filled
+				total += atomic.LoadInt64(&captured) - int64(buff[0][0])
+			}
+			time.Sleep(2 * time.Millisecond)
+		}
+		return total / (nReads / 2)
+	}
+
+	small, large := measure(4), measure(256)
+	if small >= large {
+		t.Errorf("Expected smaller buffer to have lower latency, got %d (size 4) and %d (size 256) samples", small, large)
+	}
+}
@@ -0,0 +1,119 @@
+package audio
+
+import (
+	"fmt"
+)
+
+// ChannelReader is a Reader which provides more than 2 channels.
+// Drivers of multi-channel devices return the Reader implementing it.
+// Read of the ChannelReader returns the first 2 channels.
+type ChannelReader interface {
+	Reader
+	// ChannelCount returns the number of the channels.
+	ChannelCount() int
+	// ReadChannels reads the interleaved samples of all channels.
+	// len(samples) must be a multiple of ChannelCount(), and n is the number
+	// of the samples per channel.
+	ReadChannels(samples []float32) (n int, err error)
+}
+
+type channelReader struct {
+	channels int
+	read     func(samples []float32) (int, error)
+	buff     []float32
+}
+
+// NewChannelReader creates a ChannelReader from the function reading the interleaved
+// samples of the given number of channels.
+func NewChannelReader(channels int, read func(samples []float32) (n int, err error)) ChannelReader {
+	return &channelReader{channels: channels, read: read}
+}
+
+func (r *channelReader) ChannelCount() int {
+	return r.channels
+}
+
+func (r *channelReader) ReadChannels(samples []float32) (int, error) {
+	return r.read(samples)
+}
+
+func (r *channelReader) Read(samples [][2]float32) (int, error) {
+	size := len(samples) * r.channels
+	if len(r.buff) < size {
+		r.buff = make([]float32, size)
+	}
+
+	n, err := r.read(r.buff[:size])
+	for i := 0; i < n; i++ {
+		samples[i][0] = r.buff[i*r.channels]
+		if r.channels > 1 {
+			samples[i][1] = r.buff[i*r.channels+1]
+		} else {
+			samples[i][1] = 0
+		}
+	}
+	return n, err
+}
+
+// MapChannels selects and reorders the channels of the source.
+// The first and the second channels of the output are taken from the source
+// channels at channelMap[0] and channelMap[1]. If channelMap has one element,
+// the output is mono and the second channel is silent. The source not
+// implementing ChannelReader is treated as stereo.
+func MapChannels(channelMap []int) TransformFunc {
+	return func(r Reader) Reader {
+		channels := 2
+		cr, ok := r.(ChannelReader)
+		if ok {
+			channels = cr.ChannelCount()
+		}
+
+		if err := validateChannelMap(channelMap, channels); err != nil {
+			return ReaderFunc(func(samples [][2]float32) (int, error) {
+				return 0, err
+			})
+		}
+
+		var buff []float32
+		return ReaderFunc(func(samples [][2]float32) (int, error) {
+			size := len(samples) * channels
+			if len(buff) < size {
+				buff = make([]float32, size)
+			}
+
+			var n int
+			var err error
+			if ok {
+				n, err = cr.ReadChannels(buff[:size])
+			} else {
+				n, err = r.Read(samples)
+				for i := 0; i < n; i++ {
+					buff[2*i], buff[2*i+1] = samples[i][0], samples[i][1]
+				}
+			}
+
+			for i := 0; i < n; i++ {
+				frame := buff[i*channels : (i+1)*channels]
+				samples[i][0] = frame[channelMap[0]]
+				if len(channelMap) > 1 {
+					samples[i][1] = frame[channelMap[1]]
+				} else {
+					samples[i][1] = 0
+				}
+			}
+			return n, err
+		})
+	}
+}
+
+func validateChannelMap(channelMap []int, channels int) error {
+	if len(channelMap) == 0 || len(channelMap) > 2 {
+		return fmt.Errorf("channel map must have 1 or 2 channels, got %d", len(channelMap))
+	}
+	for _, ch := range channelMap {
+		if ch < 0 || ch >= channels {
+			return fmt.Errorf("channel %d is out of range of %d channels", ch, channels)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package audio
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newQuadReader returns a 4-channel reader whose sample values are 10*frame+channel.
+func newQuadReader() ChannelReader {
+	var frame int
+	return NewChannelReader(4, func(samples []float32) (int, error) {
+		n := len(samples) / 4
+		for i := 0; i < n; i++ {
+			for ch := 0; ch < 4; ch++ {
+				samples[i*4+ch] = float32(10*frame + ch)
+			}
+			frame++
+		}
+		return n, nil
+	})
+}
+
+func TestChannelReader(t *testing.T) {
+	samples := make([][2]float32, 2)
+	if _, err := newQuadReader().Read(samples); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := [][2]float32{{0, 1}, {10, 11}}
+	if !reflect.DeepEqual(expected, samples) {
+		t.Errorf("Expected first 2 channels %v, got %v", expected, samples)
+	}
+}
+
+func TestMapChannels(t *testing.T) {
+	stereo := ReaderFunc(func(samples [][2]float32) (int, error) {
+		for i := range samples {
+			samples[i] = [2]float32{float32(10 * i), float32(10*i + 1)}
+		}
+		return len(samples), nil
+	})
+
+	cases := map[string]struct {
+		r          Reader
+		channelMap []int
+		expected   [][2]float32
+	}{
+		"QuadToStereo": {
+			r: newQuadReader(), channelMap: []int{2, 3},
+			expected: [][2]float32{{2, 3}, {12, 13}, {22, 23}},
+		},
+		"QuadToReorderedStereo": {
+			r: newQuadReader(), channelMap: []int{3, 1},
+			expected: [][2]float32{{3, 1}, {13, 11}, {23, 21}},
+		},
+		"QuadToMono": {
+			r: newQuadReader(), channelMap: []int{2},
+			expected: [][2]float32{{2, 0}, {12, 0}, {22, 0}},
+		},
+		"StereoSwap": {
+			r: stereo, channelMap: []int{1, 0},
+			expected: [][2]float32{{1, 0}, {11, 10}, {21, 20}},
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			samples := make([][2]float32, 3)
+			n, err := MapChannels(c.channelMap)(c.r).Read(samples)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(c.expected, samples[:n]) {
+				t.Errorf("Expected %v, got %v", c.expected, samples[:n])
+			}
+		})
+	}
+
+	for name, channelMap := range map[string][]int{
+		"OutOfRange":   {0, 4},
+		"Negative":     {-1},
+		"Empty":        {},
+		"TooManyItems": {0, 1, 2},
+	} {
+		channelMap := channelMap
+		t.Run(name, func(t *testing.T) {
+			if _, err := MapChannels(channelMap)(newQuadReader()).Read(make([][2]float32, 3)); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
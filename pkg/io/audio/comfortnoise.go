@@ -0,0 +1,37 @@
+package audio
+
+import (
+	"math/rand"
+)
+
+// ComfortNoise returns a transform which fills digital silence, where both
+// channels are exactly zero, with low-pass filtered noise whose amplitude
+// doesn't exceed level. Other samples are passed through as is. It's useful
+// after a noise gate or VAD which mutes the audio, since the absolute silence
+// sounds like a dropped call to the listener.
+func ComfortNoise(level float32) TransformFunc {
+	if level < 0 || level > 1 {
+		panic("ComfortNoise level must be in range of 0 to 1!")
+	}
+
+	return func(r Reader) Reader {
+		rnd := rand.New(rand.NewSource(1))
+		var lp [2]float32
+		return ReaderFunc(func(samples [][2]float32) (int, error) {
+			n, err := r.Read(samples)
+			for i := range samples[:n] {
+				// Keep the filter state running, so that the noise is continuous
+				// over the gaps in silence.
+				for ch := range lp {
+					lp[ch] += (2*rnd.Float32() - 1 - lp[ch]) / 2
+				}
+				if samples[i][0] != 0 || samples[i][1] != 0 {
+					continue
+				}
+				samples[i][0] = lp[0] * level
+				samples[i][1] = lp[1] * level
+			}
+			return n, err
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestComfortNoise(t *testing.T) {
+	const level = 0.01
+
+	src := ReaderFunc(func(samples [][2]float32) (int, error) {
+		for i := range samples {
+			if i < len(samples)/2 {
+				samples[i] = [2]float32{0.5, -0.5}
+			} else {
+				samples[i] = [2]float32{}
+			}
+		}
+		return len(samples), nil
+	})
+
+	r := ComfortNoise(level)(src)
+	samples := make([][2]float32, 960)
+	n, err := r.Read(samples)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n != len(samples) {
+		t.Fatalf("Expected %d samples, got %d", len(samples), n)
+	}
+
+	for i, s := range samples[:n/2] {
+		if s != [2]float32{0.5, -0.5} {
+			t.Fatalf("Expected non-silent sample %d to be passed through, got %v", i, s)
+		}
+	}
+
+	var sum float64
+	for i, s := range samples[n/2:] {
+		for _, v := range s {
+			if v == 0 {
+				t.Fatalf("Expected silent sample %d to be filled by noise", i)
+			}
+			if math.Abs(float64(v)) > level {
+				t.Fatalf("Expected noise amplitude under %f, got %f", level, v)
+			}
+			sum += float64(v) * float64(v)
+		}
+	}
+	rms := math.Sqrt(sum / float64(n))
+	if rms < level/10 {
+		t.Errorf("Expected noise RMS level close to %f, got %f", level, rms)
+	}
+}
@@ -0,0 +1,76 @@
+package audio
+
+import (
+	"time"
+)
+
+// Conceal returns a transform which conceals the gaps of the audio caused by
+// the stalls of the source, e.g. the hiccups of USB or Bluetooth microphones.
+// If the source returns the samples later than their duration at sampleRate by
+// more than maxDelay, the missing samples are substituted before the returned
+// samples, so that the timing of the stream is kept. The substitution is the
+// last samples faded out in 10ms followed by silence. onConceal is called with
+// the number of the substituted samples of each gap if it's not nil.
+func Conceal(sampleRate int, maxDelay time.Duration, onConceal func(n int)) TransformFunc {
+	return func(r Reader) Reader {
+		if sampleRate <= 0 {
+			return r
+		}
+
+		fadeSamples := sampleRate / 100
+		var last [][2]float32
+		var pending [][2]float32
+		var gap, concealed int
+
+		// conceal writes the substitution of the gap to samples
+		conceal := func(samples [][2]float32) int {
+			n := gap
+			if n > len(samples) {
+				n = len(samples)
+			}
+			for i := range samples[:n] {
+				samples[i] = [2]float32{}
+				if concealed < fadeSamples && len(last) > 0 {
+					gain := 1 - float32(concealed)/float32(fadeSamples)
+					s := last[concealed%len(last)]
+					samples[i] = [2]float32{s[0] * gain, s[1] * gain}
+				}
+				concealed++
+			}
+			gap -= n
+			return n
+		}
+
+		return ReaderFunc(func(samples [][2]float32) (int, error) {
+			if gap > 0 {
+				return conceal(samples), nil
+			}
+			if len(pending) > 0 {
+				n := copy(samples, pending)
+				pending = pending[n:]
+				return n, nil
+			}
+
+			start := time.Now()
+			n, err := r.Read(samples)
+			if n == 0 || err != nil {
+				return n, err
+			}
+
+			duration := time.Duration(n) * time.Second / time.Duration(sampleRate)
+			delay := time.Since(start) - duration
+			if missing := int(delay.Seconds() * float64(sampleRate)); delay > maxDelay && missing > 0 {
+				// The samples read are returned after the substitution
+				pending = append(pending[:0], samples[:n]...)
+				gap, concealed = missing, 0
+				if onConceal != nil {
+					onConceal(gap)
+				}
+				return conceal(samples), nil
+			}
+
+			last = append(last[:0], samples[:n]...)
+			return n, nil
+		})
+	}
+}
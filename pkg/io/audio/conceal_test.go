@@ -0,0 +1,68 @@
+package audio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConceal(t *testing.T) {
+	const sampleRate = 1000
+
+	var reads int
+	src := ReaderFunc(func(samples [][2]float32) (int, error) {
+		reads++
+		n := 10
+		if reads == 3 {
+			// Stall 100ms on the third read
+			time.Sleep(100 * time.Millisecond)
+		}
+		for i := range samples[:n] {
+			samples[i] = [2]float32{float32(reads), -float32(reads)}
+		}
+		return n, nil
+	})
+
+	var gaps []int
+	r := Conceal(sampleRate, 20*time.Millisecond, func(n int) {
+		gaps = append(gaps, n)
+	})(src)
+
+	var out [][2]float32
+	buff := make([][2]float32, 32)
+	for reads < 4 || len(out) < 40 {
+		n, err := r.Read(buff)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		out = append(out, buff[:n]...)
+	}
+
+	if len(gaps) != 1 {
+		t.Fatalf("Expected a gap, got %v", gaps)
+	}
+	// The gap is the stall minus the duration of the samples
+	if gaps[0] < 80 || 150 < gaps[0] {
+		t.Errorf("Expected about 90 concealed samples, got %d", gaps[0])
+	}
+	if len(out) != 40+gaps[0] {
+		t.Fatalf("Expected %d samples, got %d", 40+gaps[0], len(out))
+	}
+
+	gap := out[20 : 20+gaps[0]]
+	// The last samples before the gap are faded out in 10ms, then silence
+	if gap[0] != [2]float32{2, -2} {
+		t.Errorf("Expected the gap to start from the last sample, got %v", gap[0])
+	}
+	if gap[5][0] >= 2 || gap[5][0] <= 0 {
+		t.Errorf("Expected the faded sample, got %v", gap[5])
+	}
+	for i, s := range gap[10:] {
+		if s != [2]float32{} {
+			t.Fatalf("Expected silence at %d, got %v", 10+i, s)
+		}
+	}
+	// The samples read after the stall follow the gap
+	if s := out[20+gaps[0]]; s != [2]float32{3, -3} {
+		t.Errorf("Expected the samples after the gap, got %v", s)
+	}
+}
@@ -0,0 +1,102 @@
+package audio
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// driftWindow is the minimum duration of the timestamps to estimate the
+	// clock rate of the device. The samples are passed through until then.
+	driftWindow = time.Second
+	// maxDrift is the maximum ratio of the clock drift to be corrected. Larger
+	// deviation is treated as the error of the timestamps.
+	maxDrift = 0.01
+)
+
+// DriftCorrection returns audio transform which resamples the samples of a
+// TimestampReader, so that sampleRate samples are output per second of the
+// timestamps. The clock of a sound card drifts from the system clock, e.g. by
+// 0.1%, which makes the audio out of sync with the video over long calls. The
+// clock rate of the device is estimated from the number of the samples read
+// since the first timestamp. The reader is returned as is if it's not a
+// TimestampReader. The last sample of the source is dropped since it can't be
+// interpolated.
+func DriftCorrection(sampleRate int) TransformFunc {
+	return func(r Reader) Reader {
+		tr, ok := r.(TimestampReader)
+		if !ok || sampleRate <= 0 {
+			return r
+		}
+
+		// step is the ratio of the device clock rate to sampleRate
+		step := 1.0
+		var started bool
+		var tStart time.Time
+		// total is the number of the samples read from the source
+		var total int64
+		// buff holds the source samples from the one at floor(pos)
+		var buff [][2]float32
+		var chunk [][2]float32
+		var pos float64
+		var readErr error
+
+		estimate := func(n int, timestamp time.Time) {
+			if n == 0 || timestamp.IsZero() {
+				return
+			}
+			if !started {
+				started = true
+				tStart = timestamp.Add(-time.Duration(total) * time.Second / time.Duration(sampleRate))
+				return
+			}
+			elapsed := timestamp.Sub(tStart)
+			if elapsed < driftWindow {
+				return
+			}
+			ratio := float64(total) / elapsed.Seconds() / float64(sampleRate)
+			step = math.Max(1-maxDrift, math.Min(1+maxDrift, ratio))
+		}
+
+		return ReaderFunc(func(samples [][2]float32) (int, error) {
+			var n int
+			for ; n < len(samples); n++ {
+				i := int(pos)
+				for i+1 >= len(buff) {
+					if readErr != nil {
+						if n > 0 {
+							return n, nil
+						}
+						return 0, readErr
+					}
+
+					// Read enough samples for the rest of the request
+					size := int(float64(len(samples)-n)*step) + 2
+					if len(chunk) < size {
+						chunk = make([][2]float32, size)
+					}
+					m, timestamp, err := tr.ReadTimestamp(chunk[:size])
+					estimate(m, timestamp)
+					total += int64(m)
+					buff = append(buff, chunk[:m]...)
+					readErr = err
+				}
+
+				frac := float32(pos - float64(i))
+				s0, s1 := buff[i], buff[i+1]
+				samples[n][0] = s0[0] + (s1[0]-s0[0])*frac
+				samples[n][1] = s0[1] + (s1[1]-s0[1])*frac
+				pos += step
+			}
+
+			// Discard the consumed samples
+			consumed := int(pos)
+			if consumed > len(buff) {
+				consumed = len(buff)
+			}
+			buff = buff[:copy(buff, buff[consumed:])]
+			pos -= float64(consumed)
+			return n, nil
+		})
+	}
+}
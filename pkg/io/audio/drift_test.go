@@ -0,0 +1,64 @@
+package audio
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDriftCorrection(t *testing.T) {
+	const (
+		sampleRate = 48000
+		// The clock of the device is 0.1% faster than the system clock
+		deviceRate = sampleRate * 1.001
+		duration   = 60 * time.Second
+	)
+
+	// The value of each sample is its index to find the capture time of the
+	// output samples. Each read is stamped by the system clock.
+	tStart := time.Now()
+	var index int
+	src := TimestampReaderFunc(func(samples [][2]float32) (int, time.Time, error) {
+		timestamp := tStart.Add(time.Duration(float64(index) / deviceRate * float64(time.Second)))
+		for i := range samples {
+			samples[i] = [2]float32{float32(index), float32(index)}
+			index++
+		}
+		return len(samples), timestamp, nil
+	})
+
+	r := DriftCorrection(sampleRate)(src)
+	buf := make([][2]float32, sampleRate/50)
+	var out int
+	for out < int(duration.Seconds()*sampleRate) {
+		n, err := r.Read(buf)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for _, s := range buf[:n] {
+			// Video frames are stamped by the system clock, so the audio is
+			// aligned if the output sample is played at its capture time.
+			captured := time.Duration(float64(s[0]) / deviceRate * float64(time.Second))
+			played := time.Duration(out) * time.Second / sampleRate
+			if diff := captured - played; out%sampleRate == 0 && math.Abs(diff.Seconds()) > 0.002 {
+				t.Errorf("Expected the sample played at %v to be captured at the same time, got %v", played, captured)
+			}
+			out++
+		}
+	}
+
+	// Without the correction, the audio would be 60ms behind the video
+	if drift := float64(index)/deviceRate - duration.Seconds(); math.Abs(drift) > 0.005 {
+		t.Errorf("Expected %v of the source samples to be read, got %.3fs more", duration, drift)
+	}
+}
+
+func TestDriftCorrection_NoTimestamp(t *testing.T) {
+	src := ReaderFunc(func(samples [][2]float32) (int, error) {
+		return len(samples), nil
+	})
+	r := DriftCorrection(48000)(src)
+	if _, ok := r.(ReaderFunc); !ok {
+		t.Errorf("Expected the reader without timestamps to be returned as is, got %T", r)
+	}
+}
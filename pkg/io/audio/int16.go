@@ -0,0 +1,80 @@
+package audio
+
+// Int16Reader is a Reader which can also provide the samples as int16. Drivers
+// capturing int16 samples return it to avoid the conversion for the encoders
+// consuming int16, and the encoders prefer ReadInt16 if available.
+type Int16Reader interface {
+	Reader
+	ReadInt16(samples [][2]int16) (n int, err error)
+}
+
+// Int16ReaderFunc is a proxy type for Int16Reader. Read converts the samples
+// to float32.
+type Int16ReaderFunc func(samples [][2]int16) (n int, err error)
+
+func (rf Int16ReaderFunc) ReadInt16(samples [][2]int16) (int, error) {
+	return rf(samples)
+}
+
+func (rf Int16ReaderFunc) Read(samples [][2]float32) (int, error) {
+	buff := make([][2]int16, len(samples))
+	n, err := rf(buff)
+	for i := 0; i < n; i++ {
+		samples[i][0] = Int16ToFloat32(buff[i][0])
+		samples[i][1] = Int16ToFloat32(buff[i][1])
+	}
+	return n, err
+}
+
+// Int16ToFloat32 converts an int16 sample to float32 in range of -1.0 to 1.0.
+func Int16ToFloat32(v int16) float32 {
+	return float32(v) / 0x8000
+}
+
+// Float32ToInt16 converts a float32 sample to int16. The sample is rounded to
+// the nearest value and clipped if it's out of range of -1.0 to 1.0.
+func Float32ToInt16(v float32) int16 {
+	s := v * 0x8000
+	switch {
+	case s >= 0x7FFF:
+		return 0x7FFF
+	case s <= -0x8000:
+		return -0x8000
+	case s < 0:
+		return int16(s - 0.5)
+	default:
+		return int16(s + 0.5)
+	}
+}
+
+// ToInt16 converts r to an Int16Reader. r is returned as is if it's already
+// an Int16Reader. Otherwise, the samples are read as float32 and converted.
+func ToInt16(r Reader) Int16Reader {
+	if ir, ok := r.(Int16Reader); ok {
+		return ir
+	}
+
+	return &int16Reader{r: r}
+}
+
+type int16Reader struct {
+	r    Reader
+	buff [][2]float32
+}
+
+func (r *int16Reader) Read(samples [][2]float32) (int, error) {
+	return r.r.Read(samples)
+}
+
+func (r *int16Reader) ReadInt16(samples [][2]int16) (int, error) {
+	if len(r.buff) < len(samples) {
+		r.buff = make([][2]float32, len(samples))
+	}
+
+	n, err := r.r.Read(r.buff[:len(samples)])
+	for i := 0; i < n; i++ {
+		samples[i][0] = Float32ToInt16(r.buff[i][0])
+		samples[i][1] = Float32ToInt16(r.buff[i][1])
+	}
+	return n, err
+}
@@ -0,0 +1,83 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestInt16RoundTrip(t *testing.T) {
+	t.Run("Int16", func(t *testing.T) {
+		for _, v := range []int16{-0x8000, -0x4000, -1, 0, 1, 0x1234, 0x7FFF} {
+			if got := Float32ToInt16(Int16ToFloat32(v)); got != v {
+				t.Errorf("Expected %d, got %d", v, got)
+			}
+		}
+	})
+	t.Run("Float32", func(t *testing.T) {
+		// The quantization error is up to a half step
+		const maxErr = 0.5 / 0x8000
+		for _, v := range []float32{-1, -0.5, -0.12345, 0, 0.00001, 0.3333, 0.9999} {
+			got := Int16ToFloat32(Float32ToInt16(v))
+			if diff := math.Abs(float64(got - v)); diff > maxErr {
+				t.Errorf("Expected %f within %g, got %f", v, maxErr, got)
+			}
+		}
+	})
+	t.Run("Clip", func(t *testing.T) {
+		if v := Float32ToInt16(1.5); v != 0x7FFF {
+			t.Errorf("Expected %d, got %d", 0x7FFF, v)
+		}
+		if v := Float32ToInt16(-1.5); v != -0x8000 {
+			t.Errorf("Expected %d, got %d", -0x8000, v)
+		}
+	})
+}
+
+func TestToInt16(t *testing.T) {
+	t.Run("Int16Reader", func(t *testing.T) {
+		r := Int16ReaderFunc(func(samples [][2]int16) (int, error) {
+			return len(samples), nil
+		})
+		if _, ok := ToInt16(r).(Int16ReaderFunc); !ok {
+			t.Error("Int16Reader must be returned as is")
+		}
+	})
+	t.Run("Reader", func(t *testing.T) {
+		r := ToInt16(ReaderFunc(func(samples [][2]float32) (int, error) {
+			for i := range samples {
+				samples[i] = [2]float32{0.5, -0.25}
+			}
+			return len(samples), nil
+		}))
+		samples := make([][2]int16, 4)
+		n, err := r.ReadInt16(samples)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if n != len(samples) {
+			t.Fatalf("Expected %d samples, got %d", len(samples), n)
+		}
+		for _, s := range samples {
+			if s != [2]int16{0x4000, -0x2000} {
+				t.Errorf("Expected %v, got %v", [2]int16{0x4000, -0x2000}, s)
+			}
+		}
+	})
+	t.Run("Float32", func(t *testing.T) {
+		r := Int16ReaderFunc(func(samples [][2]int16) (int, error) {
+			for i := range samples {
+				samples[i] = [2]int16{0x4000, -0x8000}
+			}
+			return len(samples), nil
+		})
+		samples := make([][2]float32, 2)
+		if _, err := r.Read(samples); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for _, s := range samples {
+			if s != [2]float32{0.5, -1} {
+				t.Errorf("Expected %v, got %v", [2]float32{0.5, -1}, s)
+			}
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package audio
+
+import (
+	"math"
+	"sync"
+)
+
+// Level returns a transform which passes samples through as is and reports
+// the RMS level of every window samples to fn. The level is calculated over
+// the first channels channels, 1 or 2, and is 1.0 for a full scale square
+// wave. fn is called in a separate goroutine; if fn is still processing a
+// previous level, only the latest level is kept and the others are dropped, so
+// a slow fn never stalls the downstream. The goroutine exits when no level is
+// left, so it doesn't outlive the reader.
+func Level(channels, window int, fn func(rms float64)) TransformFunc {
+	if channels != 1 && channels != 2 {
+		panic("Level channels must be 1 or 2!")
+	}
+	if window <= 0 {
+		panic("Level window must be positive!")
+	}
+
+	return func(r Reader) Reader {
+		var mu sync.Mutex
+		var latest float64
+		var pending, running bool
+		report := func() {
+			for {
+				mu.Lock()
+				if !pending {
+					running = false
+					mu.Unlock()
+					return
+				}
+				rms := latest
+				pending = false
+				mu.Unlock()
+				fn(rms)
+			}
+		}
+
+		var sum float64
+		var cnt int
+		return ReaderFunc(func(samples [][2]float32) (int, error) {
+			n, err := r.Read(samples)
+			for _, s := range samples[:n] {
+				for _, v := range s[:channels] {
+					sum += float64(v) * float64(v)
+				}
+				cnt++
+				if cnt == window {
+					mu.Lock()
+					latest, pending = math.Sqrt(sum/float64(channels*window)), true
+					if !running {
+						running = true
+						go report()
+					}
+					mu.Unlock()
+					sum, cnt = 0, 0
+				}
+			}
+			return n, err
+		})
+	}
+}
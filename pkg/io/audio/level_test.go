@@ -0,0 +1,92 @@
+package audio
+
+import (
+	"io"
+	"math"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestLevel(t *testing.T) {
+	const (
+		sampleRate = 48000
+		amplitude  = 0.5
+		frequency  = 1000
+	)
+
+	for name, channels := range map[string]int{"Mono": 1, "Stereo": 2} {
+		channels := channels
+		t.Run(name, func(t *testing.T) {
+			var pos int
+			src := ReaderFunc(func(samples [][2]float32) (int, error) {
+				if pos >= sampleRate {
+					return 0, io.EOF
+				}
+				for i := range samples {
+					v := float32(amplitude * math.Sin(2*math.Pi*frequency*float64(pos)/sampleRate))
+					samples[i] = [2]float32{v, 0}
+					if channels == 2 {
+						samples[i][1] = v
+					}
+					pos++
+				}
+				return len(samples), nil
+			})
+
+			levels := make(chan float64, 100)
+			r := Level(channels, sampleRate/10, func(rms float64) {
+				levels <- rms
+			})(src)
+
+			buf := make([][2]float32, sampleRate/50)
+			for {
+				_, err := r.Read(buf)
+				if err == io.EOF {
+					break
+				}
+				// Give enough time to the callback
+				time.Sleep(time.Millisecond)
+			}
+
+			expected := amplitude / math.Sqrt2
+			var cnt int
+			timeout := time.After(time.Second)
+			for cnt < 10 {
+				select {
+				case rms := <-levels:
+					if math.Abs(rms-expected) > 0.01 {
+						t.Errorf("Expected RMS level %f, got %f", expected, rms)
+					}
+					cnt++
+				case <-timeout:
+					t.Fatalf("Expected 10 levels, got %d", cnt)
+				}
+			}
+		})
+	}
+}
+
+func TestLevelGoroutine(t *testing.T) {
+	n := runtime.NumGoroutine()
+
+	// The source is never ended by an error, like a stopped track
+	r := Level(2, 10, func(float64) {
+		time.Sleep(time.Millisecond)
+	})(ReaderFunc(func(samples [][2]float32) (int, error) {
+		return len(samples), nil
+	}))
+	buf := make([][2]float32, 100)
+	for i := 0; i < 10; i++ {
+		if _, err := r.Read(buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; runtime.NumGoroutine() > n; i++ {
+		if i >= 100 {
+			t.Fatalf("Expected the goroutine of the callback to exit, got %d goroutines", runtime.NumGoroutine()-n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,120 @@
+package audio
+
+import (
+	"math"
+)
+
+const (
+	// limiterLookahead is the number of the samples by which the limiter
+	// delays the audio to lower the gain before the peaks. It's 5ms at 48kHz.
+	limiterLookahead = 240
+	// limiterRelease is the time constant in samples to restore the gain
+	// after the peaks. It's 50ms at 48kHz.
+	limiterRelease = 2400
+	// limiterKneeDB is the width of the soft knee around the ceiling.
+	limiterKneeDB = 6
+)
+
+// Limiter returns a transform which keeps the peaks of the audio under ceilingDB
+// in dBFS, e.g. to protect the encoder input from the distortion of the loud
+// transients. 0dBFS is the full scale, the same as AGC. Unlike clipping, the gain
+// is lowered smoothly before the peaks by looking ahead, so the waveform is not
+// distorted. The gain starts to be lowered from limiterKneeDB/2 below the ceiling.
+// The audio is delayed by the lookahead of 240 samples.
+func Limiter(ceilingDB float64) TransformFunc {
+	if ceilingDB > 0 {
+		panic("Limiter ceiling must not be above 0dBFS!")
+	}
+	release := 1 - math.Exp(-1.0/limiterRelease)
+
+	return func(r Reader) Reader {
+		// delayed samples and their desired gains
+		var delay [limiterLookahead][2]float32
+		var delayGains [limiterLookahead]float64
+		for i := range delayGains {
+			delayGains[i] = 1
+		}
+		// minimums of the desired gains for the moving average
+		var mins [limiterLookahead]float64
+		for i := range mins {
+			mins[i] = 1
+		}
+		sum := float64(limiterLookahead)
+		minGains := &slidingMin{}
+		gain := 1.0
+		var pos, cnt int
+
+		return ReaderFunc(func(samples [][2]float32) (int, error) {
+			n, err := r.Read(samples)
+			for i := range samples[:n] {
+				peak := math.Max(math.Abs(float64(samples[i][0])), math.Abs(float64(samples[i][1])))
+				desired := limiterGain(peak, ceilingDB)
+
+				// The moving average of the minimums over the lookahead is lower
+				// than the desired gain of the delayed sample, and it changes
+				// smoothly while the minimum steps.
+				m := minGains.push(cnt, desired, limiterLookahead+1)
+				sum += m - mins[pos]
+				mins[pos] = m
+				avg := sum / limiterLookahead
+				if avg < gain {
+					gain = avg
+				} else {
+					gain += (avg - gain) * release
+				}
+
+				s, g := delay[pos], delayGains[pos]
+				delay[pos], delayGains[pos] = samples[i], desired
+				pos = (pos + 1) % limiterLookahead
+				cnt++
+
+				// Guard against the rounding error of the moving average
+				g = math.Min(gain, g)
+				samples[i] = [2]float32{float32(float64(s[0]) * g), float32(float64(s[1]) * g)}
+			}
+			return n, err
+		})
+	}
+}
+
+// limiterGain returns the gain to limit the peak by the soft knee curve.
+func limiterGain(peak, ceilingDB float64) float64 {
+	if peak == 0 {
+		return 1
+	}
+	level := 20 * math.Log10(peak)
+	var out float64
+	switch over := level - ceilingDB; {
+	case over < -limiterKneeDB/2:
+		return 1
+	case over > limiterKneeDB/2:
+		out = ceilingDB
+	default:
+		// Quadratic curve connecting the slopes of 1 and 0, which doesn't exceed the ceiling
+		d := over + limiterKneeDB/2
+		out = level - d*d/(2*limiterKneeDB)
+	}
+	return math.Min(dbToAmplitude(out-level), 1)
+}
+
+// slidingMin calculates the minimum of the values in a sliding window.
+type slidingMin struct {
+	// indices and values of the candidates of the minimum in ascending order
+	indices []int
+	values  []float64
+}
+
+// push adds the value at index i and returns the minimum of the last window values.
+func (m *slidingMin) push(i int, v float64, window int) float64 {
+	for len(m.values) > 0 && m.values[len(m.values)-1] >= v {
+		m.indices = m.indices[:len(m.indices)-1]
+		m.values = m.values[:len(m.values)-1]
+	}
+	m.indices = append(m.indices, i)
+	m.values = append(m.values, v)
+	if m.indices[0] <= i-window {
+		m.indices = m.indices[1:]
+		m.values = m.values[1:]
+	}
+	return m.values[0]
+}
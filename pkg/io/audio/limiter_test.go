@@ -0,0 +1,121 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLimiter(t *testing.T) {
+	const (
+		sampleRate = 48000
+		frequency  = 1000
+		ceilingDB  = -3
+	)
+	ceiling := float32(dbToAmplitude(ceilingDB))
+
+	sine := func(amplitude float64) Reader {
+		var pos int
+		return ReaderFunc(func(samples [][2]float32) (int, error) {
+			for i := range samples {
+				v := float32(amplitude * math.Sin(2*math.Pi*frequency*float64(pos)/sampleRate))
+				samples[i] = [2]float32{v, v}
+				pos++
+			}
+			return len(samples), nil
+		})
+	}
+
+	// thd returns the ratio of the RMS of the components other than the
+	// fundamental to the RMS of the fundamental, as a proxy of THD.
+	thd := func(samples [][2]float32) float64 {
+		var re, im, total float64
+		for i, s := range samples {
+			v := float64(s[0])
+			phase := 2 * math.Pi * frequency * float64(i) / sampleRate
+			re += v * math.Cos(phase)
+			im += v * math.Sin(phase)
+			total += v * v
+		}
+		fundamental := 2 * (re*re + im*im) / float64(len(samples))
+		return math.Sqrt(math.Max(total-fundamental, 0) / fundamental)
+	}
+
+	t.Run("OverCeiling", func(t *testing.T) {
+		// +6dBFS transient after a quiet part
+		var pos int
+		loud, quiet := sine(2), sine(0.1)
+		src := ReaderFunc(func(samples [][2]float32) (int, error) {
+			for i := range samples {
+				s := [][2]float32{{}}
+				if pos < sampleRate/2 {
+					quiet.Read(s)
+				} else {
+					loud.Read(s)
+				}
+				samples[i] = s[0]
+				pos++
+			}
+			return len(samples), nil
+		})
+
+		r := Limiter(ceilingDB)(src)
+		buf := make([][2]float32, sampleRate/10)
+		for i := 0; i < 20; i++ {
+			if _, err := r.Read(buf); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for _, s := range buf {
+				if math.Abs(float64(s[0])) > float64(ceiling) || math.Abs(float64(s[1])) > float64(ceiling) {
+					t.Fatalf("Expected the peaks under %.3f at %dms, got %v", ceiling, i*100, s)
+				}
+			}
+		}
+
+		// 1 second after the transient, the gain is stable
+		if d := thd(buf); d > 0.01 {
+			t.Errorf("Expected THD under 1%%, got %.2f%%", d*100)
+		}
+		var peak float32
+		for _, s := range buf {
+			if s[0] > peak {
+				peak = s[0]
+			}
+		}
+		if peak < ceiling*0.9 {
+			t.Errorf("Expected the peak close to %.3f, got %.3f", ceiling, peak)
+		}
+
+		// Hard clipping the same signal is much more distorted
+		clipped := make([][2]float32, len(buf))
+		loud.Read(clipped)
+		for i := range clipped {
+			v := float32(math.Max(-float64(ceiling), math.Min(float64(ceiling), float64(clipped[i][0]))))
+			clipped[i] = [2]float32{v, v}
+		}
+		if d := thd(clipped); d < 0.1 {
+			t.Errorf("Expected THD of the hard clip over 10%%, got %.2f%%", d*100)
+		}
+	})
+	t.Run("UnderCeiling", func(t *testing.T) {
+		// Samples under the knee are passed through with the delay
+		in := make([][2]float32, sampleRate/10)
+		sine(0.3).Read(in)
+		src := ReaderFunc(func(samples [][2]float32) (int, error) {
+			return copy(samples, in), nil
+		})
+
+		out := make([][2]float32, len(in))
+		if _, err := Limiter(ceilingDB)(src).Read(out); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for i := range out {
+			var expected [2]float32
+			if i >= limiterLookahead {
+				expected = in[i-limiterLookahead]
+			}
+			if out[i] != expected {
+				t.Fatalf("Expected %v at %d, got %v", expected, i, out[i])
+			}
+		}
+	})
+}
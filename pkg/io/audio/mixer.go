@@ -0,0 +1,107 @@
+package audio
+
+import (
+	"io"
+)
+
+// MixerSource is a source of Mixer.
+type MixerSource struct {
+	Reader Reader
+	// SampleRate of the source. The source is resampled to the rate of the
+	// mixer if it's different. 0 means same as the mixer.
+	SampleRate int
+	// Gain is the linear gain applied to the source before mixing.
+	// 0 means 1.0.
+	Gain float32
+}
+
+// Mixer is a Reader summing the samples of multiple sources, e.g. a microphone
+// and the system audio. The sources ended by io.EOF are removed and the others
+// keep being mixed. Read returns io.EOF after all sources are ended.
+type Mixer struct {
+	sources []*mixerSource
+}
+
+type mixerSource struct {
+	r    Reader
+	gain float32
+	buff [][2]float32
+}
+
+// NewMixer creates a Mixer producing the samples at sampleRate.
+func NewMixer(sampleRate int, sources ...MixerSource) *Mixer {
+	m := &Mixer{}
+	for _, s := range sources {
+		r := s.Reader
+		if s.SampleRate != 0 {
+			r = Resample(s.SampleRate, sampleRate)(r)
+		}
+		gain := s.Gain
+		if gain == 0 {
+			gain = 1
+		}
+		m.sources = append(m.sources, &mixerSource{r: r, gain: gain})
+	}
+	return m
+}
+
+// Read reads len(samples) samples from each source and mixes them. The sources
+// shorter than the others are padded with silence. The mixed samples are clipped
+// to [-1.0, 1.0]. Errors other than io.EOF are returned as is.
+func (m *Mixer) Read(samples [][2]float32) (int, error) {
+	if len(m.sources) == 0 {
+		return 0, io.EOF
+	}
+
+	for i := range samples {
+		samples[i] = [2]float32{}
+	}
+
+	var n int
+	var active []*mixerSource
+	for _, s := range m.sources {
+		read, err := s.read(len(samples))
+		for i, v := range s.buff[:read] {
+			samples[i][0] += v[0] * s.gain
+			samples[i][1] += v[1] * s.gain
+		}
+		if read > n {
+			n = read
+		}
+
+		switch err {
+		case nil:
+			active = append(active, s)
+		case io.EOF:
+		default:
+			return 0, err
+		}
+	}
+	m.sources = active
+
+	for i := range samples[:n] {
+		samples[i][0] = clip(float64(samples[i][0]))
+		samples[i][1] = clip(float64(samples[i][1]))
+	}
+	if n == 0 && len(m.sources) == 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// read reads up to size samples into the buffer of the source.
+func (s *mixerSource) read(size int) (int, error) {
+	if len(s.buff) < size {
+		s.buff = make([][2]float32, size)
+	}
+
+	var n int
+	for n < size {
+		read, err := s.r.Read(s.buff[n:size])
+		n += read
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,126 @@
+package audio
+
+import (
+	"io"
+	"math"
+	"testing"
+)
+
+// tone returns a reader of the sine wave ending after n samples. n < 0 means infinite.
+func tone(frequency float64, sampleRate int, amplitude float32, n int) Reader {
+	var i int
+	return ReaderFunc(func(samples [][2]float32) (int, error) {
+		if i == n {
+			return 0, io.EOF
+		}
+		var read int
+		for ; read < len(samples) && i != n; read++ {
+			v := amplitude * float32(math.Sin(2*math.Pi*frequency*float64(i)/float64(sampleRate)))
+			samples[read] = [2]float32{v, v}
+			i++
+		}
+		return read, nil
+	})
+}
+
+func TestMixer(t *testing.T) {
+	const sampleRate = 48000
+
+	t.Run("Sum", func(t *testing.T) {
+		m := NewMixer(sampleRate,
+			MixerSource{Reader: tone(440, sampleRate, 0.25, -1)},
+			MixerSource{Reader: tone(1000, sampleRate, 0.5, -1), Gain: 0.5},
+		)
+		a, b := tone(440, sampleRate, 0.25, -1), tone(1000, sampleRate, 0.25, -1)
+
+		samples := make([][2]float32, 480)
+		expectedA := make([][2]float32, 480)
+		expectedB := make([][2]float32, 480)
+		for j := 0; j < 3; j++ {
+			n, err := m.Read(samples)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if n != len(samples) {
+				t.Fatalf("Expected %d samples, got %d", len(samples), n)
+			}
+			a.Read(expectedA)
+			b.Read(expectedB)
+			for i := range samples {
+				expected := expectedA[i][0] + expectedB[i][0]
+				if d := samples[i][0] - expected; d < -1e-6 || 1e-6 < d || samples[i][1] != samples[i][0] {
+					t.Fatalf("Expected sample %d to be %v, got %v", i, expected, samples[i])
+				}
+			}
+		}
+	})
+	t.Run("Resample", func(t *testing.T) {
+		// Constant source at the half rate
+		m := NewMixer(sampleRate,
+			MixerSource{Reader: tone(440, sampleRate, 0.25, -1)},
+			MixerSource{Reader: ReaderFunc(func(samples [][2]float32) (int, error) {
+				for i := range samples {
+					samples[i] = [2]float32{0.5, -0.5}
+				}
+				return len(samples), nil
+			}), SampleRate: sampleRate / 2},
+		)
+		a := tone(440, sampleRate, 0.25, -1)
+
+		samples := make([][2]float32, 480)
+		expected := make([][2]float32, 480)
+		if _, err := m.Read(samples); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		a.Read(expected)
+		for i := range samples {
+			l, r := expected[i][0]+0.5, expected[i][1]-0.5
+			if dl, dr := samples[i][0]-l, samples[i][1]-r; dl < -1e-6 || 1e-6 < dl || dr < -1e-6 || 1e-6 < dr {
+				t.Fatalf("Expected sample %d to be %v, got %v", i, [2]float32{l, r}, samples[i])
+			}
+		}
+	})
+	t.Run("SourceEnded", func(t *testing.T) {
+		m := NewMixer(sampleRate,
+			MixerSource{Reader: tone(440, sampleRate, 0.25, 100)},
+			MixerSource{Reader: tone(1000, sampleRate, 0.25, 250)},
+		)
+		samples := make([][2]float32, 200)
+		expected := []struct {
+			n   int
+			err error
+		}{
+			{200, nil},
+			{50, nil},
+			{0, io.EOF},
+		}
+		for j, e := range expected {
+			n, err := m.Read(samples)
+			if n != e.n || err != e.err {
+				t.Fatalf("Read %d: Expected (%d, %v), got (%d, %v)", j, e.n, e.err, n, err)
+			}
+		}
+	})
+	t.Run("Clip", func(t *testing.T) {
+		m := NewMixer(sampleRate,
+			MixerSource{Reader: tone(440, sampleRate, 1, -1), Gain: 2},
+			MixerSource{Reader: tone(440, sampleRate, 1, -1), Gain: 2},
+		)
+		samples := make([][2]float32, 480)
+		if _, err := m.Read(samples); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var clipped int
+		for i, s := range samples {
+			if s[0] < -1 || 1 < s[0] {
+				t.Fatalf("Expected sample %d to be clipped, got %v", i, s)
+			}
+			if s[0] == 1 || s[0] == -1 {
+				clipped++
+			}
+		}
+		if clipped == 0 {
+			t.Error("Expected the peaks to be clipped")
+		}
+	})
+}
@@ -0,0 +1,17 @@
+package audio
+
+// Mute returns a transform which replaces the samples with silence while mute
+// returns true, e.g. to mute the audio without stopping the stream.
+func Mute(mute func() bool) TransformFunc {
+	return func(r Reader) Reader {
+		return ReaderFunc(func(samples [][2]float32) (int, error) {
+			n, err := r.Read(samples)
+			if mute() {
+				for i := range samples[:n] {
+					samples[i] = [2]float32{}
+				}
+			}
+			return n, err
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package audio
+
+import (
+	"testing"
+)
+
+func TestMute(t *testing.T) {
+	src := ReaderFunc(func(samples [][2]float32) (int, error) {
+		for i := range samples {
+			samples[i] = [2]float32{0.5, -0.5}
+		}
+		return len(samples), nil
+	})
+
+	var mute bool
+	r := Mute(func() bool { return mute })(src)
+	for _, m := range []bool{false, true, false} {
+		mute = m
+		samples := make([][2]float32, 16)
+		n, err := r.Read(samples)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if n != len(samples) {
+			t.Fatalf("Expected %d samples, got %d", len(samples), n)
+		}
+		expected := [2]float32{0.5, -0.5}
+		if mute {
+			expected = [2]float32{}
+		}
+		for _, s := range samples[:n] {
+			if s != expected {
+				t.Fatalf("Expected %v while mute is %v, got %v", expected, mute, s)
+			}
+		}
+	}
+}
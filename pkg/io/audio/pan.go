@@ -0,0 +1,78 @@
+package audio
+
+import (
+	"math"
+)
+
+// Pan returns a transform which adjusts the left/right balance of the stereo
+// audio. position is in range of -1 (hard left) to 1 (hard right), and 0
+// passes the audio through as is. The channel on the opposite side of the
+// position is attenuated linearly, e.g. the right channel is muted at -1.
+func Pan(position float64) TransformFunc {
+	if position < -1 || position > 1 {
+		panic("Pan position must be in range of -1 to 1!")
+	}
+
+	gain := [2]float32{1, 1}
+	if position > 0 {
+		gain[0] = float32(1 - position)
+	} else {
+		gain[1] = float32(1 + position)
+	}
+
+	return func(r Reader) Reader {
+		return ReaderFunc(func(samples [][2]float32) (int, error) {
+			n, err := r.Read(samples)
+			for i := range samples[:n] {
+				samples[i][0] *= gain[0]
+				samples[i][1] *= gain[1]
+			}
+			return n, err
+		})
+	}
+}
+
+// maxInterauralDelay is the delay of the sound reaching the far ear from a
+// source at the side of the listener.
+const maxInterauralDelay = 0.00066
+
+// Position returns a transform which places the mono source at position in
+// range of -1 (left) to 1 (right) of the stereo image. The channels of the
+// source are mixed down to mono, and distributed with the constant power pan
+// law. As a simple approximation of HRTF, the channel of the far ear is
+// delayed up to 0.66ms by the interaural time difference at sampleRate.
+func Position(sampleRate int, position float64) TransformFunc {
+	if position < -1 || position > 1 {
+		panic("Position position must be in range of -1 to 1!")
+	}
+
+	angle := (position + 1) * math.Pi / 4
+	gain := [2]float32{float32(math.Cos(angle)), float32(math.Sin(angle))}
+	delay := int(math.Abs(position)*maxInterauralDelay*float64(sampleRate) + 0.5)
+	far := 1
+	if position > 0 {
+		far = 0
+	}
+
+	return func(r Reader) Reader {
+		// history holds the last delayed samples of the far ear
+		history := make([]float32, delay)
+		var buff []float32
+		return ReaderFunc(func(samples [][2]float32) (int, error) {
+			n, err := r.Read(samples)
+			if cap(buff) < delay+n {
+				buff = make([]float32, delay+n)
+			}
+			buff = buff[:delay+n]
+			copy(buff, history)
+			for i := range samples[:n] {
+				mono := (samples[i][0] + samples[i][1]) / 2
+				buff[delay+i] = mono
+				samples[i][1-far] = mono * gain[1-far]
+				samples[i][far] = buff[i] * gain[far]
+			}
+			copy(history, buff[n:])
+			return n, err
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPan(t *testing.T) {
+	src := ReaderFunc(func(samples [][2]float32) (int, error) {
+		for i := range samples {
+			samples[i] = [2]float32{0.5, -0.5}
+		}
+		return len(samples), nil
+	})
+
+	cases := map[string]struct {
+		position float64
+		expected [2]float32
+	}{
+		"HardLeft":  {-1, [2]float32{0.5, 0}},
+		"HalfLeft":  {-0.5, [2]float32{0.5, -0.25}},
+		"Center":    {0, [2]float32{0.5, -0.5}},
+		"HardRight": {1, [2]float32{0, -0.5}},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			samples := make([][2]float32, 16)
+			n, err := Pan(c.position)(src).Read(samples)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for _, s := range samples[:n] {
+				if s != c.expected {
+					t.Fatalf("Expected %v, got %v", c.expected, s)
+				}
+			}
+		})
+	}
+}
+
+func TestPosition(t *testing.T) {
+	const sampleRate = 48000
+	// Impulse on the first sample
+	var i int
+	src := ReaderFunc(func(samples [][2]float32) (int, error) {
+		for j := range samples {
+			samples[j] = [2]float32{}
+			if i == 0 {
+				samples[j] = [2]float32{1, 1}
+			}
+			i++
+		}
+		return len(samples), nil
+	})
+
+	r := Position(sampleRate, -1)(src)
+	var left, right []float32
+	for k := 0; k < 4; k++ {
+		samples := make([][2]float32, 16)
+		n, err := r.Read(samples)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for _, s := range samples[:n] {
+			left = append(left, s[0])
+			right = append(right, s[1])
+		}
+	}
+
+	if left[0] != 1 {
+		t.Errorf("Expected the impulse on the left without delay, got %v", left[:4])
+	}
+	for j, v := range right {
+		if math.Abs(float64(v)) > 1e-6 {
+			t.Errorf("Expected the right channel to be silent at hard left, got %f at %d", v, j)
+		}
+	}
+
+	i = 0
+	r = Position(sampleRate, 0.5)(src)
+	samples := make([][2]float32, 64)
+	if _, err := r.Read(samples); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Interaural delay of 0.33ms
+	delay := 16
+	if samples[0][1] == 0 || samples[0][0] != 0 {
+		t.Errorf("Expected the impulse only on the near (right) ear first, got %v", samples[0])
+	}
+	if samples[delay][0] == 0 {
+		t.Errorf("Expected the impulse on the far (left) ear after %d samples", delay)
+	}
+	if l, r := float64(samples[delay][0]), float64(samples[0][1]); math.Abs(l*l+r*r-1) > 1e-6 {
+		t.Errorf("Expected constant power, got %f and %f", l, r)
+	}
+}
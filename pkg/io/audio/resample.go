@@ -0,0 +1,58 @@
+package audio
+
+// Resample returns audio transform which converts the sample rate from from to to
+// by linear interpolation. The reader is returned as is if the rates are same.
+// The last sample of the source is dropped since it can't be interpolated.
+func Resample(from, to int) TransformFunc {
+	return func(r Reader) Reader {
+		if from == to || from <= 0 || to <= 0 {
+			return r
+		}
+
+		step := float64(from) / float64(to)
+		// buff holds the source samples from the one at floor(pos)
+		var buff [][2]float32
+		var chunk [][2]float32
+		var pos float64
+		var readErr error
+
+		return ReaderFunc(func(samples [][2]float32) (int, error) {
+			var n int
+			for ; n < len(samples); n++ {
+				i := int(pos)
+				for i+1 >= len(buff) {
+					if readErr != nil {
+						if n > 0 {
+							return n, nil
+						}
+						return 0, readErr
+					}
+
+					// Read enough samples for the rest of the request
+					size := int(float64(len(samples)-n)*step) + 2
+					if len(chunk) < size {
+						chunk = make([][2]float32, size)
+					}
+					m, err := r.Read(chunk[:size])
+					buff = append(buff, chunk[:m]...)
+					readErr = err
+				}
+
+				frac := float32(pos - float64(i))
+				s0, s1 := buff[i], buff[i+1]
+				samples[n][0] = s0[0] + (s1[0]-s0[0])*frac
+				samples[n][1] = s0[1] + (s1[1]-s0[1])*frac
+				pos += step
+			}
+
+			// Discard the consumed samples
+			consumed := int(pos)
+			if consumed > len(buff) {
+				consumed = len(buff)
+			}
+			buff = buff[:copy(buff, buff[consumed:])]
+			pos -= float64(consumed)
+			return n, nil
+		})
+	}
+}
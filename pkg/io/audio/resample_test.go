@@ -0,0 +1,66 @@
+package audio
+
+import (
+	"io"
+	"testing"
+)
+
+func TestResample(t *testing.T) {
+	// ramp returns a reader of the ramp 0, 1, 2, ... ending at n samples
+	ramp := func(n int) Reader {
+		var i int
+		return ReaderFunc(func(samples [][2]float32) (int, error) {
+			if i >= n {
+				return 0, io.EOF
+			}
+			var read int
+			for ; read < len(samples) && i < n; read++ {
+				samples[read] = [2]float32{float32(i), -float32(i)}
+				i++
+			}
+			return read, nil
+		})
+	}
+
+	cases := map[string]struct {
+		from, to int
+		expected []float32
+	}{
+		"Upsample":   {8000, 16000, []float32{0, 0.5, 1, 1.5, 2, 2.5, 3, 3.5, 4, 4.5, 5, 5.5, 6, 6.5, 7, 7.5, 8, 8.5}},
+		"Downsample": {16000, 8000, []float32{0, 2, 4, 6, 8}},
+		"Same":       {8000, 8000, []float32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			r := Resample(c.from, c.to)(ramp(10))
+
+			// Read in small chunks to test the state across the reads
+			var out []float32
+			buff := make([][2]float32, 3)
+			for {
+				n, err := r.Read(buff)
+				for _, s := range buff[:n] {
+					if s[1] != -s[0] {
+						t.Fatalf("Expected the channels to be resampled independently, got %v", s)
+					}
+					out = append(out, s[0])
+				}
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+			if len(out) != len(c.expected) {
+				t.Fatalf("Expected %v, got %v", c.expected, out)
+			}
+			for i := range out {
+				if out[i] != c.expected[i] {
+					t.Fatalf("Expected %v, got %v", c.expected, out)
+				}
+			}
+		})
+	}
+}
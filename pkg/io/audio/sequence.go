@@ -0,0 +1,101 @@
+package audio
+
+import (
+	"math"
+	"sync"
+)
+
+// sequencePeriod is the period of the sequence numbers of the samples. The
+// sample values of the sequence are exactly representable by float32.
+const sequencePeriod = 1 << 16
+
+// sequenceSample returns the sample value of the sequence number seq.
+func sequenceSample(seq uint32) float32 {
+	return float32(seq%sequencePeriod)/(sequencePeriod/2) - 1
+}
+
+// decodeSequenceSample returns the sequence number of the sample value v.
+func decodeSequenceSample(v float32) uint32 {
+	return uint32(math.Round(float64(v+1)*(sequencePeriod/2))) % sequencePeriod
+}
+
+// EmbedSequence is a transform replacing the samples with a sawtooth of the
+// sequence numbers of the samples, starting from 0. It's placed at the source
+// of the samples validated by SequenceChecker. The sawtooth is audible, so it's
+// only for diagnostics.
+func EmbedSequence(r Reader) Reader {
+	var seq uint32
+	return ReaderFunc(func(samples [][2]float32) (int, error) {
+		n, err := r.Read(samples)
+		for i := range samples[:n] {
+			v := sequenceSample(seq)
+			samples[i] = [2]float32{v, v}
+			seq++
+		}
+		return n, err
+	})
+}
+
+// SequenceChecker is a Reader validating the continuity of the samples by the
+// sequence numbers embedded by EmbedSequence, e.g. to debug the samples dropped
+// or duplicated by the buffers. The transforms changing the sample values, like
+// gain and resampling, must not be placed between them.
+type SequenceChecker struct {
+	r Reader
+
+	mu         sync.Mutex
+	started    bool
+	last       uint32
+	dropped    int
+	duplicated int
+}
+
+// NewSequenceChecker creates a SequenceChecker reading the samples from r.
+func NewSequenceChecker(r Reader) *SequenceChecker {
+	return &SequenceChecker{r: r}
+}
+
+// Read reads the samples from the underlying reader and validates their
+// sequence numbers.
+func (c *SequenceChecker) Read(samples [][2]float32) (int, error) {
+	n, err := c.r.Read(samples)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range samples[:n] {
+		seq := decodeSequenceSample(s[0])
+		if !c.started {
+			c.started = true
+			c.last = seq
+			continue
+		}
+
+		// The sequence wraps around, so the gap shorter than a half period is
+		// treated as the dropped samples and the others as the samples going back.
+		gap := (seq - c.last + sequencePeriod) % sequencePeriod
+		switch {
+		case gap == 0 || gap >= sequencePeriod/2:
+			c.duplicated++
+			continue
+		case gap > 1:
+			c.dropped += int(gap - 1)
+		}
+		c.last = seq
+	}
+	return n, err
+}
+
+// Dropped returns the number of the samples missing in the sequence.
+func (c *SequenceChecker) Dropped() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
+
+// Duplicated returns the number of the samples whose sequence number is not
+// after the previous one.
+func (c *SequenceChecker) Duplicated() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.duplicated
+}
@@ -0,0 +1,59 @@
+package audio
+
+import (
+	"testing"
+)
+
+func TestSequenceChecker(t *testing.T) {
+	src := EmbedSequence(ReaderFunc(func(samples [][2]float32) (int, error) {
+		return len(samples), nil
+	}))
+
+	// The transform drops 5 samples of the third read and repeats the samples of the sixth read
+	var i int
+	var last [][2]float32
+	c := NewSequenceChecker(ReaderFunc(func(samples [][2]float32) (int, error) {
+		i++
+		switch i {
+		case 3:
+			if _, err := src.Read(make([][2]float32, 5)); err != nil {
+				return 0, err
+			}
+		case 7:
+			return copy(samples, last), nil
+		}
+		n, err := src.Read(samples)
+		last = append(last[:0], samples[:n]...)
+		return n, err
+	}))
+
+	buff := make([][2]float32, 480)
+	for j := 0; j < 10; j++ {
+		if _, err := c.Read(buff); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := c.Dropped(); n != 5 {
+		t.Errorf("Expected 5 dropped samples, got %d", n)
+	}
+	if n := c.Duplicated(); n != 480 {
+		t.Errorf("Expected 480 duplicated samples, got %d", n)
+	}
+}
+
+func TestSequenceWrapAround(t *testing.T) {
+	src := EmbedSequence(ReaderFunc(func(samples [][2]float32) (int, error) {
+		return len(samples), nil
+	}))
+	c := NewSequenceChecker(src)
+
+	buff := make([][2]float32, 1000)
+	for read := 0; read < 3*sequencePeriod; read += len(buff) {
+		if _, err := c.Read(buff); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d, dup := c.Dropped(), c.Duplicated(); d != 0 || dup != 0 {
+		t.Errorf("Expected no discontinuity, got %d dropped and %d duplicated", d, dup)
+	}
+}
@@ -0,0 +1,30 @@
+package audio
+
+import (
+	"time"
+)
+
+// TimestampReader is a Reader which can also provide the capture time of the
+// samples, e.g. the drivers stamping the buffers received from the device. The
+// time is on the system clock shared with video.RawFrame.Timestamp, so the
+// samples can be aligned to the video by DriftCorrection even if the clock of
+// the sound card drifts.
+type TimestampReader interface {
+	Reader
+	// ReadTimestamp reads the samples like Read and returns the capture time
+	// of the first sample.
+	ReadTimestamp(samples [][2]float32) (n int, timestamp time.Time, err error)
+}
+
+// TimestampReaderFunc is a proxy type for TimestampReader. Read discards the
+// timestamp.
+type TimestampReaderFunc func(samples [][2]float32) (n int, timestamp time.Time, err error)
+
+func (rf TimestampReaderFunc) ReadTimestamp(samples [][2]float32) (int, time.Time, error) {
+	return rf(samples)
+}
+
+func (rf TimestampReaderFunc) Read(samples [][2]float32) (int, error) {
+	n, _, err := rf(samples)
+	return n, err
+}
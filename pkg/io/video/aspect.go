@@ -0,0 +1,42 @@
+package video
+
+import (
+	"image"
+	"math"
+)
+
+// SquarePixel returns a transform to rescale anamorphic video into square pixels.
+// par is the pixel aspect ratio of the incoming video, which is a ratio of the pixel width
+// to the pixel height. The width of the video is scaled by par and the height is kept.
+// Setting scaler=nil to use default scaler. (ScalerNearestNeighbor)
+func SquarePixel(par float64, scaler Scaler) TransformFunc {
+	return func(r Reader) Reader {
+		if par <= 0 || par == 1 {
+			return r
+		}
+
+		var img image.Image
+		src := ReaderFunc(func() (image.Image, error) {
+			return img, nil
+		})
+
+		var scaled Reader
+		var rect image.Rectangle
+		return ReaderFunc(func() (image.Image, error) {
+			var err error
+			img, err = r.Read()
+			if err != nil {
+				return nil, err
+			}
+
+			bounds := img.Bounds()
+			if scaled == nil || bounds.Dx() != rect.Dx() || bounds.Dy() != rect.Dy() {
+				// Rebuild the scaler since the size of the incoming video is changed
+				rect = bounds
+				width := int(math.Round(float64(bounds.Dx()) * par))
+				scaled = Scale(width, bounds.Dy(), scaler)(src)
+			}
+			return scaled.Read()
+		})
+	}
+}
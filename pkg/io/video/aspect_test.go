@@ -0,0 +1,51 @@
+package video
+
+import (
+	"image"
+	"testing"
+)
+
+func TestSquarePixel(t *testing.T) {
+	cases := map[string]struct {
+		src           image.Image
+		par           float64
+		width, height int
+	}{
+		"NTSC16:9": {
+			src:    image.NewYCbCr(image.Rect(0, 0, 720, 480), image.YCbCrSubsampleRatio420),
+			par:    32.0 / 27.0,
+			width:  853,
+			height: 480,
+		},
+		"NTSC4:3": {
+			src:    image.NewRGBA(image.Rect(0, 0, 720, 480)),
+			par:    8.0 / 9.0,
+			width:  640,
+			height: 480,
+		},
+		"Square": {
+			src:    image.NewRGBA(image.Rect(0, 0, 640, 480)),
+			par:    1,
+			width:  640,
+			height: 480,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			r := SquarePixel(c.par, nil)(ReaderFunc(func() (image.Image, error) {
+				return c.src, nil
+			}))
+			for i := 0; i < 2; i++ {
+				img, err := r.Read()
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if w, h := img.Bounds().Dx(), img.Bounds().Dy(); w != c.width || h != c.height {
+					t.Errorf("Expected %dx%d, got %dx%d", c.width, c.height, w, h)
+				}
+			}
+		})
+	}
+}
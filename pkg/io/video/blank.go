@@ -0,0 +1,67 @@
+package video
+
+import (
+	"image"
+)
+
+// Blank returns a transform which replaces the frames with black frames of the
+// same size while blank returns true, e.g. to mute the video without stopping
+// the stream. The black frames have the same type as the source frames if it's
+// *image.YCbCr, *image.RGBA or *image.Gray, otherwise they are I420 frames.
+//
+// The returned Reader keeps RawReader of r, and the raw frames are passed through
+// as is while not blanking. The black frames keep the timestamps of the frames.
+func Blank(blank func() bool) TransformFunc {
+	return func(r Reader) Reader {
+		var black image.Image
+		var format interface{}
+		return mapFrames(r, func(img image.Image, err error) (image.Image, error) {
+			if err != nil || !blank() {
+				return img, err
+			}
+
+			if f := blackFormat(img); black == nil || black.Bounds() != img.Bounds() || f != format {
+				black, format = newBlackImage(img.Bounds(), f), f
+			}
+			return black, nil
+		})
+	}
+}
+
+type rgbaFormat struct{}
+type grayFormat struct{}
+
+// blackFormat returns the format of the black frame for img, which is
+// rgbaFormat, grayFormat or image.YCbCrSubsampleRatio.
+func blackFormat(img image.Image) interface{} {
+	switch v := img.(type) {
+	case *image.YCbCr:
+		return v.SubsampleRatio
+	case *image.RGBA:
+		return rgbaFormat{}
+	case *image.Gray:
+		return grayFormat{}
+	default:
+		return image.YCbCrSubsampleRatio420
+	}
+}
+
+func newBlackImage(rect image.Rectangle, format interface{}) image.Image {
+	switch f := format.(type) {
+	case rgbaFormat:
+		black := image.NewRGBA(rect)
+		for i := 3; i < len(black.Pix); i += 4 {
+			black.Pix[i] = 0xFF
+		}
+		return black
+	case grayFormat:
+		return image.NewGray(rect)
+	default:
+		black := image.NewYCbCr(rect, f.(image.YCbCrSubsampleRatio))
+		for i := range black.Cb {
+			black.Cb[i] = 128
+			black.Cr[i] = 128
+		}
+		return black
+	}
+}
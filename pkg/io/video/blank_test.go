@@ -0,0 +1,109 @@
+package video
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/frame"
+)
+
+func TestBlank(t *testing.T) {
+	cases := map[string]image.Image{
+		"I420":  image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420),
+		"I444":  image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio444),
+		"RGBA":  image.NewRGBA(image.Rect(0, 0, 4, 2)),
+		"Gray":  image.NewGray(image.Rect(0, 0, 4, 2)),
+		"NRGBA": image.NewNRGBA(image.Rect(0, 0, 4, 2)),
+	}
+	for name, img := range cases {
+		img := img
+		t.Run(name, func(t *testing.T) {
+			// Fill the source with white
+			for y := 0; y < 2; y++ {
+				for x := 0; x < 4; x++ {
+					switch v := img.(type) {
+					case *image.YCbCr:
+						v.Y[v.YOffset(x, y)] = 0xFF
+						v.Cb[v.COffset(x, y)] = 128
+						v.Cr[v.COffset(x, y)] = 128
+					case interface{ Set(x, y int, c color.Color) }:
+						v.Set(x, y, color.White)
+					}
+				}
+			}
+
+			var blank bool
+			r := Blank(func() bool { return blank })(ReaderFunc(func() (image.Image, error) {
+				return img, nil
+			}))
+
+			for _, b := range []bool{false, true, true, false} {
+				blank = b
+				out, err := r.Read()
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if !blank {
+					if out != img {
+						t.Error("Expected the frame to be passed through")
+					}
+					continue
+				}
+				if out.Bounds() != img.Bounds() {
+					t.Errorf("Expected bounds %v, got %v", img.Bounds(), out.Bounds())
+				}
+				if _, ok := img.(*image.NRGBA); !ok && out.ColorModel() != img.ColorModel() {
+					t.Errorf("Expected the same color model as the source")
+				}
+				for y := 0; y < 2; y++ {
+					for x := 0; x < 4; x++ {
+						if r, g, b, a := out.At(x, y).RGBA(); r != 0 || g != 0 || b != 0 || a != 0xFFFF {
+							t.Fatalf("Expected black at (%d, %d), got %v", x, y, out.At(x, y))
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestBlankRaw(t *testing.T) {
+	b := make([]byte, 4*2*3/2)
+	for i := range b {
+		b[i] = 0xFF
+	}
+	ts := time.Now()
+	var blank bool
+	r, ok := Blank(func() bool { return blank })(RawReaderFunc(func() (RawFrame, error) {
+		f, err := NewI420Frame(b, 4, 2)
+		f.Timestamp = ts
+		return f, err
+	})).(RawReader)
+	if !ok {
+		t.Fatal("Expected RawReader")
+	}
+
+	for _, blank = range []bool{false, true} {
+		f, err := r.ReadRaw()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !f.Timestamp.Equal(ts) {
+			t.Errorf("Expected timestamp %v, got %v", ts, f.Timestamp)
+		}
+		if f.Format != frame.FormatI420 || f.Width != 4 || f.Height != 2 {
+			t.Errorf("Expected 4x2 I420 frame, got %dx%d %s", f.Width, f.Height, f.Format)
+		}
+		if !blank {
+			if &f.Planes[0][0] != &b[0] {
+				t.Error("Expected the frame to be passed through")
+			}
+			continue
+		}
+		if y := f.Planes[0][0]; y != 0 {
+			t.Errorf("Expected black, got luma %d", y)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package video
+
+import (
+	"image"
+	"image/draw"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/frame"
+)
+
+// framePool is shared by the transforms allocating the frames from the pool.
+var framePool frame.Pool
+
+// DropPolicy decides which frame to drop when the frame buffer is full.
+type DropPolicy int
+
+const (
+	// DropPolicyBlock doesn't drop any frame. The upstream reader is blocked
+	// until the downstream consumes a frame from the buffer.
+	DropPolicyBlock DropPolicy = iota
+	// DropPolicyOldest drops the oldest frame in the buffer to store the incoming frame.
+	// This keeps the latency lowest.
+	DropPolicyOldest
+	// DropPolicyNewest drops the incoming frame.
+	DropPolicyNewest
+)
+
+// BufferedReader is a Reader returned by Buffer.
+type BufferedReader interface {
+	Reader
+	// Buffered returns the number of the frames stored in the buffer.
+	Buffered() int
+	// Dropped returns the number of the frames dropped by the policy.
+	Dropped() uint64
+	// BufferedBytes returns the bytes of the frames stored in the buffer.
+	BufferedBytes() int
+	// Close discards the stored frames and stops the goroutine reading the
+	// upstream reader, which may be blocked by DropPolicyBlock, once the
+	// current read of the upstream returns. The following Reads return io.EOF.
+	io.Closer
+}
+
+// Buffer returns video buffering transform.
+// This transform reads frames from the upstream reader in a separate goroutine
+// and stores up to size frames, so that a slow downstream (e.g. encoder) doesn't block
+// the capture. When the buffer is full, policy decides which frame to drop.
+// The buffered frames are allocated from the pool, and the frame returned by
+// Read is put back to the pool by the next Read. The returned Reader
+// implements BufferedReader and RawReader, and the timestamps of the frames are
+// kept if r is a RawReader.
+func Buffer(size int, policy DropPolicy) TransformFunc {
+	return BoundedBuffer(size, 0, policy)
+}
+
+// BoundedBuffer returns video buffering transform like Buffer, which also caps
+// the bytes of the stored frames to maxBytes, e.g. to keep the memory bounded
+// on the constrained devices under the backpressure of a slow downstream. The
+// buffer is full when storing the frame exceeds maxBytes, and policy decides
+// which frame to drop. The frame larger than maxBytes is always dropped. The
+// frame being used by the downstream is not counted. maxBytes of 0 means no
+// limit.
+func BoundedBuffer(size, maxBytes int, policy DropPolicy) TransformFunc {
+	if size <= 0 {
+		panic("Buffer size must be positive!")
+	}
+	if maxBytes < 0 {
+		panic("Buffer bytes must not be negative!")
+	}
+
+	return func(r Reader) Reader {
+		var mu sync.Mutex
+		cond := sync.NewCond(&mu)
+		frames := make([]bufferedFrame, 0, size)
+		var readErr error
+		var closed bool
+		var last image.Image
+		var dropped uint64
+		// bytes is the sum of the bytes of frames
+		var bytes int
+
+		go func() {
+			for {
+				img, timestamp, err := readImage(r)
+				if err != nil {
+					mu.Lock()
+					if !closed {
+						readErr = err
+					}
+					cond.Broadcast()
+					mu.Unlock()
+					return
+				}
+
+				// Upstream reader may reuse the image buffer, so it has to be copied.
+				// The bytes are counted on the copy, which may be converted to RGBA.
+				cloned := clonePooledImage(img, &framePool)
+				n := imageBytes(cloned)
+
+				mu.Lock()
+				if closed {
+					mu.Unlock()
+					releaseImage(cloned, &framePool)
+					return
+				}
+				if maxBytes > 0 && n > maxBytes {
+					// The frame can't be stored even if the buffer is empty
+					dropped++
+					mu.Unlock()
+					releaseImage(cloned, &framePool)
+					continue
+				}
+				full := func() bool {
+					return len(frames) >= size || (maxBytes > 0 && bytes+n > maxBytes)
+				}
+
+				if full() {
+					switch policy {
+					case DropPolicyOldest:
+						for full() {
+							dropped++
+							bytes -= imageBytes(frames[0].img)
+							releaseImage(frames[0].img, &framePool)
+							copy(frames, frames[1:])
+							frames[len(frames)-1] = bufferedFrame{}
+							frames = frames[:len(frames)-1]
+						}
+					case DropPolicyNewest:
+						dropped++
+						mu.Unlock()
+						releaseImage(cloned, &framePool)
+						continue
+					default:
+						for full() && !closed {
+							cond.Wait()
+						}
+						if closed {
+							mu.Unlock()
+							releaseImage(cloned, &framePool)
+							return
+						}
+					}
+				}
+
+				frames = append(frames, bufferedFrame{img: cloned, timestamp: timestamp})
+				bytes += n
+				cond.Broadcast()
+				mu.Unlock()
+			}
+		}()
+
+		read := func() (bufferedFrame, error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			for len(frames) == 0 && readErr == nil {
+				cond.Wait()
+			}
+			if len(frames) == 0 {
+				return bufferedFrame{}, readErr
+			}
+
+			if last != nil {
+				releaseImage(last, &framePool)
+			}
+			f := frames[0]
+			last = f.img
+			bytes -= imageBytes(f.img)
+			copy(frames, frames[1:])
+			frames[len(frames)-1] = bufferedFrame{}
+			frames = frames[:len(frames)-1]
+			cond.Broadcast()
+			return f, nil
+		}
+		buffered := func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(frames)
+		}
+		droppedFrames := func() uint64 {
+			mu.Lock()
+			defer mu.Unlock()
+			return dropped
+		}
+		bufferedBytes := func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return bytes
+		}
+		closeBuffer := func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			if closed {
+				return nil
+			}
+			closed = true
+			readErr = io.EOF
+			for i := range frames {
+				releaseImage(frames[i].img, &framePool)
+				frames[i] = bufferedFrame{}
+			}
+			frames = frames[:0]
+			bytes = 0
+			cond.Broadcast()
+			return nil
+		}
+		return &bufferedReader{read: read, buffered: buffered, dropped: droppedFrames, bufferedBytes: bufferedBytes, close: closeBuffer}
+	}
+}
+
+// bufferedFrame is a frame stored in the buffer with its capture time.
+type bufferedFrame struct {
+	img       image.Image
+	timestamp time.Time
+}
+
+type bufferedReader struct {
+	read          func() (bufferedFrame, error)
+	buffered      func() int
+	dropped       func() uint64
+	bufferedBytes func() int
+	close         func() error
+}
+
+func (r *bufferedReader) Read() (image.Image, error) {
+	f, err := r.read()
+	return f.img, err
+}
+
+func (r *bufferedReader) ReadRaw() (RawFrame, error) {
+	f, err := r.read()
+	if err != nil {
+		return RawFrame{}, err
+	}
+	return NewRawFrame(f.img, f.timestamp)
+}
+
+func (r *bufferedReader) Buffered() int {
+	return r.buffered()
+}
+
+func (r *bufferedReader) Dropped() uint64 {
+	return r.dropped()
+}
+
+func (r *bufferedReader) BufferedBytes() int {
+	return r.bufferedBytes()
+}
+
+func (r *bufferedReader) Close() error {
+	return r.close()
+}
+
+// Clone returns a deep copy of img, e.g. to keep a frame which is only valid
+// until the next Read.
+func Clone(img image.Image) image.Image {
+	return cloneImage(img)
+}
+
+// cloneImage returns deep copy of img
+func cloneImage(img image.Image) image.Image {
+	return clonePooledImage(img, nil)
+}
+
+// clonePooledImage returns deep copy of img allocated from pool.
+// If pool is nil, new buffers are allocated.
+func clonePooledImage(img image.Image, pool *frame.Pool) image.Image {
+	alloc := func(size int) []uint8 {
+		if pool == nil {
+			return make([]uint8, size)
+		}
+		return pool.Get(size)
+	}
+	clone := func(b []uint8) []uint8 {
+		cloned := alloc(len(b))
+		copy(cloned, b)
+		return cloned
+	}
+
+	switch v := img.(type) {
+	case *image.YCbCr:
+		cloned := *v
+		cloned.Y = clone(v.Y)
+		cloned.Cb = clone(v.Cb)
+		cloned.Cr = clone(v.Cr)
+		return &cloned
+	case *image.RGBA:
+		cloned := *v
+		cloned.Pix = clone(v.Pix)
+		return &cloned
+	case *image.Gray:
+		cloned := *v
+		cloned.Pix = clone(v.Pix)
+		return &cloned
+	default:
+		bounds := img.Bounds()
+		cloned := &image.RGBA{
+			Pix:    alloc(4 * bounds.Dx() * bounds.Dy()),
+			Stride: 4 * bounds.Dx(),
+			Rect:   bounds,
+		}
+		draw.Draw(cloned, cloned.Rect, img, bounds.Min, draw.Src)
+		return cloned
+	}
+}
+
+// imageBytes returns the bytes of the pixel data of img.
+func imageBytes(img image.Image) int {
+	switch v := img.(type) {
+	case *image.YCbCr:
+		return len(v.Y) + len(v.Cb) + len(v.Cr)
+	case *image.RGBA:
+		return len(v.Pix)
+	case *image.Gray:
+		return len(v.Pix)
+	}
+	return 0
+}
+
+// releaseImage puts the buffers of img cloned by clonePooledImage back to pool.
+// img must not be used after that.
+func releaseImage(img image.Image, pool *frame.Pool) {
+	switch v := img.(type) {
+	case *image.YCbCr:
+		pool.Put(v.Y)
+		pool.Put(v.Cb)
+		pool.Put(v.Cr)
+	case *image.RGBA:
+		pool.Put(v.Pix)
+	case *image.Gray:
+		pool.Put(v.Pix)
+	}
+}
@@ -0,0 +1,266 @@
+package video
+
+import (
+	"image"
+	"io"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/frame"
+)
+
+func TestBuffer(t *testing.T) {
+	const nFrames = 10
+
+	cases := map[string]struct {
+		policy   DropPolicy
+		expected []uint8
+	}{
+		"Block": {
+			policy:   DropPolicyBlock,
+			expected: []uint8{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		},
+		"Oldest": {
+			policy:   DropPolicyOldest,
+			expected: []uint8{7, 8, 9},
+		},
+		"Newest": {
+			policy:   DropPolicyNewest,
+			expected: []uint8{0, 1, 2},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			// Upstream reuses the image buffer like most of the drivers.
+			img := image.NewGray(image.Rect(0, 0, 1, 1))
+			var cnt uint8
+			done := make(chan struct{})
+			r := Buffer(3, c.policy)(ReaderFunc(func() (image.Image, error) {
+				if cnt == nFrames {
+					close(done)
+					return nil, io.EOF
+				}
+				img.Pix[0] = cnt
+				cnt++
+				return img, nil
+			}))
+
+			if c.policy != DropPolicyBlock {
+				// Simulate the slow encoder by starting to read after all frames are captured.
+				<-done
+				if n := r.(BufferedReader).Buffered(); n != 3 {
+					t.Errorf("Expected 3 frames to be buffered, got %d", n)
+				}
+				if n := r.(BufferedReader).Dropped(); n != nFrames-3 {
+					t.Errorf("Expected %d frames to be dropped, got %d", nFrames-3, n)
+				}
+			}
+
+			var got []uint8
+			for {
+				frame, err := r.Read()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				got = append(got, frame.(*image.Gray).Pix[0])
+			}
+			if !reflect.DeepEqual(c.expected, got) {
+				t.Errorf("Expected frames %v, got %v", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestBoundedBuffer(t *testing.T) {
+	const nFrames = 10
+	// 16x16 I420 frame is 384 bytes, and 3 frames fit in the buffer
+	const maxBytes = 3*384 + 100
+
+	for _, policy := range []DropPolicy{DropPolicyOldest, DropPolicyNewest} {
+		img := image.NewYCbCr(image.Rect(0, 0, 16, 16), image.YCbCrSubsampleRatio420)
+		var cnt int
+		done := make(chan struct{})
+		r := BoundedBuffer(nFrames, maxBytes, policy)(ReaderFunc(func() (image.Image, error) {
+			if cnt == nFrames {
+				close(done)
+				return nil, io.EOF
+			}
+			cnt++
+			return img, nil
+		}))
+
+		// Flood the slow consumer
+		<-done
+		br := r.(BufferedReader)
+		if n := br.BufferedBytes(); n > maxBytes {
+			t.Errorf("Expected the buffered bytes under %d, got %d", maxBytes, n)
+		}
+		if n := br.Buffered(); n != 3 {
+			t.Errorf("Expected 3 frames to be buffered, got %d", n)
+		}
+		if n := br.Dropped(); n != nFrames-3 {
+			t.Errorf("Expected %d frames to be dropped, got %d", nFrames-3, n)
+		}
+	}
+
+	t.Run("LargeFrame", func(t *testing.T) {
+		img := image.NewYCbCr(image.Rect(0, 0, 64, 64), image.YCbCrSubsampleRatio420)
+		var cnt int
+		r := BoundedBuffer(nFrames, maxBytes, DropPolicyBlock)(ReaderFunc(func() (image.Image, error) {
+			if cnt == nFrames {
+				return nil, io.EOF
+			}
+			cnt++
+			return img, nil
+		}))
+		// The blocking policy doesn't block on the frames which never fit
+		if _, err := r.Read(); err != io.EOF {
+			t.Errorf("Expected %v, got %v", io.EOF, err)
+		}
+		if n := r.(BufferedReader).Dropped(); n != nFrames {
+			t.Errorf("Expected %d frames larger than the limit to be dropped, got %d", nFrames, n)
+		}
+	})
+	t.Run("ConvertedFrame", func(t *testing.T) {
+		// NRGBA frames are stored as RGBA, and 16x16 RGBA frame is 1024 bytes
+		img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+		var cnt int
+		done := make(chan struct{})
+		r := BoundedBuffer(nFrames, 3*1024+100, DropPolicyOldest)(ReaderFunc(func() (image.Image, error) {
+			if cnt == nFrames {
+				close(done)
+				return nil, io.EOF
+			}
+			cnt++
+			return img, nil
+		}))
+
+		<-done
+		br := r.(BufferedReader)
+		if n := br.Buffered(); n != 3 {
+			t.Errorf("Expected 3 frames to be buffered, got %d", n)
+		}
+		if n := br.BufferedBytes(); n != 3*1024 {
+			t.Errorf("Expected %d bytes to be buffered, got %d", 3*1024, n)
+		}
+		for i := 0; i < 3; i++ {
+			if _, err := r.Read(); err != nil {
+				t.Fatalf("Failed to read: %v", err)
+			}
+		}
+		if n := br.BufferedBytes(); n != 0 {
+			t.Errorf("Expected no bytes to be buffered after reading all, got %d", n)
+		}
+	})
+}
+
+func TestBufferClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	img := image.NewYCbCr(image.Rect(0, 0, 16, 16), image.YCbCrSubsampleRatio420)
+	var cnt int32
+	r := Buffer(1, DropPolicyBlock)(ReaderFunc(func() (image.Image, error) {
+		atomic.AddInt32(&cnt, 1)
+		return img, nil
+	}))
+	// The first frame is stored and the second one blocks the goroutine
+	for atomic.LoadInt32(&cnt) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	br := r.(BufferedReader)
+	if err := br.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	if n := br.Buffered(); n != 0 {
+		t.Errorf("Expected the frames to be discarded, got %d", n)
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("Expected %v, got %v", io.EOF, err)
+	}
+
+	timeout := time.After(time.Second)
+	for runtime.NumGoroutine() > before {
+		select {
+		case <-timeout:
+			t.Fatal("Expected the goroutine blocked by the policy to exit")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	if n := atomic.LoadInt32(&cnt); n != 2 {
+		t.Errorf("Expected no read after Close, read %d frames", n)
+	}
+}
+
+func TestBufferLatency(t *testing.T) {
+	// Measures average delay between the capture and the consumption
+	// with the slow consumer.
+	measure := func(size int) time.Duration {
+		var mu sync.Mutex
+		captured := make(map[uint8]time.Time)
+		img := image.NewGray(image.Rect(0, 0, 1, 1))
+		var cnt uint8
+		r := Buffer(size, DropPolicyOldest)(ReaderFunc(func() (image.Image, error) {
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			cnt++
+			img.Pix[0] = cnt
+			captured[cnt] = time.Now()
+			mu.Unlock()
+			return img, nil
+		}))
+
+		var total time.Duration
+		const nFrames = 20
+		for i := 0; i < nFrames; i++ {
+			frame, err := r.Read()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			mu.Lock()
+			delay := time.Since(captured[frame.(*image.Gray).Pix[0]])
+			mu.Unlock()
+			if i >= nFrames/2 {
+				// Measure after the buffer is filled
+				total += delay
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		return total / (nFrames / 2)
+	}
+
+	small, large := measure(1), measure(8)
+	if small >= large {
+		t.Errorf("Expected smaller buffer to have lower latency, got %v (size 1) and %v (size 8)", small, large)
+	}
+}
+
+func BenchmarkBufferClone(b *testing.B) {
+	img := image.NewYCbCr(image.Rect(0, 0, 1280, 720), image.YCbCrSubsampleRatio420)
+
+	pools := map[string]*frame.Pool{
+		"Alloc": nil,
+		"Pool":  &frame.Pool{},
+	}
+	for name, pool := range pools {
+		pool := pool
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				cloned := clonePooledImage(img, pool)
+				if pool != nil {
+					releaseImage(cloned, pool)
+				}
+			}
+		})
+	}
+}
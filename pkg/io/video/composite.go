@@ -0,0 +1,148 @@
+package video
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sync"
+)
+
+var errCompositeNoLayer = errors.New("composite: at least one layer is required")
+
+// CompositeLayer is a source of Composite placed on the output frames.
+type CompositeLayer struct {
+	Reader Reader
+	// Rect is the region of the output frame where the source is drawn.
+	// The frames are scaled to fit it. The position and the size are rounded
+	// down to even numbers to align the chroma planes.
+	Rect image.Rectangle
+	// Scaler is used to scale the frames. nil means ScalerNearestNeighbor.
+	Scaler Scaler
+}
+
+// Composite returns a reader composing the layers into I420 frames of the
+// given size, e.g. the camera in the corner of the screen share (picture in
+// picture) or the cameras side by side. The layers are drawn in order, so the
+// later layers are on top of the earlier ones. The area without layers is black.
+//
+// The output frame rate follows the first layer, and the other layers are
+// read in separate goroutines so that the latest frames of them are drawn
+// regardless of their frame rates. The first frame is returned after the first
+// layer produces a frame; the other layers are drawn once their first frames
+// arrive. If a layer other than the first one fails, its last frame is kept.
+// The error of the first layer is returned by Read.
+func Composite(width, height int, layers ...CompositeLayer) Reader {
+	if len(layers) == 0 {
+		return ReaderFunc(func() (image.Image, error) {
+			return nil, errCompositeNoLayer
+		})
+	}
+
+	dst := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+	sources := make([]*compositeSource, len(layers))
+	for i, l := range layers {
+		rect := image.Rect(l.Rect.Min.X&^1, l.Rect.Min.Y&^1, l.Rect.Max.X&^1, l.Rect.Max.Y&^1).Intersect(dst.Rect)
+		s := &compositeSource{rect: rect}
+		if !rect.Empty() {
+			s.r = ToI420(Scale(rect.Dx(), rect.Dy(), l.Scaler)(l.Reader))
+		}
+		sources[i] = s
+		if i > 0 && s.r != nil {
+			go s.run()
+		}
+	}
+
+	return ReaderFunc(func() (image.Image, error) {
+		first := sources[0]
+		if first.r != nil {
+			img, err := first.r.Read()
+			if err != nil {
+				return nil, err
+			}
+			if err := first.store(img); err != nil {
+				return nil, err
+			}
+		}
+
+		fillBlack(dst)
+		for _, s := range sources {
+			s.draw(dst)
+		}
+		return dst, nil
+	})
+}
+
+// compositeSource holds the latest frame of a layer.
+type compositeSource struct {
+	r    Reader
+	rect image.Rectangle
+
+	mu     sync.Mutex
+	latest *image.YCbCr
+}
+
+func (s *compositeSource) run() {
+	for {
+		img, err := s.r.Read()
+		if err != nil {
+			return
+		}
+		if err := s.store(img); err != nil {
+			return
+		}
+	}
+}
+
+// store copies img since it's only valid until the next read.
+func (s *compositeSource) store(img image.Image) error {
+	v, ok := img.(*image.YCbCr)
+	if !ok || v.SubsampleRatio != image.YCbCrSubsampleRatio420 {
+		return fmt.Errorf("composite: unexpected image type %T", img)
+	}
+	if size := v.Rect.Size(); size != s.rect.Size() {
+		return fmt.Errorf("composite: unexpected frame size %v", size)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.latest == nil {
+		s.latest = image.NewYCbCr(image.Rect(0, 0, s.rect.Dx(), s.rect.Dy()), image.YCbCrSubsampleRatio420)
+	}
+	copyYCbCr(s.latest, v, image.Point{})
+	return nil
+}
+
+func (s *compositeSource) draw(dst *image.YCbCr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.latest != nil {
+		copyYCbCr(dst, s.latest, s.rect.Min)
+	}
+}
+
+// copyYCbCr copies I420 src to dst at pt. pt must be even and src must fit in dst.
+func copyYCbCr(dst, src *image.YCbCr, pt image.Point) {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	for y := 0; y < h; y++ {
+		d := dst.YOffset(pt.X, pt.Y+y)
+		s := src.YOffset(bounds.Min.X, bounds.Min.Y+y)
+		copy(dst.Y[d:d+w], src.Y[s:s+w])
+	}
+	for y := 0; y < h/2; y++ {
+		d := dst.COffset(pt.X, pt.Y+2*y)
+		s := src.COffset(bounds.Min.X, bounds.Min.Y+2*y)
+		copy(dst.Cb[d:d+w/2], src.Cb[s:s+w/2])
+		copy(dst.Cr[d:d+w/2], src.Cr[s:s+w/2])
+	}
+}
+
+func fillBlack(img *image.YCbCr) {
+	for i := range img.Y {
+		img.Y[i] = 0
+	}
+	for i := range img.Cb {
+		img.Cb[i] = 128
+		img.Cr[i] = 128
+	}
+}
@@ -0,0 +1,93 @@
+package video
+
+import (
+	"image"
+	"testing"
+	"time"
+)
+
+func solidFrame(w, h int, y, cb, cr uint8) *image.YCbCr {
+	img := image.NewYCbCr(image.Rect(0, 0, w, h), image.YCbCrSubsampleRatio420)
+	for i := range img.Y {
+		img.Y[i] = y
+	}
+	for i := range img.Cb {
+		img.Cb[i], img.Cr[i] = cb, cr
+	}
+	return img
+}
+
+func TestComposite(t *testing.T) {
+	background := solidFrame(8, 8, 200, 100, 150)
+	// Different size and rate from the background
+	pip := solidFrame(6, 4, 50, 160, 90)
+
+	r := Composite(32, 16,
+		CompositeLayer{
+			Reader: ReaderFunc(func() (image.Image, error) {
+				time.Sleep(time.Millisecond)
+				return background, nil
+			}),
+			Rect: image.Rect(0, 0, 32, 16),
+		},
+		CompositeLayer{
+			Reader: ReaderFunc(func() (image.Image, error) {
+				time.Sleep(3 * time.Millisecond)
+				return pip, nil
+			}),
+			// Rounded down to (16, 8)-(30, 16)
+			Rect: image.Rect(17, 9, 31, 16),
+		},
+	)
+
+	var out *image.YCbCr
+	for i := 0; i < 100; i++ {
+		img, err := r.Read()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		out = img.(*image.YCbCr)
+		if out.Y[out.YOffset(16, 8)] == 50 {
+			break
+		}
+	}
+	if out.Rect != image.Rect(0, 0, 32, 16) || out.SubsampleRatio != image.YCbCrSubsampleRatio420 {
+		t.Fatalf("Unexpected output frame: %v %v", out.Rect, out.SubsampleRatio)
+	}
+
+	inPIP := image.Rect(16, 8, 30, 16)
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 32; x++ {
+			expected := [3]uint8{200, 100, 150}
+			if image.Pt(x, y).In(inPIP) {
+				expected = [3]uint8{50, 160, 90}
+			}
+			c := out.COffset(x, y)
+			if got := [3]uint8{out.Y[out.YOffset(x, y)], out.Cb[c], out.Cr[c]}; got != expected {
+				t.Fatalf("Expected %v at (%d, %d), got %v", expected, x, y, got)
+			}
+		}
+	}
+}
+
+func TestCompositeBlank(t *testing.T) {
+	// The area without layers is black
+	r := Composite(8, 8, CompositeLayer{
+		Reader: ReaderFunc(func() (image.Image, error) {
+			return solidFrame(2, 2, 255, 128, 128), nil
+		}),
+		Rect: image.Rect(0, 0, 4, 8),
+	})
+	img, err := r.Read()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	out := img.(*image.YCbCr)
+	if l, r := out.Y[out.YOffset(3, 7)], out.Y[out.YOffset(4, 0)]; l != 255 || r != 0 {
+		t.Errorf("Expected the left half white and the right half black, got %d and %d", l, r)
+	}
+
+	if _, err := Composite(8, 8).Read(); err != errCompositeNoLayer {
+		t.Errorf("Expected %v, got %v", errCompositeNoLayer, err)
+	}
+}
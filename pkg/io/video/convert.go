@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+
+	"github.com/pion/mediadevices/pkg/frame"
 )
 
 var (
@@ -12,7 +14,7 @@ var (
 
 // imageToYCbCr converts src to *image.YCbCr and store it to dst
 // Note: conversion can be lossy
-func imageToYCbCr(dst *image.YCbCr, src image.Image) {
+func imageToYCbCr(dst *image.YCbCr, src image.Image, cs frame.ColorSpace) {
 	if dst == nil {
 		panic("dst can't be nil")
 	}
@@ -44,9 +46,14 @@ func imageToYCbCr(dst *image.YCbCr, src image.Image) {
 	dst.CStride = dx
 	dst.Rect = bounds
 
+	rgbToYCbCr := color.RGBToYCbCr
+	if !cs.IsDefault() {
+		rgbToYCbCr = cs.RGBToYCbCr
+	}
+
 	switch s := src.(type) {
 	case *image.RGBA:
-		if hasCGOConvert {
+		if hasCGOConvert && cs.IsDefault() {
 			rgbaToI444(dst, s)
 			return
 		}
@@ -54,7 +61,7 @@ func imageToYCbCr(dst *image.YCbCr, src image.Image) {
 		addr := 0
 		for yi := 0; yi < dy; yi++ {
 			for xi := 0; xi < dx; xi++ {
-				dst.Y[i], dst.Cb[i], dst.Cr[i] = color.RGBToYCbCr(
+				dst.Y[i], dst.Cb[i], dst.Cr[i] = rgbToYCbCr(
 					s.Pix[addr+0], s.Pix[addr+1], s.Pix[addr+2],
 				)
 				addr += 4
@@ -68,7 +75,7 @@ func imageToYCbCr(dst *image.YCbCr, src image.Image) {
 				// TODO: probably try to get the alpha value with something like
 				// https://en.wikipedia.org/wiki/Alpha_compositing
 				r, g, b, _ := src.At(xi, yi).RGBA()
-				yy, cb, cr := color.RGBToYCbCr(uint8(r/256), uint8(g/256), uint8(b/256))
+				yy, cb, cr := rgbToYCbCr(uint8(r/256), uint8(g/256), uint8(b/256))
 				dst.Y[i] = yy
 				dst.Cb[i] = cb
 				dst.Cr[i] = cr
@@ -124,8 +131,16 @@ func i422ToI420(img *image.YCbCr) {
 	img.Cr = img.Cr[:cLen]
 }
 
-// ToI420 converts r to a new reader that will output images in I420 format
+// ToI420 converts r to a new reader that will output images in I420 format.
+// The returned reader implements RawReader. If r is a RawReader providing I420
+// frames, ReadRaw passes them through without converting them into image.Image.
 func ToI420(r Reader) Reader {
+	return ToI420WithColorSpace(r, frame.ColorSpace{})
+}
+
+// ToI420WithColorSpace is ToI420 converting RGB images into YCbCr of the given
+// color space. YCbCr images are passed as is.
+func ToI420WithColorSpace(r Reader, cs frame.ColorSpace) Reader {
 	f444to420 := i444ToI420
 	f422to420 := i422ToI420
 	if hasCGOConvert {
@@ -133,33 +148,83 @@ func ToI420(r Reader) Reader {
 		f422to420 = i422ToI420CGO
 	}
 
-	var yuvImg image.YCbCr
-	return ReaderFunc(func() (image.Image, error) {
+	return &i420Reader{
+		r:         r,
+		f444to420: f444to420,
+		f422to420: f422to420,
+		cs:        cs,
+	}
+}
+
+type i420Reader struct {
+	r         Reader
+	yuvImg    image.YCbCr
+	f444to420 func(*image.YCbCr)
+	f422to420 func(*image.YCbCr)
+	cs        frame.ColorSpace
+}
+
+func (r *i420Reader) Read() (image.Image, error) {
+	img, err := r.r.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.convert(img)
+}
+
+func (r *i420Reader) ReadRaw() (RawFrame, error) {
+	rr, ok := r.r.(RawReader)
+	if !ok {
 		img, err := r.Read()
 		if err != nil {
-			return nil, err
+			return RawFrame{}, err
 		}
+		return rawFrameFromImage(img)
+	}
 
-		imageToYCbCr(&yuvImg, img)
-
-		// Covert pixel format to I420
-		switch yuvImg.SubsampleRatio {
-		case image.YCbCrSubsampleRatio444:
-			f444to420(&yuvImg)
-		case image.YCbCrSubsampleRatio422:
-			f422to420(&yuvImg)
-		case image.YCbCrSubsampleRatio420:
-		default:
-			return nil, fmt.Errorf("unsupported pixel format: %s", yuvImg.SubsampleRatio)
-		}
+	f, err := rr.ReadRaw()
+	if err != nil {
+		return RawFrame{}, err
+	}
+	if f.Format == frame.FormatI420 {
+		return f, nil
+	}
 
-		yuvImg.SubsampleRatio = image.YCbCrSubsampleRatio420
-		return &yuvImg, nil
-	})
+	img, err := f.Image()
+	if err != nil {
+		return RawFrame{}, err
+	}
+	yuvImg, err := r.convert(img)
+	if err != nil {
+		return RawFrame{}, err
+	}
+	converted, err := rawFrameFromImage(yuvImg)
+	converted.Timestamp = f.Timestamp
+	return converted, err
 }
 
-// imageToRGBA converts src to *image.RGBA and store it to dst
-func imageToRGBA(dst *image.RGBA, src image.Image) {
+func (r *i420Reader) convert(img image.Image) (*image.YCbCr, error) {
+	imageToYCbCr(&r.yuvImg, img, r.cs)
+
+	// Covert pixel format to I420
+	switch r.yuvImg.SubsampleRatio {
+	case image.YCbCrSubsampleRatio444:
+		r.f444to420(&r.yuvImg)
+	case image.YCbCrSubsampleRatio422:
+		r.f422to420(&r.yuvImg)
+	case image.YCbCrSubsampleRatio420:
+	default:
+		return nil, fmt.Errorf("unsupported pixel format: %s", r.yuvImg.SubsampleRatio)
+	}
+
+	r.yuvImg.SubsampleRatio = image.YCbCrSubsampleRatio420
+	return &r.yuvImg, nil
+}
+
+// imageToRGBA converts src to *image.RGBA and store it to dst.
+// YCbCr images are converted in the color space cs.
+func imageToRGBA(dst *image.RGBA, src image.Image, cs frame.ColorSpace) {
 	if dst == nil {
 		panic("dst can't be nil")
 	}
@@ -179,6 +244,20 @@ func imageToRGBA(dst *image.RGBA, src image.Image) {
 	dst.Stride = 4 * dx
 	dst.Rect = bounds
 
+	if srcYCbCr, ok := src.(*image.YCbCr); ok && !cs.IsDefault() {
+		i := 0
+		for yi := bounds.Min.Y; yi < bounds.Max.Y; yi++ {
+			for xi := bounds.Min.X; xi < bounds.Max.X; xi++ {
+				yy := srcYCbCr.Y[srcYCbCr.YOffset(xi, yi)]
+				ci := srcYCbCr.COffset(xi, yi)
+				dst.Pix[i+0], dst.Pix[i+1], dst.Pix[i+2] = cs.YCbCrToRGB(yy, srcYCbCr.Cb[ci], srcYCbCr.Cr[ci])
+				dst.Pix[i+3] = 0xFF
+				i += 4
+			}
+		}
+		return
+	}
+
 	if hasCGOConvert {
 		if srcYCbCr, ok := src.(*image.YCbCr); ok &&
 			srcYCbCr.SubsampleRatio == image.YCbCrSubsampleRatio444 {
@@ -203,6 +282,12 @@ func imageToRGBA(dst *image.RGBA, src image.Image) {
 
 // ToRGBA converts r to a new reader that will output images in RGBA format
 func ToRGBA(r Reader) Reader {
+	return ToRGBAWithColorSpace(r, frame.ColorSpace{})
+}
+
+// ToRGBAWithColorSpace is ToRGBA converting YCbCr images of the given color space,
+// e.g. prop.Video.ColorSpace of the source.
+func ToRGBAWithColorSpace(r Reader, cs frame.ColorSpace) Reader {
 	var dst image.RGBA
 	return ReaderFunc(func() (image.Image, error) {
 		img, err := r.Read()
@@ -210,7 +295,49 @@ func ToRGBA(r Reader) Reader {
 			return nil, err
 		}
 
-		imageToRGBA(&dst, img)
+		imageToRGBA(&dst, img, cs)
 		return &dst, nil
 	})
 }
+
+// FromRGBA converts r to a new reader that will output images in the given format.
+// This is useful to convert images back for the encoder after processing them in RGBA
+// with ToRGBA. Supported formats are FormatI420, FormatI444 and FormatRGBA.
+// FormatBGRA is not supported since image.Image has no BGRA layout and the
+// reordered pixels would be read as RGBA with red and blue swapped. The BGRA
+// frames of the devices are decoded into RGBA by the frame package.
+func FromRGBA(r Reader, f frame.Format) Reader {
+	return FromRGBAWithColorSpace(r, f, frame.ColorSpace{})
+}
+
+// FromRGBAWithColorSpace is FromRGBA converting images into YCbCr of the given color space.
+func FromRGBAWithColorSpace(r Reader, f frame.Format, cs frame.ColorSpace) Reader {
+	switch f {
+	case frame.FormatI420:
+		return ToI420WithColorSpace(r, cs)
+	case frame.FormatI444:
+		var yuvImg image.YCbCr
+		return ReaderFunc(func() (image.Image, error) {
+			img, err := r.Read()
+			if err != nil {
+				return nil, err
+			}
+
+			imageToYCbCr(&yuvImg, img, cs)
+			if yuvImg.SubsampleRatio != image.YCbCrSubsampleRatio444 {
+				return nil, fmt.Errorf("unsupported pixel format: %s", yuvImg.SubsampleRatio)
+			}
+			return &yuvImg, nil
+		})
+	case frame.FormatRGBA:
+		return ToRGBA(r)
+	case frame.FormatBGRA:
+		return ReaderFunc(func() (image.Image, error) {
+			return nil, fmt.Errorf("unsupported pixel format: %s, use %s instead", f, frame.FormatRGBA)
+		})
+	default:
+		return ReaderFunc(func() (image.Image, error) {
+			return nil, fmt.Errorf("unsupported pixel format: %s", f)
+		})
+	}
+}
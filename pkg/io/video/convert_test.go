@@ -4,6 +4,8 @@ import (
 	"image"
 	"reflect"
 	"testing"
+
+	"github.com/pion/mediadevices/pkg/frame"
 )
 
 var imageSizes = map[string][2]int{
@@ -108,6 +110,48 @@ func TestToI420(t *testing.T) {
 				Rect:    image.Rect(0, 0, 4, 4),
 			},
 		},
+		"I444Averaged": {
+			// Chroma of each 2x2 block is averaged with truncation
+			src: &image.YCbCr{
+				SubsampleRatio: image.YCbCrSubsampleRatio444,
+				Y:              []uint8{0x10, 0x20, 0x30, 0x40},
+				Cb:             []uint8{0x10, 0x20, 0x30, 0x41},
+				Cr:             []uint8{0xFF, 0xFF, 0x00, 0x01},
+				YStride:        2,
+				CStride:        2,
+				Rect:           image.Rect(0, 0, 2, 2),
+			},
+			expected: &image.YCbCr{
+				SubsampleRatio: image.YCbCrSubsampleRatio420,
+				Y:              []uint8{0x10, 0x20, 0x30, 0x40},
+				Cb:             []uint8{0x28},
+				Cr:             []uint8{0x7F},
+				YStride:        2,
+				CStride:        1,
+				Rect:           image.Rect(0, 0, 2, 2),
+			},
+		},
+		"I422Averaged": {
+			// Chroma of two vertical pixels is averaged with truncation
+			src: &image.YCbCr{
+				SubsampleRatio: image.YCbCrSubsampleRatio422,
+				Y:              []uint8{0x10, 0x20, 0x30, 0x40},
+				Cb:             []uint8{0x10, 0x21},
+				Cr:             []uint8{0xF0, 0x00},
+				YStride:        2,
+				CStride:        1,
+				Rect:           image.Rect(0, 0, 2, 2),
+			},
+			expected: &image.YCbCr{
+				SubsampleRatio: image.YCbCrSubsampleRatio420,
+				Y:              []uint8{0x10, 0x20, 0x30, 0x40},
+				Cb:             []uint8{0x18},
+				Cr:             []uint8{0x78},
+				YStride:        2,
+				CStride:        1,
+				Rect:           image.Rect(0, 0, 2, 2),
+			},
+		},
 		"RGBA": {
 			src: &image.RGBA{
 				Pix: []uint8{
@@ -251,6 +295,122 @@ func BenchmarkToI420(b *testing.B) {
 	}
 }
 
+func TestFromRGBA(t *testing.T) {
+	// Full range BT.601 values
+	colors := map[string]struct {
+		rgb   [3]uint8
+		ycbcr [3]uint8
+	}{
+		"Red":   {[3]uint8{0xFF, 0x00, 0x00}, [3]uint8{76, 85, 255}},
+		"Green": {[3]uint8{0x00, 0xFF, 0x00}, [3]uint8{150, 44, 21}},
+		"Blue":  {[3]uint8{0x00, 0x00, 0xFF}, [3]uint8{29, 255, 107}},
+		"White": {[3]uint8{0xFF, 0xFF, 0xFF}, [3]uint8{255, 128, 128}},
+		"Black": {[3]uint8{0x00, 0x00, 0x00}, [3]uint8{0, 128, 128}},
+	}
+	near := func(a, b uint8) bool {
+		return a-b <= 2 || b-a <= 2
+	}
+
+	for name, c := range colors {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+			for i := 0; i < len(src.Pix); i += 4 {
+				src.Pix[i+0], src.Pix[i+1], src.Pix[i+2], src.Pix[i+3] = c.rgb[0], c.rgb[1], c.rgb[2], 0xFF
+			}
+			r := ReaderFunc(func() (image.Image, error) {
+				return src, nil
+			})
+
+			for _, format := range []frame.Format{frame.FormatI444, frame.FormatI420} {
+				img, err := FromRGBA(r, format).Read()
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				yuv := img.(*image.YCbCr)
+				got := [3]uint8{yuv.Y[0], yuv.Cb[0], yuv.Cr[0]}
+				if !near(got[0], c.ycbcr[0]) || !near(got[1], c.ycbcr[1]) || !near(got[2], c.ycbcr[2]) {
+					t.Errorf("%s: expected YCbCr %v, got %v", format, c.ycbcr, got)
+				}
+
+				// Convert back to RGBA
+				rgba, err := ToRGBA(ReaderFunc(func() (image.Image, error) {
+					return img, nil
+				})).Read()
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				p := rgba.(*image.RGBA).RGBAAt(0, 0)
+				if !near(p.R, c.rgb[0]) || !near(p.G, c.rgb[1]) || !near(p.B, c.rgb[2]) {
+					t.Errorf("%s: expected RGB %v after round trip, got %v", format, c.rgb, p)
+				}
+			}
+		})
+	}
+
+	for _, format := range []frame.Format{frame.FormatMJPEG, frame.FormatBGRA} {
+		if img, err := FromRGBA(ReaderFunc(func() (image.Image, error) {
+			return image.NewRGBA(image.Rect(0, 0, 2, 2)), nil
+		}), format).Read(); err == nil {
+			t.Errorf("Expected an error on unsupported format %s, got %T", format, img)
+		}
+	}
+}
+
+func TestColorSpaceConversion(t *testing.T) {
+	src := image.NewYCbCr(image.Rect(0, 0, 2, 2), image.YCbCrSubsampleRatio420)
+	for i := range src.Y {
+		src.Y[i] = 100
+	}
+	src.Cb[0], src.Cr[0] = 100, 180
+	r := ReaderFunc(func() (image.Image, error) {
+		return src, nil
+	})
+
+	bt709 := frame.ColorSpace{Matrix: frame.ColorMatrixBT709, Range: frame.ColorRangeFull}
+	cases := map[string]struct {
+		cs  frame.ColorSpace
+		rgb [3]uint8
+	}{
+		"BT601": {frame.ColorSpace{Matrix: frame.ColorMatrixBT601}, [3]uint8{173, 73, 50}},
+		"BT709": {bt709, [3]uint8{182, 81, 48}},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			img, err := ToRGBAWithColorSpace(r, c.cs).Read()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			p := img.(*image.RGBA).RGBAAt(1, 1)
+			// BT.601 may use the integer conversion of image/color
+			near := func(a, b uint8) bool {
+				d := int(a) - int(b)
+				return -1 <= d && d <= 1
+			}
+			if !near(p.R, c.rgb[0]) || !near(p.G, c.rgb[1]) || !near(p.B, c.rgb[2]) || p.A != 0xFF {
+				t.Errorf("Expected RGB %v, got %v", c.rgb, p)
+			}
+		})
+	}
+
+	limited := frame.ColorSpace{Matrix: frame.ColorMatrixBT709, Range: frame.ColorRangeLimited}
+	white := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for i := range white.Pix {
+		white.Pix[i] = 0xFF
+	}
+	img, err := FromRGBAWithColorSpace(ReaderFunc(func() (image.Image, error) {
+		return white, nil
+	}), frame.FormatI420, limited).Read()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	yuv := img.(*image.YCbCr)
+	if got := [3]uint8{yuv.Y[0], yuv.Cb[0], yuv.Cr[0]}; got != [3]uint8{235, 128, 128} {
+		t.Errorf("Expected limited range white, got %v", got)
+	}
+}
+
 func BenchmarkToRGBA(b *testing.B) {
 	for name, sz := range imageSizes {
 		cases := map[string]image.Image{
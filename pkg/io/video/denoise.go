@@ -0,0 +1,147 @@
+package video
+
+import (
+	"errors"
+	"image"
+)
+
+var errDenoiseUnsupportedImageType = errors.New("denoise: unsupported image type")
+
+// denoiseMaxThreshold is the difference of the samples treated as noise at
+// the strength 1.0. Larger differences are treated as edges or motion.
+const denoiseMaxThreshold = 32
+
+// Denoise returns video transform which reduces the spatial noise of the frames,
+// e.g. of the webcams in low light, to improve the appearance and the compression
+// at low bitrates. Each sample is replaced by the mean of the 3x3 neighbors which
+// are different from it by less than the threshold given by strength [0.0, 1.0],
+// so that the edges are kept sharp. Only *image.YCbCr frames are supported.
+func Denoise(strength float64) TransformFunc {
+	th := denoiseThreshold(strength)
+	return denoise(func(dst, src *image.YCbCr, first bool) {
+		forEachPlane(dst, src, func(dst, src []uint8, dstStride, srcStride, w, h int) {
+			denoisePlaneSpatial(dst, src, dstStride, srcStride, w, h, th)
+		})
+	})
+}
+
+// DenoiseTemporal returns video transform which reduces the noise of the static
+// area by blending the frame with the previous output. The samples different from
+// the previous output by more than the threshold given by strength [0.0, 1.0] are
+// treated as motion and passed as is to avoid the ghosting. It can be combined
+// with Denoise by Chain. Only *image.YCbCr frames are supported.
+func DenoiseTemporal(strength float64) TransformFunc {
+	th := denoiseThreshold(strength)
+	// Weight of the current frame in 1/256, from 1.0 to 0.25
+	weight := 256 - int(192*clampStrength(strength))
+	return denoise(func(dst, src *image.YCbCr, first bool) {
+		forEachPlane(dst, src, func(dst, src []uint8, dstStride, srcStride, w, h int) {
+			if first {
+				for y := 0; y < h; y++ {
+					copy(dst[y*dstStride:y*dstStride+w], src[y*srcStride:y*srcStride+w])
+				}
+				return
+			}
+			denoisePlaneTemporal(dst, src, dstStride, srcStride, w, h, th, weight)
+		})
+	})
+}
+
+func clampStrength(strength float64) float64 {
+	switch {
+	case strength < 0:
+		return 0
+	case strength > 1:
+		return 1
+	}
+	return strength
+}
+
+func denoiseThreshold(strength float64) int {
+	return int(clampStrength(strength) * denoiseMaxThreshold)
+}
+
+// denoise calls filter with the output frame reused across the frames. first is
+// true if the output frame doesn't have the previous output.
+func denoise(filter func(dst, src *image.YCbCr, first bool)) TransformFunc {
+	return func(r Reader) Reader {
+		var dst *image.YCbCr
+
+		return ReaderFunc(func() (image.Image, error) {
+			img, err := r.Read()
+			if err != nil {
+				return nil, err
+			}
+
+			v, ok := img.(*image.YCbCr)
+			if !ok {
+				return nil, errDenoiseUnsupportedImageType
+			}
+			bounds := v.Bounds()
+			if bounds.Empty() {
+				return img, nil
+			}
+
+			rect := image.Rect(0, 0, bounds.Dx(), bounds.Dy())
+			first := false
+			if dst == nil || dst.Rect != rect || dst.SubsampleRatio != v.SubsampleRatio {
+				dst = image.NewYCbCr(rect, v.SubsampleRatio)
+				first = true
+			}
+			filter(dst, v, first)
+			return dst, nil
+		})
+	}
+}
+
+// forEachPlane calls f for Y, Cb and Cr planes of src and dst.
+// dst must have the same size as src and start at the origin.
+func forEachPlane(dst, src *image.YCbCr, f func(dst, src []uint8, dstStride, srcStride, w, h int)) {
+	bounds := src.Bounds()
+	cw, ch := dst.CStride, len(dst.Cb)/dst.CStride
+	f(dst.Y, src.Y[src.YOffset(bounds.Min.X, bounds.Min.Y):], dst.YStride, src.YStride, dst.Rect.Dx(), dst.Rect.Dy())
+	cOffset := src.COffset(bounds.Min.X, bounds.Min.Y)
+	f(dst.Cb, src.Cb[cOffset:], dst.CStride, src.CStride, cw, ch)
+	f(dst.Cr, src.Cr[cOffset:], dst.CStride, src.CStride, cw, ch)
+}
+
+func denoisePlaneSpatial(dst, src []uint8, dstStride, srcStride, w, h, th int) {
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := int(src[y*srcStride+x])
+			var sum, n int
+			for yy := y - 1; yy <= y+1; yy++ {
+				if yy < 0 || yy >= h {
+					continue
+				}
+				for xx := x - 1; xx <= x+1; xx++ {
+					if xx < 0 || xx >= w {
+						continue
+					}
+					v := int(src[yy*srcStride+xx])
+					if d := v - c; -th <= d && d <= th {
+						sum += v
+						n++
+					}
+				}
+			}
+			dst[y*dstStride+x] = uint8((sum + n/2) / n)
+		}
+	}
+}
+
+// denoisePlaneTemporal blends src into dst holding the previous output.
+func denoisePlaneTemporal(dst, src []uint8, dstStride, srcStride, w, h, th, weight int) {
+	for y := 0; y < h; y++ {
+		d := dst[y*dstStride : y*dstStride+w]
+		s := src[y*srcStride : y*srcStride+w]
+		for x := range d {
+			diff := int(s[x]) - int(d[x])
+			if diff < -th || th < diff {
+				d[x] = s[x]
+				continue
+			}
+			d[x] = uint8(int(d[x]) + (diff*weight+128)>>8)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package video
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+)
+
+// noisyFrame returns a frame whose left half is 64 and right half is 192 with
+// the uniform noise of the given amplitude.
+func noisyFrame(random *rand.Rand, amplitude int) *image.YCbCr {
+	img := image.NewYCbCr(image.Rect(0, 0, 32, 16), image.YCbCrSubsampleRatio420)
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 32; x++ {
+			base := 64
+			if x >= 16 {
+				base = 192
+			}
+			img.Y[y*img.YStride+x] = uint8(base + random.Intn(2*amplitude+1) - amplitude)
+		}
+	}
+	for i := range img.Cb {
+		img.Cb[i], img.Cr[i] = 128, 128
+	}
+	return img
+}
+
+// noiseEnergy returns the mean squared difference of the horizontally adjacent
+// luma samples excluding the edge at the center.
+func noiseEnergy(img *image.YCbCr) float64 {
+	var sum, n int
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 1; x < img.Rect.Dx(); x++ {
+			if x == 16 {
+				continue
+			}
+			d := int(img.Y[y*img.YStride+x]) - int(img.Y[y*img.YStride+x-1])
+			sum += d * d
+			n++
+		}
+	}
+	return float64(sum) / float64(n)
+}
+
+func TestDenoise(t *testing.T) {
+	random := rand.New(rand.NewSource(0))
+	src := noisyFrame(random, 8)
+	r := Denoise(0.5)(ReaderFunc(func() (image.Image, error) {
+		return src, nil
+	}))
+
+	img, err := r.Read()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	out := img.(*image.YCbCr)
+
+	before, after := noiseEnergy(src), noiseEnergy(out)
+	if after > before/4 {
+		t.Errorf("Expected the noise energy to be reduced from %v to less than %v, got %v", before, before/4, after)
+	}
+	// The edge must be kept sharp
+	for y := 0; y < 16; y++ {
+		l, r := int(out.Y[y*out.YStride+15]), int(out.Y[y*out.YStride+16])
+		if l < 56 || 72 < l || r < 184 || 200 < r {
+			t.Fatalf("Expected the edge to be kept at line %d, got %d and %d", y, l, r)
+		}
+	}
+
+	if _, err := Denoise(0.5)(ReaderFunc(func() (image.Image, error) {
+		return image.NewRGBA(image.Rect(0, 0, 2, 2)), nil
+	})).Read(); err != errDenoiseUnsupportedImageType {
+		t.Errorf("Expected %v, got %v", errDenoiseUnsupportedImageType, err)
+	}
+}
+
+func TestDenoiseTemporal(t *testing.T) {
+	random := rand.New(rand.NewSource(0))
+	var src *image.YCbCr
+	r := DenoiseTemporal(1)(ReaderFunc(func() (image.Image, error) {
+		return src, nil
+	}))
+
+	// Noise of the static area is averaged over the frames
+	var out *image.YCbCr
+	for i := 0; i < 10; i++ {
+		src = noisyFrame(random, 8)
+		img, err := r.Read()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		out = img.(*image.YCbCr)
+	}
+	before, after := noiseEnergy(src), noiseEnergy(out)
+	if after > before/2 {
+		t.Errorf("Expected the noise energy to be reduced from %v to less than %v, got %v", before, before/2, after)
+	}
+
+	// Motion is passed without blending
+	src = noisyFrame(random, 0)
+	for i := range src.Y {
+		src.Y[i] = 255 - src.Y[i]
+	}
+	img, err := r.Read()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	out = img.(*image.YCbCr)
+	for i := range src.Y {
+		if out.Y[i] != src.Y[i] {
+			t.Fatalf("Expected the moved sample %d to be %d, got %d", i, src.Y[i], out.Y[i])
+		}
+	}
+}
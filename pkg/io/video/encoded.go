@@ -0,0 +1,44 @@
+package video
+
+import (
+	"errors"
+	"image"
+)
+
+var errDecoderNotAvailable = errors.New("video: encoded frames can't be decoded")
+
+// EncodedReader is a Reader of the already encoded frames, e.g. JPEG images of
+// MJPEG cameras or H.264 access units. ReadEncoded returns one encoded frame per
+// call, so that the frame boundaries are kept. The frame is only valid until the
+// next read.
+type EncodedReader interface {
+	Reader
+	ReadEncoded() ([]byte, error)
+}
+
+type encodedReader struct {
+	read   func() ([]byte, error)
+	decode func([]byte) (image.Image, error)
+}
+
+// NewEncodedReader creates an EncodedReader from the function reading encoded frames.
+// Read decodes the frame by decode. If decode is nil, Read returns an error.
+func NewEncodedReader(read func() ([]byte, error), decode func([]byte) (image.Image, error)) EncodedReader {
+	return &encodedReader{read: read, decode: decode}
+}
+
+func (r *encodedReader) ReadEncoded() ([]byte, error) {
+	return r.read()
+}
+
+func (r *encodedReader) Read() (image.Image, error) {
+	if r.decode == nil {
+		return nil, errDecoderNotAvailable
+	}
+
+	b, err := r.read()
+	if err != nil {
+		return nil, err
+	}
+	return r.decode(b)
+}
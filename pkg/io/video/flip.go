@@ -0,0 +1,82 @@
+package video
+
+import (
+	"errors"
+	"image"
+)
+
+var errFlipUnsupportedImageType = errors.New("flip: unsupported image type")
+
+// FlipH returns video transform which mirrors the frames horizontally.
+// It's useful to show the preview of the front-facing camera.
+func FlipH() TransformFunc {
+	return flip(flipPlaneH)
+}
+
+// FlipV returns video transform which flips the frames vertically.
+func FlipV() TransformFunc {
+	return flip(flipPlaneV)
+}
+
+// flipPlaneFunc flips w x h pixels of bpp bytes from src to dst.
+type flipPlaneFunc func(dst, src []uint8, dstStride, srcStride, w, h, bpp int)
+
+func flipPlaneH(dst, src []uint8, dstStride, srcStride, w, h, bpp int) {
+	for y := 0; y < h; y++ {
+		d := dst[y*dstStride : y*dstStride+w*bpp]
+		s := src[y*srcStride : y*srcStride+w*bpp]
+		for x := 0; x < w; x++ {
+			copy(d[(w-1-x)*bpp:(w-x)*bpp], s[x*bpp:(x+1)*bpp])
+		}
+	}
+}
+
+func flipPlaneV(dst, src []uint8, dstStride, srcStride, w, h, bpp int) {
+	for y := 0; y < h; y++ {
+		copy(dst[(h-1-y)*dstStride:(h-1-y)*dstStride+w*bpp], src[y*srcStride:y*srcStride+w*bpp])
+	}
+}
+
+func flip(flipPlane flipPlaneFunc) TransformFunc {
+	return func(r Reader) Reader {
+		var imgFlipped image.Image
+
+		return ReaderFunc(func() (image.Image, error) {
+			img, err := r.Read()
+			if err != nil {
+				return nil, err
+			}
+
+			bounds := img.Bounds()
+			if bounds.Empty() {
+				return img, nil
+			}
+			rect := image.Rect(0, 0, bounds.Dx(), bounds.Dy())
+
+			switch v := img.(type) {
+			case *image.YCbCr:
+				dst, ok := imgFlipped.(*image.YCbCr)
+				if !ok || dst.Rect != rect || dst.SubsampleRatio != v.SubsampleRatio {
+					dst = image.NewYCbCr(rect, v.SubsampleRatio)
+					imgFlipped = dst
+				}
+				cw, ch := dst.CStride, len(dst.Cb)/dst.CStride
+				flipPlane(dst.Y, v.Y[v.YOffset(bounds.Min.X, bounds.Min.Y):], dst.YStride, v.YStride, rect.Dx(), rect.Dy(), 1)
+				cOffset := v.COffset(bounds.Min.X, bounds.Min.Y)
+				flipPlane(dst.Cb, v.Cb[cOffset:], dst.CStride, v.CStride, cw, ch, 1)
+				flipPlane(dst.Cr, v.Cr[cOffset:], dst.CStride, v.CStride, cw, ch, 1)
+				return dst, nil
+			case *image.RGBA:
+				dst, ok := imgFlipped.(*image.RGBA)
+				if !ok || dst.Rect != rect {
+					dst = image.NewRGBA(rect)
+					imgFlipped = dst
+				}
+				flipPlane(dst.Pix, v.Pix[v.PixOffset(bounds.Min.X, bounds.Min.Y):], dst.Stride, v.Stride, rect.Dx(), rect.Dy(), 4)
+				return dst, nil
+			default:
+				return nil, errFlipUnsupportedImageType
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package video
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestFlip(t *testing.T) {
+	cases := map[string]struct {
+		src       image.Image
+		expectedH image.Image
+		expectedV image.Image
+	}{
+		"RGBA": {
+			src: &image.RGBA{
+				Pix: []uint8{
+					0x00, 0x01, 0x02, 0x03, 0x10, 0x11, 0x12, 0x13, 0x20, 0x21, 0x22, 0x23,
+					0x30, 0x31, 0x32, 0x33, 0x40, 0x41, 0x42, 0x43, 0x50, 0x51, 0x52, 0x53,
+				},
+				Stride: 12,
+				Rect:   image.Rect(0, 0, 3, 2),
+			},
+			expectedH: &image.RGBA{
+				Pix: []uint8{
+					0x20, 0x21, 0x22, 0x23, 0x10, 0x11, 0x12, 0x13, 0x00, 0x01, 0x02, 0x03,
+					0x50, 0x51, 0x52, 0x53, 0x40, 0x41, 0x42, 0x43, 0x30, 0x31, 0x32, 0x33,
+				},
+				Stride: 12,
+				Rect:   image.Rect(0, 0, 3, 2),
+			},
+			expectedV: &image.RGBA{
+				Pix: []uint8{
+					0x30, 0x31, 0x32, 0x33, 0x40, 0x41, 0x42, 0x43, 0x50, 0x51, 0x52, 0x53,
+					0x00, 0x01, 0x02, 0x03, 0x10, 0x11, 0x12, 0x13, 0x20, 0x21, 0x22, 0x23,
+				},
+				Stride: 12,
+				Rect:   image.Rect(0, 0, 3, 2),
+			},
+		},
+		"I420": {
+			src: &image.YCbCr{
+				Y: []uint8{
+					0x00, 0x01, 0x02, 0x03,
+					0x10, 0x11, 0x12, 0x13,
+					0x20, 0x21, 0x22, 0x23,
+					0x30, 0x31, 0x32, 0x33,
+				},
+				Cb:             []uint8{0x40, 0x41, 0x50, 0x51},
+				Cr:             []uint8{0x60, 0x61, 0x70, 0x71},
+				YStride:        4,
+				CStride:        2,
+				SubsampleRatio: image.YCbCrSubsampleRatio420,
+				Rect:           image.Rect(0, 0, 4, 4),
+			},
+			expectedH: &image.YCbCr{
+				Y: []uint8{
+					0x03, 0x02, 0x01, 0x00,
+					0x13, 0x12, 0x11, 0x10,
+					0x23, 0x22, 0x21, 0x20,
+					0x33, 0x32, 0x31, 0x30,
+				},
+				Cb:             []uint8{0x41, 0x40, 0x51, 0x50},
+				Cr:             []uint8{0x61, 0x60, 0x71, 0x70},
+				YStride:        4,
+				CStride:        2,
+				SubsampleRatio: image.YCbCrSubsampleRatio420,
+				Rect:           image.Rect(0, 0, 4, 4),
+			},
+			expectedV: &image.YCbCr{
+				Y: []uint8{
+					0x30, 0x31, 0x32, 0x33,
+					0x20, 0x21, 0x22, 0x23,
+					0x10, 0x11, 0x12, 0x13,
+					0x00, 0x01, 0x02, 0x03,
+				},
+				Cb:             []uint8{0x50, 0x51, 0x40, 0x41},
+				Cr:             []uint8{0x70, 0x71, 0x60, 0x61},
+				YStride:        4,
+				CStride:        2,
+				SubsampleRatio: image.YCbCrSubsampleRatio420,
+				Rect:           image.Rect(0, 0, 4, 4),
+			},
+		},
+		"SubImage": {
+			src: (&image.RGBA{
+				Pix: []uint8{
+					0x00, 0x01, 0x02, 0x03, 0x10, 0x11, 0x12, 0x13, 0x20, 0x21, 0x22, 0x23,
+					0x30, 0x31, 0x32, 0x33, 0x40, 0x41, 0x42, 0x43, 0x50, 0x51, 0x52, 0x53,
+				},
+				Stride: 12,
+				Rect:   image.Rect(0, 0, 3, 2),
+			}).SubImage(image.Rect(1, 0, 3, 2)),
+			expectedH: &image.RGBA{
+				Pix: []uint8{
+					0x20, 0x21, 0x22, 0x23, 0x10, 0x11, 0x12, 0x13,
+					0x50, 0x51, 0x52, 0x53, 0x40, 0x41, 0x42, 0x43,
+				},
+				Stride: 8,
+				Rect:   image.Rect(0, 0, 2, 2),
+			},
+			expectedV: &image.RGBA{
+				Pix: []uint8{
+					0x40, 0x41, 0x42, 0x43, 0x50, 0x51, 0x52, 0x53,
+					0x10, 0x11, 0x12, 0x13, 0x20, 0x21, 0x22, 0x23,
+				},
+				Stride: 8,
+				Rect:   image.Rect(0, 0, 2, 2),
+			},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			src := ReaderFunc(func() (image.Image, error) {
+				return c.src, nil
+			})
+			for dir, tc := range map[string]struct {
+				transform TransformFunc
+				expected  image.Image
+			}{
+				"FlipH": {FlipH(), c.expectedH},
+				"FlipV": {FlipV(), c.expectedV},
+			} {
+				r := tc.transform(src)
+				for i := 0; i < 2; i++ {
+					// Run twice to check the reused buffer
+					img, err := r.Read()
+					if err != nil {
+						t.Fatalf("Unexpected error: %v", err)
+					}
+					if !reflect.DeepEqual(tc.expected, img) {
+						t.Errorf("%s: Expected output image:\n%v\ngot:\n%v", dir, tc.expected, img)
+					}
+				}
+			}
+		})
+	}
+
+	t.Run("Twice", func(t *testing.T) {
+		img := image.NewYCbCr(image.Rect(0, 0, 6, 4), image.YCbCrSubsampleRatio420)
+		for i := range img.Y {
+			img.Y[i] = uint8(i)
+		}
+		for i := range img.Cb {
+			img.Cb[i], img.Cr[i] = uint8(i), uint8(i+100)
+		}
+		src := ReaderFunc(func() (image.Image, error) {
+			return img, nil
+		})
+		for name, transform := range map[string]TransformFunc{
+			"FlipH": Merge(FlipH(), FlipH()),
+			"FlipV": Merge(FlipV(), FlipV()),
+		} {
+			out, err := transform(src).Read()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(img, out) {
+				t.Errorf("%s: Flipping twice must be identical to the source", name)
+			}
+		}
+	})
+}
@@ -0,0 +1,42 @@
+package video
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/pion/mediadevices/pkg/frame"
+)
+
+// Layout describes the memory layout of a frame.
+type Layout struct {
+	Format frame.Format
+	// Strides of the planes. YUV formats have Y, Cb and Cr planes, and RGB formats
+	// have only the first one.
+	Strides [3]int
+}
+
+// FrameInfo returns the memory layout of img, so that the transforms can access
+// the pixels efficiently without a type switch on every concrete image type.
+// The drivers produce *image.YCbCr in I420, I422 or I444, or *image.RGBA.
+// It returns an error for the other types.
+func FrameInfo(img image.Image) (Layout, error) {
+	switch v := img.(type) {
+	case *image.YCbCr:
+		l := Layout{Strides: [3]int{v.YStride, v.CStride, v.CStride}}
+		switch v.SubsampleRatio {
+		case image.YCbCrSubsampleRatio420:
+			l.Format = frame.FormatI420
+		case image.YCbCrSubsampleRatio422:
+			l.Format = frame.FormatI422
+		case image.YCbCrSubsampleRatio444:
+			l.Format = frame.FormatI444
+		default:
+			return Layout{}, fmt.Errorf("unsupported pixel format: %s", v.SubsampleRatio)
+		}
+		return l, nil
+	case *image.RGBA:
+		return Layout{Format: frame.FormatRGBA, Strides: [3]int{v.Stride}}, nil
+	default:
+		return Layout{}, fmt.Errorf("unsupported image type: %T", img)
+	}
+}
@@ -0,0 +1,49 @@
+package video
+
+import (
+	"image"
+	"testing"
+
+	"github.com/pion/mediadevices/pkg/frame"
+)
+
+func TestFrameInfo(t *testing.T) {
+	rect := image.Rect(0, 0, 8, 4)
+	cases := map[string]struct {
+		img      image.Image
+		expected Layout
+	}{
+		"I420": {
+			image.NewYCbCr(rect, image.YCbCrSubsampleRatio420),
+			Layout{Format: frame.FormatI420, Strides: [3]int{8, 4, 4}},
+		},
+		"I422": {
+			image.NewYCbCr(rect, image.YCbCrSubsampleRatio422),
+			Layout{Format: frame.FormatI422, Strides: [3]int{8, 4, 4}},
+		},
+		"I444": {
+			image.NewYCbCr(rect, image.YCbCrSubsampleRatio444),
+			Layout{Format: frame.FormatI444, Strides: [3]int{8, 8, 8}},
+		},
+		"RGBA": {
+			image.NewRGBA(rect),
+			Layout{Format: frame.FormatRGBA, Strides: [3]int{32}},
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			l, err := FrameInfo(c.img)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if l != c.expected {
+				t.Errorf("Expected %+v, got %+v", c.expected, l)
+			}
+		})
+	}
+
+	if _, err := FrameInfo(image.NewGray(rect)); err == nil {
+		t.Error("Expected error on unsupported image type")
+	}
+}
@@ -0,0 +1,67 @@
+package video
+
+import (
+	"image"
+	"sync"
+)
+
+// Observe returns a transform which passes frames through as is and calls fn
+// with a copy of each frame in a separate goroutine. fn is called on best-effort
+// basis; if fn is still processing a previous frame, only the latest frame is kept
+// and the others are dropped, so that a slow fn never stalls the downstream. The
+// goroutine exits when no frame is left, so it doesn't outlive the reader.
+//
+// The returned Reader keeps RawReader and EncodedReader of r. The raw frames keep
+// their timestamps, and the encoded frames are passed through without calling fn.
+func Observe(fn func(image.Image)) TransformFunc {
+	return func(r Reader) Reader {
+		c := &latestCaller{fn: fn}
+		return keepEncoded(r, mapFrames(r, func(img image.Image, err error) (image.Image, error) {
+			if err != nil {
+				return nil, err
+			}
+			// Upstream reader may reuse the image buffer, so it has to be copied.
+			c.call(cloneImage(img))
+			return img, nil
+		}))
+	}
+}
+
+// latestCaller calls fn with the latest image passed to call in a goroutine.
+// The goroutine is started by call and exits when no image is left to process,
+// so that it's not left behind when the reader is no longer read.
+type latestCaller struct {
+	fn func(image.Image)
+
+	mu      sync.Mutex
+	latest  image.Image
+	running bool
+}
+
+// call replaces the image not yet taken by fn with img.
+func (c *latestCaller) call(img image.Image) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.latest = img
+	if !c.running {
+		c.running = true
+		go c.run()
+	}
+}
+
+func (c *latestCaller) run() {
+	for {
+		c.mu.Lock()
+		img := c.latest
+		c.latest = nil
+		if img == nil {
+			c.running = false
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+
+		c.fn(img)
+	}
+}
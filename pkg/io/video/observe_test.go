@@ -0,0 +1,101 @@
+package video
+
+import (
+	"image"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestObserve(t *testing.T) {
+	const nFrames = 10
+
+	t.Run("CountFrames", func(t *testing.T) {
+		img := image.NewGray(image.Rect(0, 0, 1, 1))
+		var cnt uint8
+		var r Reader = ReaderFunc(func() (image.Image, error) {
+			if cnt == nFrames {
+				return nil, io.EOF
+			}
+			// Give enough time to the callback to catch up
+			time.Sleep(5 * time.Millisecond)
+			img.Pix[0] = cnt
+			cnt++
+			return img, nil
+		})
+
+		var mu sync.Mutex
+		var got []uint8
+		r = Observe(func(img image.Image) {
+			mu.Lock()
+			got = append(got, img.(*image.Gray).Pix[0])
+			mu.Unlock()
+		})(r)
+
+		for {
+			if _, err := r.Read(); err == io.EOF {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(got) != nFrames {
+			t.Fatalf("Expected %d callbacks, got %d", nFrames, len(got))
+		}
+		for i, v := range got {
+			if int(v) != i {
+				t.Errorf("Expected frame %d, got %d", i, v)
+			}
+		}
+	})
+
+	t.Run("SlowCallback", func(t *testing.T) {
+		img := image.NewGray(image.Rect(0, 0, 1, 1))
+		var cnt int
+		var r Reader = ReaderFunc(func() (image.Image, error) {
+			if cnt == nFrames {
+				return nil, io.EOF
+			}
+			cnt++
+			return img, nil
+		})
+
+		release := make(chan struct{})
+		var mu sync.Mutex
+		var called int
+		r = Observe(func(image.Image) {
+			<-release
+			mu.Lock()
+			called++
+			mu.Unlock()
+		})(r)
+
+		done := make(chan struct{})
+		go func() {
+			for {
+				if _, err := r.Read(); err == io.EOF {
+					close(done)
+					return
+				}
+			}
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Slow callback blocked the reader")
+		}
+		close(release)
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		// A frame being processed and the latest frame are expected to be observed.
+		if called == 0 || called > 2 {
+			t.Errorf("Expected 1 or 2 callbacks, got %d", called)
+		}
+	})
+}
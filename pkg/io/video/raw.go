@@ -0,0 +1,265 @@
+package video
+
+import (
+	"fmt"
+	"image"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/frame"
+)
+
+// RawFrame is a video frame stored as byte planes. Supported formats are
+// FormatI420, FormatI422 and FormatI444, which use Y, Cb and Cr planes, and FormatRGBA,
+// which uses the first plane only. Unused planes are nil.
+type RawFrame struct {
+	Format  frame.Format
+	Width   int
+	Height  int
+	Planes  [3][]byte
+	Strides [3]int
+	// Timestamp is the capture time of the frame. It's zero if the source doesn't
+	// know it, and then the encoders use the time when the frame is read.
+	Timestamp time.Time
+}
+
+// RawReader is a Reader which can also provide frames as byte planes.
+// ReadRaw doesn't allocate image.Image, so encoders prefer it if available.
+// The planes are only valid until the next read.
+type RawReader interface {
+	Reader
+	ReadRaw() (RawFrame, error)
+}
+
+// RawReaderFunc is a proxy type for RawReader. Read wraps the planes by image.Image
+// without copying them.
+type RawReaderFunc func() (RawFrame, error)
+
+func (rf RawReaderFunc) ReadRaw() (RawFrame, error) {
+	return rf()
+}
+
+func (rf RawReaderFunc) Read() (image.Image, error) {
+	f, err := rf()
+	if err != nil {
+		return nil, err
+	}
+
+	return f.Image()
+}
+
+// NewI420Frame splits an I420 frame into planes without copying it.
+func NewI420Frame(b []byte, width, height int) (RawFrame, error) {
+	yi := width * height
+	cbi := yi + width*height/4
+	cri := cbi + width*height/4
+
+	if cri > len(b) {
+		return RawFrame{}, fmt.Errorf("frame length (%d) less than expected (%d)", len(b), cri)
+	}
+
+	return RawFrame{
+		Format:  frame.FormatI420,
+		Width:   width,
+		Height:  height,
+		Planes:  [3][]byte{b[:yi], b[yi:cbi], b[cbi:cri]},
+		Strides: [3]int{width, width / 2, width / 2},
+	}, nil
+}
+
+// NewRawFrame wraps img captured at timestamp by RawFrame without copying it.
+// img must be *image.YCbCr of I420, I422 or I444, or *image.RGBA.
+func NewRawFrame(img image.Image, timestamp time.Time) (RawFrame, error) {
+	f, err := rawFrameFromImage(img)
+	f.Timestamp = timestamp
+	return f, err
+}
+
+// Image returns an image.Image sharing the planes of f.
+func (f *RawFrame) Image() (image.Image, error) {
+	rect := image.Rect(0, 0, f.Width, f.Height)
+	switch f.Format {
+	case frame.FormatI420, frame.FormatI422, frame.FormatI444:
+		ratio := image.YCbCrSubsampleRatio420
+		switch f.Format {
+		case frame.FormatI422:
+			ratio = image.YCbCrSubsampleRatio422
+		case frame.FormatI444:
+			ratio = image.YCbCrSubsampleRatio444
+		}
+		return &image.YCbCr{
+			Y:              f.Planes[0],
+			Cb:             f.Planes[1],
+			Cr:             f.Planes[2],
+			YStride:        f.Strides[0],
+			CStride:        f.Strides[1],
+			SubsampleRatio: ratio,
+			Rect:           rect,
+		}, nil
+	case frame.FormatRGBA:
+		return &image.RGBA{
+			Pix:    f.Planes[0],
+			Stride: f.Strides[0],
+			Rect:   rect,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported pixel format: %s", f.Format)
+	}
+}
+
+// rawFrameFromImage returns a RawFrame sharing the pixels of img.
+func rawFrameFromImage(img image.Image) (RawFrame, error) {
+	bounds := img.Bounds()
+	f := RawFrame{
+		Width:  bounds.Dx(),
+		Height: bounds.Dy(),
+	}
+
+	switch v := img.(type) {
+	case *image.YCbCr:
+		switch v.SubsampleRatio {
+		case image.YCbCrSubsampleRatio420:
+			f.Format = frame.FormatI420
+		case image.YCbCrSubsampleRatio422:
+			f.Format = frame.FormatI422
+		case image.YCbCrSubsampleRatio444:
+			f.Format = frame.FormatI444
+		default:
+			return RawFrame{}, fmt.Errorf("unsupported pixel format: %s", v.SubsampleRatio)
+		}
+		f.Planes = [3][]byte{
+			v.Y[v.YOffset(bounds.Min.X, bounds.Min.Y):],
+			v.Cb[v.COffset(bounds.Min.X, bounds.Min.Y):],
+			v.Cr[v.COffset(bounds.Min.X, bounds.Min.Y):],
+		}
+		f.Strides = [3]int{v.YStride, v.CStride, v.CStride}
+	case *image.RGBA:
+		f.Format = frame.FormatRGBA
+		f.Planes[0] = v.Pix[v.PixOffset(bounds.Min.X, bounds.Min.Y):]
+		f.Strides[0] = v.Stride
+	default:
+		return RawFrame{}, fmt.Errorf("unsupported image type: %T", img)
+	}
+
+	return f, nil
+}
+
+// ToRaw converts r to a RawReader. r is returned as is if it's already a RawReader.
+// Otherwise, the frames are read as image.Image and the planes are shared with it.
+func ToRaw(r Reader) RawReader {
+	if rr, ok := r.(RawReader); ok {
+		return rr
+	}
+
+	return &rawReader{r: r}
+}
+
+type rawReader struct {
+	r Reader
+}
+
+func (r *rawReader) Read() (image.Image, error) {
+	return r.r.Read()
+}
+
+func (r *rawReader) ReadRaw() (RawFrame, error) {
+	img, err := r.r.Read()
+	if err != nil {
+		return RawFrame{}, err
+	}
+
+	return rawFrameFromImage(img)
+}
+
+// readImage reads a frame of r with its timestamp, which is zero if r is not
+// a RawReader.
+func readImage(r Reader) (image.Image, time.Time, error) {
+	rr, ok := r.(RawReader)
+	if !ok {
+		img, err := r.Read()
+		return img, time.Time{}, err
+	}
+
+	f, err := rr.ReadRaw()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	img, err := f.Image()
+	return img, f.Timestamp, err
+}
+
+// mapFrames returns a Reader passing the frames of r and the errors through fn,
+// which may replace the frame. If r is a RawReader, so is the returned Reader, and
+// ReadRaw keeps the timestamps of the frames. The frames which are not replaced by
+// fn are passed through as is.
+func mapFrames(r Reader, fn func(image.Image, error) (image.Image, error)) Reader {
+	m := &frameMapper{r: r, fn: fn}
+	if rr, ok := r.(RawReader); ok {
+		return &rawFrameMapper{frameMapper: m, rr: rr}
+	}
+	return m
+}
+
+// keepEncoded returns mapped which also implements EncodedReader passing the
+// encoded frames of r through if r is an EncodedReader. It's for the transforms
+// which don't modify the frames, since the encoded frames are not decoded.
+func keepEncoded(r, mapped Reader) Reader {
+	er, ok := r.(EncodedReader)
+	if !ok {
+		return mapped
+	}
+	if rr, ok := mapped.(RawReader); ok {
+		return struct {
+			RawReader
+			encodedPass
+		}{rr, encodedPass{er}}
+	}
+	return struct {
+		Reader
+		encodedPass
+	}{mapped, encodedPass{er}}
+}
+
+type frameMapper struct {
+	r  Reader
+	fn func(image.Image, error) (image.Image, error)
+}
+
+func (m *frameMapper) Read() (image.Image, error) {
+	return m.fn(m.r.Read())
+}
+
+type rawFrameMapper struct {
+	*frameMapper
+	rr RawReader
+}
+
+func (m *rawFrameMapper) ReadRaw() (RawFrame, error) {
+	f, err := m.rr.ReadRaw()
+	if err != nil {
+		_, err = m.fn(nil, err)
+		return RawFrame{}, err
+	}
+	img, err := f.Image()
+	if err != nil {
+		return RawFrame{}, err
+	}
+
+	mapped, err := m.fn(img, nil)
+	if err != nil {
+		return RawFrame{}, err
+	}
+	if mapped == img {
+		return f, nil
+	}
+	mf, err := rawFrameFromImage(mapped)
+	mf.Timestamp = f.Timestamp
+	return mf, err
+}
+
+type encodedPass struct {
+	er EncodedReader
+}
+
+func (p encodedPass) ReadEncoded() ([]byte, error) {
+	return p.er.ReadEncoded()
+}
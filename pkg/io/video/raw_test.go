@@ -0,0 +1,229 @@
+package video
+
+import (
+	"image"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/frame"
+)
+
+func TestRawFrameImage(t *testing.T) {
+	b := []byte{
+		0x01, 0x02, 0x03, 0x04,
+		0x05, 0x06, 0x07, 0x08,
+		0x10, 0x20,
+		0x30, 0x40,
+	}
+	f, err := NewI420Frame(b, 4, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := &image.YCbCr{
+		Y:              b[:8],
+		Cb:             b[8:10],
+		Cr:             b[10:12],
+		YStride:        4,
+		CStride:        2,
+		SubsampleRatio: image.YCbCrSubsampleRatio420,
+		Rect:           image.Rect(0, 0, 4, 2),
+	}
+	img, err := f.Image()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(expected, img) {
+		t.Errorf("Expected image:\n%v\ngot:\n%v", expected, img)
+	}
+
+	if _, err := NewI420Frame(b[:11], 4, 2); err == nil {
+		t.Error("Expected error on short frame")
+	}
+}
+
+func TestToRaw(t *testing.T) {
+	t.Run("RawReader", func(t *testing.T) {
+		r := RawReaderFunc(func() (RawFrame, error) {
+			return RawFrame{}, nil
+		})
+		if _, ok := ToRaw(r).(RawReaderFunc); !ok {
+			t.Error("RawReader must be returned as is")
+		}
+	})
+	t.Run("Reader", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+		r := ToRaw(ReaderFunc(func() (image.Image, error) {
+			return img, nil
+		}))
+		f, err := r.ReadRaw()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if f.Format != frame.FormatRGBA || f.Width != 2 || f.Height != 2 || f.Strides[0] != 8 {
+			t.Errorf("Unexpected frame: %+v", f)
+		}
+		if &f.Planes[0][0] != &img.Pix[0] {
+			t.Error("Pixels must be shared with the image")
+		}
+	})
+	t.Run("I422", func(t *testing.T) {
+		img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio422)
+		r := ToRaw(ReaderFunc(func() (image.Image, error) {
+			return img, nil
+		}))
+		f, err := r.ReadRaw()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if f.Format != frame.FormatI422 || f.Strides != [3]int{4, 2, 2} {
+			t.Errorf("Unexpected frame: %+v", f)
+		}
+		decoded, err := f.Image()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(img, decoded) {
+			t.Errorf("Expected image:\n%v\ngot:\n%v", img, decoded)
+		}
+	})
+}
+
+func TestToI420Raw(t *testing.T) {
+	t.Run("PassThrough", func(t *testing.T) {
+		b := make([]byte, 4*2*3/2)
+		r := ToRaw(ToI420(RawReaderFunc(func() (RawFrame, error) {
+			return NewI420Frame(b, 4, 2)
+		})))
+		f, err := r.ReadRaw()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if &f.Planes[0][0] != &b[0] {
+			t.Error("I420 frame must be passed through without copy")
+		}
+	})
+	t.Run("Convert", func(t *testing.T) {
+		b := []byte{
+			0x01, 0x02, 0x03, 0x04,
+			0x05, 0x06, 0x07, 0x08,
+			0x10, 0x20, 0x30, 0x40,
+			0x10, 0x20, 0x30, 0x40,
+			0x50, 0x60, 0x70, 0x80,
+			0x50, 0x60, 0x70, 0x80,
+		}
+		r := ToRaw(ToI420(RawReaderFunc(func() (RawFrame, error) {
+			return RawFrame{
+				Format:  frame.FormatI444,
+				Width:   4,
+				Height:  2,
+				Planes:  [3][]byte{b[:8], b[8:16], b[16:24]},
+				Strides: [3]int{4, 4, 4},
+			}, nil
+		})))
+		f, err := r.ReadRaw()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expected := RawFrame{
+			Format: frame.FormatI420,
+			Width:  4,
+			Height: 2,
+			Planes: [3][]byte{
+				{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+				{0x18, 0x38},
+				{0x58, 0x78},
+			},
+			Strides: [3]int{4, 2, 2},
+		}
+		if !reflect.DeepEqual(expected, f) {
+			t.Errorf("Expected frame:\n%v\ngot:\n%v", expected, f)
+		}
+	})
+}
+
+func BenchmarkReadI420(b *testing.B) {
+	decoder, err := frame.NewDecoder(frame.FormatI420)
+	if err != nil {
+		b.Fatalf("Unexpected error: %v", err)
+	}
+
+	for name, sz := range imageSizes {
+		buf := make([]byte, sz[0]*sz[1]*3/2)
+		b.Run(name, func(b *testing.B) {
+			b.Run("Image", func(b *testing.B) {
+				r := ToI420(ReaderFunc(func() (image.Image, error) {
+					return decoder.Decode(buf, sz[0], sz[1])
+				}))
+
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					img, err := r.Read()
+					if err != nil {
+						b.Fatalf("Unexpected error: %v", err)
+					}
+					_ = img.(*image.YCbCr).Y[0]
+				}
+			})
+			b.Run("Raw", func(b *testing.B) {
+				r := ToRaw(ToI420(RawReaderFunc(func() (RawFrame, error) {
+					return NewI420Frame(buf, sz[0], sz[1])
+				})))
+
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					f, err := r.ReadRaw()
+					if err != nil {
+						b.Fatalf("Unexpected error: %v", err)
+					}
+					_ = f.Planes[0][0]
+				}
+			})
+		})
+	}
+}
+
+func TestTimestampThroughTransforms(t *testing.T) {
+	b := make([]byte, 64*48*3/2)
+	start := time.Now()
+	var cnt int
+	src := RawReaderFunc(func() (RawFrame, error) {
+		if cnt == 10 {
+			return RawFrame{}, io.EOF
+		}
+		f, err := NewI420Frame(b, 64, 48)
+		f.Timestamp = start.Add(time.Duration(cnt) * 40 * time.Millisecond)
+		cnt++
+		return f, err
+	})
+
+	r := Merge(
+		Blank(func() bool { return false }),
+		Observe(func(image.Image) {}),
+		Thumbnail(16, 12, 0, func([]byte) {}),
+		Buffer(10, DropPolicyBlock),
+		Scale(32, 24, nil),
+		DetectSceneChange(0.3, func() {}),
+	)(src)
+	rr, ok := r.(RawReader)
+	if !ok {
+		t.Fatal("Expected RawReader")
+	}
+
+	for i := 0; i < 10; i++ {
+		f, err := rr.ReadRaw()
+		if err != nil {
+			t.Fatalf("Failed to read: %v", err)
+		}
+		if f.Width != 32 || f.Height != 24 {
+			t.Errorf("Expected scaled frame of 32x24, got %dx%d", f.Width, f.Height)
+		}
+		if expected := start.Add(time.Duration(i) * 40 * time.Millisecond); !f.Timestamp.Equal(expected) {
+			t.Errorf("Expected timestamp %v, got %v", expected, f.Timestamp)
+		}
+	}
+	if _, err := rr.ReadRaw(); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package video
 import (
 	"errors"
 	"image"
+	"math"
 
 	"golang.org/x/image/draw"
 )
@@ -15,6 +16,7 @@ var (
 		"NearestNeighbor": ScalerNearestNeighbor,
 		"ApproxBiLinear":  ScalerApproxBiLinear,
 		"BiLinear":        ScalerBiLinear,
+		"Lanczos3":        ScalerLanczos3,
 	}
 )
 
@@ -27,16 +29,33 @@ var (
 	ScalerApproxBiLinear  = Scaler(draw.ApproxBiLinear)
 	ScalerBiLinear        = Scaler(draw.BiLinear)
 	ScalerCatmullRom      = Scaler(draw.CatmullRom)
+	// ScalerLanczos3 is the slowest but keeps the details and suppresses the
+	// aliasing of the downscales best, e.g. for the thumbnails and the low
+	// resolution layers of simulcast.
+	ScalerLanczos3 = Scaler(&draw.Kernel{Support: 3, At: lanczos3})
 )
 
+// lanczos3 is the Lanczos kernel with 3 lobes.
+func lanczos3(t float64) float64 {
+	if t == 0 {
+		return 1
+	}
+	x := math.Pi * t
+	return 3 * math.Sin(x) * math.Sin(x/3) / (x * x)
+}
+
 var errUnsupportedImageType = errors.New("scaling: unsupported image type")
 
 // Scale returns video scaling transform.
 // Setting scaler=nil to use default scaler. (ScalerNearestNeighbor)
+// Any interpolation kernel of draw.Kernel can be used as well as the listed ones.
 // Negative width or height value will keep the aspect ratio of incoming image.
 //
 // Note: computation cost to scale YCbCr format is 10 times higher than RGB
 // due to the implementation in x/image/draw package.
+//
+// The returned Reader keeps RawReader of r, and the scaled frames keep the
+// timestamps of the source frames.
 func Scale(width, height int, scaler Scaler) TransformFunc {
 	return func(r Reader) Reader {
 		scalerCached := ScalerNearestNeighbor
@@ -156,8 +175,7 @@ func Scale(width, height int, scaler Scaler) TransformFunc {
 			}
 		}
 
-		return ReaderFunc(func() (image.Image, error) {
-			img, err := r.Read()
+		return mapFrames(r, func(img image.Image, err error) (image.Image, error) {
 			if err != nil {
 				return nil, err
 			}
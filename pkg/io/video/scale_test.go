@@ -2,6 +2,8 @@ package video
 
 import (
 	"image"
+	"image/color"
+	"math"
 	"reflect"
 	"testing"
 )
@@ -278,3 +280,59 @@ func BenchmarkScale(b *testing.B) {
 		})
 	}
 }
+
+func TestScaleLanczos3(t *testing.T) {
+	const (
+		size   = 256
+		factor = 4
+	)
+	// The smooth pattern is kept by the downscale, and the fine stripes above
+	// the Nyquist frequency of the output must be removed to avoid aliasing.
+	smooth := func(u, v float64) float64 {
+		return 128 + 60*math.Sin(2*math.Pi*u/64)*math.Cos(2*math.Pi*v/48)
+	}
+	src := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			// Pixel values are sampled at the centers
+			u, v := float64(x)+0.5, float64(y)+0.5
+			c := uint8(smooth(u, v) + 50*math.Sin(2*math.Pi*0.4*u))
+			src.SetRGBA(x, y, color.RGBA{c, c, c, 0xFF})
+		}
+	}
+	reference := image.NewRGBA(image.Rect(0, 0, size/factor, size/factor))
+	for y := 0; y < size/factor; y++ {
+		for x := 0; x < size/factor; x++ {
+			c := uint8(smooth((float64(x)+0.5)*factor, (float64(y)+0.5)*factor))
+			reference.SetRGBA(x, y, color.RGBA{c, c, c, 0xFF})
+		}
+	}
+
+	psnr := func(scaler Scaler) float64 {
+		r := Scale(size/factor, size/factor, scaler)(ReaderFunc(func() (image.Image, error) {
+			return src, nil
+		}))
+		img, err := r.Read()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		out := img.(*image.RGBA)
+		var mse float64
+		for y := 0; y < size/factor; y++ {
+			for x := 0; x < size/factor; x++ {
+				d := float64(out.RGBAAt(x, y).R) - float64(reference.RGBAAt(x, y).R)
+				mse += d * d
+			}
+		}
+		mse /= float64(size * size / factor / factor)
+		return 10 * math.Log10(255*255/mse)
+	}
+
+	nearest, lanczos := psnr(ScalerNearestNeighbor), psnr(ScalerLanczos3)
+	if lanczos < 35 {
+		t.Errorf("Expected PSNR of Lanczos-3 over 35dB, got %.1fdB", lanczos)
+	}
+	if lanczos < nearest+10 {
+		t.Errorf("Expected PSNR of Lanczos-3 to be 10dB higher than nearest neighbor %.1fdB, got %.1fdB", nearest, lanczos)
+	}
+}
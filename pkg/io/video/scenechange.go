@@ -0,0 +1,68 @@
+package video
+
+import (
+	"image"
+	"image/color"
+)
+
+// sceneChangeGrid is the number of the sampling points in each axis
+// used to compare the frames.
+const sceneChangeGrid = 32
+
+// DetectSceneChange returns a transform which calls onChange before passing
+// the frame whose content is largely different from the previous frame.
+// The difference is the mean absolute difference of the luma sampled on
+// a downsampled grid, normalized to [0.0, 1.0]. onChange is called when
+// the difference is larger than or equal to threshold.
+//
+// The returned Reader keeps RawReader and EncodedReader of r. The encoded frames
+// are passed through without the detection since they are not decoded.
+func DetectSceneChange(threshold float64, onChange func()) TransformFunc {
+	return func(r Reader) Reader {
+		var prev, cur []uint8
+		return keepEncoded(r, mapFrames(r, func(img image.Image, err error) (image.Image, error) {
+			if err != nil {
+				return nil, err
+			}
+			if img.Bounds().Empty() {
+				return img, nil
+			}
+
+			cur = sampleLuma(img, cur[:0])
+			if len(prev) == len(cur) && meanAbsDiff(prev, cur) >= threshold {
+				onChange()
+			}
+			prev, cur = cur, prev
+			return img, nil
+		}))
+	}
+}
+
+func sampleLuma(img image.Image, dst []uint8) []uint8 {
+	bounds := img.Bounds()
+	for j := 0; j < sceneChangeGrid; j++ {
+		y := bounds.Min.Y + (2*j+1)*bounds.Dy()/(2*sceneChangeGrid)
+		for i := 0; i < sceneChangeGrid; i++ {
+			x := bounds.Min.X + (2*i+1)*bounds.Dx()/(2*sceneChangeGrid)
+			switch v := img.(type) {
+			case *image.YCbCr:
+				dst = append(dst, v.Y[v.YOffset(x, y)])
+			default:
+				dst = append(dst, color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+			}
+		}
+	}
+	return dst
+}
+
+func meanAbsDiff(a, b []uint8) float64 {
+	var sum int
+	for i := range a {
+		d := int(a[i]) - int(b[i])
+		if d < 0 {
+			d = -d
+		}
+		sum += d
+	}
+	return float64(sum) / float64(len(a)*255)
+}
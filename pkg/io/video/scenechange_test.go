@@ -0,0 +1,103 @@
+package video
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestDetectSceneChange(t *testing.T) {
+	const nFrames = 20
+
+	img := image.NewYCbCr(image.Rect(0, 0, 320, 240), image.YCbCrSubsampleRatio420)
+	var cnt int
+	r := ReaderFunc(func() (image.Image, error) {
+		if cnt == nFrames {
+			return nil, io.EOF
+		}
+		// Slightly moving gradient, and sudden switch to the different scene at the frame 10
+		for i := range img.Y {
+			if cnt < 10 {
+				img.Y[i] = uint8(i%img.YStride/2 + cnt)
+			} else {
+				img.Y[i] = uint8(255 - i%img.YStride/2 - cnt)
+			}
+		}
+		cnt++
+		return img, nil
+	})
+
+	var changed []int
+	detected := DetectSceneChange(0.3, func() {
+		// The callback is called before the frame is returned.
+		changed = append(changed, cnt-1)
+	})(r)
+
+	for {
+		if _, err := detected.Read(); err == io.EOF {
+			break
+		}
+	}
+
+	if len(changed) != 1 || changed[0] != 10 {
+		t.Errorf("Expected scene change at the frame 10, detected at %v", changed)
+	}
+}
+
+func TestDetectSceneChangeKeepsReaders(t *testing.T) {
+	t.Run("Raw", func(t *testing.T) {
+		b := make([]byte, 64*64*3/2)
+		start := time.Now()
+		var cnt int
+		r := RawReaderFunc(func() (RawFrame, error) {
+			// Switch to the white frame at the frame 1
+			for i := 0; i < 64*64; i++ {
+				b[i] = uint8(255 * cnt)
+			}
+			f, err := NewI420Frame(b, 64, 64)
+			f.Timestamp = start.Add(time.Duration(cnt) * time.Second)
+			cnt++
+			return f, err
+		})
+
+		var changed int
+		detected, ok := DetectSceneChange(0.3, func() { changed++ })(r).(RawReader)
+		if !ok {
+			t.Fatal("Expected RawReader")
+		}
+		for i := 0; i < 2; i++ {
+			f, err := detected.ReadRaw()
+			if err != nil {
+				t.Fatalf("Failed to read: %v", err)
+			}
+			if expected := start.Add(time.Duration(i) * time.Second); !f.Timestamp.Equal(expected) {
+				t.Errorf("Expected timestamp %v, got %v", expected, f.Timestamp)
+			}
+		}
+		if changed != 1 {
+			t.Errorf("Expected 1 scene change, detected %d", changed)
+		}
+	})
+	t.Run("Encoded", func(t *testing.T) {
+		encoded := []byte{0x00, 0x00, 0x00, 0x01, 0x65}
+		r := NewEncodedReader(func() ([]byte, error) {
+			return encoded, nil
+		}, nil)
+
+		detected, ok := DetectSceneChange(0.3, func() {
+			t.Error("Unexpected scene change on the encoded frame")
+		})(r).(EncodedReader)
+		if !ok {
+			t.Fatal("Expected EncodedReader")
+		}
+		b, err := detected.ReadEncoded()
+		if err != nil {
+			t.Fatalf("Failed to read: %v", err)
+		}
+		if !bytes.Equal(encoded, b) {
+			t.Errorf("Expected %v, got %v", encoded, b)
+		}
+	})
+}
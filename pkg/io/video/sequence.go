@@ -0,0 +1,141 @@
+package video
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+)
+
+// sequenceBits is the number of bits of the sequence number
+const sequenceBits = 32
+
+// sequenceBlock returns the region of the n-th bit of the sequence number
+func sequenceBlock(bounds image.Rectangle, n int) image.Rectangle {
+	w := bounds.Dx() / sequenceBits
+	if w < 1 {
+		w = 1
+	}
+	h := bounds.Dy() / 16
+	if h < 1 {
+		h = 1
+	}
+	x := bounds.Min.X + n*w
+	return image.Rect(x, bounds.Min.Y, x+w, bounds.Min.Y+h)
+}
+
+// DrawSequence draws seq on the top-left of img as a row of black and white
+// blocks from MSB to LSB. Since the blocks are large, seq can be read by
+// DecodeSequence even after scaling or lossy encoding and decoding.
+// img must be *image.YCbCr or draw.Image, otherwise it's not modified.
+func DrawSequence(img image.Image, seq uint32) {
+	for n := 0; n < sequenceBits; n++ {
+		v := uint8(16)
+		if seq&(1<<uint(sequenceBits-1-n)) != 0 {
+			v = 235
+		}
+		block := sequenceBlock(img.Bounds(), n).Intersect(img.Bounds())
+		switch img := img.(type) {
+		case *image.YCbCr:
+			for y := block.Min.Y; y < block.Max.Y; y++ {
+				for x := block.Min.X; x < block.Max.X; x++ {
+					img.Y[img.YOffset(x, y)] = v
+					ci := img.COffset(x, y)
+					img.Cb[ci] = 128
+					img.Cr[ci] = 128
+				}
+			}
+		case draw.Image:
+			draw.Draw(img, block, image.NewUniform(color.Gray{Y: v}), image.Point{}, draw.Src)
+		}
+	}
+}
+
+// DecodeSequence reads the sequence number drawn by DrawSequence.
+func DecodeSequence(img image.Image) uint32 {
+	var seq uint32
+	for n := 0; n < sequenceBits; n++ {
+		block := sequenceBlock(img.Bounds(), n)
+		center := image.Pt((block.Min.X+block.Max.X)/2, (block.Min.Y+block.Max.Y)/2)
+		gray := color.GrayModel.Convert(img.At(center.X, center.Y)).(color.Gray)
+		seq <<= 1
+		if gray.Y > 128 {
+			seq |= 1
+		}
+	}
+	return seq
+}
+
+// EmbedSequence is a transform drawing the sequence number of each frame by
+// DrawSequence, starting from 0. The frames are modified in place. It's placed
+// at the source of the frames validated by SequenceChecker.
+func EmbedSequence(r Reader) Reader {
+	var seq uint32
+	return ReaderFunc(func() (image.Image, error) {
+		img, err := r.Read()
+		if err != nil {
+			return nil, err
+		}
+		DrawSequence(img, seq)
+		seq++
+		return img, nil
+	})
+}
+
+// SequenceChecker is a Reader validating the continuity of the frames by the
+// sequence numbers drawn by DrawSequence, e.g. to debug the frames dropped or
+// duplicated by the transforms. The sequence numbers are drawn by EmbedSequence
+// or by the source itself like the counter of videopattern driver.
+type SequenceChecker struct {
+	r Reader
+
+	mu         sync.Mutex
+	started    bool
+	last       uint32
+	dropped    int
+	duplicated int
+}
+
+// NewSequenceChecker creates a SequenceChecker reading the frames from r.
+func NewSequenceChecker(r Reader) *SequenceChecker {
+	return &SequenceChecker{r: r}
+}
+
+// Read reads a frame from the underlying reader and validates its sequence number.
+func (c *SequenceChecker) Read() (image.Image, error) {
+	img, err := c.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	seq := DecodeSequence(img)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case !c.started:
+		c.started = true
+	case seq > c.last:
+		c.dropped += int(seq - c.last - 1)
+	default:
+		// Repeated or stale frame
+		c.duplicated++
+		return img, nil
+	}
+	c.last = seq
+	return img, nil
+}
+
+// Dropped returns the number of the frames missing in the sequence.
+func (c *SequenceChecker) Dropped() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
+
+// Duplicated returns the number of the frames whose sequence number is not
+// larger than the previous one.
+func (c *SequenceChecker) Duplicated() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.duplicated
+}
@@ -0,0 +1,76 @@
+package video
+
+import (
+	"image"
+	"testing"
+)
+
+func TestSequenceChecker(t *testing.T) {
+	for name, newImage := range map[string]func() image.Image{
+		"YCbCr": func() image.Image {
+			return image.NewYCbCr(image.Rect(0, 0, 64, 32), image.YCbCrSubsampleRatio420)
+		},
+		"RGBA": func() image.Image {
+			return image.NewRGBA(image.Rect(0, 0, 64, 32))
+		},
+	} {
+		newImage := newImage
+		t.Run(name, func(t *testing.T) {
+			src := EmbedSequence(ReaderFunc(func() (image.Image, error) {
+				return newImage(), nil
+			}))
+
+			// The transform drops the frame 3 and repeats the frame 6
+			var i int
+			var last image.Image
+			c := NewSequenceChecker(ReaderFunc(func() (image.Image, error) {
+				i++
+				switch i {
+				case 3:
+					if _, err := src.Read(); err != nil {
+						return nil, err
+					}
+				case 7:
+					return last, nil
+				}
+				img, err := src.Read()
+				last = img
+				return img, err
+			}))
+
+			for j := 0; j < 10; j++ {
+				if _, err := c.Read(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if n := c.Dropped(); n != 1 {
+				t.Errorf("Expected 1 dropped frame, got %d", n)
+			}
+			if n := c.Duplicated(); n != 1 {
+				t.Errorf("Expected 1 duplicated frame, got %d", n)
+			}
+		})
+	}
+}
+
+func TestDecodeSequence(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 640, 480), image.YCbCrSubsampleRatio420)
+	for _, seq := range []uint32{0, 1, 0x12345678, 0xFFFFFFFF} {
+		DrawSequence(img, seq)
+		if decoded := DecodeSequence(img); decoded != seq {
+			t.Errorf("Expected %x, got %x", seq, decoded)
+		}
+	}
+
+	// The sequence is kept after scaling
+	DrawSequence(img, 0x5A5A5A5A)
+	scaled, err := Scale(320, 240, nil)(ReaderFunc(func() (image.Image, error) {
+		return img, nil
+	})).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded := DecodeSequence(scaled); decoded != 0x5A5A5A5A {
+		t.Errorf("Expected %x after scaling, got %x", 0x5A5A5A5A, decoded)
+	}
+}
@@ -0,0 +1,53 @@
+package video
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"time"
+)
+
+// Thumbnail returns a transform which passes frames through as is and calls fn
+// with a JPEG thumbnail of the frame every interval. The frames are downscaled
+// to width and height by Scale, so a negative width or height keeps the aspect
+// ratio. The thumbnails are encoded and passed to fn in a separate goroutine on
+// best-effort basis like Observe, and the frames which can't be scaled are skipped.
+// The returned Reader keeps RawReader and EncodedReader of r like Observe.
+func Thumbnail(width, height int, interval time.Duration, fn func(jpeg []byte)) TransformFunc {
+	return func(r Reader) Reader {
+		var current image.Image
+		scaled := Scale(width, height, nil)(ReaderFunc(func() (image.Image, error) {
+			return current, nil
+		}))
+
+		var buf bytes.Buffer
+		c := &latestCaller{fn: func(img image.Image) {
+			buf.Reset()
+			if err := jpeg.Encode(&buf, img, nil); err != nil {
+				return
+			}
+			fn(append([]byte(nil), buf.Bytes()...))
+		}}
+
+		var last time.Time
+		return keepEncoded(r, mapFrames(r, func(img image.Image, err error) (image.Image, error) {
+			if err != nil {
+				return nil, err
+			}
+			if time.Since(last) < interval {
+				return img, nil
+			}
+			last = time.Now()
+
+			current = img
+			thumbnail, err := scaled.Read()
+			current = nil
+			if err != nil {
+				return img, nil
+			}
+			// The scaled image is reused by the next scaling, so it has to be copied.
+			c.call(cloneImage(thumbnail))
+			return img, nil
+		}))
+	}
+}
@@ -0,0 +1,101 @@
+package video
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"io"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestThumbnail(t *testing.T) {
+	const interval = 50 * time.Millisecond
+
+	img := image.NewYCbCr(image.Rect(0, 0, 640, 480), image.YCbCrSubsampleRatio420)
+	var cnt int
+	var r Reader = ReaderFunc(func() (image.Image, error) {
+		if cnt == 50 {
+			return nil, io.EOF
+		}
+		cnt++
+		time.Sleep(5 * time.Millisecond)
+		return img, nil
+	})
+
+	var mu sync.Mutex
+	var times []time.Time
+	var sizes []image.Point
+	r = Thumbnail(160, -1, interval, func(b []byte) {
+		thumbnail, err := jpeg.Decode(bytes.NewReader(b))
+		if err != nil {
+			t.Errorf("Failed to decode thumbnail: %v", err)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		times = append(times, time.Now())
+		sizes = append(sizes, thumbnail.Bounds().Size())
+	})(r)
+
+	var frames int
+	start := time.Now()
+	for {
+		out, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if out != img {
+			t.Fatal("Expected the frames to be passed through as is")
+		}
+		frames++
+	}
+	elapsed := time.Since(start)
+	time.Sleep(10 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if frames != 50 {
+		t.Errorf("Expected 50 frames, got %d", frames)
+	}
+	// The first frame and a frame every interval
+	expected := int(elapsed/interval) + 1
+	if n := len(times); n < expected-1 || n > expected {
+		t.Fatalf("Expected %d thumbnails in %v, got %d", expected, elapsed, n)
+	}
+	for i, size := range sizes {
+		if size != image.Pt(160, 120) {
+			t.Errorf("Expected thumbnail of 160x120, got %v", size)
+		}
+		// fn is called asynchronously, so the timing jitters
+		if i > 0 && times[i].Sub(times[i-1]) < interval/2 {
+			t.Errorf("Expected thumbnails every %v, got %v", interval, times[i].Sub(times[i-1]))
+		}
+	}
+}
+
+func TestThumbnailGoroutine(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	// The source is never ended by an error, like a stopped track
+	img := image.NewYCbCr(image.Rect(0, 0, 16, 16), image.YCbCrSubsampleRatio420)
+	r := Thumbnail(8, 8, 0, func([]byte) {})(ReaderFunc(func() (image.Image, error) {
+		return img, nil
+	}))
+	for i := 0; i < 10; i++ {
+		if _, err := r.Read(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	timeout := time.After(time.Second)
+	for runtime.NumGoroutine() > before {
+		select {
+		case <-timeout:
+			t.Fatal("Expected the goroutine encoding the thumbnails to exit")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
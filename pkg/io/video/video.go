@@ -18,7 +18,8 @@ func (rf ReaderFunc) Read() (img image.Image, err error) {
 type TransformFunc func(r Reader) Reader
 
 // Merge merges transforms and produces a new TransformFunc that will execute
-// transforms in order
+// transforms in order. The first listed transform is applied first, so
+// Merge(a, b) produces b(a(r)). nil transforms are skipped.
 func Merge(transforms ...TransformFunc) TransformFunc {
 	return func(r Reader) Reader {
 		for _, transform := range transforms {
@@ -32,3 +33,10 @@ func Merge(transforms ...TransformFunc) TransformFunc {
 		return r
 	}
 }
+
+// Chain chains transforms and produces a new TransformFunc. It's the same as
+// Merge; the first listed transform is applied first, so Chain(a, b) produces
+// b(a(r)). nil transforms are skipped.
+func Chain(transforms ...TransformFunc) TransformFunc {
+	return Merge(transforms...)
+}
@@ -0,0 +1,46 @@
+package video
+
+import (
+	"image"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	for name, merge := range map[string]func(...TransformFunc) TransformFunc{
+		"Merge": Merge,
+		"Chain": Chain,
+	} {
+		merge := merge
+		t.Run(name, func(t *testing.T) {
+			var order []string
+			stage := func(name string) TransformFunc {
+				return func(r Reader) Reader {
+					return ReaderFunc(func() (image.Image, error) {
+						img, err := r.Read()
+						order = append(order, name)
+						return img, err
+					})
+				}
+			}
+
+			src := ReaderFunc(func() (image.Image, error) {
+				return image.NewRGBA(image.Rect(0, 0, 4, 4)), nil
+			})
+			r := merge(stage("scale"), nil, stage("rotate"))(src)
+
+			if _, err := r.Read(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			expected := []string{"scale", "rotate"}
+			if len(order) != len(expected) {
+				t.Fatalf("Expected %v, got %v", expected, order)
+			}
+			for i := range expected {
+				if order[i] != expected[i] {
+					t.Fatalf("Expected %v, got %v", expected, order)
+				}
+			}
+		})
+	}
+}
@@ -20,12 +20,51 @@ func (p *Media) FitnessDistance(o Media) float64 {
 	cmps := comparisons{}
 	cmps.add(p.Width, o.Width)
 	cmps.add(p.Height, o.Height)
-	cmps.add(p.FrameFormat, o.FrameFormat)
+	if p.FrameFormat != frame.FormatAny {
+		cmps.add(p.FrameFormat, o.FrameFormat)
+	}
+	cmps.add(p.bitDepth(), o.bitDepth())
 	cmps.add(p.SampleRate, o.SampleRate)
 	cmps.add(p.Latency, o.Latency)
+	if p.FacingMode != "" {
+		cmps.add(p.FacingMode, o.FacingMode)
+	}
 	return cmps.fitnessDistance()
 }
 
+// Exact is a set of the properties which must be matched exactly. It
+// implements the exact constraints of
+// https://w3c.github.io/mediacapture-main/#dom-constraindoublerange-exact
+type Exact int
+
+// Exact definitions.
+const (
+	ExactWidth Exact = 1 << iota
+	ExactHeight
+	ExactFrameFormat
+	ExactBitDepth
+	ExactSampleRate
+	ExactLatency
+	ExactFacingMode
+)
+
+// SatisfiesExact returns true if o has the same values as p for the properties
+// in exact. The properties not in exact are not compared.
+func (p *Media) SatisfiesExact(o Media, exact Exact) bool {
+	switch {
+	case exact&ExactWidth != 0 && p.Width != o.Width:
+	case exact&ExactHeight != 0 && p.Height != o.Height:
+	case exact&ExactFrameFormat != 0 && p.FrameFormat != frame.FormatAny && p.FrameFormat != o.FrameFormat:
+	case exact&ExactBitDepth != 0 && p.bitDepth() != o.bitDepth():
+	case exact&ExactSampleRate != 0 && p.SampleRate != o.SampleRate:
+	case exact&ExactLatency != 0 && p.Latency != o.Latency:
+	case exact&ExactFacingMode != 0 && p.FacingMode != "" && p.FacingMode != o.FacingMode:
+	default:
+		return true
+	}
+	return false
+}
+
 type comparisons map[string]string
 
 func (c comparisons) add(actual, ideal interface{}) {
@@ -67,6 +106,47 @@ type Video struct {
 	Width, Height int
 	FrameRate     float32
 	FrameFormat   frame.Format
+	// PixelAspectRatio is a ratio of the pixel width to the pixel height.
+	// 0 means square pixels, which is same as 1.
+	PixelAspectRatio float64
+	// BitDepth is the number of bits per sample, e.g. 10 for FormatP010.
+	// 0 means 8 bits.
+	BitDepth int
+	// ColorSpace is the matrix and the range of the YCbCr frames, e.g. BT.709
+	// limited range of HD cameras. The zero value means BT.601 full range.
+	ColorSpace frame.ColorSpace
+	// Orientation is the clockwise rotation in degrees (0, 90, 180 or 270) to
+	// display the frames upright, e.g. of the cameras mounted sideways. Drivers
+	// set it, and the frames are not rotated by the track.
+	Orientation int
+	// FacingMode is the direction the camera faces. Drivers set it if they
+	// know it, e.g. the front and back cameras of mobile devices, and the
+	// empty value means unknown. As a constraint, the cameras facing it are
+	// preferred.
+	FacingMode FacingMode
+}
+
+// FacingMode is the direction a camera faces.
+// Reference: https://w3c.github.io/mediacapture-main/#dom-videofacingmodeenum
+type FacingMode string
+
+// FacingMode definitions.
+const (
+	// FacingModeUser faces the user, e.g. the front camera of a phone.
+	FacingModeUser FacingMode = "user"
+	// FacingModeEnvironment faces away from the user, e.g. the back camera.
+	FacingModeEnvironment FacingMode = "environment"
+	// FacingModeLeft faces to the left of the user.
+	FacingModeLeft FacingMode = "left"
+	// FacingModeRight faces to the right of the user.
+	FacingModeRight FacingMode = "right"
+)
+
+func (v *Video) bitDepth() int {
+	if v.BitDepth == 0 {
+		return 8
+	}
+	return v.BitDepth
 }
 
 // Audio represents an audio's properties
@@ -84,6 +164,15 @@ type Codec struct {
 	// Target bitrate in bps.
 	BitRate int
 
+	// BitRateMode is the rate control mode of the encoder.
+	BitRateMode BitRateMode
+
+	// Lower and upper bounds of the bitrate in bps in BitRateModeVBR.
+	// 0 means no bound. The codecs without the lower bound, openh264 and x265,
+	// ignore MinBitRate.
+	MinBitRate int
+	MaxBitRate int
+
 	// Quolity of the encoding [0-9].
 	// Larger value results higher quality and higher CPU usage.
 	// It depends on the selected codec.
@@ -91,4 +180,56 @@ type Codec struct {
 
 	// Expected interval of the keyframes in frames.
 	KeyFrameInterval int
+
+	// Number of the encoding threads.
+	// 0 means the default of the codec, which is a single thread for vpx and
+	// av1, and automatic selection by openh264 and x265.
+	Threads int
+
+	// LatencyMode is the tradeoff between the encoding latency and the
+	// efficiency, which is mapped to the codec specific settings like the
+	// lookahead and the speed presets.
+	LatencyMode LatencyMode
+
+	// Controls are the codec specific controls applied after the standard
+	// settings, e.g. {"VP8E_SET_CPUUSED": -6} for libvpx. The names supported by
+	// each codec are registered by codec.RegisterControls.
+	Controls map[string]int
+
+	// PreferHardware selects the hardware accelerated encoder registered by
+	// codec.RegisterHardware if it's available. The software encoder is used
+	// otherwise.
+	PreferHardware bool
 }
+
+// LatencyMode is a tradeoff between the latency and the efficiency of the encoding.
+type LatencyMode int
+
+// LatencyMode definitions.
+const (
+	// LatencyModeDefault uses the default settings of the codec.
+	LatencyModeDefault LatencyMode = iota
+	// LatencyModeRealtime disables the lookahead and uses the fastest settings
+	// to encode each frame without delay, e.g. for video calls.
+	LatencyModeRealtime
+	// LatencyModeBalanced allows a short lookahead and slower settings.
+	LatencyModeBalanced
+	// LatencyModeQuality uses the lookahead and the settings for the best
+	// efficiency at the cost of the latency, e.g. for broadcasting.
+	LatencyModeQuality
+)
+
+// BitRateMode is a rate control mode of the encoding.
+type BitRateMode int
+
+// BitRateMode definitions.
+const (
+	// BitRateModeDefault uses the default rate control of the codec.
+	BitRateModeDefault BitRateMode = iota
+	// BitRateModeCBR keeps the bitrate constant at the cost of the quality of
+	// the complex scenes, e.g. for the constrained links.
+	BitRateModeCBR
+	// BitRateModeVBR allows the bitrate to vary between MinBitRate and
+	// MaxBitRate to keep the quality.
+	BitRateModeVBR
+)
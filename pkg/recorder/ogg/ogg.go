@@ -0,0 +1,275 @@
+// Package ogg implements a recorder which writes encoded Opus audio samples
+// into an Ogg file.
+//
+// Reference: https://tools.ietf.org/html/rfc7845
+package ogg
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/webrtc/v2"
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+const (
+	headerTypeContinued = 0x01
+	headerTypeBOS       = 0x02
+	headerTypeEOS       = 0x04
+
+	pageHeaderSize = 27
+	maxSegments    = 255
+
+	// Granule positions of Opus streams are always counted at 48kHz
+	granuleRate = 48000
+)
+
+var (
+	errNoAudio = errors.New("ogg: audio has to be given")
+	errClosed  = errors.New("ogg: recorder is already closed")
+	crcTable   = newCRCTable()
+)
+
+// Recorder writes encoded samples of an Opus track into an Ogg file.
+//
+// Recorder.NewTrack can be used as a mediadevices.TrackGenerator:
+//
+//	rec, _ := ogg.NewRecorder(f, &prop.Audio{SampleRate: 48000, ChannelCount: 2})
+//	md := mediadevices.NewMediaDevicesFromCodecs(codecs, mediadevices.WithTrackGenerator(rec.NewTrack))
+type Recorder struct {
+	mu     sync.Mutex
+	w      io.Writer
+	audio  *prop.Audio
+	serial uint32
+	seq    uint32
+	closed bool
+
+	id      string
+	codec   *webrtc.RTPCodec
+	samples uint64
+	// pending is the last packet, held to mark the last page by EOS on Close
+	pending []byte
+	granule uint64
+}
+
+// NewRecorder creates a new Ogg recorder writing to w.
+// audio describes the track to be recorded.
+func NewRecorder(w io.Writer, audio *prop.Audio) (*Recorder, error) {
+	if audio == nil {
+		return nil, errNoAudio
+	}
+	return &Recorder{w: w, audio: audio, serial: rand.Uint32()}, nil
+}
+
+// NewTrack creates a track to be recorded. It has the same signature as mediadevices.TrackGenerator.
+func (r *Recorder) NewTrack(_ uint8, _ uint32, id, _ string, codec *webrtc.RTPCodec) (mediadevices.LocalTrack, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if codec.Name != webrtc.Opus {
+		return nil, fmt.Errorf("ogg: %s is not supported", codec.Name)
+	}
+	if r.codec != nil {
+		return nil, errors.New("ogg: audio track is already created")
+	}
+	if r.closed {
+		return nil, errClosed
+	}
+
+	if err := r.writeHeader(); err != nil {
+		return nil, err
+	}
+	r.id = id
+	r.codec = codec
+	return &track{rec: r}, nil
+}
+
+// Close writes the pending sample as the end of the stream. The underlying
+// writer is not closed.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return errClosed
+	}
+	r.closed = true
+	if r.codec == nil {
+		return nil
+	}
+	return r.writePacket(r.pending, r.granule, headerTypeEOS)
+}
+
+type track struct {
+	rec *Recorder
+}
+
+func (t *track) WriteSample(s media.Sample) error {
+	r := t.rec
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return errClosed
+	}
+
+	if r.pending != nil {
+		if err := r.writePacket(r.pending, r.granule, 0); err != nil {
+			return err
+		}
+	}
+
+	// Sample data is only valid during the call
+	r.pending = append([]byte{}, s.Data...)
+	r.samples += uint64(s.Samples)
+	r.granule = r.samples * granuleRate / uint64(r.sampleRate())
+	return nil
+}
+
+func (t *track) Codec() *webrtc.RTPCodec {
+	return t.rec.codec
+}
+
+func (t *track) ID() string {
+	return t.rec.id
+}
+
+func (t *track) Kind() webrtc.RTPCodecType {
+	return t.rec.codec.Type
+}
+
+// sampleRate returns the rate of the samples counted by media.Sample.Samples.
+func (r *Recorder) sampleRate() int {
+	if r.audio.SampleRate == 0 {
+		return granuleRate
+	}
+	return r.audio.SampleRate
+}
+
+func (r *Recorder) writeHeader() error {
+	channels := r.audio.ChannelCount
+	if channels == 0 {
+		channels = 2
+	}
+	if err := r.writePacket(opusHead(channels, r.sampleRate()), 0, headerTypeBOS); err != nil {
+		return err
+	}
+	return r.writePacket(opusTags(), 0, 0)
+}
+
+// opusHead returns Opus identification header.
+// Reference: https://tools.ietf.org/html/rfc7845#section-5.1
+func opusHead(channels, sampleRate int) []byte {
+	b := make([]byte, 19)
+	copy(b, "OpusHead")
+	b[8] = 1 // version
+	b[9] = byte(channels)
+	binary.LittleEndian.PutUint16(b[10:], 0) // pre-skip
+	binary.LittleEndian.PutUint32(b[12:], uint32(sampleRate))
+	binary.LittleEndian.PutUint16(b[16:], 0) // output gain
+	b[18] = 0                                // channel mapping family
+	return b
+}
+
+// opusTags returns Opus comment header without user comments.
+// Reference: https://tools.ietf.org/html/rfc7845#section-5.2
+func opusTags() []byte {
+	const vendor = "pion/mediadevices"
+	b := make([]byte, 8+4+len(vendor)+4)
+	copy(b, "OpusTags")
+	binary.LittleEndian.PutUint32(b[8:], uint32(len(vendor)))
+	copy(b[12:], vendor)
+	binary.LittleEndian.PutUint32(b[12+len(vendor):], 0) // user comment list length
+	return b
+}
+
+// writePacket writes a packet ending at granule. A packet longer than a page
+// is split into the continued pages whose granule position is -1 as no packet
+// ends on them. The flags are set to the first page, except EOS to the last one.
+func (r *Recorder) writePacket(packet []byte, granule uint64, flags byte) error {
+	for {
+		// A packet is terminated by a lacing value less than 255
+		n := len(packet)/255 + 1
+		last := n <= maxSegments
+		pageGranule := granule
+		pageFlags := flags &^ headerTypeEOS
+		if last {
+			pageFlags |= flags & headerTypeEOS
+		} else {
+			n = maxSegments
+			pageGranule = ^uint64(0)
+		}
+
+		segments := make([]byte, n)
+		size := 0
+		for i := range segments {
+			l := len(packet) - size
+			if l > 255 {
+				l = 255
+			}
+			segments[i] = byte(l)
+			size += l
+		}
+		if err := r.writePage(segments, packet[:size], pageGranule, pageFlags); err != nil {
+			return err
+		}
+
+		packet = packet[size:]
+		if last {
+			return nil
+		}
+		flags = flags&headerTypeEOS | headerTypeContinued
+	}
+}
+
+// writePage writes an Ogg page.
+// Reference: https://tools.ietf.org/html/rfc3533#section-6
+func (r *Recorder) writePage(segments, data []byte, granule uint64, flags byte) error {
+	page := make([]byte, pageHeaderSize, pageHeaderSize+len(segments)+len(data))
+	copy(page, "OggS")
+	page[4] = 0 // version
+	page[5] = flags
+	binary.LittleEndian.PutUint64(page[6:], granule)
+	binary.LittleEndian.PutUint32(page[14:], r.serial)
+	binary.LittleEndian.PutUint32(page[18:], r.seq)
+	page[26] = byte(len(segments))
+	page = append(page, segments...)
+	page = append(page, data...)
+	binary.LittleEndian.PutUint32(page[22:], checksum(page))
+
+	r.seq++
+	_, err := r.w.Write(page)
+	return err
+}
+
+func newCRCTable() *[256]uint32 {
+	var t [256]uint32
+	for i := range t {
+		c := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if c&0x80000000 != 0 {
+				c = c<<1 ^ 0x04C11DB7
+			} else {
+				c <<= 1
+			}
+		}
+		t[i] = c
+	}
+	return &t
+}
+
+// checksum returns CRC32 of the page whose checksum field is zero. Unlike
+// hash/crc32, Ogg uses the non-reflected polynomial without the final XOR.
+func checksum(b []byte) uint32 {
+	var c uint32
+	for _, v := range b {
+		c = c<<8 ^ crcTable[byte(c>>24)^v]
+	}
+	return c
+}
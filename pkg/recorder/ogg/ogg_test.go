@@ -0,0 +1,220 @@
+package ogg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec/opus"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/webrtc/v2"
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+type parsedPage struct {
+	flags   byte
+	granule uint64
+	packets [][]byte
+}
+
+// parse is a minimal Ogg parser to validate the recorded file.
+// Packets continued from the previous page are joined to the previous page.
+func parse(t *testing.T, b []byte) []parsedPage {
+	var pages []parsedPage
+	var serial, seq uint32
+	var continued []byte
+	for len(b) > 0 {
+		if len(b) < pageHeaderSize || string(b[:4]) != "OggS" {
+			t.Fatalf("Capture pattern is not found at %d bytes before the end", len(b))
+		}
+		nSegments := int(b[26])
+		segments := b[pageHeaderSize : pageHeaderSize+nSegments]
+		size := pageHeaderSize + nSegments
+		for _, l := range segments {
+			size += int(l)
+		}
+		if len(b) < size {
+			t.Fatal("Page is truncated")
+		}
+		page := append([]byte{}, b[:size]...)
+		b = b[size:]
+
+		crc := binary.LittleEndian.Uint32(page[22:])
+		binary.LittleEndian.PutUint32(page[22:], 0)
+		if c := checksum(page); c != crc {
+			t.Errorf("Expected checksum %08x, got %08x", c, crc)
+		}
+
+		p := parsedPage{
+			flags:   page[5],
+			granule: binary.LittleEndian.Uint64(page[6:]),
+		}
+		if len(pages) == 0 {
+			serial = binary.LittleEndian.Uint32(page[14:])
+		} else if s := binary.LittleEndian.Uint32(page[14:]); s != serial {
+			t.Errorf("Expected serial %d, got %d", serial, s)
+		}
+		if s := binary.LittleEndian.Uint32(page[18:]); s != seq {
+			t.Errorf("Expected page sequence %d, got %d", seq, s)
+		}
+		seq++
+
+		if (p.flags&headerTypeContinued != 0) != (continued != nil) {
+			t.Errorf("Continued flag of page %d doesn't match the previous page", seq-1)
+		}
+		data := page[pageHeaderSize+nSegments:]
+		packet := continued
+		for _, l := range segments {
+			packet = append(packet, data[:l]...)
+			data = data[l:]
+			if l < 255 {
+				p.packets = append(p.packets, packet)
+				packet = nil
+			}
+		}
+		continued = packet
+
+		if p.flags&headerTypeContinued != 0 && len(pages) > 0 {
+			last := &pages[len(pages)-1]
+			last.flags |= p.flags &^ headerTypeContinued
+			last.granule = p.granule
+			last.packets = append(last.packets, p.packets...)
+			continue
+		}
+		pages = append(pages, p)
+	}
+	if continued != nil {
+		t.Error("Last packet is not terminated")
+	}
+	return pages
+}
+
+func TestRecorder(t *testing.T) {
+	buf := &bytes.Buffer{}
+	rec, err := NewRecorder(buf, &prop.Audio{SampleRate: 48000, ChannelCount: 2})
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+
+	codec := &webrtc.RTPCodec{Name: webrtc.Opus, Type: webrtc.RTPCodecTypeAudio}
+	codec.ClockRate = 48000
+	track, err := rec.NewTrack(0, 0, "audio", "", codec)
+	if err != nil {
+		t.Fatalf("Failed to create track: %v", err)
+	}
+	if _, err := rec.NewTrack(0, 0, "audio2", "", codec); err == nil {
+		t.Error("Creating the second track must fail")
+	}
+
+	// 1 second of 480Hz tone
+	var phase int
+	tone := audio.ReaderFunc(func(samples [][2]float32) (int, error) {
+		for i := range samples {
+			v := float32(math.Sin(2*math.Pi*float64(phase)/100) * 0.25)
+			samples[i] = [2]float32{v, v}
+			phase++
+		}
+		return len(samples), nil
+	})
+	enc, err := opus.NewEncoder(tone, prop.Media{
+		Audio: prop.Audio{SampleRate: 48000, ChannelCount: 2, Latency: 20 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create encoder: %v", err)
+	}
+	counter := enc.(interface{ LastFrameSamples() int })
+	buff := make([]byte, 1024)
+	for i := 0; i < 50; i++ {
+		n, err := enc.Read(buff)
+		if err != nil {
+			t.Fatalf("Failed to encode: %v", err)
+		}
+		err = track.WriteSample(media.Sample{Data: buff[:n], Samples: uint32(counter.LastFrameSamples())})
+		if err != nil {
+			t.Fatalf("Failed to write sample: %v", err)
+		}
+	}
+
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Failed to close recorder: %v", err)
+	}
+	if err := track.WriteSample(media.Sample{}); err != errClosed {
+		t.Errorf("Expected %v after close, got %v", errClosed, err)
+	}
+
+	pages := parse(t, buf.Bytes())
+	if len(pages) != 52 {
+		t.Fatalf("Expected 2 header pages and 50 audio pages, got %d", len(pages))
+	}
+	if p := pages[0]; p.flags != headerTypeBOS || len(p.packets) != 1 ||
+		!bytes.HasPrefix(p.packets[0], []byte("OpusHead")) || p.packets[0][9] != 2 {
+		t.Errorf("First page must be stereo OpusHead with BOS, got flags %x and %q", p.flags, p.packets)
+	}
+	if p := pages[1]; p.flags != 0 || p.granule != 0 || len(p.packets) != 1 ||
+		!bytes.HasPrefix(p.packets[0], []byte("OpusTags")) {
+		t.Errorf("Second page must be OpusTags, got flags %x and %q", p.flags, p.packets)
+	}
+	for i, p := range pages[2:] {
+		if expected := uint64(i+1) * 960; p.granule != expected {
+			t.Errorf("Expected granule position %d on page %d, got %d", expected, i+2, p.granule)
+		}
+		if eos := i == len(pages)-3; (p.flags&headerTypeEOS != 0) != eos {
+			t.Errorf("EOS flag of page %d must be %v", i+2, eos)
+		}
+	}
+	if d := time.Duration(pages[len(pages)-1].granule) * time.Second / granuleRate; d != time.Second {
+		t.Errorf("Expected duration %v, got %v", time.Second, d)
+	}
+}
+
+func TestRecorder_LongPacket(t *testing.T) {
+	buf := &bytes.Buffer{}
+	rec, err := NewRecorder(buf, &prop.Audio{SampleRate: 16000})
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	codec := &webrtc.RTPCodec{Name: webrtc.Opus, Type: webrtc.RTPCodecTypeAudio}
+	track, err := rec.NewTrack(0, 0, "audio", "", codec)
+	if err != nil {
+		t.Fatalf("Failed to create track: %v", err)
+	}
+
+	// A packet of 255 * 255 bytes needs 256 segments including the terminating one
+	long := bytes.Repeat([]byte{0xAA}, 255*255)
+	if err := track.WriteSample(media.Sample{Data: long, Samples: 320}); err != nil {
+		t.Fatalf("Failed to write sample: %v", err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Failed to close recorder: %v", err)
+	}
+
+	pages := parse(t, buf.Bytes())
+	if len(pages) != 3 {
+		t.Fatalf("Expected 3 pages, got %d", len(pages))
+	}
+	p := pages[2]
+	if len(p.packets) != 1 || !bytes.Equal(p.packets[0], long) {
+		t.Error("Long packet is not restored from the continued pages")
+	}
+	if p.flags != headerTypeEOS {
+		t.Errorf("Expected EOS on the last page, got flags %x", p.flags)
+	}
+	// 320 samples at 16kHz are 20ms
+	if p.granule != 960 {
+		t.Errorf("Expected granule position 960, got %d", p.granule)
+	}
+}
+
+func TestNewTrack_UnsupportedCodec(t *testing.T) {
+	rec, err := NewRecorder(&bytes.Buffer{}, &prop.Audio{})
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	vp8 := &webrtc.RTPCodec{Name: webrtc.VP8, Type: webrtc.RTPCodecTypeVideo}
+	if _, err := rec.NewTrack(0, 0, "video", "", vp8); err == nil {
+		t.Error("Creating VP8 track must fail")
+	}
+}
@@ -0,0 +1,113 @@
+package webm
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// EBML element IDs used in WebM.
+// Reference: https://www.matroska.org/technical/elements.html
+const (
+	idEBML               = 0x1A45DFA3
+	idEBMLVersion        = 0x4286
+	idEBMLReadVersion    = 0x42F7
+	idEBMLMaxIDLength    = 0x42F2
+	idEBMLMaxSizeLength  = 0x42F3
+	idDocType            = 0x4282
+	idDocTypeVersion     = 0x4287
+	idDocTypeReadVersion = 0x4285
+
+	idSegment       = 0x18538067
+	idInfo          = 0x1549A966
+	idTimecodeScale = 0x2AD7B1
+	idMuxingApp     = 0x4D80
+	idWritingApp    = 0x5741
+
+	idTracks            = 0x1654AE6B
+	idTrackEntry        = 0xAE
+	idTrackNumber       = 0xD7
+	idTrackUID          = 0x73C5
+	idTrackType         = 0x83
+	idCodecID           = 0x86
+	idCodecPrivate      = 0x63A2
+	idVideo             = 0xE0
+	idPixelWidth        = 0xB0
+	idPixelHeight       = 0xBA
+	idDisplayWidth      = 0x54B0
+	idDisplayHeight     = 0x54BA
+	idAudio             = 0xE1
+	idSamplingFrequency = 0xB5
+	idChannels          = 0x9F
+
+	idCluster     = 0x1F43B675
+	idTimecode    = 0xE7
+	idSimpleBlock = 0xA3
+)
+
+// sizeUnknown is a special size value which is used for live streaming.
+// The element with unknown size ends at the next element which is not a valid child.
+var sizeUnknown = []byte{0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+
+func encodeID(id uint32) []byte {
+	switch {
+	case id > 0xFFFFFF:
+		return []byte{byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id)}
+	case id > 0xFFFF:
+		return []byte{byte(id >> 16), byte(id >> 8), byte(id)}
+	case id > 0xFF:
+		return []byte{byte(id >> 8), byte(id)}
+	default:
+		return []byte{byte(id)}
+	}
+}
+
+// encodeVInt encodes v as a variable length integer which is used as an element size.
+func encodeVInt(v uint64) []byte {
+	for l := 1; l <= 8; l++ {
+		// All bits set to one is reserved for the unknown size
+		if v < (1<<uint(7*l))-1 {
+			b := make([]byte, l)
+			for i := l - 1; i >= 0; i-- {
+				b[i] = byte(v)
+				v >>= 8
+			}
+			b[0] |= 0x80 >> uint(l-1)
+			return b
+		}
+	}
+	panic("webm: too large element size")
+}
+
+func element(id uint32, payload ...[]byte) []byte {
+	var l int
+	for _, p := range payload {
+		l += len(p)
+	}
+	b := append(encodeID(id), encodeVInt(uint64(l))...)
+	for _, p := range payload {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func uintElement(id uint32, v uint64) []byte {
+	l := 1
+	for ; l < 8 && v>>uint(8*l) != 0; l++ {
+	}
+	b := make([]byte, l)
+	for i := l - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return element(id, b)
+}
+
+func floatElement(id uint32, v float64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, math.Float64bits(v))
+	return element(id, b)
+}
+
+func stringElement(id uint32, v string) []byte {
+	return element(id, []byte(v))
+}
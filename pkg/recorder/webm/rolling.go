@@ -0,0 +1,206 @@
+package webm
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/webrtc/v2"
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+// RollingRecorder keeps the encoded samples of the last duration in memory and
+// writes them into a WebM file on demand, e.g. to capture the moments before
+// an incident.
+//
+// RollingRecorder.NewTrack can be used as a mediadevices.TrackGenerator in the
+// same way as Recorder. To keep sending the tracks by PeerConnection, wrap the
+// track generated for it:
+//
+//	rec, _ := webm.NewRollingRecorder(&prop.Video{Width: 640, Height: 480}, nil, 30*time.Second)
+//	gen := func(pt uint8, ssrc uint32, id, label string, codec *webrtc.RTPCodec) (mediadevices.LocalTrack, error) {
+//		t, err := pc.NewTrack(pt, ssrc, id, label)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return rec.Tee(t), nil
+//	}
+type RollingRecorder struct {
+	mu       sync.Mutex
+	video    *prop.Video
+	audio    *prop.Audio
+	duration time.Duration
+	tracks   []*rollingTrack
+
+	now func() time.Time
+}
+
+type rollingTrack struct {
+	mediadevices.LocalTrack
+	rec     *RollingRecorder
+	samples []rollingSample
+}
+
+type rollingSample struct {
+	at       time.Time
+	keyframe bool
+	sample   media.Sample
+}
+
+// NewRollingRecorder creates a new RollingRecorder keeping the samples of the
+// last duration. video and audio describe the tracks to be recorded. Either of
+// them can be nil to record only one kind of media.
+func NewRollingRecorder(video *prop.Video, audio *prop.Audio, duration time.Duration) (*RollingRecorder, error) {
+	if video == nil && audio == nil {
+		return nil, errNoTrack
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("webm: invalid duration %v", duration)
+	}
+
+	return &RollingRecorder{
+		video:    video,
+		audio:    audio,
+		duration: duration,
+		now:      time.Now,
+	}, nil
+}
+
+// NewTrack creates a track to be recorded. It has the same signature as mediadevices.TrackGenerator.
+func (r *RollingRecorder) NewTrack(_ uint8, _ uint32, id, _ string, codec *webrtc.RTPCodec) (mediadevices.LocalTrack, error) {
+	if err := checkCodec(codec); err != nil {
+		return nil, err
+	}
+	return r.Tee(&discardTrack{codec: codec, id: id}), nil
+}
+
+// Tee wraps t to keep the samples written to t. The samples are written to t as is.
+func (r *RollingRecorder) Tee(t mediadevices.LocalTrack) mediadevices.LocalTrack {
+	rt := &rollingTrack{LocalTrack: t, rec: r}
+
+	r.mu.Lock()
+	r.tracks = append(r.tracks, rt)
+	r.mu.Unlock()
+	return rt
+}
+
+func (t *rollingTrack) WriteSample(s media.Sample) error {
+	if err := t.LocalTrack.WriteSample(s); err != nil {
+		return err
+	}
+
+	r := t.rec
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Sample data is only valid during the call
+	s.Data = append([]byte(nil), s.Data...)
+	t.samples = append(t.samples, rollingSample{
+		at:       r.now(),
+		keyframe: isKeyframe(t.Codec().Name, s.Data),
+		sample:   s,
+	})
+	r.trim()
+	return nil
+}
+
+// trim drops the samples older than the duration. Video samples are kept from the
+// last keyframe before that, so that the video can be decoded from the start, and
+// audio samples are kept from the same time.
+func (r *RollingRecorder) trim() {
+	cutoff := r.now().Add(-r.duration)
+	for _, t := range r.tracks {
+		if t.Kind() != webrtc.RTPCodecTypeVideo {
+			continue
+		}
+		var i int
+		for j, s := range t.samples {
+			if s.at.After(cutoff) {
+				break
+			}
+			if s.keyframe {
+				i = j
+			}
+		}
+		// Inter frames before the first keyframe can't be decoded
+		for i < len(t.samples) && !t.samples[i].keyframe {
+			i++
+		}
+		t.samples = t.samples[i:]
+		if len(t.samples) > 0 && t.samples[0].at.Before(cutoff) {
+			cutoff = t.samples[0].at
+		}
+	}
+
+	for _, t := range r.tracks {
+		if t.Kind() == webrtc.RTPCodecTypeVideo {
+			continue
+		}
+		var i int
+		for i < len(t.samples) && t.samples[i].at.Before(cutoff) {
+			i++
+		}
+		t.samples = t.samples[i:]
+	}
+}
+
+// discardTrack is a LocalTrack discarding the samples.
+type discardTrack struct {
+	codec *webrtc.RTPCodec
+	id    string
+}
+
+func (t *discardTrack) WriteSample(media.Sample) error { return nil }
+func (t *discardTrack) Codec() *webrtc.RTPCodec        { return t.codec }
+func (t *discardTrack) ID() string                     { return t.id }
+func (t *discardTrack) Kind() webrtc.RTPCodecType      { return t.codec.Type }
+
+// Dump writes the kept samples into a WebM file at path. The recording starts
+// from a keyframe of the video, and the audio samples before it are dropped.
+// Recording continues after Dump.
+func (r *RollingRecorder) Dump(path string) error {
+	r.mu.Lock()
+	tracks := make([]*rollingTrack, 0, len(r.tracks))
+	samples := make(map[*rollingTrack][]rollingSample)
+	var start time.Time
+	for _, t := range r.tracks {
+		tracks = append(tracks, t)
+		samples[t] = t.samples
+		if t.Kind() == webrtc.RTPCodecTypeVideo && len(t.samples) > 0 {
+			start = t.samples[0].at
+		}
+	}
+	r.mu.Unlock()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rec, err := NewRecorder(f, r.video, r.audio)
+	if err != nil {
+		return err
+	}
+	for _, t := range tracks {
+		w, err := rec.NewTrack(0, 0, t.ID(), "", t.Codec())
+		if err != nil {
+			return err
+		}
+		for _, s := range samples[t] {
+			if s.at.Before(start) {
+				continue
+			}
+			if err := w.WriteSample(s.sample); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rec.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
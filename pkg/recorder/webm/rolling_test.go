@@ -0,0 +1,92 @@
+package webm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/webrtc/v2"
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+func TestRollingRecorder(t *testing.T) {
+	rec, err := NewRollingRecorder(
+		&prop.Video{Width: 640, Height: 480},
+		&prop.Audio{SampleRate: 48000, ChannelCount: 2},
+		1500*time.Millisecond,
+	)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	now := time.Unix(0, 0)
+	rec.now = func() time.Time { return now }
+
+	vp8 := &webrtc.RTPCodec{Name: webrtc.VP8, Type: webrtc.RTPCodecTypeVideo}
+	vp8.ClockRate = 90000
+	opus := &webrtc.RTPCodec{Name: webrtc.Opus, Type: webrtc.RTPCodecTypeAudio}
+	opus.ClockRate = 48000
+
+	videoTrack, err := rec.NewTrack(0, 0, "video", "", vp8)
+	if err != nil {
+		t.Fatalf("Failed to create video track: %v", err)
+	}
+	audioTrack, err := rec.NewTrack(0, 0, "audio", "", opus)
+	if err != nil {
+		t.Fatalf("Failed to create audio track: %v", err)
+	}
+
+	// Record 3 seconds of 25fps video with a keyframe every second and 20ms audio frames
+	for ms := 0; ms < 3000; ms += 20 {
+		now = time.Unix(0, 0).Add(time.Duration(ms) * time.Millisecond)
+		if ms%40 == 0 {
+			frame := []byte{0x01, 0x02, 0x03} // inter frame
+			if ms%1000 == 0 {
+				frame[0] = 0x00 // key frame
+			}
+			if err := videoTrack.WriteSample(media.Sample{Data: frame, Samples: 3600}); err != nil {
+				t.Fatalf("Failed to write video sample: %v", err)
+			}
+		}
+		if err := audioTrack.WriteSample(media.Sample{Data: []byte{0xFC}, Samples: 960}); err != nil {
+			t.Fatalf("Failed to write audio sample: %v", err)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "TestRollingRecorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dump.webm")
+	if err := rec.Dump(path); err != nil {
+		t.Fatalf("Failed to dump: %v", err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The last 1.5 seconds start at 1.48s, so the dump starts from the keyframe at 1s.
+	f := parse(t, b)
+	var video []parsedBlock
+	var audio int
+	for _, b := range f.blocks {
+		if b.track == 1 {
+			video = append(video, b)
+		} else {
+			audio++
+		}
+	}
+	if len(video) != 50 || audio != 100 {
+		t.Fatalf("Expected 50 video and 100 audio blocks, got %d and %d", len(video), audio)
+	}
+	if !video[0].keyframe {
+		t.Error("Expected the dump to start from a keyframe")
+	}
+	if last := video[len(video)-1].timecode; last != 1960 {
+		t.Errorf("Expected last video timestamp to be 1960ms, got %dms", last)
+	}
+}
@@ -0,0 +1,354 @@
+// Package webm implements a recorder which muxes encoded VP8/VP9 video and
+// Opus audio samples into a WebM file.
+package webm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/pion/mediadevices"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/webrtc/v2"
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+const (
+	trackTypeVideo = 1
+	trackTypeAudio = 2
+
+	// Timestamps are written in milliseconds
+	timecodeScale = 1000000
+)
+
+var (
+	errNoTrack     = errors.New("webm: at least one of video or audio has to be given")
+	errClosed      = errors.New("webm: recorder is already closed")
+	codecIDs       = map[string]string{webrtc.VP8: "V_VP8", webrtc.VP9: "V_VP9", webrtc.Opus: "A_OPUS"}
+	clusterMaxDist = int64(math.MaxInt16)
+)
+
+// Recorder writes encoded samples of a video and an audio track into a WebM file.
+// Samples of the tracks are interleaved by their timestamps.
+//
+// Recorder.NewTrack can be used as a mediadevices.TrackGenerator:
+//
+//	rec, _ := webm.NewRecorder(f, &prop.Video{Width: 640, Height: 480}, &prop.Audio{SampleRate: 48000, ChannelCount: 2})
+//	md := mediadevices.NewMediaDevicesFromCodecs(codecs, mediadevices.WithTrackGenerator(rec.NewTrack))
+type Recorder struct {
+	mu     sync.Mutex
+	w      io.Writer
+	tracks []*track
+	header bool
+	closed bool
+
+	clusterOpen     bool
+	clusterTimecode int64
+}
+
+type track struct {
+	rec       *Recorder
+	number    uint64
+	trackType uint64
+	video     *prop.Video
+	audio     *prop.Audio
+
+	id      string
+	codec   *webrtc.RTPCodec
+	samples uint64
+	pending []block
+}
+
+type block struct {
+	timecode int64
+	keyframe bool
+	data     []byte
+}
+
+// NewRecorder creates a new WebM recorder writing to w.
+// video and audio describe the tracks to be recorded. Either of them can be nil
+// to record only one kind of media.
+func NewRecorder(w io.Writer, video *prop.Video, audio *prop.Audio) (*Recorder, error) {
+	if video == nil && audio == nil {
+		return nil, errNoTrack
+	}
+
+	r := &Recorder{w: w}
+	if video != nil {
+		r.tracks = append(r.tracks, &track{
+			rec: r, number: uint64(len(r.tracks) + 1), trackType: trackTypeVideo, video: video,
+		})
+	}
+	if audio != nil {
+		r.tracks = append(r.tracks, &track{
+			rec: r, number: uint64(len(r.tracks) + 1), trackType: trackTypeAudio, audio: audio,
+		})
+	}
+	return r, nil
+}
+
+// NewTrack creates a track to be recorded. It has the same signature as mediadevices.TrackGenerator.
+func (r *Recorder) NewTrack(_ uint8, _ uint32, id, _ string, codec *webrtc.RTPCodec) (mediadevices.LocalTrack, error) {
+	if err := checkCodec(codec); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	trackType := uint64(trackTypeVideo)
+	if codec.Type == webrtc.RTPCodecTypeAudio {
+		trackType = trackTypeAudio
+	}
+
+	for _, t := range r.tracks {
+		if t.trackType != trackType {
+			continue
+		}
+		if t.codec != nil {
+			return nil, fmt.Errorf("webm: %s track is already created", codec.Type)
+		}
+		t.id = id
+		t.codec = codec
+		return t, nil
+	}
+	return nil, fmt.Errorf("webm: %s track is not configured", codec.Type)
+}
+
+// checkCodec returns an error if the samples of codec can't be recorded.
+// The timestamps are calculated from the clock rate.
+func checkCodec(codec *webrtc.RTPCodec) error {
+	if _, ok := codecIDs[codec.Name]; !ok {
+		return fmt.Errorf("webm: %s is not supported", codec.Name)
+	}
+	if codec.ClockRate == 0 {
+		return fmt.Errorf("webm: clock rate of %s is not set", codec.Name)
+	}
+	return nil
+}
+
+// Close writes all pending samples. The underlying writer is not closed.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return errClosed
+	}
+	r.closed = true
+	return r.flush(true)
+}
+
+func (t *track) WriteSample(s media.Sample) error {
+	r := t.rec
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return errClosed
+	}
+
+	t.pending = append(t.pending, block{
+		timecode: int64(t.samples * 1000 / uint64(t.codec.ClockRate)),
+		keyframe: isKeyframe(t.codec.Name, s.Data),
+		data:     append([]byte(nil), s.Data...),
+	})
+	t.samples += uint64(s.Samples)
+
+	return r.flush(false)
+}
+
+func (t *track) Codec() *webrtc.RTPCodec {
+	return t.codec
+}
+
+func (t *track) ID() string {
+	return t.id
+}
+
+func (t *track) Kind() webrtc.RTPCodecType {
+	return t.codec.Type
+}
+
+// isKeyframe returns true if b is a keyframe of the codec. All frames of the audio
+// codecs are treated as keyframes.
+func isKeyframe(codecName string, b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	switch codecName {
+	case webrtc.VP8:
+		// Reference: https://tools.ietf.org/html/rfc6386#section-9.1
+		return b[0]&0x01 == 0
+	case webrtc.VP9:
+		// Reference: VP9 Bitstream Specification, 6.2 Uncompressed header syntax
+		profile := (b[0]>>5)&0x01 | (b[0]>>3)&0x02
+		if profile == 3 {
+			// show_existing_frame and frame_type follow reserved_zero bit
+			return (b[0]>>2)&0x01 == 0 && (b[0]>>1)&0x01 == 0
+		}
+		// show_existing_frame == 0 && frame_type == KEY_FRAME
+		return (b[0]>>3)&0x01 == 0 && (b[0]>>2)&0x01 == 0
+	default:
+		return true
+	}
+}
+
+// flush writes pending blocks in the order of timestamps.
+// Unless all is true, blocks are written only while every track has pending blocks
+// since an older block may come later from the track without pending blocks.
+func (r *Recorder) flush(all bool) error {
+	if !r.header {
+		for _, t := range r.tracks {
+			if t.codec == nil && !all {
+				// Wait until all tracks are created to write the track information
+				return nil
+			}
+		}
+		if err := r.writeHeader(); err != nil {
+			return err
+		}
+		r.header = true
+	}
+
+	for {
+		var next *track
+		for _, t := range r.tracks {
+			if t.codec == nil {
+				continue
+			}
+			if len(t.pending) == 0 {
+				if all {
+					continue
+				}
+				return nil
+			}
+			if next == nil || t.pending[0].timecode < next.pending[0].timecode {
+				next = t
+			}
+		}
+		if next == nil {
+			return nil
+		}
+
+		b := next.pending[0]
+		next.pending = next.pending[1:]
+		if err := r.writeBlock(next, b); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *Recorder) writeHeader() error {
+	header := element(idEBML,
+		uintElement(idEBMLVersion, 1),
+		uintElement(idEBMLReadVersion, 1),
+		uintElement(idEBMLMaxIDLength, 4),
+		uintElement(idEBMLMaxSizeLength, 8),
+		stringElement(idDocType, "webm"),
+		uintElement(idDocTypeVersion, 2),
+		uintElement(idDocTypeReadVersion, 2),
+	)
+
+	// Segment size is unknown since the recording is streamed
+	header = append(header, encodeID(idSegment)...)
+	header = append(header, sizeUnknown...)
+
+	header = append(header, element(idInfo,
+		uintElement(idTimecodeScale, timecodeScale),
+		stringElement(idMuxingApp, "pion/mediadevices"),
+		stringElement(idWritingApp, "pion/mediadevices"),
+	)...)
+
+	var entries [][]byte
+	for _, t := range r.tracks {
+		if t.codec == nil {
+			continue
+		}
+		entries = append(entries, t.entry())
+	}
+	header = append(header, element(idTracks, entries...)...)
+
+	_, err := r.w.Write(header)
+	return err
+}
+
+func (t *track) entry() []byte {
+	payload := [][]byte{
+		uintElement(idTrackNumber, t.number),
+		uintElement(idTrackUID, t.number),
+		uintElement(idTrackType, t.trackType),
+		stringElement(idCodecID, codecIDs[t.codec.Name]),
+	}
+
+	switch t.trackType {
+	case trackTypeVideo:
+		video := [][]byte{
+			uintElement(idPixelWidth, uint64(t.video.Width)),
+			uintElement(idPixelHeight, uint64(t.video.Height)),
+		}
+		if par := t.video.PixelAspectRatio; par > 0 && par != 1 {
+			// Tag the display size to play non-square pixels with the correct aspect ratio
+			video = append(video,
+				uintElement(idDisplayWidth, uint64(math.Round(float64(t.video.Width)*par))),
+				uintElement(idDisplayHeight, uint64(t.video.Height)),
+			)
+		}
+		payload = append(payload, element(idVideo, video...))
+	case trackTypeAudio:
+		channels := t.audio.ChannelCount
+		if channels == 0 {
+			channels = 2
+		}
+		payload = append(payload, element(idAudio,
+			floatElement(idSamplingFrequency, float64(t.audio.SampleRate)),
+			uintElement(idChannels, uint64(channels)),
+		))
+		if t.codec.Name == webrtc.Opus {
+			payload = append(payload, element(idCodecPrivate, opusHead(channels, t.audio.SampleRate)))
+		}
+	}
+
+	return element(idTrackEntry, payload...)
+}
+
+// opusHead returns Opus identification header.
+// Reference: https://tools.ietf.org/html/rfc7845#section-5.1
+func opusHead(channels, sampleRate int) []byte {
+	b := make([]byte, 19)
+	copy(b, "OpusHead")
+	b[8] = 1 // version
+	b[9] = byte(channels)
+	binary.LittleEndian.PutUint16(b[10:], 0) // pre-skip
+	binary.LittleEndian.PutUint32(b[12:], uint32(sampleRate))
+	binary.LittleEndian.PutUint16(b[16:], 0) // output gain
+	b[18] = 0                                // channel mapping family
+	return b
+}
+
+func (r *Recorder) writeBlock(t *track, b block) error {
+	rel := b.timecode - r.clusterTimecode
+	if !r.clusterOpen || rel < 0 || rel > clusterMaxDist ||
+		(b.keyframe && t.trackType == trackTypeVideo) {
+		// Cluster size is unknown since the recording is streamed
+		cluster := append(encodeID(idCluster), sizeUnknown...)
+		cluster = append(cluster, uintElement(idTimecode, uint64(b.timecode))...)
+		if _, err := r.w.Write(cluster); err != nil {
+			return err
+		}
+		r.clusterOpen = true
+		r.clusterTimecode = b.timecode
+		rel = 0
+	}
+
+	var flags byte
+	if b.keyframe {
+		flags |= 0x80
+	}
+	blockHeader := append(encodeVInt(t.number), byte(rel>>8), byte(rel), flags)
+	_, err := r.w.Write(element(idSimpleBlock, blockHeader, b.data))
+	return err
+}
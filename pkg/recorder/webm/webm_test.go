@@ -0,0 +1,179 @@
+package webm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/webrtc/v2"
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+type parsedBlock struct {
+	track    uint64
+	timecode int64
+	keyframe bool
+}
+
+type parsedFile struct {
+	docType  string
+	codecIDs []string
+	blocks   []parsedBlock
+}
+
+func readVInt(b []byte, keepMarker bool) (uint64, int) {
+	l := 1
+	for ; l <= 8 && b[0]&(0x80>>uint(l-1)) == 0; l++ {
+	}
+	v := uint64(b[0])
+	if !keepMarker {
+		v &= 0xFF >> uint(l)
+	}
+	for i := 1; i < l; i++ {
+		v = v<<8 | uint64(b[i])
+	}
+	return v, l
+}
+
+// parse is a minimal WebM parser to validate the recorded file.
+func parse(t *testing.T, b []byte) parsedFile {
+	masters := map[uint64]bool{
+		idEBML: true, idSegment: true, idTracks: true, idTrackEntry: true, idCluster: true,
+	}
+	var f parsedFile
+	var clusterTimecode int64
+	for len(b) > 0 {
+		id, n := readVInt(b, true)
+		b = b[n:]
+		size, n := readVInt(b, false)
+		b = b[n:]
+		if masters[id] {
+			// Parse children of the master element
+			continue
+		}
+		if uint64(len(b)) < size {
+			t.Fatalf("Element %x is truncated", id)
+		}
+		payload := b[:size]
+		b = b[size:]
+
+		switch id {
+		case idDocType:
+			f.docType = string(payload)
+		case idCodecID:
+			f.codecIDs = append(f.codecIDs, string(payload))
+		case idTimecode:
+			var v int64
+			for _, c := range payload {
+				v = v<<8 | int64(c)
+			}
+			clusterTimecode = v
+		case idSimpleBlock:
+			track, n := readVInt(payload, false)
+			rel := int16(binary.BigEndian.Uint16(payload[n:]))
+			f.blocks = append(f.blocks, parsedBlock{
+				track:    track,
+				timecode: clusterTimecode + int64(rel),
+				keyframe: payload[n+2]&0x80 != 0,
+			})
+		}
+	}
+	return f
+}
+
+func TestRecorder(t *testing.T) {
+	buf := &bytes.Buffer{}
+	rec, err := NewRecorder(buf,
+		&prop.Video{Width: 640, Height: 480},
+		&prop.Audio{SampleRate: 48000, ChannelCount: 2},
+	)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+
+	vp8 := &webrtc.RTPCodec{Name: webrtc.VP8, Type: webrtc.RTPCodecTypeVideo}
+	vp8.ClockRate = 90000
+	opus := &webrtc.RTPCodec{Name: webrtc.Opus, Type: webrtc.RTPCodecTypeAudio}
+	opus.ClockRate = 48000
+
+	if _, err := rec.NewTrack(0, 0, "video", "", &webrtc.RTPCodec{Name: webrtc.VP8, Type: webrtc.RTPCodecTypeVideo}); err == nil {
+		t.Error("Creating the track of the codec without the clock rate must fail")
+	}
+
+	videoTrack, err := rec.NewTrack(0, 0, "video", "", vp8)
+	if err != nil {
+		t.Fatalf("Failed to create video track: %v", err)
+	}
+	audioTrack, err := rec.NewTrack(0, 0, "audio", "", opus)
+	if err != nil {
+		t.Fatalf("Failed to create audio track: %v", err)
+	}
+	if _, err := rec.NewTrack(0, 0, "video2", "", vp8); err == nil {
+		t.Error("Creating the second video track must fail")
+	}
+
+	// Record 2 seconds of 30fps video and 20ms audio frames concurrently
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 60; i++ {
+			frame := []byte{0x01, 0x02, 0x03} // inter frame
+			if i%30 == 0 {
+				frame[0] = 0x00 // key frame
+			}
+			if err := videoTrack.WriteSample(media.Sample{Data: frame, Samples: 3000}); err != nil {
+				t.Errorf("Failed to write video sample: %v", err)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			if err := audioTrack.WriteSample(media.Sample{Data: []byte{0xFC}, Samples: 960}); err != nil {
+				t.Errorf("Failed to write audio sample: %v", err)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	wg.Wait()
+
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Failed to close recorder: %v", err)
+	}
+
+	f := parse(t, buf.Bytes())
+	if f.docType != "webm" {
+		t.Errorf("Expected DocType webm, got %s", f.docType)
+	}
+	if len(f.codecIDs) != 2 || f.codecIDs[0] != "V_VP8" || f.codecIDs[1] != "A_OPUS" {
+		t.Errorf("Expected tracks [V_VP8 A_OPUS], got %v", f.codecIDs)
+	}
+
+	cnt := make(map[uint64]int)
+	var keyframes int
+	var last int64
+	for _, b := range f.blocks {
+		if b.timecode < last {
+			t.Errorf("Blocks are not interleaved by the timestamp: %d after %d", b.timecode, last)
+		}
+		last = b.timecode
+		cnt[b.track]++
+		if b.track == 1 && b.keyframe {
+			keyframes++
+		}
+	}
+	if cnt[1] != 60 || cnt[2] != 100 {
+		t.Errorf("Expected 60 video and 100 audio blocks, got %d and %d", cnt[1], cnt[2])
+	}
+	if keyframes != 2 {
+		t.Errorf("Expected 2 video keyframes, got %d", keyframes)
+	}
+	if last != 1980 {
+		t.Errorf("Expected last timestamp to be 1980ms, got %dms", last)
+	}
+}
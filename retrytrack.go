@@ -0,0 +1,53 @@
+package mediadevices
+
+import (
+	"errors"
+
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+// IsTemporaryError returns true if err or one of the errors wrapped by err
+// has Temporary() method returning true. (e.g. net.Error)
+// It's the default classifier of the errors returned by WriteSample.
+func IsTemporaryError(err error) bool {
+	var temporary interface{ Temporary() bool }
+	return errors.As(err, &temporary) && temporary.Temporary()
+}
+
+// retryTrack is a LocalTrack which retries WriteSample of the inner track on
+// temporary errors.
+type retryTrack struct {
+	LocalTrack
+	isTemporary func(error) bool
+	retries     int
+}
+
+func newRetryTrack(t LocalTrack, isTemporary func(error) bool, retries int) LocalTrack {
+	if isTemporary == nil {
+		isTemporary = IsTemporaryError
+	}
+	return &retryTrack{
+		LocalTrack:  t,
+		isTemporary: isTemporary,
+		retries:     retries,
+	}
+}
+
+// WriteSample writes s to the inner track. If it fails with a temporary error,
+// it's retried up to the retry count and then the sample is dropped without
+// returning the error. Other errors are returned immediately.
+func (t *retryTrack) WriteSample(s media.Sample) error {
+	err := t.LocalTrack.WriteSample(s)
+	for i := 0; err != nil && t.isTemporary(err); i++ {
+		if i >= t.retries {
+			return nil
+		}
+		err = t.LocalTrack.WriteSample(s)
+	}
+	return err
+}
+
+// Unwrap returns the inner track.
+func (t *retryTrack) Unwrap() LocalTrack {
+	return t.LocalTrack
+}
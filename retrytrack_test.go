@@ -0,0 +1,97 @@
+package mediadevices
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+type temporaryError struct{}
+
+func (e *temporaryError) Error() string   { return "temporary" }
+func (e *temporaryError) Temporary() bool { return true }
+
+// errorTrackMock returns the errors in order and then writes the samples to
+// the inner track.
+type errorTrackMock struct {
+	LocalTrack
+	errs    []error
+	written int
+}
+
+func (t *errorTrackMock) WriteSample(s media.Sample) error {
+	t.written++
+	if len(t.errs) > 0 {
+		err := t.errs[0]
+		t.errs = t.errs[1:]
+		return err
+	}
+	return t.LocalTrack.WriteSample(s)
+}
+
+func TestIsTemporaryError(t *testing.T) {
+	cases := map[string]struct {
+		err       error
+		temporary bool
+	}{
+		"Temporary":        {&temporaryError{}, true},
+		"WrappedTemporary": {fmt.Errorf("wrapped: %w", &temporaryError{}), true},
+		"Fatal":            {errors.New("fatal"), false},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			if temporary := IsTemporaryError(c.err); temporary != c.temporary {
+				t.Errorf("Expected %v, got %v", c.temporary, temporary)
+			}
+		})
+	}
+}
+
+func TestRetryTrack(t *testing.T) {
+	errFatal := errors.New("fatal")
+	cases := map[string]struct {
+		errs     []error
+		retries  int
+		err      error
+		written  int
+		received bool
+	}{
+		"NoError": {
+			written: 1, received: true,
+		},
+		"Retried": {
+			errs: []error{&temporaryError{}, &temporaryError{}}, retries: 2,
+			written: 3, received: true,
+		},
+		"Dropped": {
+			errs: []error{&temporaryError{}, &temporaryError{}}, retries: 1,
+			written: 2, received: false,
+		},
+		"Fatal": {
+			errs: []error{&temporaryError{}, errFatal}, retries: 2,
+			err: errFatal, written: 2, received: false,
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			samples := make(chan media.Sample, 1)
+			mock := &errorTrackMock{
+				LocalTrack: &localTrackMock{samples: samples},
+				errs:       c.errs,
+			}
+			if err := newRetryTrack(mock, nil, c.retries).WriteSample(media.Sample{}); err != c.err {
+				t.Errorf("Expected error %v, got %v", c.err, err)
+			}
+			if mock.written != c.written {
+				t.Errorf("Expected %d writes, got %d", c.written, mock.written)
+			}
+			if received := len(samples) > 0; received != c.received {
+				t.Errorf("Expected received %v, got %v", c.received, received)
+			}
+		})
+	}
+}
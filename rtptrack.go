@@ -0,0 +1,237 @@
+package mediadevices
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v2"
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+// URIs of the RTP header extensions written by RTPTrack.
+const (
+	// AbsSendTimeURI is the absolute send time used by REMB bandwidth estimation.
+	AbsSendTimeURI = "http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time"
+	// TransportCCURI is the transport-wide sequence number used by
+	// transport-cc bandwidth estimation.
+	TransportCCURI = "http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01"
+	// VideoOrientationURI is the coordination of video orientation (CVO) which
+	// tells the receiver to rotate the frames to display them upright.
+	VideoOrientationURI = "urn:3gpp:video-orientation"
+)
+
+// rtpHeaderExtensionSizes is the size of the data of the supported extensions.
+var rtpHeaderExtensionSizes = map[string]int{
+	AbsSendTimeURI:      3,
+	TransportCCURI:      2,
+	VideoOrientationURI: 1,
+}
+
+// RTPHeaderExtension is an RTP header extension mapped to the ID by SDP extmap.
+// Reference: https://tools.ietf.org/html/rfc8285
+type RTPHeaderExtension struct {
+	URI string
+	// ID is the local identifier of the one-byte header in range of 1 to 14.
+	ID uint8
+}
+
+// HeaderExtensionTrack is a LocalTrack writing RTP header extensions.
+type HeaderExtensionTrack interface {
+	LocalTrack
+	// HeaderExtensions returns the RTP header extensions written to the packets.
+	HeaderExtensions() []RTPHeaderExtension
+}
+
+// orientationSetter is implemented by the LocalTracks writing the orientation
+// of the device selected for the track.
+type orientationSetter interface {
+	SetOrientation(degrees int)
+}
+
+// defaultRTPTrackMTU is the MTU of RTPTrack if it's not specified.
+const defaultRTPTrackMTU = 1200
+
+// RTPTrackConfig is the configuration of RTPTrack.
+type RTPTrackConfig struct {
+	// MTU is the maximum size of the packets. 0 means 1200 bytes.
+	MTU int
+	// SSRC of the packets. 0 means a random SSRC.
+	SSRC uint32
+	// HeaderExtensions are written to the packets. AbsSendTimeURI and
+	// TransportCCURI are written to all packets, and VideoOrientationURI is
+	// written to the last packet of each frame.
+	HeaderExtensions []RTPHeaderExtension
+}
+
+// RTPTrack is a LocalTrack writing the samples to an io.Writer as RTP packets
+// with the RTP header extensions, e.g. to send them to a UDP socket.
+// It can be returned by TrackGenerator.
+type RTPTrack struct {
+	codec       *webrtc.RTPCodec
+	id          string
+	w           io.Writer
+	packetizer  rtp.Packetizer
+	extensions  []RTPHeaderExtension
+	extSize     int
+	transportSN uint16
+	orientation int32
+}
+
+// NewRTPTrack creates an RTPTrack writing the packets of codec to w.
+// It fails if the header extensions are not supported or the IDs are invalid.
+func NewRTPTrack(codec *webrtc.RTPCodec, id string, w io.Writer, config RTPTrackConfig) (*RTPTrack, error) {
+	extSize, err := headerExtensionsSize(config.HeaderExtensions)
+	if err != nil {
+		return nil, err
+	}
+
+	mtu := config.MTU
+	if mtu == 0 {
+		mtu = defaultRTPTrackMTU
+	}
+	if mtu <= extSize {
+		return nil, fmt.Errorf("track: MTU %d is too small for the header extensions", mtu)
+	}
+	ssrc := config.SSRC
+	if ssrc == 0 {
+		ssrc = rand.Uint32()
+	}
+
+	return &RTPTrack{
+		codec: codec,
+		id:    id,
+		w:     w,
+		packetizer: rtp.NewPacketizer(
+			mtu-extSize,
+			codec.PayloadType,
+			ssrc,
+			codec.Payloader,
+			rtp.NewRandomSequencer(),
+			codec.ClockRate,
+		),
+		extensions: append([]RTPHeaderExtension(nil), config.HeaderExtensions...),
+		extSize:    extSize,
+	}, nil
+}
+
+// headerExtensionsSize validates the extensions and returns the maximum size
+// of the extension block of the one-byte header.
+func headerExtensionsSize(extensions []RTPHeaderExtension) (int, error) {
+	if len(extensions) == 0 {
+		return 0, nil
+	}
+
+	var size int
+	ids := make(map[uint8]bool)
+	for _, e := range extensions {
+		n, ok := rtpHeaderExtensionSizes[e.URI]
+		if !ok {
+			return 0, fmt.Errorf("track: unsupported header extension %s", e.URI)
+		}
+		if e.ID < 1 || e.ID > 14 {
+			return 0, fmt.Errorf("track: header extension ID %d is out of range [1-14]", e.ID)
+		}
+		if ids[e.ID] {
+			return 0, fmt.Errorf("track: duplicated header extension ID %d", e.ID)
+		}
+		ids[e.ID] = true
+		size += 1 + n
+	}
+	// Profile, length and padding to 32 bits
+	return 4 + (size+3)/4*4, nil
+}
+
+// HeaderExtensions returns the header extensions written to the packets.
+func (t *RTPTrack) HeaderExtensions() []RTPHeaderExtension {
+	return append([]RTPHeaderExtension(nil), t.extensions...)
+}
+
+// SetOrientation sets the clockwise rotation in degrees to display the frames
+// upright, which is written by VideoOrientationURI extension. It's set from
+// the orientation of the device when the track is used by MediaDevices.
+func (t *RTPTrack) SetOrientation(degrees int) {
+	atomic.StoreInt32(&t.orientation, int32(degrees))
+}
+
+func (t *RTPTrack) WriteSample(s media.Sample) error {
+	for _, p := range t.packetizer.Packetize(s.Data, s.Samples) {
+		if _, err := t.w.Write(t.marshal(p)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// marshal marshals p with the header extensions.
+func (t *RTPTrack) marshal(p *rtp.Packet) []byte {
+	b := make([]byte, 12, 12+t.extSize+len(p.Payload))
+	b[0] = 2 << 6
+	if p.Marker {
+		b[1] = 1 << 7
+	}
+	b[1] |= p.PayloadType
+	binary.BigEndian.PutUint16(b[2:], p.SequenceNumber)
+	binary.BigEndian.PutUint32(b[4:], p.Timestamp)
+	binary.BigEndian.PutUint32(b[8:], p.SSRC)
+
+	var elements []byte
+	for _, e := range t.extensions {
+		var data []byte
+		switch e.URI {
+		case AbsSendTimeURI:
+			v := absSendTime(time.Now())
+			data = []byte{byte(v >> 16), byte(v >> 8), byte(v)}
+		case TransportCCURI:
+			t.transportSN++
+			data = []byte{byte(t.transportSN >> 8), byte(t.transportSN)}
+		case VideoOrientationURI:
+			if !p.Marker {
+				continue
+			}
+			data = []byte{videoOrientation(int(atomic.LoadInt32(&t.orientation)))}
+		}
+		elements = append(elements, e.ID<<4|byte(len(data)-1))
+		elements = append(elements, data...)
+	}
+
+	if len(elements) > 0 {
+		b[0] |= 1 << 4
+		words := (len(elements) + 3) / 4
+		b = append(b, 0xBE, 0xDE, byte(words>>8), byte(words))
+		b = append(b, elements...)
+		b = append(b, make([]byte, words*4-len(elements))...)
+	}
+	return append(b, p.Payload...)
+}
+
+// ntpEpochOffset is the seconds from the NTP epoch (1900) to the Unix epoch.
+const ntpEpochOffset = 2208988800
+
+// absSendTime returns 6.18 fixed point seconds of the NTP time of t.
+func absSendTime(t time.Time) uint32 {
+	sec := uint64(t.Unix() + ntpEpochOffset)
+	frac := uint64(t.Nanosecond()) << 18 / uint64(time.Second)
+	return uint32(sec<<18|frac) & 0xFFFFFF
+}
+
+// videoOrientation returns the CVO byte of the clockwise rotation in degrees.
+func videoOrientation(degrees int) byte {
+	return byte(((degrees/90)%4 + 4) % 4)
+}
+
+func (t *RTPTrack) Codec() *webrtc.RTPCodec {
+	return t.codec
+}
+
+func (t *RTPTrack) ID() string {
+	return t.id
+}
+
+func (t *RTPTrack) Kind() webrtc.RTPCodecType {
+	return t.codec.Type
+}
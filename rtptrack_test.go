@@ -0,0 +1,171 @@
+package mediadevices
+
+import (
+	"encoding/binary"
+	"image"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec/raw"
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/webrtc/v2"
+)
+
+// splitPayloader splits the payload by mtu.
+type splitPayloader struct{}
+
+func (splitPayloader) Payload(mtu int, payload []byte) [][]byte {
+	var out [][]byte
+	for len(payload) > mtu {
+		out = append(out, payload[:mtu])
+		payload = payload[mtu:]
+	}
+	return append(out, payload)
+}
+
+// packetWriter sends the written packets to the channel. The packets are
+// dropped if the channel is full.
+type packetWriter chan []byte
+
+func (w packetWriter) Write(b []byte) (int, error) {
+	select {
+	case w <- append([]byte(nil), b...):
+	default:
+	}
+	return len(b), nil
+}
+
+// parseHeaderExtensions returns the one-byte header extensions of the RTP packet.
+func parseHeaderExtensions(t *testing.T, b []byte) (marker bool, extensions map[uint8][]byte) {
+	t.Helper()
+	if len(b) < 12 || b[0]>>6 != 2 {
+		t.Fatalf("Invalid RTP packet: %v", b)
+	}
+	marker = b[1]>>7 == 1
+	extensions = make(map[uint8][]byte)
+	if b[0]&0x10 == 0 {
+		return marker, extensions
+	}
+	ext := b[12:]
+	if ext[0] != 0xBE || ext[1] != 0xDE {
+		t.Fatalf("Expected one-byte header profile, got %x", ext[:2])
+	}
+	ext = ext[4 : 4+4*int(binary.BigEndian.Uint16(ext[2:]))]
+	for len(ext) > 0 && ext[0] != 0 {
+		id, n := ext[0]>>4, int(ext[0]&0xF)+1
+		extensions[id] = ext[1 : 1+n]
+		ext = ext[1+n:]
+	}
+	return marker, extensions
+}
+
+func TestRTPTrackHeaderExtensions(t *testing.T) {
+	extensions := []RTPHeaderExtension{
+		{URI: AbsSendTimeURI, ID: 3},
+		{URI: TransportCCURI, ID: 5},
+		{URI: VideoOrientationURI, ID: 7},
+	}
+
+	img := image.NewYCbCr(image.Rect(0, 0, 16, 8), image.YCbCrSubsampleRatio420)
+	id := registerMock(t, &videoAdapterMock{
+		read: func() (image.Image, error) {
+			time.Sleep(time.Millisecond)
+			return img, nil
+		},
+		props: []prop.Media{
+			{Video: prop.Video{Width: 16, Height: 8, FrameFormat: frame.FormatI420, Orientation: 90}},
+		},
+	}, "TestRTPTrackHeaderExtensions")
+
+	packets := make(packetWriter, 100)
+	md := NewMediaDevicesFromCodecs(
+		map[webrtc.RTPCodecType][]*webrtc.RTPCodec{
+			webrtc.RTPCodecTypeVideo: {
+				{Name: raw.Name, Type: webrtc.RTPCodecTypeVideo, Payloader: splitPayloader{}},
+			},
+		},
+		WithTrackGenerator(func(_ uint8, _ uint32, id, _ string, codec *webrtc.RTPCodec) (LocalTrack, error) {
+			return NewRTPTrack(codec, id, packets, RTPTrackConfig{MTU: 100, HeaderExtensions: extensions})
+		}),
+	)
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = raw.Name
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	tr := s.GetVideoTracks()[0]
+	defer tr.Stop()
+
+	if declared := tr.HeaderExtensions(); !reflect.DeepEqual(extensions, declared) {
+		t.Fatalf("Expected extensions %v, got %v", extensions, declared)
+	}
+
+	var frames int
+	var lastSN uint16
+	for i := 0; frames < 2; i++ {
+		var b []byte
+		select {
+		case b = <-packets:
+		case <-time.After(time.Second):
+			t.Fatal("Timeout")
+		}
+		if len(b) > 100 {
+			t.Errorf("Expected packets up to MTU, got %d bytes", len(b))
+		}
+
+		marker, exts := parseHeaderExtensions(t, b)
+		for id := range exts {
+			var declared bool
+			for _, e := range extensions {
+				declared = declared || e.ID == id
+			}
+			if !declared {
+				t.Errorf("Undeclared extension ID %d is written", id)
+			}
+		}
+		if len(exts[3]) != 3 {
+			t.Errorf("Expected 3 bytes of abs-send-time, got %v", exts[3])
+		}
+		sn := binary.BigEndian.Uint16(exts[5])
+		if i > 0 && sn != lastSN+1 {
+			t.Errorf("Expected transport sequence number %d, got %d", lastSN+1, sn)
+		}
+		lastSN = sn
+		if marker {
+			frames++
+			if o, ok := exts[7]; !ok || o[0] != 1 {
+				t.Errorf("Expected video orientation 1 (90 degrees) on the last packet of the frame, got %v", o)
+			}
+		} else if _, ok := exts[7]; ok {
+			t.Error("Unexpected video orientation on the middle of the frame")
+		}
+	}
+}
+
+func TestRTPTrackInvalidHeaderExtensions(t *testing.T) {
+	codec := &webrtc.RTPCodec{Name: raw.Name, Type: webrtc.RTPCodecTypeVideo, Payloader: splitPayloader{}}
+	cases := map[string][]RTPHeaderExtension{
+		"Unsupported": {{URI: "urn:ietf:params:rtp-hdrext:toffset", ID: 1}},
+		"ZeroID":      {{URI: TransportCCURI, ID: 0}},
+		"TooLargeID":  {{URI: TransportCCURI, ID: 15}},
+		"DuplicatedID": {
+			{URI: TransportCCURI, ID: 1},
+			{URI: AbsSendTimeURI, ID: 1},
+		},
+	}
+	for name, extensions := range cases {
+		extensions := extensions
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewRTPTrack(codec, "id", make(packetWriter), RTPTrackConfig{HeaderExtensions: extensions}); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
@@ -6,6 +6,22 @@ import (
 	"github.com/pion/webrtc/v2/pkg/media"
 )
 
+// Sampler writes the encoded frames to the track as media samples. The samplers
+// decide the timing and the packetization of the samples. Sample is called for
+// each encoded frame, and b is only valid during the call.
+type Sampler interface {
+	Sample(b []byte) error
+}
+
+// SamplerGenerator is a function to create a Sampler writing to track.
+type SamplerGenerator func(track LocalTrack) Sampler
+
+// defaultSamplerGenerator creates the sampler using the wall clock duration
+// between the frames as the duration of the sample.
+var defaultSamplerGenerator = SamplerGenerator(func(track LocalTrack) Sampler {
+	return newSampler(track)
+})
+
 type sampler struct {
 	track         LocalTrack
 	clockRate     float64
@@ -20,7 +36,7 @@ func newSampler(track LocalTrack) *sampler {
 	}
 }
 
-func (s *sampler) sample(b []byte) error {
+func (s *sampler) Sample(b []byte) error {
 	now := time.Now()
 	duration := now.Sub(s.lastTimestamp).Seconds()
 	samples := uint32(s.clockRate * duration)
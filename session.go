@@ -0,0 +1,141 @@
+package mediadevices
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pion/webrtc/v2"
+)
+
+var errSessionNoTrack = errors.New("session: no track matches the constraints")
+
+// SessionConfig is the configuration of NewSession.
+type SessionConfig struct {
+	// Configuration is used to create the PeerConnection, e.g. the STUN and
+	// TURN servers.
+	Configuration webrtc.Configuration
+	// Constraints selects the devices of the stream. If CodecName of the
+	// constraints is empty, the codec is selected from the codecs of the offer.
+	Constraints MediaStreamConstraints
+	// Codecs are the names of the codecs which can be selected in order of
+	// preference, e.g. webrtc.VP8. Empty means any codec of the offer in the
+	// order of the offer.
+	Codecs []string
+	// Options are used to create MediaDevices, e.g. WithLogger.
+	Options []MediaDevicesOption
+}
+
+// Session is a stream sent by a PeerConnection answering a remote offer.
+type Session struct {
+	pc     *webrtc.PeerConnection
+	stream MediaStream
+}
+
+// NewSession answers offer by a new PeerConnection sending the stream of the
+// devices selected by the constraints of config. The codecs of offer are
+// registered to the PeerConnection by PopulateFromSDP, and the tracks of the
+// stream are added as send-only transceivers. The answer to be sent to the
+// remote peer is returned by Answer.
+func NewSession(offer webrtc.SessionDescription, config SessionConfig) (*Session, error) {
+	mediaEngine := webrtc.MediaEngine{}
+	if err := mediaEngine.PopulateFromSDP(offer); err != nil {
+		return nil, err
+	}
+	api := webrtc.NewAPI(webrtc.WithMediaEngine(mediaEngine))
+	pc, err := api.NewPeerConnection(config.Configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	codecs := make(map[webrtc.RTPCodecType][]*webrtc.RTPCodec)
+	for _, kind := range []webrtc.RTPCodecType{
+		webrtc.RTPCodecTypeAudio,
+		webrtc.RTPCodecTypeVideo,
+	} {
+		codecs[kind] = preferCodecs(pc.GetRegisteredRTPCodecs(kind), config.Codecs)
+	}
+	md := NewMediaDevicesFromCodecs(codecs, config.Options...)
+	stream, err := md.GetUserMedia(config.Constraints)
+	if err != nil {
+		pc.Close()
+		return nil, err
+	}
+
+	s := &Session{pc: pc, stream: stream}
+	if err := s.answer(offer); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// preferCodecs returns the codecs named in names in the order of names.
+// All codecs are returned if names is empty.
+func preferCodecs(codecs []*webrtc.RTPCodec, names []string) []*webrtc.RTPCodec {
+	if len(names) == 0 {
+		return codecs
+	}
+
+	var preferred []*webrtc.RTPCodec
+	for _, name := range names {
+		for _, c := range codecs {
+			if c.Name == name {
+				preferred = append(preferred, c)
+			}
+		}
+	}
+	return preferred
+}
+
+func (s *Session) answer(offer webrtc.SessionDescription) error {
+	trackers := s.stream.GetTracks()
+	if len(trackers) == 0 {
+		return errSessionNoTrack
+	}
+	for _, tracker := range trackers {
+		t := tracker.Track()
+		if t == nil {
+			return fmt.Errorf("session: track %s is not a webrtc track", tracker.LocalTrack().ID())
+		}
+		_, err := s.pc.AddTransceiverFromTrack(t,
+			webrtc.RtpTransceiverInit{
+				Direction: webrtc.RTPTransceiverDirectionSendonly,
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := s.pc.SetRemoteDescription(offer); err != nil {
+		return err
+	}
+	answer, err := s.pc.CreateAnswer(nil)
+	if err != nil {
+		return err
+	}
+	return s.pc.SetLocalDescription(answer)
+}
+
+// PeerConnection returns the PeerConnection sending the stream.
+func (s *Session) PeerConnection() *webrtc.PeerConnection {
+	return s.pc
+}
+
+// Stream returns the stream sent by the session.
+func (s *Session) Stream() MediaStream {
+	return s.stream
+}
+
+// Answer returns the answer to the offer including the gathered candidates.
+func (s *Session) Answer() webrtc.SessionDescription {
+	return *s.pc.LocalDescription()
+}
+
+// Close stops the tracks of the stream and closes the PeerConnection.
+func (s *Session) Close() error {
+	for _, t := range s.stream.GetTracks() {
+		t.Stop()
+	}
+	return s.pc.Close()
+}
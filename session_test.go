@@ -0,0 +1,103 @@
+package mediadevices
+
+import (
+	"image"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/codec/raw"
+	"github.com/pion/webrtc/v2"
+)
+
+func TestSession(t *testing.T) {
+	// Only VP8 encoder is registered in this test
+	codec.Register(webrtc.VP8, codec.VideoEncoderBuilder(raw.NewVideoEncoder))
+
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		return img, nil
+	}}, "TestSession")
+
+	newOfferer := func(t *testing.T) (*webrtc.PeerConnection, webrtc.SessionDescription) {
+		pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pc.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo, webrtc.RtpTransceiverInit{
+			Direction: webrtc.RTPTransceiverDirectionRecvonly,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		offer, err := pc.CreateOffer(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := pc.SetLocalDescription(offer); err != nil {
+			t.Fatal(err)
+		}
+		return pc, offer
+	}
+	constraints := MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+		},
+	}
+
+	t.Run("OfferAnswer", func(t *testing.T) {
+		offerer, offer := newOfferer(t)
+		defer offerer.Close()
+
+		s, err := NewSession(offer, SessionConfig{
+			Constraints: constraints,
+			Codecs:      []string{webrtc.VP9, webrtc.VP8},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+		defer s.Close()
+
+		tracks := s.Stream().GetVideoTracks()
+		if len(tracks) != 1 {
+			t.Fatalf("Expected 1 video track, got %d", len(tracks))
+		}
+		if name := tracks[0].Codec().Name; name != webrtc.VP8 {
+			t.Errorf("Expected %s to be selected, got %s", webrtc.VP8, name)
+		}
+
+		answer := s.Answer()
+		if answer.Type != webrtc.SDPTypeAnswer {
+			t.Errorf("Expected answer, got %s", answer.Type)
+		}
+		for _, expected := range []string{"m=video", "a=sendonly", "VP8/90000"} {
+			if !strings.Contains(answer.SDP, expected) {
+				t.Errorf("Expected the answer to contain %s, got:\n%s", expected, answer.SDP)
+			}
+		}
+		if err := offerer.SetRemoteDescription(answer); err != nil {
+			t.Errorf("Failed to set the answer: %v", err)
+		}
+	})
+	t.Run("NoCommonCodec", func(t *testing.T) {
+		offerer, offer := newOfferer(t)
+		defer offerer.Close()
+
+		if _, err := NewSession(offer, SessionConfig{
+			Constraints: constraints,
+			Codecs:      []string{webrtc.H264 + "-unknown"},
+		}); err == nil {
+			t.Error("Expected error without common codec")
+		}
+	})
+	t.Run("NoTrack", func(t *testing.T) {
+		offerer, offer := newOfferer(t)
+		defer offerer.Close()
+
+		if _, err := NewSession(offer, SessionConfig{}); err != errSessionNoTrack {
+			t.Errorf("Expected %v, got %v", errSessionNoTrack, err)
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package mediadevices
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/io/video"
+)
+
+// Snapshot captures a still image from a video device, e.g. to take a profile
+// photo without starting a track. The device is selected by the constraints in
+// the same way as GetUserMedia, opened to read a frame transformed by
+// VideoTransform, and closed. The encoder options of the constraints are ignored.
+func Snapshot(constraints MediaOption) (image.Image, error) {
+	var c MediaTrackConstraints
+	if constraints != nil {
+		constraints(&c)
+	}
+	switch {
+	case c.OpenTimeout < 0:
+		return nil, fmt.Errorf("invalid open timeout %v", c.OpenTimeout)
+	case c.OpenRetryCount < 0:
+		return nil, fmt.Errorf("invalid open retry count %d", c.OpenRetryCount)
+	case c.OpenRetryInterval < 0:
+		return nil, fmt.Errorf("invalid open retry interval %v", c.OpenRetryInterval)
+	}
+
+	d, c, err := selectBestDriver(nopLogger{}, videoFilter(c), c)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := openDriverWithRetry(nopLogger{}, d, c); err != nil {
+		return nil, err
+	}
+	defer d.Close()
+
+	r, err := d.(driver.VideoRecorder).VideoRecord(c.Media)
+	if err != nil {
+		return nil, err
+	}
+	if c.VideoTransform != nil {
+		r = c.VideoTransform(r)
+	}
+
+	img, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	// The frame may be released by the driver when the device is closed
+	return video.Clone(img), nil
+}
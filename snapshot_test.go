@@ -0,0 +1,44 @@
+package mediadevices
+
+import (
+	"image"
+	"testing"
+
+	_ "github.com/pion/mediadevices/pkg/driver/videotest"
+	"github.com/pion/mediadevices/pkg/io/video"
+)
+
+func TestSnapshot(t *testing.T) {
+	t.Run("Device", func(t *testing.T) {
+		img, err := Snapshot(func(c *MediaTrackConstraints) {
+			c.DeviceLabel = "VideoTest"
+			c.Width, c.Height = 640, 480
+		})
+		if err != nil {
+			t.Fatalf("Failed to snapshot: %v", err)
+		}
+		if size := img.Bounds().Size(); size != image.Pt(640, 480) {
+			t.Errorf("Expected 640x480, got %v", size)
+		}
+	})
+	t.Run("VideoTransform", func(t *testing.T) {
+		img, err := Snapshot(func(c *MediaTrackConstraints) {
+			c.DeviceLabel = "VideoTest"
+			c.VideoTransform = video.Scale(320, 240, nil)
+		})
+		if err != nil {
+			t.Fatalf("Failed to snapshot: %v", err)
+		}
+		if size := img.Bounds().Size(); size != image.Pt(320, 240) {
+			t.Errorf("Expected 320x240, got %v", size)
+		}
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := Snapshot(func(c *MediaTrackConstraints) {
+			c.DeviceID = "TestSnapshotNotFound"
+		})
+		if err == nil {
+			t.Error("Expected error")
+		}
+	})
+}
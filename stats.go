@@ -0,0 +1,81 @@
+package mediadevices
+
+import (
+	"sync"
+	"time"
+)
+
+// VideoStats is the statistics of a video track.
+type VideoStats struct {
+	// FrameRate is the rate of the frames written to the track in the last second.
+	FrameRate float64
+	// QueuedFrames is the number of the frames awaiting encode in the frame
+	// buffer. It's always 0 if FrameBufferSize of the constraints is 0.
+	// It keeps increasing up to the buffer size if the encoder can't keep up.
+	QueuedFrames int
+	// QueuedBytes is the bytes of the frames counted by QueuedFrames. It's
+	// capped by MaxBufferBytes of the constraints.
+	QueuedBytes int
+	// EncodeTime is the moving average of the time from passing a frame to the
+	// encoder until the encoded frame is read.
+	EncodeTime time.Duration
+	// BitRate is the bits per second of the encoded frames written to the
+	// track in the last second.
+	BitRate float64
+	// DroppedFrames is the total number of the frames dropped by the frame
+	// buffer since the track is created, including the ones of the devices
+	// switched from. It's always 0 if FrameBufferSize of the constraints is 0.
+	DroppedFrames uint64
+}
+
+// AudioStats is the statistics of an audio track.
+type AudioStats struct {
+	// ConcealedSamples is the number of the samples substituted for the gaps
+	// of the device. It's always 0 if AudioConcealDelay of the constraints is 0.
+	ConcealedSamples uint64
+	// ConcealmentEvents is the number of the gaps concealed.
+	ConcealmentEvents uint64
+}
+
+// encodeTimeWeight is the weight of the new sample of the moving average.
+const encodeTimeWeight = 8
+
+// encodeTimer measures the moving average of the encode time.
+type encodeTimer struct {
+	mu      sync.Mutex
+	passed  time.Time
+	average time.Duration
+}
+
+// pass records that a frame is passed to the encoder.
+func (e *encodeTimer) pass() {
+	now := time.Now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.passed = now
+}
+
+// encoded records that the frame passed last is encoded.
+func (e *encodeTimer) encoded() {
+	now := time.Now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.passed.IsZero() {
+		return
+	}
+	d := now.Sub(e.passed)
+	e.passed = time.Time{}
+	if e.average == 0 {
+		e.average = d
+		return
+	}
+	e.average += (d - e.average) / encodeTimeWeight
+}
+
+func (e *encodeTimer) value() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.average
+}
@@ -0,0 +1,44 @@
+package mediadevices
+
+import (
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+// SampleWriter is the destination of the samples copied by NewTeeTrack, e.g.
+// the LocalTrack of the recorders in pkg/recorder writing to a file.
+type SampleWriter interface {
+	// WriteSample writes s. The data of s is only valid during the call.
+	WriteSample(s media.Sample) error
+}
+
+// teeTrack is a LocalTrack which writes the samples to both the inner track
+// and the writer.
+type teeTrack struct {
+	LocalTrack
+	w SampleWriter
+}
+
+// NewTeeTrack wraps t to write every sample written to t to w too, e.g. to
+// record the track while streaming it over WebRTC from the single encoded
+// output. The sample is written to w even if t fails and vice versa, and the
+// first error is returned, which ends the track. Wrap w to ignore its errors if
+// the recording must not stop the streaming.
+func NewTeeTrack(t LocalTrack, w SampleWriter) LocalTrack {
+	return &teeTrack{
+		LocalTrack: t,
+		w:          w,
+	}
+}
+
+func (t *teeTrack) WriteSample(s media.Sample) error {
+	err := t.LocalTrack.WriteSample(s)
+	if errW := t.w.WriteSample(s); err == nil {
+		err = errW
+	}
+	return err
+}
+
+// Unwrap returns the inner track.
+func (t *teeTrack) Unwrap() LocalTrack {
+	return t.LocalTrack
+}
@@ -0,0 +1,57 @@
+package mediadevices
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/pion/webrtc/v2"
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+func TestTeeTrack(t *testing.T) {
+	codec := &webrtc.RTPCodec{Type: webrtc.RTPCodecTypeVideo}
+
+	t.Run("Samples", func(t *testing.T) {
+		lt := &localTrackMock{codec: codec, id: "test", samples: make(chan media.Sample, 10)}
+		buf := NewBufferTrack(codec, "recording")
+		track := NewTeeTrack(lt, buf)
+		if track.ID() != "test" {
+			t.Errorf("Expected the ID of the inner track, got %q", track.ID())
+		}
+
+		var expected []media.Sample
+		for i := 0; i < 10; i++ {
+			data := []byte{byte(i), byte(i * 2)}
+			if err := track.WriteSample(media.Sample{Data: data, Samples: uint32(i)}); err != nil {
+				t.Fatalf("Failed to write sample: %v", err)
+			}
+			expected = append(expected, media.Sample{Data: []byte{byte(i), byte(i * 2)}, Samples: uint32(i)})
+			// Data is only valid during the call
+			data[0], data[1] = 0xFF, 0xFF
+		}
+
+		close(lt.samples)
+		var streamed []media.Sample
+		for s := range lt.samples {
+			streamed = append(streamed, s)
+		}
+		if !reflect.DeepEqual(expected, streamed) {
+			t.Errorf("Expected the track to receive %v, got %v", expected, streamed)
+		}
+		if recorded := buf.Samples(); !reflect.DeepEqual(expected, recorded) {
+			t.Errorf("Expected the writer to receive %v, got %v", expected, recorded)
+		}
+	})
+	t.Run("Error", func(t *testing.T) {
+		errWrite := errors.New("write error")
+		buf := NewBufferTrack(codec, "recording")
+		track := NewTeeTrack(&errorTrackMock{LocalTrack: NewBufferTrack(codec, "test"), errs: []error{errWrite}}, buf)
+		if err := track.WriteSample(media.Sample{Data: []byte{0}, Samples: 1}); err != errWrite {
+			t.Errorf("Expected error %v, got %v", errWrite, err)
+		}
+		if buf.Len() != 1 {
+			t.Errorf("Expected the sample to be written to the writer even if the track fails, got %d samples", buf.Len())
+		}
+	})
+}
@@ -1,14 +1,22 @@
 package mediadevices
 
 import (
+	"errors"
 	"fmt"
+	"image"
 	"io"
 	"math/rand"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/pion/mediadevices/pkg/codec"
 	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/frame"
 	mio "github.com/pion/mediadevices/pkg/io"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
 	"github.com/pion/webrtc/v2"
 	"github.com/pion/webrtc/v2/pkg/media"
 )
@@ -16,12 +24,64 @@ import (
 // Tracker is an interface that represent MediaStreamTrack
 // Reference: https://w3c.github.io/mediacapture-main/#mediastreamtrack
 type Tracker interface {
+	// Track returns the *webrtc.Track written by LocalTrack, which is found by
+	// WebRTCTrack. It returns nil if the track is generated by a custom
+	// TrackGenerator returning the other LocalTrack.
 	Track() *webrtc.Track
+	// LocalTrack returns the track generated by TrackGenerator.
 	LocalTrack() LocalTrack
+	// Codec returns the codec selected for the track.
+	Codec() *webrtc.RTPCodec
+	// Kind returns the kind of the track, "audio" or "video".
+	// Reference: https://w3c.github.io/mediacapture-main/#dom-mediastreamtrack-kind
+	Kind() string
+	// ReadyState returns the state of the track. The track is ended when it's
+	// stopped by Stop or the track is ended by an error.
+	// Reference: https://w3c.github.io/mediacapture-main/#dom-mediastreamtrack-readystate
+	ReadyState() MediaStreamTrackState
+	// Settings returns the properties of the device selected for the track,
+	// e.g. the resolution and the orientation of the camera.
+	// Reference: https://w3c.github.io/mediacapture-main/#dom-mediastreamtrack-getsettings
+	Settings() prop.Media
+	// HeaderExtensions returns the RTP header extensions written by the
+	// LocalTrack. It's nil if the LocalTrack doesn't implement
+	// HeaderExtensionTrack, e.g. *webrtc.Track which doesn't write them.
+	HeaderExtensions() []RTPHeaderExtension
+	// Stop stops the track and releases the device. It's safe to call Stop
+	// multiple times and concurrently. The handler registered by OnEnded is not
+	// called by Stop. The frames buffered in the video encoders implementing
+	// codec.Flusher are written to the track before Stop returns, unless it
+	// takes more than a second.
 	Stop()
+	// Restart reopens the device stopped by Stop and restarts the track with the
+	// same LocalTrack, so that the track which is already added to the
+	// PeerConnection can be reused. If the device can't be reopened, the track
+	// must be acquired again by GetUserMedia.
+	Restart() error
+	// SetEnabled enables or disables the track like the enabled attribute of
+	// MediaStreamTrack. The disabled track keeps writing the samples to the
+	// LocalTrack, but the video frames are black and the audio is silent, so
+	// that the receiver sees the track muted. The track is enabled by default.
+	// The sources passing the encoded frames through are not affected.
+	// Reference: https://w3c.github.io/mediacapture-main/#dom-mediastreamtrack-enabled
+	SetEnabled(enabled bool)
+	// Enabled returns false if the track is disabled by SetEnabled.
+	Enabled() bool
+	// OnEnded registers the handler called when the track is ended by an error,
+	// e.g. driver.DeviceLostError. The error is nil if the track is ended
+	// deliberately by ErrEnded.
 	OnEnded(func(error))
 }
 
+// MediaStreamTrackState represents https://w3c.github.io/mediacapture-main/#dom-mediastreamtrackstate
+type MediaStreamTrackState string
+
+// MediaStreamTrackState definitions.
+const (
+	TrackStateLive  MediaStreamTrackState = "live"
+	TrackStateEnded MediaStreamTrackState = "ended"
+)
+
 type LocalTrack interface {
 	WriteSample(s media.Sample) error
 	Codec() *webrtc.RTPCodec
@@ -29,16 +89,59 @@ type LocalTrack interface {
 	Kind() webrtc.RTPCodecType
 }
 
+// WebRTCTrack returns the *webrtc.Track written by t. The LocalTrack wrapping
+// another LocalTrack, e.g. the tracks returned by NewTeeTrack, is unwrapped by
+// its Unwrap() LocalTrack method. ok is false if t doesn't write to a
+// *webrtc.Track, e.g. RTPTrack.
+func WebRTCTrack(t LocalTrack) (wt *webrtc.Track, ok bool) {
+	for {
+		switch v := t.(type) {
+		case *webrtc.Track:
+			return v, true
+		case interface{ Unwrap() LocalTrack }:
+			t = v.Unwrap()
+		default:
+			return nil, false
+		}
+	}
+}
+
+var (
+	errTrackNotStopped = errors.New("track: the track must be stopped before restarting")
+	errTrackEnded      = errors.New("track: the track has ended")
+)
+
+// flushTimeout is the maximum time for Stop to wait for the frames buffered in
+// the video encoder being written.
+const flushTimeout = time.Second
+
+// ErrEnded can be returned by the readers of VideoTransform and AudioTransform to
+// end the track deliberately, e.g. when an analyzer decides to finish the capture.
+// The track is ended in the same way as the failures, but the OnEnded handler is
+// called with nil. The device is kept open until Stop is called.
+var ErrEnded = errors.New("track: ended by the source")
+
 type track struct {
 	t LocalTrack
-	s *sampler
+	// w is t with the retries on the temporary write errors and the encryption
+	w LocalTrack
+	s Sampler
 
+	// deviceLabel is the label of the source device used in the logs
+	deviceLabel    string
+	logger         Logger
 	onErrorHandler atomic.Value // func(error)
+	state          atomic.Value // MediaStreamTrackState
+	disabled       int32        // accessed atomically
+
+	mu      sync.Mutex
+	stopped chan struct{}
 }
 
-func newTrack(codecs []*webrtc.RTPCodec, trackGenerator TrackGenerator, d driver.Driver, codecName string) (*track, error) {
+func newTrack(opts *MediaDevicesOptions, kind webrtc.RTPCodecType, d driver.Driver, constraints MediaTrackConstraints) (*track, error) {
+	codecName := constraints.CodecName
 	var selectedCodec *webrtc.RTPCodec
-	for _, c := range codecs {
+	for _, c := range opts.codecs[kind] {
 		if c.Name == codecName {
 			selectedCodec = c
 			break
@@ -48,7 +151,7 @@ func newTrack(codecs []*webrtc.RTPCodec, trackGenerator TrackGenerator, d driver
 		return nil, fmt.Errorf("track: %s is not registered in media engine", codecName)
 	}
 
-	t, err := trackGenerator(
+	t, err := opts.trackGenerator(
 		selectedCodec.PayloadType,
 		rand.Uint32(),
 		d.ID(),
@@ -59,10 +162,22 @@ func newTrack(codecs []*webrtc.RTPCodec, trackGenerator TrackGenerator, d driver
 		return nil, err
 	}
 
-	return &track{
-		t: t,
-		s: newSampler(t),
-	}, nil
+	// Frames are encrypted only once even if the write is retried
+	w := newEncryptTrack(
+		newRetryTrack(t, constraints.IsTemporaryWriteError, constraints.WriteRetryCount),
+		constraints.FrameEncryptor,
+	)
+	tr := &track{
+		t:       t,
+		w:       w,
+		s:       opts.samplerGenerator(w),
+		stopped: make(chan struct{}),
+
+		deviceLabel: d.Info().Label,
+		logger:      opts.logger,
+	}
+	tr.state.Store(TrackStateLive)
+	return tr, nil
 }
 
 func (t *track) OnEnded(handler func(error)) {
@@ -76,156 +191,861 @@ func (t *track) onError(err error) {
 	}
 }
 
+// stop marks the track as stopped and calls release only once.
+func (t *track) stop(release func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if isClosed(t.stopped) {
+		return
+	}
+	close(t.stopped)
+	t.state.Store(TrackStateEnded)
+	release()
+	t.logger.Debugf("%s track of device %q stopped", t.Kind(), t.deviceLabel)
+}
+
+// end marks the track as ended by err and calls the OnEnded handler
+// unless the track has been stopped.
+func (t *track) end(stopped chan struct{}, err error) {
+	t.mu.Lock()
+	if isClosed(stopped) {
+		t.mu.Unlock()
+		return
+	}
+	t.state.Store(TrackStateEnded)
+	label := t.deviceLabel
+	t.mu.Unlock()
+
+	if errors.Is(err, ErrEnded) {
+		t.logger.Infof("%s track of device %q ended by the source", t.Kind(), label)
+		t.onError(nil)
+		return
+	}
+	t.logger.Warnf("%s track of device %q ended: %v", t.Kind(), label, err)
+	t.onError(err)
+}
+
+// restart calls open with a new stop channel if the track has been stopped.
+func (t *track) restart(open func(stopped chan struct{}) error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !isClosed(t.stopped) {
+		return errTrackNotStopped
+	}
+
+	stopped := make(chan struct{})
+	if err := open(stopped); err != nil {
+		return fmt.Errorf("track: failed to restart, the track must be acquired again by GetUserMedia: %w", err)
+	}
+	t.stopped = stopped
+	t.state.Store(TrackStateLive)
+	return nil
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
 func (t *track) Track() *webrtc.Track {
-	return t.t.(*webrtc.Track)
+	wt, _ := WebRTCTrack(t.t)
+	return wt
 }
 
 func (t *track) LocalTrack() LocalTrack {
 	return t.t
 }
 
+func (t *track) HeaderExtensions() []RTPHeaderExtension {
+	if ht, ok := t.t.(HeaderExtensionTrack); ok {
+		return ht.HeaderExtensions()
+	}
+	return nil
+}
+
+func (t *track) Codec() *webrtc.RTPCodec {
+	return t.t.Codec()
+}
+
+func (t *track) Kind() string {
+	return t.t.Kind().String()
+}
+
+func (t *track) ReadyState() MediaStreamTrackState {
+	return t.state.Load().(MediaStreamTrackState)
+}
+
+func (t *track) SetEnabled(enabled bool) {
+	var disabled int32
+	if !enabled {
+		disabled = 1
+	}
+	if atomic.SwapInt32(&t.disabled, disabled) != disabled {
+		t.logger.Infof("%s track enabled: %v", t.Kind(), enabled)
+	}
+}
+
+func (t *track) Enabled() bool {
+	return atomic.LoadInt32(&t.disabled) == 0
+}
+
+func (t *track) isDisabled() bool {
+	return atomic.LoadInt32(&t.disabled) != 0
+}
+
+// VideoTracker is a Tracker of video. Video tracks returned by MediaStream
+// implement it.
+type VideoTracker interface {
+	Tracker
+	// SetResolution changes the resolution of the encoded video on the fly without
+	// rebuilding the encoder. The frames are scaled to the given size and a keyframe
+	// is inserted at the switch. It fails if the encoder doesn't implement
+	// codec.ResolutionController.
+	SetResolution(width, height int) error
+	// SetBitRate changes the target bitrate of the encoder in bps on the fly.
+	// The bitrate is kept when the track is restarted. It fails if the encoder
+	// doesn't implement codec.BitRateController.
+	SetBitRate(bitRate int) error
+	// OnBitrateEstimate feeds the available bandwidth in bps estimated by the
+	// receiver or the sender, e.g. the bitrate of REMB packets read from
+	// RTPSender, so that the encoder bitrate follows the network condition.
+	// The bitrate is capped by BitRate of the constraints if it's set.
+	OnBitrateEstimate(estimate int) error
+	// FrameFormatInfo returns the frame formats negotiated for the track.
+	FrameFormatInfo() FrameFormatInfo
+	// ParameterSets returns the current parameter sets of the encoder, e.g. SPS
+	// and PPS of H.264 to signal them out-of-band. It fails if the encoder
+	// doesn't implement codec.ParameterSetReader.
+	ParameterSets() ([][]byte, error)
+	// SwitchDevice switches the source of the live track to the video device of
+	// deviceID without changing the LocalTrack, e.g. to flip the front and back
+	// cameras without renegotiation. The settings closest to the current ones are
+	// selected for the new device, the encoder is rebuilt and a keyframe is forced
+	// at the switch. The current device is kept if the new one can't be opened.
+	SwitchDevice(deviceID string) error
+	// ActualFrameRate returns the rate of the frames written to the track in
+	// the last second. It can be lower than the requested frame rate if the
+	// device or the encoder can't keep up, or the frames are dropped.
+	ActualFrameRate() float64
+	// Stats returns the statistics of the track to diagnose the pipeline, e.g.
+	// the frames queued in the frame buffer if the encoder can't keep up.
+	Stats() VideoStats
+}
+
+// FrameFormatInfo describes the frame formats negotiated for a video track.
+type FrameFormatInfo struct {
+	// SourceFormat is the frame format of the device.
+	SourceFormat frame.Format
+	// EncoderFormat is the frame format passed to the encoder. It's empty if
+	// the encoder doesn't register its input formats.
+	EncoderFormat frame.Format
+	// Converted is true if the frames are converted from SourceFormat to
+	// EncoderFormat before being encoded.
+	Converted bool
+}
+
+// negotiateFrameFormat returns the path of the frames from the source format
+// to the input format of the encoder named codecName.
+func negotiateFrameFormat(codecName string, source frame.Format) FrameFormatInfo {
+	info := FrameFormatInfo{SourceFormat: source}
+	formats := codec.VideoInputFormats(codecName)
+	if len(formats) == 0 {
+		return info
+	}
+	for _, f := range formats {
+		if f == source {
+			info.EncoderFormat = f
+			return info
+		}
+	}
+	// The encoders convert the frames to the most preferred format
+	info.EncoderFormat = formats[0]
+	info.Converted = true
+	return info
+}
+
+var (
+	errResolutionChangeUnsupported = errors.New("track: the encoder doesn't support changing the resolution")
+	errBitRateChangeUnsupported    = errors.New("track: the encoder doesn't support changing the bitrate")
+	errParameterSetsUnsupported    = errors.New("track: the encoder doesn't report the parameter sets")
+)
+
 type videoTrack struct {
 	*track
+	// d and constraints are replaced by SwitchDevice under the mutex of the track
 	d           driver.Driver
 	constraints MediaTrackConstraints
-	encoder     io.ReadCloser
+	// sampleMu is held while sampling the encoded frame so that SwitchDevice can
+	// stop the previous source without interleaving the frames of the devices.
+	sampleMu sync.Mutex
+
+	// resolutionController is the current encoder, nil if the encoder doesn't
+	// support changing the resolution. It's protected by the mutex of the track.
+	resolutionController codec.ResolutionController
+	resolution           atomic.Value // image.Point
+	// bitRateController is the current encoder, nil if the encoder doesn't
+	// support changing the bitrate. bitRate is the bitrate set by SetBitRate,
+	// 0 if not set. They are protected by the mutex of the track.
+	bitRateController codec.BitRateController
+	bitRate           int
+	// keyFrameController is the current encoder, nil if the encoder doesn't
+	// support forcing keyframes.
+	keyFrameController codec.KeyFrameController
+	// parameterSetReader is the current encoder, nil if the encoder doesn't
+	// report the parameter sets. It's protected by the mutex of the track.
+	parameterSetReader codec.ParameterSetReader
+	// reconfigurationReporter is the current encoder, nil if the encoder
+	// doesn't report whether the reconfigurations need keyframes. It's
+	// protected by the mutex of the track.
+	reconfigurationReporter codec.ReconfigurationReporter
+
+	// frameBuffer is the frame buffer of the current source, nil if
+	// FrameBufferSize is 0. It's protected by the mutex of the track.
+	frameBuffer video.BufferedReader
+	// droppedFrames is the number of the frames dropped by the frame buffers
+	// replaced by SwitchDevice or Restart. It's protected by the mutex of the
+	// track.
+	droppedFrames uint64
+	// done is closed when the encoder of the current source is drained and
+	// closed. It's protected by the mutex of the track.
+	done chan struct{}
+
+	frameFormatInfo FrameFormatInfo
+	frameRate       frameRateMeter
+	encodeTime      encodeTimer
 }
 
-var _ Tracker = &videoTrack{}
+var _ VideoTracker = &videoTrack{}
 
 func newVideoTrack(opts *MediaDevicesOptions, d driver.Driver, constraints MediaTrackConstraints) (*videoTrack, error) {
-	codecName := constraints.CodecName
-	t, err := newTrack(opts.codecs[webrtc.RTPCodecTypeVideo], opts.trackGenerator, d, codecName)
+	t, err := newTrack(opts, webrtc.RTPCodecTypeVideo, d, constraints)
 	if err != nil {
 		return nil, err
 	}
 
-	err = d.Open()
-	if err != nil {
+	vt := videoTrack{
+		track:       t,
+		d:           d,
+		constraints: constraints,
+
+		frameFormatInfo: negotiateFrameFormat(constraints.CodecName, constraints.FrameFormat),
+	}
+	if info := vt.frameFormatInfo; info.Converted {
+		t.logger.Infof("frames of device %q are converted from %s to %s", t.deviceLabel, info.SourceFormat, info.EncoderFormat)
+	}
+
+	if err := vt.open(t.stopped); err != nil {
 		return nil, err
 	}
+	return &vt, nil
+}
+
+// open opens the driver, builds the encoder and starts the track.
+func (vt *videoTrack) open(stopped chan struct{}) error {
+	d, constraints := vt.d, vt.constraints
+
+	err := openDriverWithRetry(vt.logger, d, constraints)
+	if err != nil {
+		vt.logger.Errorf("failed to open device %q: %v", d.Info().Label, err)
+		return err
+	}
+	vt.logger.Infof("opened device %q", d.Info().Label)
 
 	vr := d.(driver.VideoRecorder)
 	r, err := vr.VideoRecord(constraints.Media)
 	if err != nil {
-		return nil, err
+		d.Close()
+		return err
+	}
+	if o, ok := vt.t.(orientationSetter); ok {
+		o.SetOrientation(constraints.Orientation)
 	}
 
 	if constraints.VideoTransform != nil {
 		r = constraints.VideoTransform(r)
 	}
 
-	encoder, err := codec.BuildVideoEncoder(r, constraints.Media)
+	if _, ok := r.(video.EncodedReader); !ok {
+		r = video.Blank(vt.isDisabled)(r)
+	}
+
+	if constraints.OnFrame != nil {
+		r = video.Observe(constraints.OnFrame)(r)
+	}
+
+	if constraints.OnThumbnail != nil {
+		r = video.Thumbnail(constraints.ThumbnailWidth, constraints.ThumbnailHeight, constraints.ThumbnailInterval, constraints.OnThumbnail)(r)
+	}
+
+	var frameBuffer video.BufferedReader
+	if constraints.FrameBufferSize > 0 {
+		r = video.BoundedBuffer(constraints.FrameBufferSize, constraints.MaxBufferBytes, constraints.FrameDropPolicy)(r)
+		frameBuffer, _ = r.(video.BufferedReader)
+	}
+
+	resizer := &resizeReader{r: video.ToRaw(r), resolution: &vt.resolution, timer: &vt.encodeTime}
+	er, encoded := r.(video.EncodedReader)
+	if encoded {
+		r = &encodedResizeReader{resizeReader: resizer, er: er}
+	} else {
+		r = resizer
+	}
+
+	// keyFrameController is set after building the encoder and is used by the
+	// scene change detector which is called in the encoder's Read.
+	var keyFrameController codec.KeyFrameController
+	if constraints.SceneChangeThreshold > 0 {
+		r = video.DetectSceneChange(constraints.SceneChangeThreshold, func() {
+			// Failure is not fatal since keyframes are periodically generated anyway
+			if keyFrameController != nil {
+				keyFrameController.ForceKeyFrame()
+			}
+		})(r)
+	}
+
+	media := constraints.Media
+	if vt.bitRate > 0 {
+		media.BitRate = vt.bitRate
+	}
+	encoder, err := codec.BuildVideoEncoder(r, media)
 	if err != nil {
-		return nil, err
+		vt.logger.Errorf("failed to build %s encoder: %v", constraints.CodecName, err)
+		closeSource(d, frameBuffer)
+		return err
 	}
+	vt.logger.Infof("built %s encoder for %dx%d video", constraints.CodecName, constraints.Width, constraints.Height)
 
-	vt := videoTrack{
-		track:       t,
-		d:           d,
-		constraints: constraints,
-		encoder:     encoder,
+	if constraints.SceneChangeThreshold > 0 {
+		var ok bool
+		keyFrameController, ok = encoder.(codec.KeyFrameController)
+		// The encoders relaying the encoded frames of the source can't force
+		// keyframes, but the detection is skipped for the encoded frames anyway.
+		if !ok && !encoded {
+			encoder.Close()
+			closeSource(d, frameBuffer)
+			return fmt.Errorf("track: %s encoder doesn't support forcing keyframes", constraints.CodecName)
+		}
+	}
+	vt.resolutionController, _ = encoder.(codec.ResolutionController)
+	vt.bitRateController, _ = encoder.(codec.BitRateController)
+	vt.keyFrameController, _ = encoder.(codec.KeyFrameController)
+	vt.parameterSetReader, _ = encoder.(codec.ParameterSetReader)
+	vt.reconfigurationReporter, _ = encoder.(codec.ReconfigurationReporter)
+	if kfc := vt.keyFrameController; kfc != nil && needsKeyFrame(vt.reconfigurationReporter, codec.ReconfigureResolution) {
+		// The keyframe is forced at the first frame of the new resolution
+		resizer.onResize = func() { kfc.ForceKeyFrame() }
+	}
+	if vt.frameBuffer != nil {
+		vt.droppedFrames += vt.frameBuffer.Dropped()
 	}
+	vt.frameBuffer = frameBuffer
 
-	go vt.start()
-	return &vt, nil
+	vt.done = make(chan struct{})
+	go vt.start(encoder, stopped, vt.done)
+	return nil
+}
+
+func (vt *videoTrack) SetResolution(width, height int) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("track: invalid resolution %dx%d", width, height)
+	}
+
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	if vt.resolutionController == nil {
+		return errResolutionChangeUnsupported
+	}
+	if err := vt.resolutionController.SetResolution(width, height); err != nil {
+		return err
+	}
+	vt.resolution.Store(image.Pt(width, height))
+	return nil
+}
+
+func (vt *videoTrack) SetBitRate(bitRate int) error {
+	if bitRate <= 0 {
+		return fmt.Errorf("track: invalid bitrate %d", bitRate)
+	}
+
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	if vt.bitRateController == nil {
+		return errBitRateChangeUnsupported
+	}
+	if err := vt.bitRateController.SetBitRate(bitRate); err != nil {
+		return err
+	}
+	vt.bitRate = bitRate
+	if vt.keyFrameController != nil && needsKeyFrame(vt.reconfigurationReporter, codec.ReconfigureBitRate) {
+		vt.keyFrameController.ForceKeyFrame()
+	}
+	return nil
+}
+
+// needsKeyFrame returns true if the reconfiguration r of the encoder needs a
+// keyframe. reporter is nil if the encoder doesn't report it.
+func needsKeyFrame(reporter codec.ReconfigurationReporter, r codec.Reconfiguration) bool {
+	if reporter == nil {
+		return r == codec.ReconfigureResolution
+	}
+	return reporter.NeedsKeyFrame(r)
+}
+
+func (vt *videoTrack) OnBitrateEstimate(estimate int) error {
+	vt.mu.Lock()
+	max, label := vt.constraints.BitRate, vt.deviceLabel
+	vt.mu.Unlock()
+
+	if max > 0 && estimate > max {
+		estimate = max
+	}
+	vt.logger.Debugf("bitrate of video track of device %q is set to %d bps by the estimate", label, estimate)
+	return vt.SetBitRate(estimate)
+}
+
+func (vt *videoTrack) ParameterSets() ([][]byte, error) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	if vt.parameterSetReader == nil {
+		return nil, errParameterSetsUnsupported
+	}
+	return vt.parameterSetReader.ParameterSets(), nil
 }
 
-func (vt *videoTrack) start() {
-	var n int
-	var err error
+func (vt *videoTrack) Settings() prop.Media {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	return vt.constraints.Media
+}
+
+func (vt *videoTrack) ActualFrameRate() float64 {
+	return vt.frameRate.rate()
+}
+
+func (vt *videoTrack) Stats() VideoStats {
+	vt.mu.Lock()
+	frameBuffer := vt.frameBuffer
+	droppedFrames := vt.droppedFrames
+	vt.mu.Unlock()
+
+	stats := VideoStats{
+		FrameRate:     vt.frameRate.rate(),
+		EncodeTime:    vt.encodeTime.value(),
+		BitRate:       vt.frameRate.bitRate(),
+		DroppedFrames: droppedFrames,
+	}
+	if frameBuffer != nil {
+		stats.QueuedFrames = frameBuffer.Buffered()
+		stats.QueuedBytes = frameBuffer.BufferedBytes()
+		stats.DroppedFrames += frameBuffer.Dropped()
+	}
+	return stats
+}
+
+func (vt *videoTrack) FrameFormatInfo() FrameFormatInfo {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	return vt.frameFormatInfo
+}
+
+func (vt *videoTrack) SwitchDevice(deviceID string) error {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+
+	if vt.ReadyState() == TrackStateEnded {
+		return errTrackEnded
+	}
+	if vt.d.ID() == deviceID {
+		return nil
+	}
+
+	// The current settings are used as the constraints to keep the video similar
+	filter := driver.FilterAnd(driver.FilterVideoRecorder(), driver.FilterID(deviceID))
+	d, constraints, err := selectBestDriver(vt.logger, filter, vt.constraints)
+	if err != nil {
+		return err
+	}
+
+	prevD, prevConstraints, prevStopped, prevFrameBuffer := vt.d, vt.constraints, vt.stopped, vt.frameBuffer
+	vt.d, vt.constraints = d, constraints
+
+	// Samples of the new source are held until the previous source is stopped
+	vt.sampleMu.Lock()
+	defer vt.sampleMu.Unlock()
+
+	stopped := make(chan struct{})
+	if err := vt.open(stopped); err != nil {
+		vt.d, vt.constraints = prevD, prevConstraints
+		return fmt.Errorf("track: failed to switch to device %q: %w", d.Info().Label, err)
+	}
+	close(prevStopped)
+	closeSource(prevD, prevFrameBuffer)
+
+	vt.stopped = stopped
+	vt.deviceLabel = d.Info().Label
+	vt.frameFormatInfo = negotiateFrameFormat(constraints.CodecName, constraints.FrameFormat)
+	if vt.keyFrameController != nil {
+		vt.keyFrameController.ForceKeyFrame()
+	}
+	vt.logger.Infof("video track is switched from device %q to %q", prevD.Info().Label, vt.deviceLabel)
+	return nil
+}
+
+// resizeReader scales the frames to the resolution set by SetResolution.
+// The frames are passed through until the resolution is set. It implements
+// video.RawReader to keep the raw frames if not scaled. The frames passed to
+// the encoder are recorded to timer.
+type resizeReader struct {
+	r          video.RawReader
+	resolution *atomic.Value // image.Point
+	timer      *encodeTimer
+	// onResize is called when the first frame of the new resolution is read,
+	// nil if not needed.
+	onResize func()
+
+	size   image.Point
+	scaled video.RawReader
+}
+
+func (r *resizeReader) reader() video.RawReader {
+	size, _ := r.resolution.Load().(image.Point)
+	if size == (image.Point{}) {
+		return r.r
+	}
+	if size != r.size {
+		r.size = size
+		r.scaled = video.ToRaw(video.Scale(size.X, size.Y, nil)(r.r))
+		if r.onResize != nil {
+			r.onResize()
+		}
+	}
+	return r.scaled
+}
+
+func (r *resizeReader) Read() (image.Image, error) {
+	img, err := r.reader().Read()
+	if err == nil {
+		r.timer.pass()
+	}
+	return img, err
+}
+
+func (r *resizeReader) ReadRaw() (video.RawFrame, error) {
+	f, err := r.reader().ReadRaw()
+	if err == nil {
+		r.timer.pass()
+	}
+	return f, err
+}
+
+// encodedResizeReader is a resizeReader which keeps the encoded frames of the
+// source for the encoders relaying them. The encoded frames are not scaled.
+type encodedResizeReader struct {
+	*resizeReader
+	er video.EncodedReader
+}
+
+func (r *encodedResizeReader) ReadEncoded() ([]byte, error) {
+	b, err := r.er.ReadEncoded()
+	if err == nil {
+		r.timer.pass()
+	}
+	return b, err
+}
+
+func (vt *videoTrack) start(encoder io.ReadCloser, stopped, done chan struct{}) {
+	err := vt.encode(encoder, stopped)
+	// Encoder is closed by this goroutine to avoid closing it during Read.
+	// done is closed before calling the OnEnded handler, which may call Stop
+	// waiting for it.
+	encoder.Close()
+	close(done)
+	if err != nil {
+		vt.track.end(stopped, err)
+	}
+}
+
+// encode writes the frames read from encoder to the track until the source
+// is stopped or an error occurs.
+func (vt *videoTrack) encode(encoder io.ReadCloser, stopped chan struct{}) error {
 	buff := make([]byte, 1024)
 	for {
-		n, err = vt.encoder.Read(buff)
+		n, err := encoder.Read(buff)
+		if isClosed(stopped) {
+			// The track is still live if the source has been switched to another
+			// device, and the trailing frames are dropped to avoid mixing them.
+			if vt.ReadyState() == TrackStateEnded {
+				if err == nil {
+					vt.sample(buff[:n])
+				}
+				vt.flush(encoder, buff)
+			}
+			return nil
+		}
 		if err != nil {
 			if e, ok := err.(*mio.InsufficientBufferError); ok {
 				buff = make([]byte, 2*e.RequiredSize)
 				continue
 			}
+			return err
+		}
 
-			vt.track.onError(err)
-			return
+		vt.sampleMu.Lock()
+		if isClosed(stopped) {
+			// The source has been switched to another device
+			vt.sampleMu.Unlock()
+			return nil
+		}
+		err = vt.s.Sample(buff[:n])
+		vt.sampleMu.Unlock()
+		if err != nil {
+			return err
 		}
+		vt.frameRate.tick(n)
+		vt.encodeTime.encoded()
+	}
+}
+
+func (vt *videoTrack) sample(b []byte) error {
+	vt.sampleMu.Lock()
+	defer vt.sampleMu.Unlock()
+	return vt.s.Sample(b)
+}
 
-		if err := vt.s.sample(buff[:n]); err != nil {
-			vt.track.onError(err)
+// flush writes the frames buffered in the encoder after the track is stopped.
+func (vt *videoTrack) flush(encoder io.ReadCloser, buff []byte) {
+	f, ok := encoder.(codec.Flusher)
+	if !ok {
+		return
+	}
+	if err := f.Flush(); err != nil {
+		vt.logger.Warnf("failed to flush %s encoder: %v", vt.Codec().Name, err)
+		return
+	}
+
+	for {
+		n, err := encoder.Read(buff)
+		if err != nil {
+			if e, ok := err.(*mio.InsufficientBufferError); ok {
+				buff = make([]byte, 2*e.RequiredSize)
+				continue
+			}
+			if err != io.EOF {
+				vt.logger.Warnf("failed to flush %s encoder: %v", vt.Codec().Name, err)
+			}
+			return
+		}
+		if err := vt.sample(buff[:n]); err != nil {
 			return
 		}
 	}
 }
 
 func (vt *videoTrack) Stop() {
-	vt.d.Close()
-	vt.encoder.Close()
+	var done chan struct{}
+	var label string
+	vt.stop(func() {
+		closeSource(vt.d, vt.frameBuffer)
+		done, label = vt.done, vt.deviceLabel
+	})
+	if done == nil {
+		return
+	}
+	// The encoder is drained by the reader goroutine. It's waited after
+	// releasing the mutex of the track not to block the other methods.
+	// Stop called by the reader goroutine itself, e.g. from VideoTransform or
+	// the LocalTrack, can't wait for it and gives up after flushTimeout.
+	timer := time.NewTimer(flushTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		vt.logger.Warnf("video track of device %q stopped before the encoder is drained", label)
+	}
+}
+
+// closeSource closes d and the frame buffer reading it, nil if not used. The
+// frame buffer has to be closed since it may be blocked by DropPolicyBlock
+// after the encoder stops reading it.
+func closeSource(d driver.Driver, frameBuffer video.BufferedReader) {
+	d.Close()
+	if frameBuffer != nil {
+		frameBuffer.Close()
+	}
+}
+
+func (vt *videoTrack) Restart() error {
+	return vt.restart(vt.open)
+}
+
+// AudioTracker is a Tracker of audio. Audio tracks returned by MediaStream
+// implement it.
+type AudioTracker interface {
+	Tracker
+	// Stats returns the statistics of the track, e.g. the samples concealed
+	// for the gaps of the device to correlate the quality issues.
+	Stats() AudioStats
 }
 
 type audioTrack struct {
 	*track
 	d           driver.Driver
 	constraints MediaTrackConstraints
-	encoder     io.ReadCloser
+
+	concealedSamples  uint64 // atomic
+	concealmentEvents uint64 // atomic
 }
 
-var _ Tracker = &audioTrack{}
+var _ AudioTracker = &audioTrack{}
 
 func newAudioTrack(opts *MediaDevicesOptions, d driver.Driver, constraints MediaTrackConstraints) (*audioTrack, error) {
-	codecName := constraints.CodecName
-	t, err := newTrack(opts.codecs[webrtc.RTPCodecTypeAudio], opts.trackGenerator, d, codecName)
+	t, err := newTrack(opts, webrtc.RTPCodecTypeAudio, d, constraints)
 	if err != nil {
 		return nil, err
 	}
 
-	err = d.Open()
-	if err != nil {
+	at := audioTrack{
+		track:       t,
+		d:           d,
+		constraints: constraints,
+	}
+
+	if err := at.open(t.stopped); err != nil {
 		return nil, err
 	}
+	return &at, nil
+}
+
+// open opens the driver, builds the encoder and starts the track.
+func (t *audioTrack) open(stopped chan struct{}) error {
+	d, constraints := t.d, t.constraints
+
+	err := openDriverWithRetry(t.logger, d, constraints)
+	if err != nil {
+		t.logger.Errorf("failed to open device %q: %v", d.Info().Label, err)
+		return err
+	}
+	t.logger.Infof("opened device %q", d.Info().Label)
 
 	ar := d.(driver.AudioRecorder)
 	reader, err := ar.AudioRecord(constraints.Media)
 	if err != nil {
-		return nil, err
+		d.Close()
+		return err
+	}
+
+	// The drift of the device clock is corrected if the driver provides the
+	// timestamps of the samples, so that the audio is kept in sync with the video.
+	reader = audio.DriftCorrection(constraints.SampleRate)(reader)
+
+	media := constraints.Media
+	if constraints.ChannelMap != nil {
+		reader = audio.MapChannels(constraints.ChannelMap)(reader)
+		media.ChannelCount = len(constraints.ChannelMap)
+	}
+
+	if constraints.AudioConcealDelay > 0 {
+		reader = audio.Conceal(constraints.SampleRate, constraints.AudioConcealDelay, func(n int) {
+			atomic.AddUint64(&t.concealedSamples, uint64(n))
+			atomic.AddUint64(&t.concealmentEvents, 1)
+			t.logger.Debugf("concealed %d samples of device %q", n, d.Info().Label)
+		})(reader)
 	}
 
 	if constraints.AudioTransform != nil {
 		reader = constraints.AudioTransform(reader)
 	}
 
-	encoder, err := codec.BuildAudioEncoder(reader, constraints.Media)
-	if err != nil {
-		return nil, err
+	reader = audio.Mute(t.isDisabled)(reader)
+
+	if constraints.OnAudioLevel != nil {
+		window := constraints.SampleRate / 10
+		if window <= 0 {
+			window = 4800
+		}
+		channels := media.ChannelCount
+		if channels != 1 {
+			channels = 2
+		}
+		reader = audio.Level(channels, window, constraints.OnAudioLevel)(reader)
 	}
 
-	at := audioTrack{
-		track:       t,
-		d:           d,
-		constraints: constraints,
-		encoder:     encoder,
+	if size := int(float64(constraints.SampleRate) * constraints.AudioBufferDuration.Seconds()); size > 0 {
+		reader = audio.Buffer(size)(reader)
 	}
-	go at.start()
-	return &at, nil
+
+	encoder, err := codec.BuildAudioEncoder(reader, media)
+	if err != nil {
+		t.logger.Errorf("failed to build %s encoder: %v", constraints.CodecName, err)
+		d.Close()
+		return err
+	}
+	t.logger.Infof("built %s encoder for %d Hz audio", constraints.CodecName, constraints.SampleRate)
+
+	go t.start(encoder, stopped)
+	return nil
 }
 
-func (t *audioTrack) start() {
+func (t *audioTrack) start(encoder io.ReadCloser, stopped chan struct{}) {
+	// Encoder is closed by this goroutine to avoid closing it during Read
+	defer encoder.Close()
+
 	buff := make([]byte, 1024)
 	sampleSize := uint32(float64(t.constraints.SampleRate) * t.constraints.Latency.Seconds())
+	counter, hasCounter := encoder.(codec.FrameSampleCounter)
 	for {
-		n, err := t.encoder.Read(buff)
+		n, err := encoder.Read(buff)
+		if isClosed(stopped) {
+			return
+		}
 		if err != nil {
-			t.track.onError(err)
+			if e, ok := err.(*mio.InsufficientBufferError); ok {
+				buff = make([]byte, 2*e.RequiredSize)
+				continue
+			}
+
+			t.track.end(stopped, err)
 			return
 		}
 
-		if err := t.t.WriteSample(media.Sample{
+		if hasCounter {
+			// Use the actual duration of the frame since it may vary
+			sampleSize = uint32(counter.LastFrameSamples())
+		}
+
+		if err := t.w.WriteSample(media.Sample{
 			Data:    buff[:n],
 			Samples: sampleSize,
 		}); err != nil {
-			t.track.onError(err)
+			t.track.end(stopped, err)
 			return
 		}
 	}
 }
 
+func (t *audioTrack) Stats() AudioStats {
+	return AudioStats{
+		ConcealedSamples:  atomic.LoadUint64(&t.concealedSamples),
+		ConcealmentEvents: atomic.LoadUint64(&t.concealmentEvents),
+	}
+}
+
+func (t *audioTrack) Settings() prop.Media {
+	return t.constraints.Media
+}
+
 func (t *audioTrack) Stop() {
-	t.d.Close()
-	t.encoder.Close()
+	t.stop(func() {
+		t.d.Close()
+	})
+}
+
+func (t *audioTrack) Restart() error {
+	return t.restart(t.open)
 }
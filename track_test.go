@@ -0,0 +1,2329 @@
+package mediadevices
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pion/mediadevices/pkg/codec"
+	"github.com/pion/mediadevices/pkg/codec/passthrough"
+	"github.com/pion/mediadevices/pkg/codec/raw"
+	"github.com/pion/mediadevices/pkg/driver"
+	"github.com/pion/mediadevices/pkg/frame"
+	"github.com/pion/mediadevices/pkg/io/audio"
+	"github.com/pion/mediadevices/pkg/io/video"
+	"github.com/pion/mediadevices/pkg/prop"
+	"github.com/pion/webrtc/v2"
+	"github.com/pion/webrtc/v2/pkg/media"
+)
+
+type videoAdapterMock struct {
+	read func() (image.Image, error)
+	// reader is returned by VideoRecord instead of read if set
+	reader video.Reader
+	props  []prop.Media // Default property is used if nil
+	// openDelay is the duration Open blocks
+	openDelay time.Duration
+	// openErr is called by Open to fail it if it returns an error
+	openErr func() error
+	opened  int32
+	closed  int32
+
+	recordedProp prop.Media
+}
+
+func (a *videoAdapterMock) Open() error {
+	time.Sleep(a.openDelay)
+	if a.openErr != nil {
+		if err := a.openErr(); err != nil {
+			return err
+		}
+	}
+	atomic.AddInt32(&a.opened, 1)
+	return nil
+}
+func (a *videoAdapterMock) Close() error {
+	atomic.AddInt32(&a.closed, 1)
+	return nil
+}
+func (a *videoAdapterMock) Properties() []prop.Media {
+	if a.props != nil {
+		return a.props
+	}
+	return []prop.Media{{Video: prop.Video{Width: 4, Height: 2, FrameFormat: frame.FormatI420}}}
+}
+func (a *videoAdapterMock) VideoRecord(p prop.Media) (video.Reader, error) {
+	a.recordedProp = p
+	if a.reader != nil {
+		return a.reader, nil
+	}
+	return video.ReaderFunc(a.read), nil
+}
+
+type audioAdapterMock struct {
+	read func([][2]float32) (int, error)
+	// channelReader is returned by AudioRecord instead of read if set
+	channelReader audio.ChannelReader
+	err           error
+	closed        int32
+}
+
+func (a *audioAdapterMock) Open() error { return nil }
+func (a *audioAdapterMock) Close() error {
+	atomic.AddInt32(&a.closed, 1)
+	return nil
+}
+func (a *audioAdapterMock) Properties() []prop.Media {
+	return []prop.Media{{Audio: prop.Audio{SampleRate: 48000, Latency: 20 * time.Millisecond}}}
+}
+func (a *audioAdapterMock) AudioRecord(p prop.Media) (audio.Reader, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	if a.channelReader != nil {
+		return a.channelReader, nil
+	}
+	return audio.ReaderFunc(a.read), nil
+}
+
+type localTrackMock struct {
+	codec   *webrtc.RTPCodec
+	id      string
+	samples chan media.Sample
+}
+
+func (t *localTrackMock) WriteSample(s media.Sample) error {
+	// Sample data is only valid during the call
+	s.Data = append([]byte(nil), s.Data...)
+	select {
+	case t.samples <- s:
+	default:
+	}
+	return nil
+}
+func (t *localTrackMock) Codec() *webrtc.RTPCodec   { return t.codec }
+func (t *localTrackMock) ID() string                { return t.id }
+func (t *localTrackMock) Kind() webrtc.RTPCodecType { return t.codec.Type }
+
+// registerMock registers the adapter and returns its device ID.
+func registerMock(t *testing.T, a driver.Adapter, label string) string {
+	// Make the label unique to run the test multiple times
+	label = fmt.Sprintf("%s%d", label, time.Now().UnixNano())
+	deviceType := driver.DeviceType(driver.Camera)
+	if _, ok := a.(driver.AudioRecorder); ok {
+		deviceType = driver.Microphone
+	}
+	if err := RegisterDriverAdapter(a, driver.Info{Label: label, DeviceType: deviceType}); err != nil {
+		t.Fatalf("Failed to register adapter: %v", err)
+	}
+	for _, d := range driver.GetManager().Query(driver.FilterFn(func(d driver.Driver) bool {
+		return d.Info().Label == label
+	})) {
+		return d.ID()
+	}
+	t.Fatal("Registered adapter is not found")
+	return ""
+}
+
+// newMediaDevicesMock returns MediaDevices with the video and audio codecs of
+// codecNames. The tracks are generated by generate, or they are localTrackMock
+// discarding the samples if generate is nil.
+func newMediaDevicesMock(codecNames []string, generate func(codec *webrtc.RTPCodec, id string) LocalTrack, opts ...MediaDevicesOption) MediaDevices {
+	codecs := make(map[webrtc.RTPCodecType][]*webrtc.RTPCodec)
+	for _, name := range codecNames {
+		for _, typ := range []webrtc.RTPCodecType{webrtc.RTPCodecTypeVideo, webrtc.RTPCodecTypeAudio} {
+			codecs[typ] = append(codecs[typ], &webrtc.RTPCodec{Name: name, Type: typ})
+		}
+	}
+	if generate == nil {
+		generate = func(codec *webrtc.RTPCodec, id string) LocalTrack {
+			return &localTrackMock{codec: codec, id: id, samples: make(chan media.Sample)}
+		}
+	}
+	opts = append([]MediaDevicesOption{
+		WithTrackGenerator(func(_ uint8, _ uint32, id, _ string, codec *webrtc.RTPCodec) (LocalTrack, error) {
+			return generate(codec, id), nil
+		}),
+	}, opts...)
+	return NewMediaDevicesFromCodecs(codecs, opts...)
+}
+
+func TestRawVideoTrack(t *testing.T) {
+	img := &image.YCbCr{
+		Y:              []uint8{0, 1, 2, 3, 4, 5, 6, 7},
+		YStride:        4,
+		Cb:             []uint8{8, 9},
+		Cr:             []uint8{10, 11},
+		CStride:        2,
+		SubsampleRatio: image.YCbCrSubsampleRatio420,
+		Rect:           image.Rect(0, 0, 4, 2),
+	}
+	id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(10 * time.Millisecond)
+		return img, nil
+	}}, "TestRawVideoTrack")
+
+	lt := &localTrackMock{samples: make(chan media.Sample, 1)}
+	md := newMediaDevicesMock([]string{raw.Name}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		lt.codec, lt.id = codec, id
+		return lt
+	})
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = raw.Name
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	defer func() {
+		for _, tr := range s.GetTracks() {
+			tr.Stop()
+		}
+	}()
+
+	select {
+	case sample := <-lt.samples:
+		expected := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+		if !bytes.Equal(expected, sample.Data) {
+			t.Errorf("Expected raw frame %v, got %v", expected, sample.Data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout")
+	}
+}
+
+func TestDeviceLost(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	errUnplugged := errors.New("unplugged")
+	var cnt int
+	id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(10 * time.Millisecond)
+		if cnt++; cnt > 5 {
+			return nil, &driver.DeviceLostError{Err: errUnplugged}
+		}
+		return img, nil
+	}}, "TestDeviceLost")
+
+	md := newMediaDevicesMock([]string{raw.Name}, nil)
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = raw.Name
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+
+	ended := make(chan error, 1)
+	for _, tr := range s.GetTracks() {
+		defer tr.Stop()
+		tr.OnEnded(func(err error) {
+			ended <- err
+		})
+	}
+
+	tr := s.GetVideoTracks()[0]
+	if state := tr.ReadyState(); state != TrackStateLive {
+		t.Errorf("Expected %s before the device is lost, got %s", TrackStateLive, state)
+	}
+
+	select {
+	case err := <-ended:
+		if _, ok := err.(*driver.DeviceLostError); !ok {
+			t.Errorf("Expected DeviceLostError, got %v", err)
+		}
+		if !errors.Is(err, errUnplugged) {
+			t.Errorf("Expected DeviceLostError to wrap the driver error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout")
+	}
+	if state := tr.ReadyState(); state != TrackStateEnded {
+		t.Errorf("Expected %s after the device is lost, got %s", TrackStateEnded, state)
+	}
+}
+
+func TestStopConcurrently(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	a := &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		return img, nil
+	}}
+	id := registerMock(t, a, "TestStopConcurrently")
+
+	md := newMediaDevicesMock([]string{raw.Name}, nil)
+	constraints := MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = raw.Name
+		},
+	}
+
+	for i := 0; i < 3; i++ {
+		s, err := md.GetUserMedia(constraints)
+		if err != nil {
+			t.Fatalf("Failed to get user media: %v", err)
+		}
+		tracks := s.GetTracks()
+		for _, tr := range tracks {
+			tr.OnEnded(func(err error) {
+				t.Errorf("OnEnded must not be called by Stop, got %v", err)
+			})
+		}
+
+		// Let the track run for a while
+		time.Sleep(10 * time.Millisecond)
+		closedBefore := atomic.LoadInt32(&a.closed)
+
+		var wg sync.WaitGroup
+		for j := 0; j < 10; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for _, tr := range tracks {
+					tr.Stop()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if closed := atomic.LoadInt32(&a.closed) - closedBefore; closed != 1 {
+			t.Fatalf("Expected driver to be closed once, got %d times", closed)
+		}
+	}
+}
+
+func TestGetUserMediaPartialFailure(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	va := &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		return img, nil
+	}}
+	videoID := registerMock(t, va, "TestGetUserMediaPartialFailureVideo")
+	aa := &audioAdapterMock{err: errors.New("audio failure")}
+	audioID := registerMock(t, aa, "TestGetUserMediaPartialFailureAudio")
+
+	md := newMediaDevicesMock([]string{raw.Name}, nil)
+	vClosedBefore := atomic.LoadInt32(&va.closed)
+	_, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = videoID
+			c.CodecName = raw.Name
+		},
+		Audio: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = audioID
+			c.CodecName = raw.Name
+		},
+	})
+	if err != aa.err {
+		t.Fatalf("Expected error %v, got %v", aa.err, err)
+	}
+
+	// Properties are queried by opening and closing the driver once
+	if closed := atomic.LoadInt32(&va.closed) - vClosedBefore; closed != 2 {
+		t.Errorf("Expected video driver to be closed, closed %d times", closed)
+	}
+	if closed := atomic.LoadInt32(&aa.closed); closed != 2 {
+		t.Errorf("Expected audio driver to be closed, closed %d times", closed)
+	}
+
+	// Both failures are reported
+	video := func(c *MediaTrackConstraints) {
+		c.Enabled = true
+		c.DeviceID = "TestGetUserMediaPartialFailureNotFound"
+		c.CodecName = raw.Name
+	}
+	_, videoErr := md.GetUserMedia(MediaStreamConstraints{Video: video})
+	if videoErr == nil {
+		t.Fatal("Expected error on the device not found")
+	}
+	_, err = md.GetUserMedia(MediaStreamConstraints{
+		Video: video,
+		Audio: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = audioID
+			c.CodecName = raw.Name
+		},
+	})
+	if expected := fmt.Sprintf("video: %v; audio: %v", videoErr, aa.err); err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, got %v", expected, err)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	a := &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		return img, nil
+	}}
+	id := registerMock(t, a, "TestRestart")
+
+	var generated int
+	lt := &localTrackMock{samples: make(chan media.Sample, 1)}
+	md := newMediaDevicesMock([]string{raw.Name}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		generated++
+		lt.codec, lt.id = codec, id
+		return lt
+	})
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = raw.Name
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	tr := s.GetVideoTracks()[0]
+	defer tr.Stop()
+
+	waitSample := func() {
+		select {
+		case <-lt.samples:
+		case <-time.After(time.Second):
+			t.Fatal("Timeout")
+		}
+	}
+	waitSample()
+
+	if err := tr.Restart(); err != errTrackNotStopped {
+		t.Errorf("Expected %v on restarting running track, got %v", errTrackNotStopped, err)
+	}
+
+	for i := 0; i < 3; i++ {
+		tr.Stop()
+		closed := atomic.LoadInt32(&a.closed)
+		if state := tr.ReadyState(); state != TrackStateEnded {
+			t.Errorf("Expected %s after Stop, got %s", TrackStateEnded, state)
+		}
+
+		// Drain the samples written before stopping
+		time.Sleep(10 * time.Millisecond)
+		select {
+		case <-lt.samples:
+		default:
+		}
+		select {
+		case <-lt.samples:
+			t.Fatal("Sample must not be written after Stop")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		if err := tr.Restart(); err != nil {
+			t.Fatalf("Failed to restart: %v", err)
+		}
+		if state := tr.ReadyState(); state != TrackStateLive {
+			t.Errorf("Expected %s after Restart, got %s", TrackStateLive, state)
+		}
+		waitSample()
+
+		if tr.LocalTrack() != lt {
+			t.Error("LocalTrack must be reused")
+		}
+		if closed != atomic.LoadInt32(&a.closed) {
+			t.Error("Driver must not be closed by Restart")
+		}
+	}
+	if generated != 1 {
+		t.Errorf("Expected track to be generated once, generated %d times", generated)
+	}
+}
+
+// keyFrameEncoderMock outputs 1 for keyframes and 0 for the other frames.
+type keyFrameEncoderMock struct {
+	r             video.Reader
+	forceKeyFrame int32
+}
+
+func (e *keyFrameEncoderMock) Read(p []byte) (int, error) {
+	if _, err := e.r.Read(); err != nil {
+		return 0, err
+	}
+	p[0] = byte(atomic.SwapInt32(&e.forceKeyFrame, 0))
+	return 1, nil
+}
+func (e *keyFrameEncoderMock) ForceKeyFrame() error {
+	atomic.StoreInt32(&e.forceKeyFrame, 1)
+	return nil
+}
+func (e *keyFrameEncoderMock) Close() error { return nil }
+
+func TestSceneChangeKeyFrame(t *testing.T) {
+	const codecName = "TestSceneChangeKeyFrame"
+	codec.Register(codecName, codec.VideoEncoderBuilder(func(r video.Reader, p prop.Media) (io.ReadCloser, error) {
+		return &keyFrameEncoderMock{r: r}, nil
+	}))
+
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	var cnt int
+	id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		// Switch to the different scene at the frame 10
+		if cnt++; cnt > 10 {
+			for i := range img.Y {
+				img.Y[i] = 255
+			}
+		}
+		return img, nil
+	}}, "TestSceneChangeKeyFrame")
+
+	lt := &localTrackMock{samples: make(chan media.Sample, 20)}
+	md := newMediaDevicesMock([]string{codecName}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		lt.codec, lt.id = codec, id
+		return lt
+	})
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = codecName
+			c.SceneChangeThreshold = 0.3
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	defer func() {
+		for _, tr := range s.GetTracks() {
+			tr.Stop()
+		}
+	}()
+
+	var keyFrames []int
+	for i := 0; i < 20; i++ {
+		select {
+		case sample := <-lt.samples:
+			if sample.Data[0] == 1 {
+				keyFrames = append(keyFrames, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timeout")
+		}
+	}
+	if len(keyFrames) != 1 || keyFrames[0] != 10 {
+		t.Errorf("Expected keyframe at the frame 10, got %v", keyFrames)
+	}
+}
+
+// timestampEncoderMock outputs the presentation time of each frame calculated
+// from the frame timestamp in microseconds.
+type timestampEncoderMock struct {
+	r     video.RawReader
+	timer *codec.FrameTimer
+}
+
+func (e *timestampEncoderMock) Read(p []byte) (int, error) {
+	f, err := e.r.ReadRaw()
+	if err != nil {
+		return 0, err
+	}
+	pts, _ := e.timer.Next(f.Timestamp)
+	binary.BigEndian.PutUint64(p, uint64(pts/time.Microsecond))
+	return 8, nil
+}
+func (e *timestampEncoderMock) ForceKeyFrame() error { return nil }
+func (e *timestampEncoderMock) Close() error         { return nil }
+
+func TestCaptureTimestamp(t *testing.T) {
+	const codecName = "TestCaptureTimestamp"
+	codec.Register(codecName, codec.VideoEncoderBuilder(func(r video.Reader, p prop.Media) (io.ReadCloser, error) {
+		return &timestampEncoderMock{r: video.ToRaw(r), timer: codec.NewFrameTimer(p.FrameRate)}, nil
+	}))
+
+	// The frames are captured at the alternating intervals
+	const nFrames = 20
+	b := make([]byte, 4*2*3/2)
+	start := time.Now()
+	captured := make([]time.Time, nFrames)
+	for i := range captured {
+		captured[i] = start.Add(time.Duration(i/2*40+i%2*10) * time.Millisecond)
+	}
+	var cnt int
+	id := registerMock(t, &videoAdapterMock{reader: video.RawReaderFunc(func() (video.RawFrame, error) {
+		time.Sleep(time.Millisecond)
+		f, err := video.NewI420Frame(b, 4, 2)
+		f.Timestamp = captured[cnt%nFrames]
+		cnt++
+		return f, err
+	})}, "TestCaptureTimestamp")
+
+	lt := &localTrackMock{samples: make(chan media.Sample, nFrames)}
+	md := newMediaDevicesMock([]string{codecName}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		lt.codec, lt.id = codec, id
+		return lt
+	})
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = codecName
+			// All the transforms on the way to the encoder must keep the timestamps
+			c.OnFrame = func(image.Image) {}
+			c.OnThumbnail = func([]byte) {}
+			c.ThumbnailWidth, c.ThumbnailHeight = 2, -1
+			c.ThumbnailInterval = time.Millisecond
+			c.FrameBufferSize = nFrames
+			c.SceneChangeThreshold = 0.3
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	tr := s.GetVideoTracks()[0]
+	defer tr.Stop()
+
+	var first time.Duration
+	for i := 0; i < nFrames; i++ {
+		select {
+		case sample := <-lt.samples:
+			pts := time.Duration(binary.BigEndian.Uint64(sample.Data)) * time.Microsecond
+			if i == 0 {
+				first = pts
+			}
+			if expected := captured[i].Sub(captured[0]); pts-first != expected {
+				t.Errorf("Expected the frame %d at %v after the first frame, got %v", i, expected, pts-first)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timeout")
+		}
+	}
+}
+
+func TestOnFrameStop(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		return img, nil
+	}}, "TestOnFrameStop")
+
+	md := newMediaDevicesMock([]string{raw.Name}, nil)
+	frames := make(chan struct{}, 1)
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = raw.Name
+			c.OnFrame = func(image.Image) {
+				select {
+				case frames <- struct{}{}:
+				default:
+				}
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	select {
+	case <-frames:
+	case <-time.After(time.Second):
+		t.Fatal("Timeout")
+	}
+	s.GetVideoTracks()[0].Stop()
+
+	// The goroutine calling OnFrame must not be left after Stop
+	timeout := time.After(time.Second)
+	for runtime.NumGoroutine() > before {
+		select {
+		case <-timeout:
+			t.Fatalf("Expected the goroutines of the track to exit, got %d goroutines left", runtime.NumGoroutine()-before)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+// variableFrameEncoderMock outputs frames of the given durations in turn.
+type variableFrameEncoderMock struct {
+	r          audio.Reader
+	durations  []int
+	i          int
+	lastFrameN int
+}
+
+func (e *variableFrameEncoderMock) Read(p []byte) (int, error) {
+	e.lastFrameN = e.durations[e.i%len(e.durations)]
+	e.i++
+	buff := make([][2]float32, e.lastFrameN)
+	for curN := 0; curN < len(buff); {
+		n, err := e.r.Read(buff[curN:])
+		if err != nil {
+			return 0, err
+		}
+		curN += n
+	}
+	p[0] = byte(e.i)
+	return 1, nil
+}
+func (e *variableFrameEncoderMock) LastFrameSamples() int { return e.lastFrameN }
+func (e *variableFrameEncoderMock) Close() error          { return nil }
+
+func TestAudioTrackVariableFrameSamples(t *testing.T) {
+	const codecName = "TestAudioTrackVariableFrameSamples"
+	durations := []int{480, 960, 1920}
+	codec.Register(codecName, codec.AudioEncoderBuilder(func(r audio.Reader, p prop.Media) (io.ReadCloser, error) {
+		return &variableFrameEncoderMock{r: r, durations: durations}, nil
+	}))
+
+	id := registerMock(t, &audioAdapterMock{read: func(samples [][2]float32) (int, error) {
+		time.Sleep(time.Millisecond)
+		return len(samples), nil
+	}}, "TestAudioTrackVariableFrameSamples")
+
+	lt := &localTrackMock{samples: make(chan media.Sample, 10)}
+	md := newMediaDevicesMock([]string{codecName}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		lt.codec, lt.id = codec, id
+		return lt
+	})
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Audio: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = codecName
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	defer func() {
+		for _, tr := range s.GetTracks() {
+			tr.Stop()
+		}
+	}()
+
+	for i := 0; i < 6; i++ {
+		select {
+		case sample := <-lt.samples:
+			if expected := uint32(durations[i%len(durations)]); sample.Samples != expected {
+				t.Errorf("Expected sample %d to have %d samples, got %d", i, expected, sample.Samples)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timeout")
+		}
+	}
+}
+
+func TestAudioConcealStats(t *testing.T) {
+	const codecName = "TestAudioConcealStats"
+	codec.Register(codecName, codec.AudioEncoderBuilder(func(r audio.Reader, p prop.Media) (io.ReadCloser, error) {
+		return &variableFrameEncoderMock{r: r, durations: []int{480}}, nil
+	}))
+
+	var reads int32
+	id := registerMock(t, &audioAdapterMock{read: func(samples [][2]float32) (int, error) {
+		if atomic.AddInt32(&reads, 1) == 5 {
+			// Stall of the device
+			time.Sleep(100 * time.Millisecond)
+		} else {
+			time.Sleep(time.Millisecond)
+		}
+		return len(samples), nil
+	}}, "TestAudioConcealStats")
+
+	lt := &localTrackMock{samples: make(chan media.Sample, 10)}
+	md := newMediaDevicesMock([]string{codecName}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		lt.codec, lt.id = codec, id
+		return lt
+	})
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Audio: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = codecName
+			c.AudioConcealDelay = 20 * time.Millisecond
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	tr := s.GetAudioTracks()[0]
+	defer tr.Stop()
+
+	timeout := time.After(time.Second)
+	for {
+		stats := tr.(AudioTracker).Stats()
+		if stats.ConcealmentEvents > 0 {
+			if stats.ConcealedSamples == 0 {
+				t.Errorf("Expected concealed samples, got %+v", stats)
+			}
+			return
+		}
+		select {
+		case <-lt.samples:
+		case <-timeout:
+			t.Fatalf("Timeout, stats: %+v", stats)
+		}
+	}
+}
+
+func TestSetEnabled(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	for i := range img.Y {
+		img.Y[i] = 0xFF
+	}
+	for i := range img.Cb {
+		img.Cb[i], img.Cr[i] = 128, 128
+	}
+	videoID := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		return img, nil
+	}}, "TestSetEnabledVideo")
+	audioID := registerMock(t, &audioAdapterMock{read: func(samples [][2]float32) (int, error) {
+		time.Sleep(time.Millisecond)
+		for i := range samples {
+			samples[i] = [2]float32{0.5, 0.5}
+		}
+		return len(samples), nil
+	}}, "TestSetEnabledAudio")
+
+	samples := map[webrtc.RTPCodecType]chan media.Sample{
+		webrtc.RTPCodecTypeVideo: make(chan media.Sample, 10),
+		webrtc.RTPCodecTypeAudio: make(chan media.Sample, 10),
+	}
+	md := newMediaDevicesMock([]string{raw.Name}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		return &localTrackMock{codec: codec, id: id, samples: samples[codec.Type]}
+	})
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = videoID
+			c.CodecName = raw.Name
+		},
+		Audio: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = audioID
+			c.CodecName = raw.Name
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	defer func() {
+		for _, tr := range s.GetTracks() {
+			tr.Stop()
+		}
+	}()
+
+	white := append(bytes.Repeat([]byte{0xFF}, 8), 128, 128, 128, 128)
+	black := append(bytes.Repeat([]byte{0}, 8), 128, 128, 128, 128)
+	sound := make([]byte, 8)
+	binary.LittleEndian.PutUint32(sound, math.Float32bits(0.5))
+	binary.LittleEndian.PutUint32(sound[4:], math.Float32bits(0.5))
+	silence := make([]byte, 8)
+
+	// waitFor waits for the sample of kind starting with expected
+	waitFor := func(kind webrtc.RTPCodecType, expected []byte) {
+		t.Helper()
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case sample := <-samples[kind]:
+				if bytes.HasPrefix(sample.Data, expected) {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("Timeout waiting for %s sample %v", kind, expected)
+			}
+		}
+	}
+
+	tracks := map[webrtc.RTPCodecType]Tracker{
+		webrtc.RTPCodecTypeVideo: s.GetVideoTracks()[0],
+		webrtc.RTPCodecTypeAudio: s.GetAudioTracks()[0],
+	}
+	for _, tr := range tracks {
+		if !tr.Enabled() {
+			t.Error("Expected the track to be enabled by default")
+		}
+	}
+	waitFor(webrtc.RTPCodecTypeVideo, white)
+	waitFor(webrtc.RTPCodecTypeAudio, sound)
+
+	for _, tr := range tracks {
+		tr.SetEnabled(false)
+	}
+	// The samples keep flowing with black frames and silence
+	waitFor(webrtc.RTPCodecTypeVideo, black)
+	waitFor(webrtc.RTPCodecTypeAudio, silence)
+	for kind, tr := range tracks {
+		if tr.Enabled() {
+			t.Errorf("Expected %s track to be disabled", kind)
+		}
+		if tr.ReadyState() != TrackStateLive {
+			t.Errorf("Expected %s track to be live while disabled", kind)
+		}
+	}
+
+	for _, tr := range tracks {
+		tr.SetEnabled(true)
+	}
+	waitFor(webrtc.RTPCodecTypeVideo, white)
+	waitFor(webrtc.RTPCodecTypeAudio, sound)
+}
+
+func TestTrackerCodec(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	videoID := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		return img, nil
+	}}, "TestTrackerCodecVideo")
+	audioID := registerMock(t, &audioAdapterMock{read: func(samples [][2]float32) (int, error) {
+		time.Sleep(time.Millisecond)
+		return len(samples), nil
+	}}, "TestTrackerCodecAudio")
+
+	videoCodec := &webrtc.RTPCodec{Name: raw.Name, Type: webrtc.RTPCodecTypeVideo, PayloadType: 100}
+	audioCodec := &webrtc.RTPCodec{Name: raw.Name, Type: webrtc.RTPCodecTypeAudio, PayloadType: 101}
+	md := NewMediaDevicesFromCodecs(
+		map[webrtc.RTPCodecType][]*webrtc.RTPCodec{
+			webrtc.RTPCodecTypeVideo: {
+				{Name: "other", Type: webrtc.RTPCodecTypeVideo, PayloadType: 99},
+				videoCodec,
+			},
+			webrtc.RTPCodecTypeAudio: {audioCodec},
+		},
+		WithTrackGenerator(func(_ uint8, _ uint32, id, _ string, codec *webrtc.RTPCodec) (LocalTrack, error) {
+			return &localTrackMock{codec: codec, id: id, samples: make(chan media.Sample)}, nil
+		}),
+	)
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = videoID
+			c.CodecName = raw.Name
+		},
+		Audio: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = audioID
+			c.CodecName = raw.Name
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	defer func() {
+		for _, tr := range s.GetTracks() {
+			tr.Stop()
+		}
+	}()
+
+	if c := s.GetVideoTracks()[0].Codec(); c != videoCodec {
+		t.Errorf("Expected video codec %v, got %v", videoCodec, c)
+	}
+	if c := s.GetAudioTracks()[0].Codec(); c != audioCodec {
+		t.Errorf("Expected audio codec %v, got %v", audioCodec, c)
+	}
+	if kind := s.GetVideoTracks()[0].Kind(); kind != "video" {
+		t.Errorf("Expected video track kind, got %s", kind)
+	}
+	if kind := s.GetAudioTracks()[0].Kind(); kind != "audio" {
+		t.Errorf("Expected audio track kind, got %s", kind)
+	}
+}
+
+func TestTemporaryWriteError(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		return img, nil
+	}}, "TestTemporaryWriteError")
+
+	samples := make(chan media.Sample, 1)
+	md := newMediaDevicesMock([]string{raw.Name}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		return &errorTrackMock{
+			LocalTrack: &localTrackMock{codec: codec, id: id, samples: samples},
+			errs:       []error{&temporaryError{}},
+		}
+	})
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = raw.Name
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	tr := s.GetVideoTracks()[0]
+	defer tr.Stop()
+	tr.OnEnded(func(err error) {
+		t.Errorf("Track must not be ended by temporary error, got %v", err)
+	})
+
+	// The first sample is dropped and the capture continues
+	for i := 0; i < 3; i++ {
+		select {
+		case <-samples:
+		case <-time.After(time.Second):
+			t.Fatal("Timeout")
+		}
+	}
+	if state := tr.ReadyState(); state != TrackStateLive {
+		t.Errorf("Expected %s, got %s", TrackStateLive, state)
+	}
+}
+
+// resolutionEncoderMock outputs the frame size and 1 for keyframes, which are
+// encoded at the resolution changes.
+type resolutionEncoderMock struct {
+	r    video.Reader
+	size image.Point
+}
+
+func (e *resolutionEncoderMock) Read(p []byte) (int, error) {
+	img, err := e.r.Read()
+	if err != nil {
+		return 0, err
+	}
+	size := img.Bounds().Size()
+	p[0], p[1], p[2] = byte(size.X), byte(size.Y), 0
+	if size != e.size {
+		e.size = size
+		p[2] = 1
+	}
+	return 3, nil
+}
+func (e *resolutionEncoderMock) SetResolution(width, height int) error { return nil }
+func (e *resolutionEncoderMock) Close() error                          { return nil }
+
+func TestSetResolution(t *testing.T) {
+	const codecName = "TestSetResolution"
+	codec.Register(codecName, codec.VideoEncoderBuilder(func(r video.Reader, p prop.Media) (io.ReadCloser, error) {
+		return &resolutionEncoderMock{r: r}, nil
+	}))
+
+	img := image.NewYCbCr(image.Rect(0, 0, 8, 4), image.YCbCrSubsampleRatio420)
+	id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		return img, nil
+	}}, "TestSetResolution")
+
+	lt := &localTrackMock{samples: make(chan media.Sample)}
+	md := newMediaDevicesMock([]string{codecName, raw.Name}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		lt.codec, lt.id = codec, id
+		return lt
+	})
+
+	t.Run("Supported", func(t *testing.T) {
+		s, err := md.GetUserMedia(MediaStreamConstraints{
+			Video: func(c *MediaTrackConstraints) {
+				c.Enabled = true
+				c.DeviceID = id
+				c.CodecName = codecName
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to get user media: %v", err)
+		}
+		tr := s.GetVideoTracks()[0].(VideoTracker)
+		defer tr.Stop()
+
+		waitSize := func(width, height int) {
+			for {
+				select {
+				case sample := <-lt.samples:
+					if int(sample.Data[0]) == width && int(sample.Data[1]) == height {
+						if sample.Data[2] != 1 {
+							t.Errorf("Expected keyframe at the switch to %dx%d", width, height)
+						}
+						return
+					}
+				case <-time.After(time.Second):
+					t.Fatalf("Timeout waiting for %dx%d", width, height)
+				}
+			}
+		}
+		waitSize(8, 4)
+
+		if err := tr.SetResolution(4, 2); err != nil {
+			t.Fatalf("Failed to set resolution: %v", err)
+		}
+		waitSize(4, 2)
+
+		if err := tr.SetResolution(16, 8); err != nil {
+			t.Fatalf("Failed to set resolution: %v", err)
+		}
+		waitSize(16, 8)
+
+		if err := tr.SetResolution(0, 8); err == nil {
+			t.Error("Expected error on invalid resolution")
+		}
+	})
+	t.Run("Unsupported", func(t *testing.T) {
+		s, err := md.GetUserMedia(MediaStreamConstraints{
+			Video: func(c *MediaTrackConstraints) {
+				c.Enabled = true
+				c.DeviceID = id
+				c.CodecName = raw.Name
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to get user media: %v", err)
+		}
+		tr := s.GetVideoTracks()[0].(VideoTracker)
+		defer tr.Stop()
+
+		if err := tr.SetResolution(4, 2); err != errResolutionChangeUnsupported {
+			t.Errorf("Expected error %v, got %v", errResolutionChangeUnsupported, err)
+		}
+	})
+}
+
+// reconfigureEncoderMock outputs the frame size and 1 for the forced keyframes.
+// It doesn't encode keyframes by itself at the resolution changes.
+type reconfigureEncoderMock struct {
+	r             video.Reader
+	forceKeyFrame int32
+}
+
+func (e *reconfigureEncoderMock) Read(p []byte) (int, error) {
+	img, err := e.r.Read()
+	if err != nil {
+		return 0, err
+	}
+	size := img.Bounds().Size()
+	p[0], p[1], p[2] = byte(size.X), byte(size.Y), byte(atomic.SwapInt32(&e.forceKeyFrame, 0))
+	return 3, nil
+}
+func (e *reconfigureEncoderMock) ForceKeyFrame() error {
+	atomic.StoreInt32(&e.forceKeyFrame, 1)
+	return nil
+}
+func (e *reconfigureEncoderMock) SetResolution(width, height int) error { return nil }
+func (e *reconfigureEncoderMock) SetBitRate(bitRate int) error          { return nil }
+func (e *reconfigureEncoderMock) Close() error                          { return nil }
+
+// scalingEncoderMock is a reconfigureEncoderMock which reports that the
+// resolution is changed without a keyframe, e.g. by scaling the references.
+type scalingEncoderMock struct {
+	reconfigureEncoderMock
+}
+
+func (e *scalingEncoderMock) NeedsKeyFrame(r codec.Reconfiguration) bool { return false }
+
+func TestReconfigurationKeyFrame(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 8, 4), image.YCbCrSubsampleRatio420)
+	id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		return img, nil
+	}}, "TestReconfigurationKeyFrame")
+
+	cases := map[string]struct {
+		encoder        func(r video.Reader) io.ReadCloser
+		resizeKeyFrame bool
+	}{
+		"Default": {
+			encoder:        func(r video.Reader) io.ReadCloser { return &reconfigureEncoderMock{r: r} },
+			resizeKeyFrame: true,
+		},
+		"Reported": {
+			encoder: func(r video.Reader) io.ReadCloser {
+				return &scalingEncoderMock{reconfigureEncoderMock{r: r}}
+			},
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			codecName := "TestReconfigurationKeyFrame" + name
+			codec.Register(codecName, codec.VideoEncoderBuilder(func(r video.Reader, p prop.Media) (io.ReadCloser, error) {
+				return c.encoder(r), nil
+			}))
+
+			lt := &localTrackMock{samples: make(chan media.Sample)}
+			md := newMediaDevicesMock([]string{codecName}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+				lt.codec, lt.id = codec, id
+				return lt
+			})
+			s, err := md.GetUserMedia(MediaStreamConstraints{
+				Video: func(c *MediaTrackConstraints) {
+					c.Enabled = true
+					c.DeviceID = id
+					c.CodecName = codecName
+				},
+			})
+			if err != nil {
+				t.Fatalf("Failed to get user media: %v", err)
+			}
+			tr := s.GetVideoTracks()[0].(VideoTracker)
+			defer tr.Stop()
+
+			// keyFrames returns the indices of the keyframes in the next n
+			// frames of the given width.
+			keyFrames := func(n, width int) []int {
+				var indices []int
+				for i := 0; i < n; {
+					select {
+					case sample := <-lt.samples:
+						if int(sample.Data[0]) != width {
+							continue
+						}
+						if sample.Data[2] == 1 {
+							indices = append(indices, i)
+						}
+						i++
+					case <-time.After(time.Second):
+						t.Fatalf("Timeout waiting for the frames of width %d", width)
+					}
+				}
+				return indices
+			}
+			keyFrames(5, 8)
+
+			if err := tr.SetBitRate(500000); err != nil {
+				t.Fatalf("Failed to set bitrate: %v", err)
+			}
+			if indices := keyFrames(10, 8); len(indices) != 0 {
+				t.Errorf("Expected no keyframe at the bitrate change, got %v", indices)
+			}
+
+			if err := tr.SetResolution(4, 2); err != nil {
+				t.Fatalf("Failed to set resolution: %v", err)
+			}
+			indices := keyFrames(10, 4)
+			if c.resizeKeyFrame {
+				if len(indices) != 1 || indices[0] != 0 {
+					t.Errorf("Expected keyframe at the first frame of the new resolution, got %v", indices)
+				}
+			} else if len(indices) != 0 {
+				t.Errorf("Expected no keyframe at the resolution change, got %v", indices)
+			}
+		})
+	}
+}
+
+func TestAudioChannelMap(t *testing.T) {
+	// Sample values are 10*frame+channel
+	var frame int
+	id := registerMock(t, &audioAdapterMock{channelReader: audio.NewChannelReader(4, func(samples []float32) (int, error) {
+		time.Sleep(time.Millisecond)
+		n := len(samples) / 4
+		for i := 0; i < n; i++ {
+			for ch := 0; ch < 4; ch++ {
+				samples[i*4+ch] = float32(10*frame + ch)
+			}
+			frame++
+		}
+		return n, nil
+	})}, "TestAudioChannelMap")
+
+	lt := &localTrackMock{samples: make(chan media.Sample, 1)}
+	md := newMediaDevicesMock([]string{raw.Name}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		lt.codec, lt.id = codec, id
+		return lt
+	})
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Audio: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = raw.Name
+			c.ChannelMap = []int{3, 1}
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	defer s.GetAudioTracks()[0].Stop()
+
+	select {
+	case sample := <-lt.samples:
+		for i := 0; i+8 <= len(sample.Data); i += 8 {
+			left := math.Float32frombits(binary.LittleEndian.Uint32(sample.Data[i:]))
+			right := math.Float32frombits(binary.LittleEndian.Uint32(sample.Data[i+4:]))
+			if int(left)%10 != 3 || int(right)%10 != 1 || int(left)/10 != int(right)/10 {
+				t.Fatalf("Expected channel 3 and 1 of the same frame, got %v and %v", left, right)
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout")
+	}
+
+	if _, err := md.GetUserMedia(MediaStreamConstraints{
+		Audio: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = raw.Name
+			c.ChannelMap = []int{0, 1, 2}
+		},
+	}); err == nil {
+		t.Error("Expected error on channel map with 3 channels")
+	}
+}
+
+func TestPassthroughCodec(t *testing.T) {
+	const codecName = "TestPassthroughCodec"
+	codec.Register(codecName, codec.VideoEncoderBuilder(passthrough.NewVideoEncoder))
+
+	// H.264 access units in Annex-B format
+	frames := [][]byte{
+		{0x00, 0x00, 0x00, 0x01, 0x67, 0x42, 0x00, 0x1F, 0x00, 0x00, 0x00, 0x01, 0x68, 0xCE, 0x3C, 0x80,
+			0x00, 0x00, 0x00, 0x01, 0x65, 0x88, 0x84, 0x00, 0x33, 0xFF},
+		{0x00, 0x00, 0x00, 0x01, 0x41, 0x9A, 0x02, 0x04},
+		{0x00, 0x00, 0x00, 0x01, 0x41, 0x9A, 0x04, 0x08},
+	}
+	// The scene change detector must keep the encoded frames for the passthrough codec
+	for name, threshold := range map[string]float64{
+		"Default":     0,
+		"SceneChange": 0.3,
+	} {
+		threshold := threshold
+		t.Run(name, func(t *testing.T) {
+			var i int
+			id := registerMock(t, &videoAdapterMock{reader: video.NewEncodedReader(func() ([]byte, error) {
+				time.Sleep(time.Millisecond)
+				frame := frames[i%len(frames)]
+				i++
+				return frame, nil
+			}, nil)}, "TestPassthroughCodec"+name)
+
+			lt := &localTrackMock{samples: make(chan media.Sample, len(frames))}
+			md := newMediaDevicesMock([]string{codecName}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+				lt.codec, lt.id = codec, id
+				return lt
+			})
+			s, err := md.GetUserMedia(MediaStreamConstraints{
+				Video: func(c *MediaTrackConstraints) {
+					c.Enabled = true
+					c.DeviceID = id
+					c.CodecName = codecName
+					c.SceneChangeThreshold = threshold
+				},
+			})
+			if err != nil {
+				t.Fatalf("Failed to get user media: %v", err)
+			}
+			tr := s.GetVideoTracks()[0]
+			defer tr.Stop()
+
+			// Each sample must be exactly one access unit
+			for _, expected := range frames {
+				select {
+				case sample := <-lt.samples:
+					if !bytes.Equal(expected, sample.Data) {
+						t.Errorf("Expected sample %v, got %v", expected, sample.Data)
+					}
+				case <-time.After(time.Second):
+					t.Fatal("Timeout")
+				}
+			}
+
+			if err := tr.(VideoTracker).SetResolution(2, 2); err != errResolutionChangeUnsupported {
+				t.Errorf("Expected error %v, got %v", errResolutionChangeUnsupported, err)
+			}
+		})
+	}
+}
+
+func TestOpenRetry(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	var failures int32
+	a := &videoAdapterMock{
+		read: func() (image.Image, error) {
+			time.Sleep(time.Millisecond)
+			return img, nil
+		},
+	}
+	id := registerMock(t, a, "TestOpenRetry")
+
+	md := newMediaDevicesMock([]string{raw.Name}, nil)
+	getUserMedia := func(retries int) (MediaStream, error) {
+		// The device is busy for the first two attempts
+		atomic.StoreInt32(&failures, 2)
+		a.openErr = func() error {
+			if atomic.AddInt32(&failures, -1) >= 0 {
+				return &driver.DeviceBusyError{Err: errors.New("busy")}
+			}
+			return nil
+		}
+		return md.GetUserMedia(MediaStreamConstraints{
+			Video: func(c *MediaTrackConstraints) {
+				c.Enabled = true
+				c.DeviceID = id
+				c.CodecName = raw.Name
+				c.OpenRetryCount = retries
+				c.OpenRetryInterval = 10 * time.Millisecond
+			},
+		})
+	}
+
+	if _, err := getUserMedia(1); err == nil {
+		t.Fatal("Expected error with insufficient retries")
+	}
+
+	start := time.Now()
+	s, err := getUserMedia(2)
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	s.GetVideoTracks()[0].Stop()
+
+	// The intervals are 10ms and 20ms
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("Expected to wait for the backoff, took %v", elapsed)
+	}
+}
+
+func TestOpenTimeout(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	a := &videoAdapterMock{
+		read: func() (image.Image, error) {
+			time.Sleep(time.Millisecond)
+			return img, nil
+		},
+		openDelay: 100 * time.Millisecond,
+	}
+	id := registerMock(t, a, "TestOpenTimeout")
+
+	md := newMediaDevicesMock([]string{raw.Name}, nil)
+
+	start := time.Now()
+	_, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = raw.Name
+			c.OpenTimeout = 10 * time.Millisecond
+		},
+	})
+	if _, ok := err.(*driver.DeviceOpenTimeoutError); !ok {
+		t.Fatalf("Expected DeviceOpenTimeoutError, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= a.openDelay {
+		t.Errorf("Expected to fail before Open returns, took %v", elapsed)
+	}
+
+	// The device opened after the timeout must be closed
+	time.Sleep(2 * a.openDelay)
+	if opened, closed := atomic.LoadInt32(&a.opened), atomic.LoadInt32(&a.closed); opened != closed {
+		t.Errorf("Expected the device to be closed, opened %d times and closed %d times", opened, closed)
+	}
+
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = raw.Name
+			c.OpenTimeout = time.Second
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	s.GetVideoTracks()[0].Stop()
+}
+
+type samplerMock struct {
+	track  LocalTrack
+	frames chan []byte
+}
+
+func (s *samplerMock) Sample(b []byte) error {
+	select {
+	case s.frames <- append([]byte(nil), b...):
+	default:
+	}
+	return s.track.WriteSample(media.Sample{Data: b, Samples: 1})
+}
+
+func TestCustomSampler(t *testing.T) {
+	img := &image.YCbCr{
+		Y:              []uint8{0, 1, 2, 3, 4, 5, 6, 7},
+		YStride:        4,
+		Cb:             []uint8{8, 9},
+		Cr:             []uint8{10, 11},
+		CStride:        2,
+		SubsampleRatio: image.YCbCrSubsampleRatio420,
+		Rect:           image.Rect(0, 0, 4, 2),
+	}
+	id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(10 * time.Millisecond)
+		return img, nil
+	}}, "TestCustomSampler")
+
+	lt := &localTrackMock{samples: make(chan media.Sample, 1)}
+	s := &samplerMock{frames: make(chan []byte, 1)}
+	md := newMediaDevicesMock([]string{raw.Name}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		lt.codec, lt.id = codec, id
+		return lt
+	}, WithSamplerGenerator(func(track LocalTrack) Sampler {
+		s.track = track
+		return s
+	}))
+	stream, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = raw.Name
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	defer stream.GetVideoTracks()[0].Stop()
+
+	expected := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+	select {
+	case b := <-s.frames:
+		if !bytes.Equal(expected, b) {
+			t.Errorf("Expected encoded frame %v, got %v", expected, b)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout")
+	}
+	select {
+	case sample := <-lt.samples:
+		if sample.Samples != 1 {
+			t.Errorf("Expected the sample written by the custom sampler, got %d samples", sample.Samples)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout")
+	}
+}
+
+// bitRateEncoderMock outputs a frame every read and records the bitrates.
+type bitRateEncoderMock struct {
+	r        video.Reader
+	bitRates chan int
+}
+
+func (e *bitRateEncoderMock) Read(p []byte) (int, error) {
+	if _, err := e.r.Read(); err != nil {
+		return 0, err
+	}
+	p[0] = 0
+	return 1, nil
+}
+func (e *bitRateEncoderMock) SetBitRate(bitRate int) error {
+	e.bitRates <- bitRate
+	return nil
+}
+func (e *bitRateEncoderMock) Close() error { return nil }
+
+func TestBitrateEstimate(t *testing.T) {
+	const codecName = "TestBitrateEstimate"
+	bitRates := make(chan int, 10)
+	initialBitRates := make(chan int, 10)
+	codec.Register(codecName, codec.VideoEncoderBuilder(func(r video.Reader, p prop.Media) (io.ReadCloser, error) {
+		initialBitRates <- p.BitRate
+		return &bitRateEncoderMock{r: r, bitRates: bitRates}, nil
+	}))
+
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		return img, nil
+	}}, "TestBitrateEstimate")
+
+	md := newMediaDevicesMock([]string{codecName}, nil)
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = codecName
+			c.BitRate = 1000000
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	tr := s.GetVideoTracks()[0].(VideoTracker)
+	defer tr.Stop()
+
+	if bitRate := <-initialBitRates; bitRate != 1000000 {
+		t.Errorf("Expected initial bitrate 1000000, got %d", bitRate)
+	}
+
+	for _, c := range []struct{ estimate, expected int }{
+		{500000, 500000},
+		{300000, 300000},
+		{2000000, 1000000},
+		{800000, 800000},
+	} {
+		if err := tr.OnBitrateEstimate(c.estimate); err != nil {
+			t.Fatalf("Failed to apply estimate: %v", err)
+		}
+		if bitRate := <-bitRates; bitRate != c.expected {
+			t.Errorf("Expected bitrate %d for estimate %d, got %d", c.expected, c.estimate, bitRate)
+		}
+	}
+	if err := tr.OnBitrateEstimate(0); err == nil {
+		t.Error("Expected error on zero estimate")
+	}
+
+	// The last bitrate must be kept after restarting
+	tr.Stop()
+	if err := tr.Restart(); err != nil {
+		t.Fatalf("Failed to restart: %v", err)
+	}
+	if bitRate := <-initialBitRates; bitRate != 800000 {
+		t.Errorf("Expected bitrate 800000 after restart, got %d", bitRate)
+	}
+}
+
+// parameterSetEncoderMock outputs a frame every read and reports the parameter sets.
+type parameterSetEncoderMock struct {
+	r video.Reader
+}
+
+func (e *parameterSetEncoderMock) Read(p []byte) (int, error) {
+	if _, err := e.r.Read(); err != nil {
+		return 0, err
+	}
+	p[0] = 0
+	return 1, nil
+}
+func (e *parameterSetEncoderMock) ParameterSets() [][]byte {
+	return [][]byte{{0x67, 0x42}, {0x68, 0xCE}}
+}
+func (e *parameterSetEncoderMock) Close() error { return nil }
+
+func TestParameterSets(t *testing.T) {
+	const codecName = "TestParameterSets"
+	codec.Register(codecName, codec.VideoEncoderBuilder(func(r video.Reader, p prop.Media) (io.ReadCloser, error) {
+		return &parameterSetEncoderMock{r: r}, nil
+	}))
+
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		return img, nil
+	}}, "TestParameterSets")
+
+	md := newMediaDevicesMock([]string{codecName, raw.Name}, nil)
+	getTrack := func(codecName string) VideoTracker {
+		s, err := md.GetUserMedia(MediaStreamConstraints{
+			Video: func(c *MediaTrackConstraints) {
+				c.Enabled = true
+				c.DeviceID = id
+				c.CodecName = codecName
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to get user media: %v", err)
+		}
+		return s.GetVideoTracks()[0].(VideoTracker)
+	}
+
+	tr := getTrack(codecName)
+	sets, err := tr.ParameterSets()
+	tr.Stop()
+	if err != nil {
+		t.Fatalf("Failed to get parameter sets: %v", err)
+	}
+	if expected := [][]byte{{0x67, 0x42}, {0x68, 0xCE}}; !reflect.DeepEqual(expected, sets) {
+		t.Errorf("Expected %v, got %v", expected, sets)
+	}
+
+	tr = getTrack(raw.Name)
+	defer tr.Stop()
+	if _, err := tr.ParameterSets(); err != errParameterSetsUnsupported {
+		t.Errorf("Expected %v, got %v", errParameterSetsUnsupported, err)
+	}
+}
+
+func TestFrameFormatInfo(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	md := newMediaDevicesMock([]string{raw.Name}, nil)
+
+	cases := map[string]struct {
+		source   frame.Format
+		expected FrameFormatInfo
+	}{
+		"SameFormat": {
+			source:   frame.FormatI420,
+			expected: FrameFormatInfo{SourceFormat: frame.FormatI420, EncoderFormat: frame.FormatI420},
+		},
+		"Converted": {
+			source:   frame.FormatYUY2,
+			expected: FrameFormatInfo{SourceFormat: frame.FormatYUY2, EncoderFormat: frame.FormatI420, Converted: true},
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			id := registerMock(t, &videoAdapterMock{
+				read: func() (image.Image, error) {
+					time.Sleep(time.Millisecond)
+					return img, nil
+				},
+				props: []prop.Media{{Video: prop.Video{Width: 4, Height: 2, FrameFormat: c.source}}},
+			}, "TestFrameFormatInfo")
+
+			s, err := md.GetUserMedia(MediaStreamConstraints{
+				Video: func(c *MediaTrackConstraints) {
+					c.Enabled = true
+					c.DeviceID = id
+					c.CodecName = raw.Name
+				},
+			})
+			if err != nil {
+				t.Fatalf("Failed to get user media: %v", err)
+			}
+			tr := s.GetVideoTracks()[0].(VideoTracker)
+			defer tr.Stop()
+
+			if info := tr.FrameFormatInfo(); info != c.expected {
+				t.Errorf("Expected %+v, got %+v", c.expected, info)
+			}
+		})
+	}
+}
+
+func TestFrameFormatAny(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	id := registerMock(t, &videoAdapterMock{
+		read: func() (image.Image, error) {
+			time.Sleep(time.Millisecond)
+			return img, nil
+		},
+		props: []prop.Media{
+			{Video: prop.Video{Width: 4, Height: 2, FrameFormat: frame.FormatMJPEG}},
+			{Video: prop.Video{Width: 4, Height: 2, FrameFormat: frame.FormatI420}},
+		},
+	}, "TestFrameFormatAny")
+
+	md := newMediaDevicesMock([]string{raw.Name}, nil)
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = raw.Name
+			c.FrameFormat = frame.FormatAny
+			// Any native format satisfies the exact frame format
+			c.Exact = prop.ExactFrameFormat
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	tr := s.GetVideoTracks()[0].(VideoTracker)
+	defer tr.Stop()
+
+	if f := tr.Settings().FrameFormat; f != frame.FormatI420 {
+		t.Errorf("Expected the native format %s to be reported, got %s", frame.FormatI420, f)
+	}
+	expected := FrameFormatInfo{SourceFormat: frame.FormatI420, EncoderFormat: frame.FormatI420}
+	if info := tr.FrameFormatInfo(); info != expected {
+		t.Errorf("Expected no conversion %+v, got %+v", expected, info)
+	}
+}
+
+// xorEncryptor prepends the key to the frame and XORs the frame with it.
+type xorEncryptor struct {
+	key       byte
+	encrypted int32
+}
+
+func (e *xorEncryptor) Encrypt(frame []byte) ([]byte, error) {
+	atomic.AddInt32(&e.encrypted, 1)
+	encrypted := make([]byte, len(frame)+1)
+	encrypted[0] = e.key
+	for i, b := range frame {
+		encrypted[i+1] = b ^ e.key
+	}
+	return encrypted, nil
+}
+
+func xorDecrypt(frame []byte) []byte {
+	decrypted := make([]byte, len(frame)-1)
+	for i, b := range frame[1:] {
+		decrypted[i] = b ^ frame[0]
+	}
+	return decrypted
+}
+
+func TestFrameEncryptor(t *testing.T) {
+	img := &image.YCbCr{
+		Y:              []uint8{0, 1, 2, 3, 4, 5, 6, 7},
+		YStride:        4,
+		Cb:             []uint8{8, 9},
+		Cr:             []uint8{10, 11},
+		CStride:        2,
+		SubsampleRatio: image.YCbCrSubsampleRatio420,
+		Rect:           image.Rect(0, 0, 4, 2),
+	}
+	videoID := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		return img, nil
+	}}, "TestFrameEncryptor")
+	audioID := registerMock(t, &audioAdapterMock{read: func(samples [][2]float32) (int, error) {
+		time.Sleep(time.Millisecond)
+		for i := range samples {
+			samples[i] = [2]float32{0.5, -0.5}
+		}
+		return len(samples), nil
+	}}, "TestFrameEncryptor")
+
+	tracks := make(map[webrtc.RTPCodecType]*BufferTrack)
+	md := newMediaDevicesMock([]string{raw.Name}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		tracks[codec.Type] = NewBufferTrack(codec, id)
+		return tracks[codec.Type]
+	})
+	videoEncryptor := &xorEncryptor{key: 0x5A}
+	audioEncryptor := &xorEncryptor{key: 0xA5}
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = videoID
+			c.CodecName = raw.Name
+			c.FrameEncryptor = videoEncryptor
+		},
+		Audio: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = audioID
+			c.CodecName = raw.Name
+			c.FrameEncryptor = audioEncryptor
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	for _, tr := range s.GetTracks() {
+		tr.Stop()
+	}
+	// Wait for the samples being written
+	time.Sleep(20 * time.Millisecond)
+
+	expectedVideo := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+	expectedAudio := make([]byte, 8)
+	binary.LittleEndian.PutUint32(expectedAudio, math.Float32bits(0.5))
+	binary.LittleEndian.PutUint32(expectedAudio[4:], math.Float32bits(-0.5))
+
+	for kind, c := range map[webrtc.RTPCodecType]struct {
+		encryptor *xorEncryptor
+		frame     []byte
+	}{
+		webrtc.RTPCodecTypeVideo: {videoEncryptor, expectedVideo},
+		webrtc.RTPCodecTypeAudio: {audioEncryptor, expectedAudio},
+	} {
+		samples := tracks[kind].Samples()
+		if len(samples) == 0 {
+			t.Fatalf("Expected %s samples to be written", kind)
+		}
+		if n := int(atomic.LoadInt32(&c.encryptor.encrypted)); n != len(samples) {
+			t.Errorf("Expected all %d %s samples to be encrypted, got %d", len(samples), kind, n)
+		}
+		for i, sample := range samples {
+			if sample.Data[0] != c.encryptor.key {
+				t.Fatalf("Expected %s sample %d to be encrypted", kind, i)
+			}
+			decrypted := xorDecrypt(sample.Data)
+			if !bytes.Equal(decrypted[:len(c.frame)], c.frame) {
+				t.Fatalf("Expected decrypted %s sample %d to start with %v, got %v", kind, i, c.frame, decrypted)
+			}
+		}
+	}
+}
+
+func TestTransformEndsTrack(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(10 * time.Millisecond)
+		return img, nil
+	}}, "TestTransformEndsTrack")
+
+	const frames = 5
+	var tr *BufferTrack
+	md := newMediaDevicesMock([]string{raw.Name}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		tr = NewBufferTrack(codec, id)
+		return tr
+	})
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = raw.Name
+			c.VideoTransform = func(r video.Reader) video.Reader {
+				var cnt int
+				return video.ReaderFunc(func() (image.Image, error) {
+					if cnt++; cnt > frames {
+						return nil, ErrEnded
+					}
+					return r.Read()
+				})
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+
+	ended := make(chan error, 1)
+	vt := s.GetVideoTracks()[0]
+	defer vt.Stop()
+	vt.OnEnded(func(err error) {
+		ended <- err
+	})
+
+	select {
+	case err := <-ended:
+		if err != nil {
+			t.Errorf("Expected nil error on the deliberate end, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout")
+	}
+	if state := vt.ReadyState(); state != TrackStateEnded {
+		t.Errorf("Expected %s, got %s", TrackStateEnded, state)
+	}
+	if n := len(tr.Samples()); n != frames {
+		t.Errorf("Expected %d samples, got %d", frames, n)
+	}
+}
+
+func TestSwitchDevice(t *testing.T) {
+	newMock := func(v uint8) *videoAdapterMock {
+		img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+		for i := range img.Y {
+			img.Y[i] = v
+		}
+		return &videoAdapterMock{read: func() (image.Image, error) {
+			time.Sleep(time.Millisecond)
+			return img, nil
+		}}
+	}
+	front, back := newMock(1), newMock(2)
+	frontID := registerMock(t, front, "TestSwitchDeviceFront")
+	backID := registerMock(t, back, "TestSwitchDeviceBack")
+
+	var tr *BufferTrack
+	md := newMediaDevicesMock([]string{raw.Name}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		tr = NewBufferTrack(codec, id)
+		return tr
+	})
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = frontID
+			c.CodecName = raw.Name
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	vt := s.GetVideoTracks()[0].(VideoTracker)
+	defer vt.Stop()
+	vt.OnEnded(func(err error) {
+		t.Errorf("Unexpected end of the track: %v", err)
+	})
+
+	time.Sleep(30 * time.Millisecond)
+	// The devices are also closed after querying the properties
+	frontClosed := atomic.LoadInt32(&front.closed)
+	if err := vt.SwitchDevice(backID); err != nil {
+		t.Fatalf("Failed to switch device: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	if n := atomic.LoadInt32(&front.closed) - frontClosed; n != 1 {
+		t.Errorf("Expected the previous device to be closed once, closed %d times", n)
+	}
+	if state := vt.ReadyState(); state != TrackStateLive {
+		t.Errorf("Expected %s after the switch, got %s", TrackStateLive, state)
+	}
+	if err := vt.SwitchDevice("nonexistent"); err == nil {
+		t.Error("Expected an error on switching to an unknown device")
+	}
+	backClosed := atomic.LoadInt32(&back.closed)
+	vt.Stop()
+	time.Sleep(10 * time.Millisecond)
+	if n := atomic.LoadInt32(&back.closed) - backClosed; n != 1 {
+		t.Errorf("Expected the current device to be closed by Stop once, closed %d times", n)
+	}
+
+	// All frames are written to the same track, and the frames of the devices
+	// must not be interleaved.
+	var fromFront, fromBack int
+	for i, sample := range tr.Samples() {
+		switch sample.Data[0] {
+		case 1:
+			if fromBack > 0 {
+				t.Fatalf("Sample %d of the previous device is written after the switch", i)
+			}
+			fromFront++
+		case 2:
+			fromBack++
+		default:
+			t.Fatalf("Unexpected sample %d: %v", i, sample.Data)
+		}
+	}
+	if fromFront == 0 || fromBack == 0 {
+		t.Errorf("Expected samples from both devices, got %d and %d", fromFront, fromBack)
+	}
+}
+
+// lookaheadEncoderMock outputs the sequence number of the frames read from the
+// source with the delay of the lookahead frames.
+type lookaheadEncoderMock struct {
+	r         video.Reader
+	lookahead int
+	queue     []byte
+	read      int32
+	flushing  bool
+}
+
+func (e *lookaheadEncoderMock) Read(p []byte) (int, error) {
+	for !e.flushing && len(e.queue) <= e.lookahead {
+		if _, err := e.r.Read(); err != nil {
+			return 0, err
+		}
+		e.queue = append(e.queue, byte(atomic.AddInt32(&e.read, 1)))
+	}
+	if len(e.queue) == 0 {
+		return 0, io.EOF
+	}
+	p[0] = e.queue[0]
+	e.queue = e.queue[1:]
+	return 1, nil
+}
+func (e *lookaheadEncoderMock) Flush() error {
+	e.flushing = true
+	return nil
+}
+func (e *lookaheadEncoderMock) Close() error { return nil }
+
+func TestFlushOnStop(t *testing.T) {
+	const codecName = "TestFlushOnStop"
+	var encoder *lookaheadEncoderMock
+	codec.Register(codecName, codec.VideoEncoderBuilder(func(r video.Reader, p prop.Media) (io.ReadCloser, error) {
+		encoder = &lookaheadEncoderMock{r: r, lookahead: 3}
+		return encoder, nil
+	}))
+
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		return img, nil
+	}}, "TestFlushOnStop")
+
+	var tr *BufferTrack
+	md := newMediaDevicesMock([]string{codecName}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		tr = NewBufferTrack(codec, id)
+		return tr
+	})
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = codecName
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	// Stop returns after the buffered frames are written
+	s.GetVideoTracks()[0].Stop()
+
+	samples := tr.Samples()
+	read := int(atomic.LoadInt32(&encoder.read))
+	if read <= encoder.lookahead {
+		t.Fatalf("Expected more than %d frames to be read, got %d", encoder.lookahead, read)
+	}
+	if len(samples) != read {
+		t.Fatalf("Expected all %d frames to be written, got %d", read, len(samples))
+	}
+	for i, sample := range samples {
+		if int(sample.Data[0]) != i+1 {
+			t.Fatalf("Expected frame %d, got %d", i+1, sample.Data[0])
+		}
+	}
+}
+
+func TestStopFromTransform(t *testing.T) {
+	const codecName = "TestStopFromTransform"
+	codec.Register(codecName, codec.VideoEncoderBuilder(func(r video.Reader, p prop.Media) (io.ReadCloser, error) {
+		return &lookaheadEncoderMock{r: r, lookahead: 3}, nil
+	}))
+
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		return img, nil
+	}}, "TestStopFromTransform")
+
+	md := newMediaDevicesMock([]string{codecName}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		return NewBufferTrack(codec, id)
+	})
+	// Stop is called by the reader goroutine, which drains the encoder
+	stopped := make(chan struct{})
+	tracks := make(chan Tracker, 1)
+	var cnt int
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = codecName
+			c.VideoTransform = func(r video.Reader) video.Reader {
+				return video.ReaderFunc(func() (image.Image, error) {
+					if cnt++; cnt == 10 {
+						(<-tracks).Stop()
+						close(stopped)
+					}
+					return r.Read()
+				})
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	tracks <- s.GetVideoTracks()[0]
+
+	select {
+	case <-stopped:
+	case <-time.After(2 * flushTimeout):
+		t.Fatal("Stop called by the reader goroutine is blocked")
+	}
+}
+
+func TestOrientation(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 2, 4), image.YCbCrSubsampleRatio420)
+	id := registerMock(t, &videoAdapterMock{
+		read: func() (image.Image, error) {
+			time.Sleep(time.Millisecond)
+			return img, nil
+		},
+		props: []prop.Media{{Video: prop.Video{Width: 2, Height: 4, FrameFormat: frame.FormatI420, Orientation: 90}}},
+	}, "TestOrientation")
+
+	md := newMediaDevicesMock([]string{raw.Name}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		return NewBufferTrack(codec, id)
+	})
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = raw.Name
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	tr := s.GetVideoTracks()[0]
+	defer tr.Stop()
+
+	settings := tr.Settings()
+	if settings.Orientation != 90 {
+		t.Errorf("Expected orientation of 90 degrees, got %d", settings.Orientation)
+	}
+	if settings.Width != 2 || settings.Height != 4 {
+		t.Errorf("Expected the resolution of the device, got %dx%d", settings.Width, settings.Height)
+	}
+}
+
+func TestActualFrameRate(t *testing.T) {
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		// 50 fps
+		time.Sleep(20 * time.Millisecond)
+		return img, nil
+	}}, "TestActualFrameRate")
+
+	md := newMediaDevicesMock([]string{raw.Name}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		return NewBufferTrack(codec, id)
+	})
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = raw.Name
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	tr := s.GetVideoTracks()[0].(VideoTracker)
+	defer tr.Stop()
+
+	time.Sleep(500 * time.Millisecond)
+	// Sleep may take longer than requested
+	if rate := tr.ActualFrameRate(); rate < 35 || 55 < rate {
+		t.Errorf("Expected frame rate around 50, got %v", rate)
+	}
+
+	tr.Stop()
+	time.Sleep(1100 * time.Millisecond)
+	if rate := tr.ActualFrameRate(); rate != 0 {
+		t.Errorf("Expected frame rate 0 after stopped, got %v", rate)
+	}
+}
+
+// slowEncoderMock takes delay to encode a frame.
+type slowEncoderMock struct {
+	r     video.Reader
+	delay time.Duration
+}
+
+func (e *slowEncoderMock) Read(p []byte) (int, error) {
+	if _, err := e.r.Read(); err != nil {
+		return 0, err
+	}
+	time.Sleep(e.delay)
+	p[0] = 0
+	return 1, nil
+}
+func (e *slowEncoderMock) Close() error { return nil }
+
+func TestVideoStats(t *testing.T) {
+	const codecName = "TestVideoStats"
+	codec.Register(codecName, codec.VideoEncoderBuilder(func(r video.Reader, p prop.Media) (io.ReadCloser, error) {
+		return &slowEncoderMock{r: r, delay: 20 * time.Millisecond}, nil
+	}))
+
+	img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+	id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(2 * time.Millisecond)
+		return img, nil
+	}}, "TestVideoStats")
+
+	md := newMediaDevicesMock([]string{codecName}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		return NewBufferTrack(codec, id)
+	})
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = codecName
+			c.FrameBufferSize = 8
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	tr := s.GetVideoTracks()[0].(VideoTracker)
+	defer tr.Stop()
+
+	// The source is faster than the encoder, so the frames are queued
+	var stats VideoStats
+	for i := 0; i < 50; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if stats = tr.Stats(); stats.QueuedFrames >= 6 && stats.EncodeTime > 0 {
+			break
+		}
+	}
+	if stats.QueuedFrames < 6 {
+		t.Errorf("Expected the queued frames to rise, got %d", stats.QueuedFrames)
+	}
+	if stats.EncodeTime < 15*time.Millisecond || 200*time.Millisecond < stats.EncodeTime {
+		t.Errorf("Expected the encode time around 20ms, got %v", stats.EncodeTime)
+	}
+
+	// Stop closes the frame buffer, which discards the queued frames
+	tr.Stop()
+	if stats = tr.Stats(); stats.QueuedFrames != 0 || stats.QueuedBytes != 0 {
+		t.Errorf("Expected the queued frames to be discarded, got %d frames of %d bytes", stats.QueuedFrames, stats.QueuedBytes)
+	}
+}
+
+func TestMaxBufferBytes(t *testing.T) {
+	const codecName = "TestMaxBufferBytes"
+	codec.Register(codecName, codec.VideoEncoderBuilder(func(r video.Reader, p prop.Media) (io.ReadCloser, error) {
+		return &slowEncoderMock{r: r, delay: 20 * time.Millisecond}, nil
+	}))
+
+	// 16x16 I420 frame is 384 bytes
+	img := image.NewYCbCr(image.Rect(0, 0, 16, 16), image.YCbCrSubsampleRatio420)
+	id := registerMock(t, &videoAdapterMock{read: func() (image.Image, error) {
+		time.Sleep(time.Millisecond)
+		return img, nil
+	}}, "TestMaxBufferBytes")
+
+	md := newMediaDevicesMock([]string{codecName}, func(codec *webrtc.RTPCodec, id string) LocalTrack {
+		return NewBufferTrack(codec, id)
+	})
+	const maxBytes = 4 * 384
+	s, err := md.GetUserMedia(MediaStreamConstraints{
+		Video: func(c *MediaTrackConstraints) {
+			c.Enabled = true
+			c.DeviceID = id
+			c.CodecName = codecName
+			c.FrameBufferSize = 64
+			c.FrameDropPolicy = video.DropPolicyOldest
+			c.MaxBufferBytes = maxBytes
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to get user media: %v", err)
+	}
+	tr := s.GetVideoTracks()[0].(VideoTracker)
+	defer tr.Stop()
+
+	// The source floods the slow encoder, but the frame buffer doesn't grow
+	// beyond the limit.
+	var stats VideoStats
+	for i := 0; i < 20; i++ {
+		time.Sleep(10 * time.Millisecond)
+		stats = tr.Stats()
+		if stats.QueuedBytes > maxBytes || stats.QueuedFrames > 4 {
+			t.Fatalf("Expected the queued frames under %d bytes, got %d frames of %d bytes", maxBytes, stats.QueuedFrames, stats.QueuedBytes)
+		}
+	}
+	if stats.DroppedFrames == 0 {
+		t.Error("Expected the dropped frames to be counted")
+	}
+}
+
+func TestWebRTCTrack(t *testing.T) {
+	codec := webrtc.NewRTPVP8Codec(webrtc.DefaultPayloadTypeVP8, 90000)
+	wt, err := webrtc.NewTrack(webrtc.DefaultPayloadTypeVP8, 1, "video", "stream", codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bt := NewBufferTrack(codec, "video")
+
+	cases := map[string]struct {
+		track LocalTrack
+		want  *webrtc.Track
+	}{
+		"WebRTCTrack": {
+			track: wt,
+			want:  wt,
+		},
+		"WrappedWebRTCTrack": {
+			track: NewTeeTrack(NewPacedTrack(NewLossyTrack(wt, LossConfig{}), 0), bt),
+			want:  wt,
+		},
+		"CustomTrack": {
+			track: bt,
+		},
+		"WrappedCustomTrack": {
+			track: NewTeeTrack(bt, wt),
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			got, ok := WebRTCTrack(c.track)
+			if got != c.want || ok != (c.want != nil) {
+				t.Errorf("Expected %v, got %v (ok: %v)", c.want, got, ok)
+			}
+			if got := (&track{t: c.track}).Track(); got != c.want {
+				t.Errorf("Expected Track() to return %v, got %v", c.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package mediadevices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pion/webrtc/v2"
+)
+
+var errWHIPNoTrack = errors.New("whip: the stream has no track to publish")
+
+// WHIPOptions is the options of PublishWHIP.
+type WHIPOptions struct {
+	// Configuration is used to create the PeerConnection.
+	Configuration webrtc.Configuration
+	// Token is sent as the bearer token if it's not empty.
+	Token string
+	// Client is used to send the HTTP requests. nil means http.DefaultClient.
+	Client *http.Client
+}
+
+// WHIPOption is a type of PublishWHIP functional option.
+type WHIPOption func(*WHIPOptions)
+
+// WithWHIPConfiguration sets the configuration of the PeerConnection,
+// e.g. the ICE servers.
+func WithWHIPConfiguration(c webrtc.Configuration) WHIPOption {
+	return func(o *WHIPOptions) {
+		o.Configuration = c
+	}
+}
+
+// WithWHIPToken sets the bearer token to authenticate to the endpoint.
+func WithWHIPToken(token string) WHIPOption {
+	return func(o *WHIPOptions) {
+		o.Token = token
+	}
+}
+
+// WithWHIPClient sets the HTTP client to send the requests.
+func WithWHIPClient(c *http.Client) WHIPOption {
+	return func(o *WHIPOptions) {
+		o.Client = c
+	}
+}
+
+// WHIPSession is a stream published by PublishWHIP.
+type WHIPSession struct {
+	pc       *webrtc.PeerConnection
+	resource string
+	opts     WHIPOptions
+}
+
+// PublishWHIP publishes the tracks of the stream to the WHIP (WebRTC-HTTP
+// ingestion protocol) endpoint at endpointURL. A new PeerConnection sending
+// the tracks is created, and the offer is sent to the endpoint by HTTP POST.
+// The session is established by the answer in the response.
+//
+// The tracks must be created by the default TrackGenerator, and their codecs
+// must be registered to the PeerConnection, e.g. by getting the stream from
+// MediaDevices created with the PeerConnection of the default codecs.
+func PublishWHIP(ctx context.Context, stream MediaStream, endpointURL string, opts ...WHIPOption) (*WHIPSession, error) {
+	var o WHIPOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	trackers := stream.GetTracks()
+	if len(trackers) == 0 {
+		return nil, errWHIPNoTrack
+	}
+
+	pc, err := webrtc.NewPeerConnection(o.Configuration)
+	if err != nil {
+		return nil, err
+	}
+	s, err := publishWHIP(ctx, pc, trackers, endpointURL, o)
+	if err != nil {
+		pc.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func publishWHIP(ctx context.Context, pc *webrtc.PeerConnection, trackers []Tracker, endpointURL string, o WHIPOptions) (*WHIPSession, error) {
+	for _, tracker := range trackers {
+		t := tracker.Track()
+		if t == nil {
+			return nil, fmt.Errorf("whip: track %s is not a webrtc track", tracker.LocalTrack().ID())
+		}
+		_, err := pc.AddTransceiverFromTrack(t,
+			webrtc.RtpTransceiverInit{
+				Direction: webrtc.RTPTransceiverDirectionSendonly,
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := pc.SetLocalDescription(offer); err != nil {
+		return nil, err
+	}
+	// The local description includes the gathered candidates since trickle ICE
+	// is optional for WHIP endpoints.
+	offer = *pc.LocalDescription()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointURL, strings.NewReader(offer.SDP))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/sdp")
+
+	resp, err := o.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("whip: unexpected response status %s", resp.Status)
+	}
+
+	// The resource URL is used to end the session
+	location, err := resp.Location()
+	if err != nil {
+		return nil, fmt.Errorf("whip: invalid resource location: %w", err)
+	}
+
+	answer := webrtc.SessionDescription{
+		Type: webrtc.SDPTypeAnswer,
+		SDP:  string(body),
+	}
+	if err := pc.SetRemoteDescription(answer); err != nil {
+		return nil, err
+	}
+
+	return &WHIPSession{
+		pc:       pc,
+		resource: location.String(),
+		opts:     o,
+	}, nil
+}
+
+// PeerConnection returns the PeerConnection publishing the stream.
+func (s *WHIPSession) PeerConnection() *webrtc.PeerConnection {
+	return s.pc
+}
+
+// Resource returns the URL of the WHIP resource of the session.
+func (s *WHIPSession) Resource() string {
+	return s.resource
+}
+
+// Close ends the session by HTTP DELETE to the resource and closes the
+// PeerConnection. The tracks are not stopped.
+func (s *WHIPSession) Close() error {
+	req, err := http.NewRequest(http.MethodDelete, s.resource, nil)
+	if err != nil {
+		s.pc.Close()
+		return err
+	}
+
+	resp, err := s.opts.do(req)
+	if err != nil {
+		s.pc.Close()
+		return err
+	}
+	resp.Body.Close()
+	if err := s.pc.Close(); err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || 300 <= resp.StatusCode {
+		return fmt.Errorf("whip: unexpected response status %s", resp.Status)
+	}
+	return nil
+}
+
+func (o *WHIPOptions) do(req *http.Request) (*http.Response, error) {
+	if o.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+o.Token)
+	}
+	c := o.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return c.Do(req)
+}
@@ -0,0 +1,145 @@
+package mediadevices
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pion/webrtc/v2"
+)
+
+func TestPublishWHIP(t *testing.T) {
+	videoTrack, err := webrtc.NewTrack(webrtc.DefaultPayloadTypeVP8, 1, "video", "stream", webrtc.NewRTPVP8Codec(webrtc.DefaultPayloadTypeVP8, 90000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	audioTrack, err := webrtc.NewTrack(webrtc.DefaultPayloadTypeOpus, 2, "audio", "stream", webrtc.NewRTPOpusCodec(webrtc.DefaultPayloadTypeOpus, 48000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewMediaStream(&trackerMock{t: videoTrack}, &trackerMock{t: audioTrack})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var offer, answer string
+	var deleted bool
+	// Mock WHIP endpoint answering by a PeerConnection
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if auth := r.Header.Get("Authorization"); auth != "Bearer token" {
+			t.Errorf("Expected bearer token, got %q", auth)
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/whip":
+			if typ := r.Header.Get("Content-Type"); typ != "application/sdp" {
+				t.Errorf("Expected content type application/sdp, got %s", typ)
+			}
+			b, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Error(err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			offer = string(b)
+
+			pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+			if err != nil {
+				t.Error(err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			defer pc.Close()
+			if err := pc.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: offer}); err != nil {
+				t.Error(err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			desc, err := pc.CreateAnswer(nil)
+			if err != nil {
+				t.Error(err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if err := pc.SetLocalDescription(desc); err != nil {
+				t.Error(err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			answer = desc.SDP
+
+			w.Header().Set("Content-Type", "application/sdp")
+			w.Header().Set("Location", "/whip/resource")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(answer))
+		case r.Method == http.MethodDelete && r.URL.Path == "/whip/resource":
+			deleted = true
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	session, err := PublishWHIP(context.Background(), s, server.URL+"/whip", WithWHIPToken("token"))
+	if err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+
+	mu.Lock()
+	for _, media := range []string{"m=video", "m=audio"} {
+		if !strings.Contains(offer, media) {
+			t.Errorf("Expected the offer to contain %s, got:\n%s", media, offer)
+		}
+	}
+	if remote := session.PeerConnection().RemoteDescription(); remote == nil || remote.SDP != answer {
+		t.Errorf("Expected the answer to be set as the remote description")
+	}
+	mu.Unlock()
+	if expected := server.URL + "/whip/resource"; session.Resource() != expected {
+		t.Errorf("Expected resource %s, got %s", expected, session.Resource())
+	}
+
+	if err := session.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !deleted {
+		t.Error("Expected the resource to be deleted")
+	}
+}
+
+func TestPublishWHIPError(t *testing.T) {
+	videoTrack, err := webrtc.NewTrack(webrtc.DefaultPayloadTypeVP8, 1, "video", "stream", webrtc.NewRTPVP8Codec(webrtc.DefaultPayloadTypeVP8, 90000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewMediaStream(&trackerMock{t: videoTrack})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	if _, err := PublishWHIP(context.Background(), s, server.URL); err == nil {
+		t.Error("Expected error on unauthorized")
+	}
+
+	empty, err := NewMediaStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PublishWHIP(context.Background(), empty, server.URL); err != errWHIPNoTrack {
+		t.Errorf("Expected %v, got %v", errWHIPNoTrack, err)
+	}
+}